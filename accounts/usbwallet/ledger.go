@@ -160,7 +160,11 @@ func (w *ledgerDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	if w.offline() {
 		return common.Address{}, nil, accounts.ErrWalletClosed
 	}
-	// Ensure the wallet is capable of signing the given transaction
+	// Ensure the wallet is capable of signing the given transaction. The app only
+	// knows the legacy RLP encoding, it would sign the wrong payload for typed ones.
+	if tx.Type() != types.LegacyTxType {
+		return common.Address{}, nil, fmt.Errorf("Ledger doesn't support signing transactions of type %d", tx.Type())
+	}
 	if chainID != nil && w.version[0] <= 1 && w.version[1] <= 0 && w.version[2] <= 2 {
 		return common.Address{}, nil, fmt.Errorf("Ledger v%d.%d.%d doesn't support signing this transaction, please update to v1.0.3 at least", w.version[0], w.version[1], w.version[2])
 	}
@@ -156,6 +156,11 @@ func (w *trezorDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	if w.device == nil {
 		return common.Address{}, nil, accounts.ErrWalletClosed
 	}
+	// The firmware only knows the legacy RLP encoding, it would sign the wrong
+	// payload for typed transactions
+	if tx.Type() != types.LegacyTxType {
+		return common.Address{}, nil, fmt.Errorf("Trezor doesn't support signing transactions of type %d", tx.Type())
+	}
 	return w.trezorSign(path, tx, chainID)
 }
 
@@ -70,6 +70,11 @@ var (
 		Usage: "value set for the evm",
 		Value: new(big.Int),
 	}
+	PowerFlag = utils.BigFlag{
+		Name:  "power",
+		Usage: "power set for the sender, settled at the prestate block",
+		Value: new(big.Int),
+	}
 	DumpFlag = cli.BoolFlag{
 		Name:  "dump",
 		Usage: "dumps the state after the run",
@@ -122,6 +127,7 @@ func init() {
 		GasFlag,
 		PriceFlag,
 		ValueFlag,
+		PowerFlag,
 		DumpFlag,
 		InputFlag,
 		MemProfileFlag,
@@ -112,6 +112,15 @@ func runCmd(ctx *cli.Context) error {
 	}
 	statedb.CreateAccount(sender)
 
+	// CreateAccount only carries the balance over, so restore the sender's
+	// prestate power before letting --power override it.
+	if account, ok := genesisConfig.Alloc[sender]; ok && account.Power != nil {
+		statedb.SetPowerAndBlock(sender, account.Power, account.PowerBlock())
+	}
+	if ctx.GlobalIsSet(PowerFlag.Name) {
+		statedb.SetPowerAndBlock(sender, utils.GlobalBig(ctx, PowerFlag.Name), new(big.Int).SetUint64(genesisConfig.Number))
+	}
+
 	if ctx.GlobalString(ReceiverFlag.Name) != "" {
 		receiver = common.HexToAddress(ctx.GlobalString(ReceiverFlag.Name))
 	}
@@ -30,6 +30,7 @@ import (
 	"github.com/ether-ark/etherark/cmd/utils"
 	"github.com/ether-ark/etherark/dashboard"
 	"github.com/ether-ark/etherark/eth"
+	"github.com/ether-ark/etherark/eth/filters"
 	"github.com/ether-ark/etherark/node"
 	"github.com/ether-ark/etherark/params"
 	whisper "github.com/ether-ark/etherark/whisper/whisperv6"
@@ -176,6 +177,14 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		utils.RegisterShhService(stack, &cfg.Shh)
 	}
 
+	// Configure GraphQL if requested
+	if endpoint := cfg.Node.GraphQLEndpoint(); endpoint != "" {
+		logsLimits := filters.Config{
+			BlockLimit:  cfg.Eth.RPCLogsBlockLimit,
+			ResultLimit: cfg.Eth.RPCLogsResultLimit,
+		}
+		utils.RegisterGraphQLService(stack, endpoint, cfg.Node.GraphQLCors, cfg.Node.GraphQLVirtualHosts, cfg.Node.HTTPTimeouts, logsLimits)
+	}
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
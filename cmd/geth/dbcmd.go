@@ -0,0 +1,316 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of go-auc.
+//
+// go-auc is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-auc is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-auc. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ether-ark/etherark/cmd/utils"
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/log"
+	"github.com/ether-ark/etherark/node"
+	"github.com/prometheus/prometheus/util/flock"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbFlags = []cli.Flag{
+		utils.DataDirFlag,
+		utils.AncientFlag,
+		utils.CacheFlag,
+		utils.DBEngineFlag,
+	}
+
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level database operations",
+		ArgsUsage: "",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+The db commands operate directly on the chain database of a stopped node. They
+are meant for inspection and for emergency repairs, modifying the database by
+hand can easily corrupt the chain.`,
+		Subcommands: []cli.Command{
+			{
+				Action:    utils.MigrateFlags(inspectDB),
+				Name:      "inspect",
+				Usage:     "Inspect the storage size for each type of data in the database",
+				ArgsUsage: "",
+				Flags:     dbFlags,
+				Description: `
+The inspect command iterates over the entire database and prints the number of
+entries and their total size, grouped by data type.`,
+			},
+			{
+				Action:    utils.MigrateFlags(compactDB),
+				Name:      "compact",
+				Usage:     "Compact the entire database",
+				ArgsUsage: "",
+				Flags:     dbFlags,
+				Description: `
+The compact command flattens the entire key-value store, discarding deleted and
+overwritten entries. It may take a long time on large databases.`,
+			},
+			{
+				Action:    utils.MigrateFlags(getDBKey),
+				Name:      "get",
+				Usage:     "Show the value of a database key",
+				ArgsUsage: "<hex-encoded key>",
+				Flags:     dbFlags,
+				Description: `
+The get command prints the hex encoded value stored under the given key.`,
+			},
+			{
+				Action:    utils.MigrateFlags(deleteDBKey),
+				Name:      "delete",
+				Usage:     "Delete a database key (WARNING: may corrupt your database)",
+				ArgsUsage: "<hex-encoded key>",
+				Flags:     dbFlags,
+				Description: `
+The delete command removes the given key from the database, printing the value
+it held beforehand so it can be restored if needed.`,
+			},
+			{
+				Action:    utils.MigrateFlags(convertDB),
+				Name:      "convert",
+				Usage:     "Convert the database to another storage engine",
+				ArgsUsage: "<leveldb|bolt>",
+				Flags:     dbFlags,
+				Description: `
+The convert command copies the entire key-value store into a new database of the
+given engine and replaces the original with it. The ancient chain segments are
+kept as they are. The node must be stopped and enough disk space must be free to
+hold a second copy of the database.`,
+			},
+		},
+	}
+)
+
+// nodeDatabaseName is the name of the chain database opened by MakeNodeDatabase.
+const nodeDatabaseName = "blocks"
+
+// lockDatadir takes the instance lock of the node's datadir, failing if a
+// running node holds it. The lock must be released once done with the database.
+func lockDatadir(stack *node.Node) flock.Releaser {
+	lock, _, err := flock.New(filepath.Join(stack.InstanceDir(), "LOCK"))
+	if err != nil {
+		utils.Fatalf("Datadir %s is in use by a running node: %v", stack.InstanceDir(), err)
+	}
+	return lock
+}
+
+// parseDBKey decodes a hex encoded database key, with or without 0x prefix.
+func parseDBKey(ctx *cli.Context) []byte {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a single key argument.")
+	}
+	key, err := hex.DecodeString(strings.TrimPrefix(ctx.Args().First(), "0x"))
+	if err != nil || len(key) == 0 {
+		utils.Fatalf("Invalid database key %q: %v", ctx.Args().First(), err)
+	}
+	return key
+}
+
+func inspectDB(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer lockDatadir(stack).Release()
+
+	db := utils.MakeNodeDatabase(ctx, stack)
+	defer db.Close()
+
+	return rawdb.InspectDatabase(db)
+}
+
+func compactDB(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer lockDatadir(stack).Release()
+
+	db := utils.MakeNodeDatabase(ctx, stack)
+	defer db.Close()
+
+	printDBStats(db)
+
+	start := time.Now()
+	log.Info("Compacting entire database")
+	if err := db.Compact(nil, nil); err != nil {
+		utils.Fatalf("Compaction failed: %v", err)
+	}
+	log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(start)))
+
+	printDBStats(db)
+	return nil
+}
+
+// printDBStats prints the internal statistics of the database, if it has any.
+func printDBStats(db ethdb.Database) {
+	properties := []string{"leveldb.stats", "leveldb.iostats"}
+	if _, err := db.Stat("bolt.stats"); err == nil {
+		properties = []string{"bolt.stats"}
+	}
+	for _, property := range properties {
+		stats, err := db.Stat(property)
+		if err != nil {
+			log.Warn("Failed to read database stats", "property", property, "err", err)
+			continue
+		}
+		fmt.Println(stats)
+	}
+}
+
+func getDBKey(ctx *cli.Context) error {
+	key := parseDBKey(ctx)
+
+	stack, _ := makeConfigNode(ctx)
+	defer lockDatadir(stack).Release()
+
+	db := utils.MakeNodeDatabase(ctx, stack)
+	defer db.Close()
+
+	value, err := db.Get(key)
+	if err != nil {
+		utils.Fatalf("Failed to read key %#x: %v", key, err)
+	}
+	fmt.Printf("%#x\n", value)
+	return nil
+}
+
+func deleteDBKey(ctx *cli.Context) error {
+	key := parseDBKey(ctx)
+
+	stack, _ := makeConfigNode(ctx)
+	defer lockDatadir(stack).Release()
+
+	db := utils.MakeNodeDatabase(ctx, stack)
+	defer db.Close()
+
+	value, err := db.Get(key)
+	if err != nil {
+		utils.Fatalf("Failed to read key %#x: %v", key, err)
+	}
+	if err := db.Delete(key); err != nil {
+		utils.Fatalf("Failed to delete key %#x: %v", key, err)
+	}
+	log.Info("Deleted database key", "key", fmt.Sprintf("%#x", key), "value", fmt.Sprintf("%#x", value))
+	return nil
+}
+
+func convertDB(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires the target engine argument.")
+	}
+	engine := ctx.Args().First()
+	if engine != ethdb.EngineLevelDB && engine != ethdb.EngineBolt {
+		utils.Fatalf("Invalid database engine %q, must be %q or %q", engine, ethdb.EngineLevelDB, ethdb.EngineBolt)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer lockDatadir(stack).Release()
+
+	root := stack.ResolvePath(nodeDatabaseName)
+	switch source := ethdb.DetectEngine(root); source {
+	case "":
+		utils.Fatalf("No database found at %s", root)
+	case engine:
+		utils.Fatalf("Database %s already uses %s", root, engine)
+	}
+	// Copy the key-value store into a fresh database next to the original one,
+	// discarding the leftovers of an interrupted conversion
+	cache := ctx.GlobalInt(utils.CacheFlag.Name) * ctx.GlobalInt(utils.CacheDatabaseFlag.Name) / 100
+
+	src, err := ethdb.NewDatabase("", root, cache, 256)
+	if err != nil {
+		utils.Fatalf("Could not open database: %v", err)
+	}
+	tmp := root + ".convert"
+	if err := os.RemoveAll(tmp); err != nil {
+		utils.Fatalf("Failed to remove stale conversion: %v", err)
+	}
+	dst, err := ethdb.NewDatabase(engine, tmp, cache, 256)
+	if err != nil {
+		utils.Fatalf("Could not create database: %v", err)
+	}
+	if err := copyDatabase(src, dst); err != nil {
+		utils.Fatalf("Conversion failed: %v", err)
+	}
+	src.Close()
+	dst.Close()
+
+	// Move the ancient store over and swap the databases
+	if _, err := os.Stat(filepath.Join(root, "ancient")); err == nil {
+		if err := os.Rename(filepath.Join(root, "ancient"), filepath.Join(tmp, "ancient")); err != nil {
+			utils.Fatalf("Failed to move ancient store: %v", err)
+		}
+	}
+	old := root + ".old"
+	if err := os.Rename(root, old); err != nil {
+		utils.Fatalf("Failed to move original database: %v", err)
+	}
+	if err := os.Rename(tmp, root); err != nil {
+		utils.Fatalf("Failed to move converted database into %s, original kept at %s: %v", root, old, err)
+	}
+	if err := os.RemoveAll(old); err != nil {
+		log.Warn("Failed to delete original database", "path", old, "err", err)
+	}
+	log.Info("Converted database", "path", root, "engine", engine)
+	return nil
+}
+
+// copyDatabase copies every entry of the source database into the destination.
+func copyDatabase(src, dst ethdb.Database) error {
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		count  int
+		size   common.StorageSize
+	)
+	it := src.NewIterator()
+	defer it.Release()
+
+	batch := dst.NewBatch()
+	for it.Next() {
+		if err := batch.Put(it.Key(), it.Value()); err != nil {
+			return err
+		}
+		count++
+		size += common.StorageSize(len(it.Key()) + len(it.Value()))
+
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Converting database", "entries", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Copied database", "entries", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -33,18 +34,23 @@ import (
 	"github.com/ether-ark/etherark/common"
 	"github.com/ether-ark/etherark/common/fdlimit"
 	"github.com/ether-ark/etherark/consensus"
+	"github.com/ether-ark/etherark/consensus/circum"
 	"github.com/ether-ark/etherark/consensus/clique"
 	"github.com/ether-ark/etherark/consensus/ethash"
+	"github.com/ether-ark/etherark/contracts/masternode/contract"
 	"github.com/ether-ark/etherark/core"
 	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types/masternode"
 	"github.com/ether-ark/etherark/core/vm"
 	"github.com/ether-ark/etherark/crypto"
 	"github.com/ether-ark/etherark/dashboard"
 	"github.com/ether-ark/etherark/eth"
 	"github.com/ether-ark/etherark/eth/downloader"
+	"github.com/ether-ark/etherark/eth/filters"
 	"github.com/ether-ark/etherark/eth/gasprice"
 	"github.com/ether-ark/etherark/ethdb"
 	"github.com/ether-ark/etherark/ethstats"
+	"github.com/ether-ark/etherark/graphql"
 	"github.com/ether-ark/etherark/les"
 	"github.com/ether-ark/etherark/log"
 	"github.com/ether-ark/etherark/metrics"
@@ -56,6 +62,7 @@ import (
 	"github.com/ether-ark/etherark/p2p/nat"
 	"github.com/ether-ark/etherark/p2p/netutil"
 	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
 	whisper "github.com/ether-ark/etherark/whisper/whisperv6"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -119,6 +126,14 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: `Database engine for new databases ("leveldb" or "bolt", default = engine of the existing database or leveldb)`,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -176,6 +191,31 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "archive",
 	}
+	SnapshotFlag = cli.BoolFlag{
+		Name:  "snapshot",
+		Usage: "Maintain a flat snapshot of the state for faster account and storage reads",
+	}
+	AddressIndexFlag = cli.BoolFlag{
+		Name:  "index.addresses",
+		Usage: "Index the transactions of every address, enabling eth_getTransactionsByAddress",
+	}
+	TokenIndexFlag = cli.BoolFlag{
+		Name:  "index.tokens",
+		Usage: "Index the ERC-20 and ERC-721 token transfers, enabling eth_getTokenTransfers",
+	}
+	SupplyIndexFlag = cli.BoolFlag{
+		Name:  "index.supply",
+		Usage: "Account the coins issued, locked and burned at every block, enabling eth_getSupply",
+	}
+	InternalTransfersFlag = cli.BoolFlag{
+		Name:  "index.transfers",
+		Usage: "Record the value transfers made by contracts in imported blocks, enabling eth_getInternalTransfers",
+	}
+	InternalTransfersRetentionFlag = cli.Uint64Flag{
+		Name:  "index.transfers.retention",
+		Usage: "Number of recent blocks to keep the internal value transfers of (0 = keep all)",
+		Value: eth.DefaultConfig.InternalTransfersRetention,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -395,6 +435,16 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: `Transaction ordering policy for built blocks ("price", "gasshare", "masternode" or "fair")`,
+		Value: eth.DefaultConfig.MinerOrdering,
+	}
+	MinerGasShareFlag = cli.Uint64Flag{
+		Name:  "miner.gasshare",
+		Usage: "Percentage of the block gas limit a single account may use with the gasshare ordering",
+		Value: eth.DefaultConfig.MinerGasShare,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -415,6 +465,38 @@ var (
 		Name:  "rpc.gascap",
 		Usage: "Sets a cap on gas that can be used in eth_call/estimateGas",
 	}
+	RPCLogsBlockLimitFlag = cli.Uint64Flag{
+		Name:  "rpc.logs.blocklimit",
+		Usage: "Maximum number of blocks a log query may span (0 = no limit)",
+	}
+	RPCLogsResultLimitFlag = cli.Uint64Flag{
+		Name:  "rpc.logs.resultlimit",
+		Usage: "Maximum number of logs a log query may return (0 = no limit)",
+	}
+	RPCAuthTokensFileFlag = cli.StringFlag{
+		Name:  "rpc.auth.tokensfile",
+		Usage: "JSON file mapping the bearer tokens accepted over HTTP, WS-RPC and GraphQL to the namespaces and methods they may call",
+	}
+	RPCAuthJWTSecretFlag = cli.StringFlag{
+		Name:  "rpc.auth.jwtsecret",
+		Usage: "File holding the hex encoded secret of the HS256 JWTs accepted as bearer tokens over HTTP, WS-RPC and GraphQL",
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpc.ratelimit",
+		Usage: "Requests per second each HTTP, WS-RPC and GraphQL client may issue (0 = no limit)",
+	}
+	RPCRateBurstFlag = cli.IntFlag{
+		Name:  "rpc.rateburst",
+		Usage: "Requests each HTTP, WS-RPC and GraphQL client may issue at once (defaults to the rate limit)",
+	}
+	RPCBatchLimitFlag = cli.IntFlag{
+		Name:  "rpc.batchlimit",
+		Usage: "Maximum number of requests in an HTTP and WS-RPC batch (0 = no limit)",
+	}
+	RPCResponseLimitFlag = cli.IntFlag{
+		Name:  "rpc.responselimit",
+		Usage: "Maximum size in bytes of the responses to an HTTP and WS-RPC request or batch (0 = no limit)",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -490,6 +572,30 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	GraphQLEnabledFlag = cli.BoolFlag{
+		Name:  "graphql",
+		Usage: "Enable the GraphQL server",
+	}
+	GraphQLListenAddrFlag = cli.StringFlag{
+		Name:  "graphql.addr",
+		Usage: "GraphQL server listening interface",
+		Value: node.DefaultGraphQLHost,
+	}
+	GraphQLPortFlag = cli.IntFlag{
+		Name:  "graphql.port",
+		Usage: "GraphQL server listening port",
+		Value: node.DefaultGraphQLPort,
+	}
+	GraphQLCORSDomainFlag = cli.StringFlag{
+		Name:  "graphql.corsdomain",
+		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
+		Value: "",
+	}
+	GraphQLVirtualHostsFlag = cli.StringFlag{
+		Name:  "graphql.vhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
+		Value: strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -650,6 +756,11 @@ var (
 		Name:  "height",
 		Usage: "block height when used in rollbackCommand",
 	}
+	BloomFilterSizeFlag = cli.Uint64Flag{
+		Name:  "bloomfilter.size",
+		Usage: "Megabytes of memory allocated to the bloom filter used by state pruning",
+		Value: 2048,
+	}
 )
 
 // MakeDataDir retrieves the currently requested data directory, terminating
@@ -847,6 +958,58 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setGraphQL creates the GraphQL listener interface string from the set
+// command line flags, returning empty if the GraphQL endpoint is disabled.
+func setGraphQL(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalBool(GraphQLEnabledFlag.Name) && cfg.GraphQLHost == "" {
+		cfg.GraphQLHost = "127.0.0.1"
+		if ctx.GlobalIsSet(GraphQLListenAddrFlag.Name) {
+			cfg.GraphQLHost = ctx.GlobalString(GraphQLListenAddrFlag.Name)
+		}
+	}
+
+	if ctx.GlobalIsSet(GraphQLPortFlag.Name) {
+		cfg.GraphQLPort = ctx.GlobalInt(GraphQLPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(GraphQLCORSDomainFlag.Name) {
+		cfg.GraphQLCors = splitAndTrim(ctx.GlobalString(GraphQLCORSDomainFlag.Name))
+	}
+	if ctx.GlobalIsSet(GraphQLVirtualHostsFlag.Name) {
+		cfg.GraphQLVirtualHosts = splitAndTrim(ctx.GlobalString(GraphQLVirtualHostsFlag.Name))
+	}
+}
+
+// setRPCPolicy applies the access control and limit flags of the HTTP and
+// websocket RPC servers to the config.
+func setRPCPolicy(ctx *cli.Context, cfg *node.Config) {
+	if file := ctx.GlobalString(RPCAuthTokensFileFlag.Name); file != "" {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			Fatalf("Failed to read RPC tokens file: %v", err)
+		}
+		tokens := make(map[string]rpc.Permissions)
+		if err := json.Unmarshal(blob, &tokens); err != nil {
+			Fatalf("Failed to parse RPC tokens file: %v", err)
+		}
+		cfg.RPCAuthTokens = tokens
+	}
+	if ctx.GlobalIsSet(RPCAuthJWTSecretFlag.Name) {
+		cfg.RPCAuthJWTSecret = ctx.GlobalString(RPCAuthJWTSecretFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCRateLimit = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateBurstFlag.Name) {
+		cfg.RPCRateBurst = ctx.GlobalInt(RPCRateBurstFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBatchLimitFlag.Name) {
+		cfg.RPCBatchLimit = ctx.GlobalInt(RPCBatchLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCResponseLimitFlag.Name) {
+		cfg.RPCResponseLimit = ctx.GlobalInt(RPCResponseLimitFlag.Name)
+	}
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -1011,9 +1174,18 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
+	setGraphQL(ctx, cfg)
+	setRPCPolicy(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		engine := ctx.GlobalString(DBEngineFlag.Name)
+		if engine != ethdb.EngineLevelDB && engine != ethdb.EngineBolt {
+			Fatalf("Invalid database engine %q, must be %q or %q", engine, ethdb.EngineLevelDB, ethdb.EngineBolt)
+		}
+		cfg.DBEngine = engine
+	}
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
 	}
@@ -1219,11 +1391,22 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
 
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	cfg.Snapshot = ctx.GlobalBool(SnapshotFlag.Name)
+	cfg.AddressIndex = ctx.GlobalBool(AddressIndexFlag.Name)
+	cfg.TokenIndex = ctx.GlobalBool(TokenIndexFlag.Name)
+	cfg.SupplyIndex = ctx.GlobalBool(SupplyIndexFlag.Name)
+	cfg.InternalTransfers = ctx.GlobalBool(InternalTransfersFlag.Name)
+	if ctx.GlobalIsSet(InternalTransfersRetentionFlag.Name) {
+		cfg.InternalTransfersRetention = ctx.GlobalUint64(InternalTransfersRetentionFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
@@ -1264,6 +1447,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.MinerNoverify = ctx.Bool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.MinerOrdering = ctx.GlobalString(MinerOrderingFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerGasShareFlag.Name) {
+		cfg.MinerGasShare = ctx.GlobalUint64(MinerGasShareFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1279,6 +1468,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(RPCGlobalGasCap.Name) {
 		cfg.RPCGasCap = new(big.Int).SetUint64(ctx.GlobalUint64(RPCGlobalGasCap.Name))
 	}
+	if ctx.GlobalIsSet(RPCLogsBlockLimitFlag.Name) {
+		cfg.RPCLogsBlockLimit = ctx.GlobalUint64(RPCLogsBlockLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogsResultLimitFlag.Name) {
+		cfg.RPCLogsResultLimit = ctx.GlobalUint64(RPCLogsResultLimitFlag.Name)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch {
@@ -1392,6 +1587,13 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterGraphQLService is a utility function to construct a new service and register it against a node.
+func RegisterGraphQLService(stack *node.Node, endpoint string, cors, vhosts []string, timeouts rpc.HTTPTimeouts, logsLimits filters.Config) {
+	if err := graphql.RegisterGraphQLService(stack, endpoint, cors, vhosts, timeouts, logsLimits); err != nil {
+		Fatalf("Failed to register the GraphQL service: %v", err)
+	}
+}
+
 func SetupMetrics(ctx *cli.Context) {
 	if metrics.Enabled {
 		log.Info("Enabling metrics collection")
@@ -1431,16 +1633,36 @@ func SplitTagsFlag(tagsFlag string) map[string]string {
 }
 
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
+// Full chain databases get the chain freezer attached.
 func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	var (
 		cache   = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 		handles = makeDatabaseHandles()
 	)
-	name := "chaindata"
+	var (
+		chainDb ethdb.Database
+		err     error
+	)
 	if ctx.GlobalString(SyncModeFlag.Name) == "light" {
-		name = "lightchaindata"
+		chainDb, err = stack.OpenDatabase("lightchaindata", cache, handles)
+	} else {
+		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", cache, handles, ctx.GlobalString(AncientFlag.Name), "")
 	}
-	chainDb, err := stack.OpenDatabase(name, cache, handles)
+	if err != nil {
+		Fatalf("Could not open database: %v", err)
+	}
+	return chainDb
+}
+
+// MakeNodeDatabase opens the chain database of a full node under the name used
+// by the eth service, which differs from the one of the chain import and export
+// commands.
+func MakeNodeDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
+	var (
+		cache   = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
+		handles = makeDatabaseHandles()
+	)
+	chainDb, err := stack.OpenDatabaseWithFreezer("blocks", cache, handles, ctx.GlobalString(AncientFlag.Name), "")
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
@@ -1471,7 +1693,9 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Fatalf("%v", err)
 	}
 	var engine consensus.Engine
-	if config.Clique != nil {
+	if config.Circum != nil {
+		engine = circum.NewCircum(config.Circum, chainDb)
+	} else if config.Clique != nil {
 		engine = clique.New(config.Clique, chainDb)
 	} else {
 		engine = ethash.NewFaker()
@@ -1494,6 +1718,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		TrieCleanLimit: eth.DefaultConfig.TrieCleanCache,
 		TrieDirtyLimit: eth.DefaultConfig.TrieDirtyCache,
 		TrieTimeLimit:  eth.DefaultConfig.TrieTimeout,
+		Snapshot:       ctx.GlobalBool(SnapshotFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cache.TrieCleanLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
@@ -1506,6 +1731,16 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
+	if engine, ok := engine.(*circum.Circum); ok {
+		// Without a running node, the witnesses are read from the chain state
+		masternodes, err := contract.NewContract(params.MasterndeContractAddress, eth.NewChainContractBackend(chainDb, chain))
+		if err != nil {
+			Fatalf("Can't bind masternode contract: %v", err)
+		}
+		engine.Masternodes(func(number *big.Int) ([]string, error) {
+			return masternode.GetIdsByBlockNumber(masternodes, number)
+		})
+	}
 	return chain, chainDb
 }
 
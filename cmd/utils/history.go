@@ -0,0 +1,316 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of go-auc.
+//
+// go-auc is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-auc is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-auc. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/internal/era"
+	"github.com/ether-ark/etherark/log"
+)
+
+// historyNetwork returns the network name used in the era file names.
+func historyNetwork(chain *core.BlockChain) string {
+	return fmt.Sprintf("chain%v", chain.Config().ChainID)
+}
+
+// ExportHistory exports the blocks between first and last, with their receipts
+// and total difficulties, into an era archive in the given directory. Whole
+// epochs are exported, the first one starting at or before the first block.
+// Era files left intact by an earlier export are kept, so an interrupted export
+// can be resumed.
+func ExportHistory(chain *core.BlockChain, dir string, first uint64, last uint64) error {
+	if first > last {
+		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	if head := chain.CurrentBlock().NumberU64(); last > head {
+		return fmt.Errorf("export failed: last (%d) is beyond the chain head (%d)", last, head)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	checksums, err := era.ReadChecksums(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var (
+		network = historyNetwork(chain)
+		start   = time.Now()
+	)
+	log.Info("Exporting chain history", "dir", dir, "first", first, "last", last)
+
+	for epoch := int(first / era.MaxEraSize); uint64(epoch)*era.MaxEraSize <= last; epoch++ {
+		from := uint64(epoch) * era.MaxEraSize
+		to := from + era.MaxEraSize - 1
+		if to > last {
+			to = last
+		}
+		// Keep the file of an earlier export if it's intact and complete
+		prefix := fmt.Sprintf("%s-%05d-", network, epoch)
+		for i, checksum := range checksums {
+			if !strings.HasPrefix(checksum.Name, prefix) {
+				continue
+			}
+			if historyFileIntact(dir, checksum, from, to) {
+				log.Info("Skipping exported history segment", "file", checksum.Name)
+				prefix = ""
+				break
+			}
+			os.Remove(filepath.Join(dir, checksum.Name))
+			checksums = append(checksums[:i], checksums[i+1:]...)
+			break
+		}
+		if prefix == "" {
+			continue
+		}
+		checksum, err := exportHistoryFile(chain, dir, network, epoch, from, to)
+		if err != nil {
+			return err
+		}
+		checksums = append(checksums, checksum)
+		sort.Slice(checksums, func(i, j int) bool { return checksums[i].Name < checksums[j].Name })
+
+		if err := era.WriteChecksums(dir, checksums); err != nil {
+			return err
+		}
+		log.Info("Exported history segment", "file", checksum.Name, "first", from, "last", to, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
+
+// historyFileIntact checks that an era file of an earlier export matches its
+// checksum and holds the expected blocks.
+func historyFileIntact(dir string, checksum era.Checksum, from, to uint64) bool {
+	path := filepath.Join(dir, checksum.Name)
+	if sum, err := era.FileChecksum(path); err != nil || sum != checksum.Sum {
+		return false
+	}
+	e, err := era.Open(path)
+	if err != nil {
+		return false
+	}
+	defer e.Close()
+
+	return e.Start() == from && e.Count() == to-from+1
+}
+
+// exportHistoryFile writes the era file holding the blocks between from and to.
+func exportHistoryFile(chain *core.BlockChain, dir string, network string, epoch int, from, to uint64) (era.Checksum, error) {
+	f, err := ioutil.TempFile(dir, ".export-")
+	if err != nil {
+		return era.Checksum{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var (
+		buf     = bufio.NewWriter(f)
+		builder = era.NewBuilder(buf)
+	)
+	for number := from; number <= to; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			return era.Checksum{}, fmt.Errorf("export failed on #%d: not found", number)
+		}
+		td := chain.GetTd(block.Hash(), number)
+		if td == nil {
+			return era.Checksum{}, fmt.Errorf("export failed on #%d: total difficulty not found", number)
+		}
+		receipts := chain.GetReceiptsByHash(block.Hash())
+		if receipts == nil && len(block.Transactions()) > 0 {
+			return era.Checksum{}, fmt.Errorf("export failed on #%d: receipts not found", number)
+		}
+		if err := builder.Add(block, receipts, td); err != nil {
+			return era.Checksum{}, fmt.Errorf("export failed on #%d: %v", number, err)
+		}
+	}
+	accumulator, err := builder.Finalize()
+	if err != nil {
+		return era.Checksum{}, err
+	}
+	if err := buf.Flush(); err != nil {
+		return era.Checksum{}, err
+	}
+	if err := f.Sync(); err != nil {
+		return era.Checksum{}, err
+	}
+	if err := f.Close(); err != nil {
+		return era.Checksum{}, err
+	}
+	name := era.Filename(network, epoch, accumulator)
+	if err := os.Rename(f.Name(), filepath.Join(dir, name)); err != nil {
+		return era.Checksum{}, err
+	}
+	sum, err := era.FileChecksum(filepath.Join(dir, name))
+	if err != nil {
+		return era.Checksum{}, err
+	}
+	return era.Checksum{Sum: sum, Name: name}, nil
+}
+
+// ImportHistory imports the era archive in the given directory. Segments already
+// present in the chain are skipped without being read, so an interrupted import
+// resumes where it stopped. Every other segment is checked against its checksum
+// and accumulator, and its headers are verified up front, before its blocks are
+// processed.
+func ImportHistory(chain *core.BlockChain, dir string) error {
+	checkInterrupt, release := watchInterrupt()
+	defer release()
+
+	checksums, err := era.ReadChecksums(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read archive checksums: %v", err)
+	}
+	log.Info("Importing chain history", "dir", dir, "files", len(checksums))
+
+	var (
+		start             = time.Now()
+		imported, skipped int
+	)
+	for _, checksum := range checksums {
+		if checkInterrupt() {
+			return fmt.Errorf("interrupted")
+		}
+		done, err := importHistoryFile(chain, dir, checksum, checkInterrupt)
+		if err != nil {
+			return fmt.Errorf("%s: %v", checksum.Name, err)
+		}
+		if done {
+			imported++
+		} else {
+			skipped++
+		}
+	}
+	log.Info("Imported chain history", "files", imported, "skipped", skipped, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// importHistoryFile imports a single era file, returning false if it was already
+// present in the chain.
+func importHistoryFile(chain *core.BlockChain, dir string, checksum era.Checksum, checkInterrupt func() bool) (bool, error) {
+	path := filepath.Join(dir, checksum.Name)
+
+	e, err := era.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer e.Close()
+
+	// Skip the segment if its last block is already in the canonical chain
+	last := e.Start() + e.Count() - 1
+	header, err := e.GetHeaderByNumber(last)
+	if err != nil {
+		return false, err
+	}
+	if local := chain.GetHeaderByNumber(last); local != nil && local.Hash() == header.Hash() && chain.HasBlock(local.Hash(), last) {
+		log.Info("Skipping present history segment", "file", checksum.Name, "first", e.Start(), "last", last)
+		return false, nil
+	}
+	// Verify the integrity of the file before using its content
+	sum, err := era.FileChecksum(path)
+	if err != nil {
+		return false, err
+	}
+	if sum != checksum.Sum {
+		return false, fmt.Errorf("checksum mismatch: have %s, want %s", sum, checksum.Sum)
+	}
+	var (
+		blocks = make([]*types.Block, 0, e.Count())
+		hashes = make([]common.Hash, 0, e.Count())
+		tds    = make([]*big.Int, 0, e.Count())
+	)
+	it := e.Iterator()
+	for it.Next() {
+		if n := len(blocks); n > 0 && it.Block.ParentHash() != blocks[n-1].Hash() {
+			return false, fmt.Errorf("block #%d not linked to its parent", it.Block.NumberU64())
+		}
+		blocks = append(blocks, it.Block)
+		hashes = append(hashes, it.Block.Hash())
+		tds = append(tds, it.TD)
+	}
+	if it.Err != nil {
+		return false, it.Err
+	}
+	if uint64(len(blocks)) != e.Count() {
+		return false, fmt.Errorf("truncated era file, %d of %d blocks", len(blocks), e.Count())
+	}
+	accumulator, err := era.ComputeAccumulator(hashes, tds)
+	if err != nil {
+		return false, err
+	}
+	if want, err := e.Accumulator(); err != nil || accumulator != want {
+		return false, fmt.Errorf("accumulator mismatch: have %x, want %x (%v)", accumulator, want, err)
+	}
+	if blocks[0].NumberU64() == 0 {
+		if blocks[0].Hash() != chain.Genesis().Hash() {
+			return false, fmt.Errorf("genesis mismatch: have %x, want %x", blocks[0].Hash(), chain.Genesis().Hash())
+		}
+		blocks, tds = blocks[1:], tds[1:]
+	}
+	// Drop the blocks imported before an interruption
+	missing := missingBlocks(chain, blocks)
+	tds = tds[len(blocks)-len(missing):]
+	blocks = missing
+	if len(blocks) == 0 {
+		return false, nil
+	}
+	// Verify all the headers of the segment concurrently before processing any
+	headers := make([]*types.Header, len(blocks))
+	seals := make([]bool, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+		seals[i] = true
+	}
+	abort, results := chain.Engine().VerifyHeaders(chain, headers, seals)
+	for i := range headers {
+		if err := <-results; err != nil {
+			close(abort)
+			return false, fmt.Errorf("invalid header #%d: %v", headers[i].Number, err)
+		}
+	}
+	close(abort)
+
+	for batch := 0; batch < len(blocks); batch += importBatchSize {
+		if checkInterrupt() {
+			return false, fmt.Errorf("interrupted")
+		}
+		end := batch + importBatchSize
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		if _, err := chain.InsertChain(blocks[batch:end]); err != nil {
+			return false, fmt.Errorf("invalid block: %v", err)
+		}
+	}
+	head := blocks[len(blocks)-1]
+	if td := chain.GetTd(head.Hash(), head.NumberU64()); td == nil || td.Cmp(tds[len(tds)-1]) != 0 {
+		return false, fmt.Errorf("total difficulty mismatch at #%d: have %v, want %v", head.NumberU64(), td, tds[len(tds)-1])
+	}
+	log.Info("Imported history segment", "file", checksum.Name, "first", blocks[0].NumberU64(), "last", head.NumberU64())
+	return true, nil
+}
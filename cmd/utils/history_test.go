@@ -0,0 +1,128 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of go-auc.
+//
+// go-auc is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-auc is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-auc. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/consensus/ethash"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/internal/era"
+	"github.com/ether-ark/etherark/params"
+)
+
+// newHistoryChain creates a chain with the given genesis, importing the blocks.
+func newHistoryChain(t *testing.T, gspec *core.Genesis, blocks []*types.Block) *core.BlockChain {
+	db := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	return chain
+}
+
+// Tests that the chain history survives an export and import roundtrip, that
+// complete segments are skipped and that corrupted ones are rejected.
+func TestHistoryRoundtrip(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.HomesteadSigner{}
+		gspec   = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		gendb   = ethdb.NewMemDatabase()
+		genesis = gspec.MustCommit(gendb)
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, era.MaxEraSize+100, func(i int, gen *core.BlockGen) {
+		if i%1000 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(address), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+			gen.AddTx(tx)
+		}
+	})
+	dir, err := ioutil.TempDir("", "history-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := newHistoryChain(t, gspec, blocks)
+	defer source.Stop()
+
+	if err := ExportHistory(source, dir, 0, uint64(len(blocks))); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	checksums, err := era.ReadChecksums(dir)
+	if err != nil || len(checksums) != 2 {
+		t.Fatalf("checksums mismatch: have %v, %v", checksums, err)
+	}
+	// Import the first segment only, then the entire archive
+	dest := newHistoryChain(t, gspec, nil)
+	defer dest.Stop()
+
+	if _, err := importHistoryFile(dest, dir, checksums[0], func() bool { return false }); err != nil {
+		t.Fatalf("failed to import first segment: %v", err)
+	}
+	if head := dest.CurrentBlock().NumberU64(); head != era.MaxEraSize-1 {
+		t.Fatalf("head mismatch after first segment: have %d, want %d", head, era.MaxEraSize-1)
+	}
+	if done, err := importHistoryFile(dest, dir, checksums[0], func() bool { return false }); err != nil || done {
+		t.Fatalf("present segment not skipped: %v, %v", done, err)
+	}
+	if err := ImportHistory(dest, dir); err != nil {
+		t.Fatalf("failed to import history: %v", err)
+	}
+	if head := dest.CurrentBlock(); head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head mismatch: have %d, want %d", head.NumberU64(), blocks[len(blocks)-1].NumberU64())
+	}
+	if receipts := dest.GetReceiptsByHash(blocks[1000].Hash()); len(receipts) != 1 {
+		t.Fatalf("receipts missing at block 1000")
+	}
+	// A corrupted segment must be rejected
+	path := filepath.Join(dir, checksums[1].Name)
+	data, _ := ioutil.ReadFile(path)
+	data[len(data)/2] ^= 0xff
+	ioutil.WriteFile(path, data, 0644)
+
+	fresh := newHistoryChain(t, gspec, blocks[:era.MaxEraSize-1])
+	defer fresh.Stop()
+	if err := ImportHistory(fresh, dir); err == nil {
+		t.Fatalf("corrupted segment imported")
+	}
+	// Re-exporting replaces the corrupted segment only
+	if err := ExportHistory(source, dir, 0, uint64(len(blocks))); err != nil {
+		t.Fatalf("failed to re-export history: %v", err)
+	}
+	if err := ImportHistory(fresh, dir); err != nil {
+		t.Fatalf("failed to import repaired history: %v", err)
+	}
+}
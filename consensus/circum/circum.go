@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"runtime"
 	"sync"
 	"time"
 
@@ -37,12 +38,15 @@ import (
 	"github.com/ether-ark/etherark/params"
 	"github.com/ether-ark/etherark/rlp"
 	"github.com/ether-ark/etherark/rpc"
+	"github.com/hashicorp/golang-lru"
 	"math"
 )
 
 const (
 	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for signer vanity
 	extraSeal   = 65 // Fixed number of extra-data suffix bytes reserved for signer seal
+
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
 )
 
 var (
@@ -134,12 +138,16 @@ type Circum struct {
 
 	cacheNumber uint64
 	cacheNodes  []string
+
+	signatures *lru.ARCCache // Signers of recent blocks, recovered during batch verification
 }
 
 func NewCircum(config *params.CircumConfig, db ethdb.Database) *Circum {
+	signatures, _ := lru.NewARC(inmemorySignatures)
 	return &Circum{
 		cacheNumber: 0,
 		config:      config,
+		signatures:  signatures,
 	}
 }
 
@@ -162,19 +170,23 @@ func (d *Circum) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	return nil
 }
 
+// BlockReward returns the mining reward of the block with the given number,
+// halving every reward period.
+func BlockReward(number *big.Int) *big.Int {
+	if number.Uint64() < rewardPeriod {
+		return new(big.Int).Set(blockReward)
+	}
+	currentPeriod := number.Uint64() / rewardPeriod
+	currentPeriod1 := float64(currentPeriod)
+	rate := new(big.Int).SetUint64(uint64(math.Pow(0.5, currentPeriod1) * 100000000))
+	blockReward1 := new(big.Int).Mul(blockReward, rate)
+	return blockReward1.Div(blockReward1, big.NewInt(100000000))
+}
+
 // AccumulateRewards credits the coinbase of the given block with the mining
 // reward.  The circum consensus allowed uncle block .
 func AccumulateRewards(state *state.StateDB, header *types.Header) {
-	if header.Number.Uint64() < rewardPeriod {
-		state.AddBalance(header.Coinbase, blockReward, header.Number)
-	}else{
-		currentPeriod := header.Number.Uint64() / rewardPeriod
-		currentPeriod1 := float64(currentPeriod)
-		rate := new(big.Int).SetUint64(uint64(math.Pow(0.5, currentPeriod1) * 100000000))
-		blockReward1 := new(big.Int).Mul(blockReward, rate)
-		blockReward2 := new(big.Int).Div(blockReward1, big.NewInt(100000000))
-		state.AddBalance(header.Coinbase, blockReward2, header.Number)
-	}
+	state.AddBalance(header.Coinbase, BlockReward(header.Number), header.Number)
 }
 
 // Finalize implements consensus.Engine, accumulating the block and uncle rewards,
@@ -252,23 +264,82 @@ func (d *Circum) verifyHeader(chain consensus.ChainReader, header *types.Header,
 	return nil
 }
 
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers
+// concurrently. Headers requiring a seal check also get their signer recovered
+// and matched against the witness they declare, the witness schedule itself is
+// checked by VerifySeal once the parent state is available. The method returns a
+// quit channel to abort the operations and a results channel to retrieve the
+// async verifications in the order of the headers.
 func (d *Circum) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
-	abort := make(chan struct{})
+	// Spawn as many workers as allowed threads
+	workers := runtime.GOMAXPROCS(0)
+	if len(headers) < workers {
+		workers = len(headers)
+	}
+	var (
+		inputs = make(chan int)
+		done   = make(chan int, workers)
+		errs   = make([]error, len(headers))
+		abort  = make(chan struct{})
+	)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for index := range inputs {
+				errs[index] = d.verifyHeaderWorker(chain, headers, seals, index)
+				done <- index
+			}
+		}()
+	}
 	results := make(chan error, len(headers))
-
 	go func() {
-		for i, header := range headers {
-			err := d.verifyHeader(chain, header, headers[:i])
+		defer close(inputs)
+		if len(headers) == 0 {
+			return
+		}
+		var (
+			in, out = 0, 0
+			checked = make([]bool, len(headers))
+			inputs  = inputs
+		)
+		for {
 			select {
+			case inputs <- in:
+				if in++; in == len(headers) {
+					inputs = nil
+				}
+			case index := <-done:
+				for checked[index] = true; checked[out]; out++ {
+					results <- errs[out]
+					if out == len(headers)-1 {
+						return
+					}
+				}
 			case <-abort:
 				return
-			case results <- err:
 			}
 		}
 	}()
 	return abort, results
 }
 
+func (d *Circum) verifyHeaderWorker(chain consensus.ChainReader, headers []*types.Header, seals []bool, index int) error {
+	header := headers[index]
+	if err := d.verifyHeader(chain, header, headers[:index]); err != nil {
+		return err
+	}
+	if !seals[index] {
+		return nil
+	}
+	signer, err := ecrecover(header, d.signatures)
+	if err != nil {
+		return err
+	}
+	if signer != header.Witness {
+		return ErrMismatchSignerAndWitness
+	}
+	return nil
+}
+
 // VerifyUncles implements consensus.Engine, always returning an error for any
 // uncles as this consensus mechanism doesn't permit uncles.
 func (d *Circum) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
@@ -308,7 +379,7 @@ func (d *Circum) verifySeal(chain consensus.ChainReader, header *types.Header, p
 }
 
 func (d *Circum) verifyBlockSigner(witness string, header *types.Header) error {
-	signer, err := ecrecover(header)
+	signer, err := ecrecover(header, d.signatures)
 	if err != nil {
 		return err
 	}
@@ -426,7 +497,12 @@ func (d *Circum) Masternodes(masternodeListFn MasternodeListFn) {
 }
 
 // ecrecover extracts the Masternode account ID from a signed header.
-func ecrecover(header *types.Header) (string, error) {
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (string, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if id, known := sigcache.Get(hash); known {
+		return id.(string), nil
+	}
 	// Retrieve the signature from the header extra-data
 	if len(header.Extra) < extraSeal {
 		return "", errMissingSignature
@@ -438,6 +514,8 @@ func ecrecover(header *types.Header) (string, error) {
 		return "", err
 	}
 	id := fmt.Sprintf("%x", pubkey[1:9])
+
+	sigcache.Add(hash, id)
 	return id, nil
 }
 
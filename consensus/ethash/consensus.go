@@ -597,18 +597,44 @@ var (
 	big32 = big.NewInt(32)
 )
 
+// BlockRewards returns the total of the rewards minted by the given block, paid
+// to its coinbase and to the coinbases of the included uncles.
+func BlockRewards(config *params.ChainConfig, header *types.Header, uncles []*types.Header) *big.Int {
+	blockReward := staticBlockReward(config, header.Number)
+
+	total := new(big.Int).Set(blockReward)
+	r := new(big.Int)
+	for _, uncle := range uncles {
+		r.Add(uncle.Number, big8)
+		r.Sub(r, header.Number)
+		r.Mul(r, blockReward)
+		r.Div(r, big8)
+		total.Add(total, r)
+
+		r.Div(blockReward, big32)
+		total.Add(total, r)
+	}
+	return total
+}
+
+// staticBlockReward selects the block reward based on chain progression.
+func staticBlockReward(config *params.ChainConfig, number *big.Int) *big.Int {
+	if config.IsConstantinople(number) {
+		return ConstantinopleBlockReward
+	}
+	if config.IsByzantium(number) {
+		return ByzantiumBlockReward
+	}
+	return FrontierBlockReward
+}
+
 // AccumulateRewards credits the coinbase of the given block with the mining
 // reward. The total reward consists of the static block reward and rewards for
 // included uncles. The coinbase of each uncle block is also rewarded.
 func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, uncles []*types.Header) {
 	// Select the correct block reward based on chain progression
-	blockReward := FrontierBlockReward
-	if config.IsByzantium(header.Number) {
-		blockReward = ByzantiumBlockReward
-	}
-	if config.IsConstantinople(header.Number) {
-		blockReward = ConstantinopleBlockReward
-	}
+	blockReward := staticBlockReward(config, header.Number)
+
 	// Accumulate the rewards for the miner and any included uncles
 	reward := new(big.Int).Set(blockReward)
 	r := new(big.Int)
@@ -34,6 +34,7 @@ import (
 	"github.com/ether-ark/etherark/consensus"
 	"github.com/ether-ark/etherark/core/rawdb"
 	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/state/snapshot"
 	"github.com/ether-ark/etherark/core/types"
 	"github.com/ether-ark/etherark/core/vm"
 	"github.com/ether-ark/etherark/crypto"
@@ -52,8 +53,13 @@ var (
 	blockValidationTimer = metrics.NewRegisteredTimer("chain/validation", nil)
 	blockExecutionTimer  = metrics.NewRegisteredTimer("chain/execution", nil)
 	blockWriteTimer      = metrics.NewRegisteredTimer("chain/write", nil)
+	blockDeepReorgMeter  = metrics.NewRegisteredMeter("chain/reorg/deep", nil)
 
 	ErrNoGenesis = errors.New("Genesis not found in chain")
+
+	// immutabilityThreshold is the number of blocks after which a chain segment
+	// is moved into the freezer, a variable to allow tests to lower it.
+	immutabilityThreshold = uint64(params.ImmutabilityThreshold)
 )
 
 const (
@@ -65,6 +71,9 @@ const (
 	badBlockLimit       = 10
 	triesInMemory       = 128
 
+	freezerRecheckInterval = time.Minute // Time interval to check for new immutable blocks to freeze
+	freezerBatchLimit      = 30000       // Maximum number of blocks to freeze in a single round
+
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	BlockChainVersion uint64 = 3
 )
@@ -76,6 +85,7 @@ type CacheConfig struct {
 	TrieCleanLimit int           // Memory allowance (MB) to use for caching trie nodes in memory
 	TrieDirtyLimit int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieTimeLimit  time.Duration // Time limit after which to flush the current in-memory trie to disk
+	Snapshot       bool          // Whether to maintain a flat snapshot of the state for faster reads
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -106,6 +116,7 @@ type BlockChain struct {
 	chainSideFeed event.Feed
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
+	deepReorgFeed event.Feed
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
@@ -117,7 +128,10 @@ type BlockChain struct {
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
+	currentFinalizedBlock atomic.Value // Latest block that can no longer be reorged (nil without finality)
+
 	stateCache    state.Database // State database to reuse between imports (contains state cache)
+	snaps         *snapshot.Tree // Flat snapshot of the recent states, nil if disabled
 	bodyCache     *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache  *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	receiptsCache *lru.Cache     // Cache for the most recent receipts per block
@@ -202,8 +216,18 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			}
 		}
 	}
+	// Load any existing snapshot, regenerating it if loading failed
+	if bc.cacheConfig.Snapshot {
+		bc.snaps = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.CurrentBlock().Root())
+	}
 	// Take ownership of this particular state
 	go bc.update()
+
+	// Start moving immutable chain segments into the freezer, if there is one
+	if ancients, ok := db.(ethdb.AncientStore); ok {
+		bc.wg.Add(1)
+		go bc.freeze(ancients)
+	}
 	return bc, nil
 }
 
@@ -261,6 +285,17 @@ func (bc *BlockChain) loadLastState() error {
 		}
 	}
 
+	// Restore the last finalized block, unless it was rewound out of the chain
+	bc.currentFinalizedBlock.Store((*types.Block)(nil))
+	if bc.finalityDepth() > 0 {
+		bc.currentFinalizedBlock.Store(bc.genesisBlock)
+		if head := rawdb.ReadHeadFinalizedBlockHash(bc.db); head != (common.Hash{}) {
+			if block := bc.GetBlockByHash(head); block != nil && block.NumberU64() <= currentBlock.NumberU64() && rawdb.ReadCanonicalHash(bc.db, block.NumberU64()) == head {
+				bc.currentFinalizedBlock.Store(block)
+			}
+		}
+		bc.updateFinalized(currentBlock)
+	}
 	// Issue a status log for the user
 	currentFastBlock := bc.CurrentFastBlock()
 
@@ -271,6 +306,9 @@ func (bc *BlockChain) loadLastState() error {
 	log.Info("Loaded most recent local header", "number", currentHeader.Number, "hash", currentHeader.Hash(), "td", headerTd, "age", common.PrettyAge(time.Unix(int64(currentHeader.Time), 0)))
 	log.Info("Loaded most recent local full block", "number", currentBlock.Number(), "hash", currentBlock.Hash(), "td", blockTd, "age", common.PrettyAge(time.Unix(int64(currentBlock.Time()), 0)))
 	log.Info("Loaded most recent local fast block", "number", currentFastBlock.Number(), "hash", currentFastBlock.Hash(), "td", fastTd, "age", common.PrettyAge(time.Unix(int64(currentFastBlock.Time()), 0)))
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil {
+		log.Info("Loaded most recent finalized block", "number", finalized.Number(), "hash", finalized.Hash(), "depth", bc.finalityDepth())
+	}
 
 	return nil
 }
@@ -287,11 +325,22 @@ func (bc *BlockChain) SetHead(head uint64) error {
 
 	// Rewind the header chain, deleting all block bodies until then
 	delFn := func(db rawdb.DatabaseDeleter, hash common.Hash, num uint64) {
+		if block := bc.GetBlock(hash, num); block != nil {
+			bc.deletePowerHistory(db, block)
+		}
 		rawdb.DeleteBody(db, hash, num)
 	}
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Drop any frozen blocks above the new head, the freezer may only hold
+	// canonical blocks
+	if ancients, ok := bc.db.(ethdb.AncientStore); ok {
+		if err := ancients.TruncateAncients(currentHeader.Number.Uint64() + 1); err != nil {
+			return err
+		}
+	}
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -326,6 +375,10 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	rawdb.WriteHeadBlockHash(bc.db, currentBlock.Hash())
 	rawdb.WriteHeadFastBlockHash(bc.db, currentFastBlock.Hash())
 
+	// The snapshot can't be rewound, regenerate it for the new head
+	if bc.snaps != nil {
+		bc.snaps.Rebuild(currentBlock.Root())
+	}
 	return bc.loadLastState()
 }
 
@@ -345,6 +398,10 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	bc.currentBlock.Store(block)
 	bc.mu.Unlock()
 
+	// Fast sync downloaded the state trie only, generate the snapshot from it
+	if bc.snaps != nil {
+		bc.snaps.Rebuild(block.Root())
+	}
 	log.Info("Committed new head block", "number", block.Number(), "hash", hash)
 	return nil
 }
@@ -366,6 +423,42 @@ func (bc *BlockChain) CurrentFastBlock() *types.Block {
 	return bc.currentFastBlock.Load().(*types.Block)
 }
 
+// CurrentFinalizedBlock retrieves the latest block that can no longer be reorged,
+// or nil if the chain has no finality depth configured.
+func (bc *BlockChain) CurrentFinalizedBlock() *types.Block {
+	block, _ := bc.currentFinalizedBlock.Load().(*types.Block)
+	return block
+}
+
+// finalityDepth returns the number of blocks after which a block becomes final,
+// zero meaning that reorgs of any depth are accepted.
+func (bc *BlockChain) finalityDepth() uint64 {
+	if bc.chainConfig.Circum == nil {
+		return 0
+	}
+	return bc.chainConfig.Circum.FinalityDepth
+}
+
+// updateFinalized moves the finalized block up to the canonical block finality
+// depth below the given head. The finalized block never moves backwards.
+func (bc *BlockChain) updateFinalized(head *types.Block) {
+	depth := bc.finalityDepth()
+	if depth == 0 || head.NumberU64() < depth {
+		return
+	}
+	number := head.NumberU64() - depth
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil && finalized.NumberU64() >= number {
+		return
+	}
+	hash := rawdb.ReadCanonicalHash(bc.db, number)
+	block := bc.GetBlock(hash, number)
+	if block == nil {
+		return
+	}
+	rawdb.WriteHeadFinalizedBlockHash(bc.db, hash)
+	bc.currentFinalizedBlock.Store(block)
+}
+
 // SetProcessor sets the processor required for making state modifications.
 func (bc *BlockChain) SetProcessor(processor Processor) {
 	bc.procmu.Lock()
@@ -401,7 +494,7 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshot(root, bc.stateCache, bc.snaps)
 }
 
 // StateCache returns the caching database underpinning the blockchain instance.
@@ -431,6 +524,10 @@ func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 	rawdb.WriteBlock(bc.db, genesis)
 
 	bc.genesisBlock = genesis
+	if bc.finalityDepth() > 0 {
+		rawdb.WriteHeadFinalizedBlockHash(bc.db, genesis.Hash())
+		bc.currentFinalizedBlock.Store(genesis)
+	}
 	bc.insert(bc.genesisBlock)
 	bc.currentBlock.Store(bc.genesisBlock)
 	bc.hc.SetGenesis(bc.genesisBlock.Header())
@@ -518,6 +615,7 @@ func (bc *BlockChain) insert(block *types.Block) {
 
 		bc.currentFastBlock.Store(block)
 	}
+	bc.updateFinalized(block)
 }
 
 // Genesis retrieves the chain's genesis block.
@@ -725,6 +823,14 @@ func (bc *BlockChain) Stop() {
 			log.Error("Dangling trie nodes after full cleanup")
 		}
 	}
+	// Flatten the snapshot diffs into the disk layer, the diffs aren't persisted
+	// and the head state was written out above
+	if bc.snaps != nil {
+		if err := bc.snaps.Cap(bc.CurrentBlock().Root(), 0); err != nil {
+			log.Error("Failed to flatten state snapshot", "err", err)
+		}
+		bc.snaps.Stop()
+	}
 	log.Info("Blockchain manager stopped")
 }
 
@@ -881,9 +987,14 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 		}
 	}
 
-	// Update the head fast sync block if better
+	// Update the head fast sync block if better. The receipts received from the
+	// network lack the power accounting, so mark the power history unavailable
+	// up to the head.
 	bc.mu.Lock()
 	head := blockChain[len(blockChain)-1]
+	if tail := head.NumberU64() + 1; stats.processed > 0 && tail > rawdb.ReadPowerHistoryTail(bc.db) {
+		rawdb.WritePowerHistoryTail(bc.db, tail)
+	}
 	if td := bc.GetTd(head.Hash(), head.NumberU64()); td != nil { // Rewind may have occurred, skip in that case
 		currentFastBlock := bc.CurrentFastBlock()
 		if bc.GetTd(currentFastBlock.Hash(), currentFastBlock.NumberU64()).Cmp(td) < 0 {
@@ -1009,6 +1120,7 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	// Write other block data using a batch.
 	batch := bc.db.NewBatch()
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
+	bc.writePowerHistory(batch, block, receipts)
 
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
@@ -1055,6 +1167,43 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	return status, nil
 }
 
+// writePowerHistory indexes the power accounting of the block's receipts by
+// transaction sender, so the power an account spent can be audited per block.
+func (bc *BlockChain) writePowerHistory(db rawdb.DatabaseWriter, block *types.Block, receipts types.Receipts) {
+	var (
+		signer  = types.MakeSigner(bc.chainConfig, block.Number())
+		entries = make(map[common.Address][]rawdb.PowerHistoryEntry)
+		senders []common.Address
+	)
+	for i, tx := range block.Transactions() {
+		if i >= len(receipts) || receipts[i].Power == nil {
+			continue
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			continue
+		}
+		if _, ok := entries[from]; !ok {
+			senders = append(senders, from)
+		}
+		entries[from] = append(entries[from], rawdb.PowerHistoryEntry{TxHash: tx.Hash(), TxIndex: uint64(i), Usage: receipts[i].Power})
+	}
+	for _, from := range senders {
+		rawdb.WritePowerHistory(db, from, block.Hash(), block.NumberU64(), entries[from])
+	}
+}
+
+// deletePowerHistory removes the power accounting of the block's transactions
+// from the power history of their senders.
+func (bc *BlockChain) deletePowerHistory(db rawdb.DatabaseDeleter, block *types.Block) {
+	signer := types.MakeSigner(bc.chainConfig, block.Number())
+	for _, tx := range block.Transactions() {
+		if from, err := types.Sender(signer, tx); err == nil {
+			rawdb.DeletePowerHistory(db, from, block.Hash(), block.NumberU64())
+		}
+	}
+}
+
 // addFutureBlock checks if the block is within the max allowed window to get
 // accepted for future processing, and returns an error if the block is too far
 // ahead and was not added.
@@ -1199,7 +1348,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 			parent = bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
 		}
 
-		state, err := state.New(parent.Root(), bc.stateCache)
+		state, err := state.NewWithSnapshot(parent.Root(), bc.stateCache, bc.snaps)
 		if err != nil {
 			return it.index, events, coalescedLogs, err
 		}
@@ -1356,8 +1505,14 @@ func (bc *BlockChain) insertSidechain(block *types.Block, it *insertIterator) (i
 		hashes  []common.Hash
 		numbers []uint64
 	)
+	finalized := bc.CurrentFinalizedBlock()
 	parent := bc.GetHeader(it.previous().Hash(), it.previous().NumberU64())
 	for parent != nil && !bc.HasState(parent.Root) {
+		// Don't regenerate the state of a sidechain forking below the finalized block
+		if finalized != nil && parent.Number.Uint64() <= finalized.NumberU64() && rawdb.ReadCanonicalHash(bc.db, parent.Number.Uint64()) != parent.Hash() {
+			current := bc.CurrentBlock()
+			return it.index, nil, nil, bc.refuseDeepReorg(current, it.previous(), rawdb.FindCommonAncestor(bc.db, current.Header(), parent), finalized)
+		}
 		hashes = append(hashes, parent.Hash())
 		numbers = append(numbers, parent.Number.Uint64())
 
@@ -1420,6 +1575,8 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		// collectLogs collects the logs that were generated during the
 		// processing of the block that corresponds with the given hash.
 		// These logs are later announced as deleted or reborn
+		finalized = bc.CurrentFinalizedBlock()
+
 		collectLogs = func(hash common.Hash, removed bool) {
 			number := bc.hc.GetBlockNumber(hash)
 			if number == nil {
@@ -1439,6 +1596,11 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			}
 		}
 	)
+	if finalized != nil && newBlock.NumberU64() < finalized.NumberU64() {
+		return bc.refuseDeepReorg(oldBlock, newBlock, rawdb.FindCommonAncestor(bc.db, oldBlock.Header(), newBlock.Header()), finalized)
+	}
+	oldHead, newHead := oldBlock, newBlock
+
 	// Reduce the longer chain to the same number as the shorter one
 	if oldBlock.NumberU64() > newBlock.NumberU64() {
 		// Old chain is longer, gather all transactions and logs as deleted ones
@@ -1467,6 +1629,11 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			commonBlock = oldBlock
 			break
 		}
+		// The old chain is canonical, so any differing block at or below the
+		// finalized one means the reorg would drop it
+		if finalized != nil && oldBlock.NumberU64() <= finalized.NumberU64() {
+			return bc.refuseDeepReorg(oldHead, newHead, rawdb.FindCommonAncestor(bc.db, oldBlock.Header(), newBlock.Header()), finalized)
+		}
 		// Remove an old block as well as stash away a new block
 		oldChain = append(oldChain, oldBlock)
 		deletedTxs = append(deletedTxs, oldBlock.Transactions()...)
@@ -1514,6 +1681,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	for _, tx := range types.TxDifference(deletedTxs, addedTxs) {
 		rawdb.DeleteTxLookupEntry(batch, tx.Hash())
 	}
+	// The power history of the dropped blocks is not canonical any more either
+	for _, block := range oldChain {
+		bc.deletePowerHistory(batch, block)
+	}
 	batch.Write()
 
 	// If any logs need to be fired, do it now. In theory we could avoid creating
@@ -1539,6 +1710,22 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	return nil
 }
 
+// refuseDeepReorg reports and rejects a reorg that would drop the finalized
+// block, whatever the length of the new chain. The common ancestor of the two
+// chains may be nil if it could not be found.
+func (bc *BlockChain) refuseDeepReorg(oldHead, newHead *types.Block, ancestor *types.Header, finalized *types.Block) error {
+	var commonBlock *types.Block
+	if ancestor != nil {
+		commonBlock = bc.GetBlock(ancestor.Hash(), ancestor.Number.Uint64())
+	}
+	log.Error("Refused reorg below finalized block", "finalized", finalized.Number(), "finalizedhash", finalized.Hash(),
+		"oldnum", oldHead.Number(), "oldhash", oldHead.Hash(), "newnum", newHead.Number(), "newhash", newHead.Hash())
+	blockDeepReorgMeter.Mark(1)
+
+	go bc.deepReorgFeed.Send(DeepReorgEvent{Head: oldHead, Block: newHead, Ancestor: commonBlock, Finalized: finalized})
+	return ErrDeepReorg
+}
+
 // PostChainEvents iterates over the events generated by a chain insertion and
 // posts them into the event feed.
 // TODO: Should not expose PostChainEvents. The chain events should be posted in WriteBlock.
@@ -1574,6 +1761,149 @@ func (bc *BlockChain) update() {
 	}
 }
 
+// freeze periodically moves the canonical chain segments that became immutable
+// out of the key-value store into the freezer.
+func (bc *BlockChain) freeze(ancients ethdb.AncientStore) {
+	defer bc.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if err := bc.migrateAncients(ancients); err != nil {
+				log.Error("Failed to freeze ancient blocks", "err", err)
+			}
+			timer.Reset(freezerRecheckInterval)
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// migrateAncients appends a batch of canonical blocks deeper than the
+// immutability threshold to the freezer and deletes them from the key-value
+// store. The genesis block is kept in the key-value store too, identifying the
+// chain the freezer belongs to.
+//
+// The chain lock is only held to snapshot the canonical hashes of the batch and
+// to delete the frozen blocks, not while copying them into the freezer.
+func (bc *BlockChain) migrateAncients(ancients ethdb.AncientStore) error {
+	first, hashes, err := bc.ancientCandidates(ancients)
+	if len(hashes) == 0 {
+		return err
+	}
+	// Move the blocks into the freezer, stopping at the first failure but still
+	// finishing the migration of everything appended until then
+	start := time.Now()
+
+	var appended int
+	for appended < len(hashes) && !bc.getProcInterrupt() {
+		if aerr := bc.appendAncient(ancients, first+uint64(appended), hashes[appended]); aerr != nil {
+			err = aerr
+			break
+		}
+		appended++
+	}
+	if appended == 0 {
+		return err
+	}
+	if err := ancients.Sync(); err != nil {
+		return err
+	}
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	// The chain may have been rewound meanwhile, only delete the blocks that are
+	// still canonical and drop the rest from the freezer again
+	var frozen int
+	for frozen < appended && rawdb.ReadCanonicalHash(bc.db, first+uint64(frozen)) == hashes[frozen] {
+		frozen++
+	}
+	if frozen < appended {
+		if err := ancients.TruncateAncients(first + uint64(frozen)); err != nil {
+			return err
+		}
+		if frozen == 0 {
+			return err
+		}
+	}
+	batch := bc.db.NewBatch()
+	for i, hash := range hashes[:frozen] {
+		if number := first + uint64(i); number > 0 {
+			rawdb.DeleteBlockWithoutNumber(batch, hash, number)
+			rawdb.DeleteCanonicalHash(batch, number)
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Moved ancient blocks into the freezer", "blocks", frozen, "number", first+uint64(frozen)-1, "elapsed", common.PrettyDuration(time.Since(start)))
+	return err
+}
+
+// ancientCandidates returns the number and the canonical hashes of the next
+// batch of blocks to freeze, cut short before any missing canonical hash.
+func (bc *BlockChain) ancientCandidates(ancients ethdb.AncientStore) (uint64, []common.Hash, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	head := bc.CurrentBlock().NumberU64()
+	if head <= immutabilityThreshold {
+		return 0, nil, nil
+	}
+	limit := head - immutabilityThreshold
+
+	first, err := ancients.Ancients()
+	if err != nil {
+		return 0, nil, err
+	}
+	if first >= limit {
+		return first, nil, nil
+	}
+	if limit-first > freezerBatchLimit {
+		limit = first + freezerBatchLimit
+	}
+	hashes := make([]common.Hash, 0, limit-first)
+	for number := first; number < limit; number++ {
+		hash := rawdb.ReadCanonicalHash(bc.db, number)
+		if hash == (common.Hash{}) {
+			return first, hashes, fmt.Errorf("canonical hash missing, can't freeze block %d", number)
+		}
+		hashes = append(hashes, hash)
+	}
+	return first, hashes, nil
+}
+
+// appendAncient copies the block with the given number and hash into the
+// freezer.
+func (bc *BlockChain) appendAncient(ancients ethdb.AncientStore, number uint64, hash common.Hash) error {
+	header := rawdb.ReadHeaderRLP(bc.db, hash, number)
+	if len(header) == 0 {
+		return fmt.Errorf("block header missing, can't freeze block %d", number)
+	}
+	body := rawdb.ReadBodyRLP(bc.db, hash, number)
+	if len(body) == 0 {
+		return fmt.Errorf("block body missing, can't freeze block %d", number)
+	}
+	receipts := rawdb.ReadReceiptsRLP(bc.db, hash, number)
+	if len(receipts) == 0 {
+		return fmt.Errorf("block receipts missing, can't freeze block %d", number)
+	}
+	td := rawdb.ReadTdRLP(bc.db, hash, number)
+	if len(td) == 0 {
+		return fmt.Errorf("total difficulty missing, can't freeze block %d", number)
+	}
+	return ancients.AppendAncient(number, hash.Bytes(), header, body, receipts, td)
+}
+
 // BadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
 func (bc *BlockChain) BadBlocks() []*types.Block {
 	blocks := make([]*types.Block, 0, bc.badBlocks.Len())
@@ -1756,3 +2086,8 @@ func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Su
 func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
 }
+
+// SubscribeDeepReorgEvent registers a subscription of DeepReorgEvent.
+func (bc *BlockChain) SubscribeDeepReorgEvent(ch chan<- DeepReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.deepReorgFeed.Subscribe(ch))
+}
@@ -18,8 +18,10 @@ package core
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"math/rand"
+	"os"
 	"sync"
 	"testing"
 	"time"
@@ -1744,3 +1746,275 @@ func TestLowDiffLongChain(t *testing.T) {
 		header = chain.GetHeader(header.ParentHash, number-1)
 	}
 }
+
+// Tests that reorgs dropping the finalized block are refused on chains with a
+// finality depth, and that the finalized block survives restarts.
+func TestDeepReorgRefused(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Circum = &params.CircumConfig{FinalityDepth: 8}
+
+	var (
+		engine  = ethash.NewFaker()
+		db      = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: &config}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(&config, genesis, engine, db, 20, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	chain, err := NewBlockChain(db, nil, &config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	if finalized := chain.CurrentFinalizedBlock(); finalized == nil || finalized.Hash() != blocks[11].Hash() {
+		t.Fatalf("finalized block mismatch: have %v, want %d", finalized, blocks[11].NumberU64())
+	}
+	// A longer fork below the finalized block must be refused
+	events := make(chan DeepReorgEvent, 1)
+	sub := chain.SubscribeDeepReorgEvent(events)
+	defer sub.Unsubscribe()
+
+	deep, _ := GenerateChain(&config, blocks[9], engine, db, 16, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{2})
+	})
+	if _, err := chain.InsertChain(deep); err != ErrDeepReorg {
+		t.Fatalf("deep reorg error mismatch: have %v, want %v", err, ErrDeepReorg)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head moved to #%d by deep reorg", head.NumberU64())
+	}
+	select {
+	case ev := <-events:
+		if ev.Finalized.Hash() != blocks[11].Hash() || ev.Head.Hash() != blocks[len(blocks)-1].Hash() {
+			t.Fatalf("deep reorg event mismatch: finalized #%d, head #%d", ev.Finalized.NumberU64(), ev.Head.NumberU64())
+		}
+		if ev.Ancestor == nil || ev.Ancestor.Hash() != blocks[9].Hash() {
+			t.Fatalf("deep reorg ancestor mismatch: have %v, want #%d", ev.Ancestor, blocks[9].NumberU64())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no deep reorg event")
+	}
+	// A longer fork above the finalized block must be accepted
+	shallow, _ := GenerateChain(&config, blocks[14], engine, db, 10, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{3})
+	})
+	if n, err := chain.InsertChain(shallow); err != nil {
+		t.Fatalf("block %d: failed to insert shallow fork: %v", n, err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != shallow[len(shallow)-1].Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", head.NumberU64(), shallow[len(shallow)-1].NumberU64())
+	}
+	if finalized := chain.CurrentFinalizedBlock(); finalized.Hash() != shallow[1].Hash() {
+		t.Fatalf("finalized block mismatch: have #%d, want #%d", finalized.NumberU64(), shallow[1].NumberU64())
+	}
+	chain.Stop()
+
+	// The finalized block must be restored on restart, and rewound with the head
+	chain, err = NewBlockChain(db, nil, &config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if finalized := chain.CurrentFinalizedBlock(); finalized.Hash() != shallow[1].Hash() {
+		t.Fatalf("restored finalized block mismatch: have #%d, want #%d", finalized.NumberU64(), shallow[1].NumberU64())
+	}
+	if hash := rawdb.ReadHeadFinalizedBlockHash(db); hash != shallow[1].Hash() {
+		t.Fatalf("stored finalized block mismatch: have %x, want %x", hash, shallow[1].Hash())
+	}
+	chain.SetHead(10)
+	if finalized := chain.CurrentFinalizedBlock(); finalized.Hash() != blocks[1].Hash() {
+		t.Fatalf("rewound finalized block mismatch: have #%d, want #%d", finalized.NumberU64(), blocks[1].NumberU64())
+	}
+}
+
+// Tests that canonical blocks deeper than the immutability threshold are moved
+// into the freezer while staying readable along with their receipts and total
+// difficulty, and that rewinding the chain truncates the frozen blocks above
+// the new head.
+func TestMigrateAncients(t *testing.T) {
+	defer func(threshold uint64) { immutabilityThreshold = threshold }(immutabilityThreshold)
+	immutabilityThreshold = 16
+
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatalf("failed to create temporary freezer directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		gendb   = ethdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 64, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	kvdb := ethdb.NewMemDatabase()
+	db, err := rawdb.NewDatabaseWithFreezer(kvdb, dir, "")
+	if err != nil {
+		t.Fatalf("failed to create freezer database: %v", err)
+	}
+	defer db.Close()
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	tds := make([]*big.Int, len(blocks))
+	for i, block := range blocks {
+		tds[i] = chain.GetTd(block.Hash(), block.NumberU64())
+	}
+	ancients := db.(ethdb.AncientStore)
+	if err := chain.migrateAncients(ancients); err != nil {
+		t.Fatalf("failed to migrate ancients: %v", err)
+	}
+	frozen := uint64(len(blocks)) - immutabilityThreshold
+	if n, _ := ancients.Ancients(); n != frozen {
+		t.Fatalf("frozen block count mismatch: have %d, want %d", n, frozen)
+	}
+	// The frozen blocks must be gone from the key-value store, except for the
+	// genesis, but all blocks must be readable through the database
+	if hash := rawdb.ReadCanonicalHash(kvdb, 0); hash != genesis.Hash() {
+		t.Errorf("genesis hash mismatch in key-value store: have %x, want %x", hash, genesis.Hash())
+	}
+	check := func(blocks []*types.Block) {
+		for i, block := range blocks {
+			num, hash := block.NumberU64(), block.Hash()
+
+			if have := rawdb.ReadCanonicalHash(db, num); have != hash {
+				t.Errorf("block #%d [%x]: canonical hash mismatch: have %x", num, hash, have)
+			}
+			if have := rawdb.ReadBlock(db, hash, num); have == nil || have.Hash() != hash {
+				t.Errorf("block #%d [%x]: block mismatch: have %v", num, hash, have)
+			}
+			if have := rawdb.ReadReceipts(db, hash, num); types.DeriveSha(have) != types.DeriveSha(receipts[i]) {
+				t.Errorf("block #%d [%x]: receipts mismatch: have %v, want %v", num, hash, have, receipts[i])
+			}
+			if have := rawdb.ReadTd(db, hash, num); have == nil || have.Cmp(tds[i]) != 0 {
+				t.Errorf("block #%d [%x]: td mismatch: have %v, want %v", num, hash, have, tds[i])
+			}
+			if kept := rawdb.HasHeader(kvdb, hash, num); kept != (num >= frozen) {
+				t.Errorf("block #%d [%x]: key-value store presence mismatch: have %v, want %v", num, hash, kept, num >= frozen)
+			}
+		}
+	}
+	check(blocks)
+
+	// Rewinding the chain must truncate the freezer above the new head
+	if err := chain.SetHead(frozen / 2); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if n, _ := ancients.Ancients(); n != frozen/2+1 {
+		t.Fatalf("rewound frozen block count mismatch: have %d, want %d", n, frozen/2+1)
+	}
+	if head := chain.CurrentHeader(); head.Hash() != blocks[frozen/2-1].Hash() {
+		t.Fatalf("rewound head mismatch: have #%d, want #%d", head.Number, frozen/2)
+	}
+	if block := rawdb.ReadBlock(kvdb, genesis.Hash(), 0); block == nil {
+		t.Errorf("genesis missing from key-value store after rewind")
+	}
+	check(blocks[:frozen/2])
+
+	for _, block := range blocks[frozen/2:] {
+		num, hash := block.NumberU64(), block.Hash()
+		if have := rawdb.ReadCanonicalHash(db, num); have != (common.Hash{}) {
+			t.Errorf("block #%d [%x]: canonical hash not deleted: have %x", num, hash, have)
+		}
+		if rawdb.HasHeader(db, hash, num) {
+			t.Errorf("block #%d [%x]: header not deleted", num, hash)
+		}
+	}
+}
+
+// Tests that the power history of the blocks dropped by a rewind is deleted
+// and that fast synced blocks mark the power history unavailable.
+func TestPowerHistoryCleanup(t *testing.T) {
+	var (
+		gendb   = ethdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 8, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for _, block := range blocks {
+		if rawdb.ReadPowerHistory(db, address, block.Hash(), block.NumberU64()) == nil {
+			t.Fatalf("block #%d: power history missing", block.NumberU64())
+		}
+	}
+	chain.SetHead(4)
+	for _, block := range blocks {
+		history := rawdb.ReadPowerHistory(db, address, block.Hash(), block.NumberU64())
+		if block.NumberU64() <= 4 && history == nil {
+			t.Errorf("block #%d: retained power history missing", block.NumberU64())
+		}
+		if block.NumberU64() > 4 && history != nil {
+			t.Errorf("block #%d: rewound power history retained", block.NumberU64())
+		}
+	}
+	// Fast sync the same blocks and check the power history is marked unavailable
+	fastdb := ethdb.NewMemDatabase()
+	gspec.MustCommit(fastdb)
+
+	fast, err := NewBlockChain(fastdb, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create fast chain: %v", err)
+	}
+	defer fast.Stop()
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := fast.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := fast.InsertReceiptChain(blocks, receipts); err != nil {
+		t.Fatalf("failed to insert receipt %d: %v", n, err)
+	}
+	if tail := rawdb.ReadPowerHistoryTail(fastdb); tail != uint64(len(blocks))+1 {
+		t.Fatalf("power history tail mismatch: have %d, want %d", tail, len(blocks)+1)
+	}
+}
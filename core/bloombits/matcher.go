@@ -163,6 +163,17 @@ func (m *Matcher) Start(ctx context.Context, begin, end uint64, results chan uin
 	}
 	sink := m.run(begin, end, cap(results), session)
 
+	// Tear the session down if the context is cancelled before it's done
+	if ctx != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				session.fail(ctx.Err())
+				session.Close()
+			case <-session.quit:
+			}
+		}()
+	}
 	// Read the output from the result sink and deliver to the user
 	session.pend.Add(1)
 	go func() {
@@ -516,8 +527,9 @@ type MatcherSession struct {
 	quit   chan struct{} // Quit channel to request pipeline termination
 	kill   chan struct{} // Term channel to signal non-graceful forced shutdown
 
-	ctx context.Context // Context used by the light client to abort filtering
-	err atomic.Value    // Global error to track retrieval failures deep in the chain
+	ctx     context.Context // Context used to abort filtering, also by the light client
+	err     error           // Global error to track retrieval failures deep in the chain
+	errLock sync.Mutex      // Lock protecting the global error
 
 	pend sync.WaitGroup
 }
@@ -536,10 +548,20 @@ func (s *MatcherSession) Close() {
 
 // Error returns any failure encountered during the matching session.
 func (s *MatcherSession) Error() error {
-	if err := s.err.Load(); err != nil {
-		return err.(error)
+	s.errLock.Lock()
+	defer s.errLock.Unlock()
+
+	return s.err
+}
+
+// fail records the first failure encountered during the matching session.
+func (s *MatcherSession) fail(err error) {
+	s.errLock.Lock()
+	defer s.errLock.Unlock()
+
+	if s.err == nil {
+		s.err = err
 	}
-	return nil
 }
 
 // AllocateRetrieval assigns a bloom bit index to a client process that can either
@@ -641,7 +663,7 @@ func (s *MatcherSession) Multiplex(batch int, wait time.Duration, mux chan chan
 
 			result := <-request
 			if result.Error != nil {
-				s.err.Store(result.Error)
+				s.fail(result.Error)
 				s.Close()
 			}
 			s.DeliverSections(result.Bit, result.Sections, result.Bitsets)
@@ -91,8 +91,9 @@ type ChainIndexer struct {
 
 	throttling time.Duration // Disk throttling to prevent a heavy upgrade from hogging resources
 
-	log  log.Logger
-	lock sync.RWMutex
+	log      log.Logger
+	lock     sync.RWMutex
+	procLock sync.Mutex // Lock held while processing a section, excluding index rebuilds
 }
 
 // NewChainIndexer creates a new chain indexer to do background processing on
@@ -313,6 +314,7 @@ func (c *ChainIndexer) updateLoop() {
 
 		case <-c.update:
 			// Section headers completed (or rolled back), update the index
+			c.procLock.Lock()
 			c.lock.Lock()
 			if c.knownSections > c.storedSections {
 				// Periodically print an upgrade log message to the user
@@ -335,6 +337,7 @@ func (c *ChainIndexer) updateLoop() {
 				if err != nil {
 					select {
 					case <-c.ctx.Done():
+						c.procLock.Unlock()
 						<-c.quit <- nil
 						return
 					default:
@@ -372,6 +375,7 @@ func (c *ChainIndexer) updateLoop() {
 				})
 			}
 			c.lock.Unlock()
+			c.procLock.Unlock()
 		}
 	}
 }
@@ -441,6 +445,21 @@ func (c *ChainIndexer) AddChildIndexer(indexer *ChainIndexer) {
 	}
 }
 
+// Rebuild invalidates the indexed sections containing the blocks from the given
+// number on and reprocesses them up to the given chain head, as if the chain was
+// reorganised there. Any section being processed is waited for first and purge
+// is run before resuming, so it can delete the stale index data without racing
+// the indexer.
+func (c *ChainIndexer) Rebuild(number, head uint64, purge func() error) error {
+	c.procLock.Lock()
+	c.newHead(number-params.GenesisBlockNumber, true)
+	err := purge()
+	c.procLock.Unlock()
+
+	c.newHead(head-params.GenesisBlockNumber, false)
+	return err
+}
+
 // loadValidSections reads the number of valid sections from the index database
 // and caches is into the local state.
 func (c *ChainIndexer) loadValidSections() {
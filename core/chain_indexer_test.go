@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
@@ -237,3 +238,85 @@ func (b *testChainIndexBackend) Commit() error {
 	}
 	return nil
 }
+
+// rebuildTestBackend implements ChainIndexerBackend, holding up the first
+// processing of a single section until released.
+type rebuildTestBackend struct {
+	hold    uint64        // Section to hold up the processing of
+	held    chan struct{} // Closed when the held section started processing
+	release chan struct{} // Closed to let the held section finish
+	once    sync.Once
+}
+
+func (b *rebuildTestBackend) Reset(ctx context.Context, section uint64, prevHead common.Hash) error {
+	if section == b.hold {
+		b.once.Do(func() {
+			close(b.held)
+			<-b.release
+		})
+	}
+	return nil
+}
+
+func (b *rebuildTestBackend) Process(ctx context.Context, header *types.Header) error {
+	return nil
+}
+
+func (b *rebuildTestBackend) Commit() error {
+	return nil
+}
+
+// Tests that rebuilding an index waits for the section being processed and
+// purges the stale data while the sections from the rebuilt one on are
+// invalidated, reprocessing them afterwards.
+func TestChainIndexerRebuild(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+
+	var parent common.Hash
+	for i := uint64(0); i < 40; i++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(i), ParentHash: parent}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), i)
+		parent = header.Hash()
+	}
+	backend := &rebuildTestBackend{hold: 2, held: make(chan struct{}), release: make(chan struct{})}
+	indexer := NewChainIndexer(db, ethdb.NewTable(db, "i"), backend, 10, 0, 0, "rebuild")
+	defer indexer.Close()
+
+	indexer.newHead(39, false)
+	<-backend.held
+
+	// Rebuild from block 15 while section 2 is being processed
+	purged := make(chan uint64, 1)
+	done := make(chan error)
+	go func() {
+		done <- indexer.Rebuild(15, 39, func() error {
+			sections, _, _ := indexer.Sections()
+			purged <- sections
+			return nil
+		})
+	}()
+	select {
+	case <-purged:
+		t.Fatalf("index purged while a section was being processed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(backend.release)
+
+	if sections := <-purged; sections != 1 {
+		t.Fatalf("sections during purge mismatch: have %d, want %d", sections, 1)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("failed to rebuild index: %v", err)
+	}
+	for deadline := time.Now().Add(3 * time.Second); ; {
+		if sections, _, _ := indexer.Sections(); sections == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rebuilt sections not reprocessed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
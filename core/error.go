@@ -32,4 +32,8 @@ var (
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// ErrDeepReorg is returned if importing a block would reorg the chain below
+	// the finalized block.
+	ErrDeepReorg = errors.New("reorg below finalized block")
 )
@@ -49,3 +49,12 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// DeepReorgEvent is posted when a reorg is refused because its common ancestor
+// is below the finalized block.
+type DeepReorgEvent struct {
+	Head      *types.Block // Local head block that was kept
+	Block     *types.Block // Head block of the refused chain
+	Ancestor  *types.Block // Common ancestor of the two chains
+	Finalized *types.Block // Finalized block the reorg would have dropped
+}
@@ -16,11 +16,13 @@ var _ = (*genesisAccountMarshaling)(nil)
 
 func (g GenesisAccount) MarshalJSON() ([]byte, error) {
 	type GenesisAccount struct {
-		Code       hexutil.Bytes               `json:"code,omitempty"`
-		Storage    map[storageJSON]storageJSON `json:"storage,omitempty"`
-		Balance    *math.HexOrDecimal256       `json:"balance" gencodec:"required"`
-		Nonce      math.HexOrDecimal64         `json:"nonce,omitempty"`
-		PrivateKey hexutil.Bytes               `json:"secretKey,omitempty"`
+		Code        hexutil.Bytes               `json:"code,omitempty"`
+		Storage     map[storageJSON]storageJSON `json:"storage,omitempty"`
+		Balance     *math.HexOrDecimal256       `json:"balance" gencodec:"required"`
+		Nonce       math.HexOrDecimal64         `json:"nonce,omitempty"`
+		PrivateKey  hexutil.Bytes               `json:"secretKey,omitempty"`
+		Power       *math.HexOrDecimal256       `json:"power,omitempty"`
+		BlockNumber *math.HexOrDecimal256       `json:"blockNumber,omitempty"`
 	}
 	var enc GenesisAccount
 	enc.Code = g.Code
@@ -33,16 +35,20 @@ func (g GenesisAccount) MarshalJSON() ([]byte, error) {
 	enc.Balance = (*math.HexOrDecimal256)(g.Balance)
 	enc.Nonce = math.HexOrDecimal64(g.Nonce)
 	enc.PrivateKey = g.PrivateKey
+	enc.Power = (*math.HexOrDecimal256)(g.Power)
+	enc.BlockNumber = (*math.HexOrDecimal256)(g.BlockNumber)
 	return json.Marshal(&enc)
 }
 
 func (g *GenesisAccount) UnmarshalJSON(input []byte) error {
 	type GenesisAccount struct {
-		Code       *hexutil.Bytes              `json:"code,omitempty"`
-		Storage    map[storageJSON]storageJSON `json:"storage,omitempty"`
-		Balance    *math.HexOrDecimal256       `json:"balance" gencodec:"required"`
-		Nonce      *math.HexOrDecimal64        `json:"nonce,omitempty"`
-		PrivateKey *hexutil.Bytes              `json:"secretKey,omitempty"`
+		Code        *hexutil.Bytes              `json:"code,omitempty"`
+		Storage     map[storageJSON]storageJSON `json:"storage,omitempty"`
+		Balance     *math.HexOrDecimal256       `json:"balance" gencodec:"required"`
+		Nonce       *math.HexOrDecimal64        `json:"nonce,omitempty"`
+		PrivateKey  *hexutil.Bytes              `json:"secretKey,omitempty"`
+		Power       *math.HexOrDecimal256       `json:"power,omitempty"`
+		BlockNumber *math.HexOrDecimal256       `json:"blockNumber,omitempty"`
 	}
 	var dec GenesisAccount
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -67,5 +73,11 @@ func (g *GenesisAccount) UnmarshalJSON(input []byte) error {
 	if dec.PrivateKey != nil {
 		g.PrivateKey = *dec.PrivateKey
 	}
+	if dec.Power != nil {
+		g.Power = (*big.Int)(dec.Power)
+	}
+	if dec.BlockNumber != nil {
+		g.BlockNumber = (*big.Int)(dec.BlockNumber)
+	}
 	return nil
 }
@@ -40,6 +40,9 @@ import (
 func TestInitAccounts(t *testing.T) {
 	path := "/Users/rolong/etz/auc-mns/bin/init.data.00"
 	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		t.Skipf("initial accounts not found: %v", err)
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -510,4 +513,15 @@ func nonce2bytes(len int) []byte {
 		panic("[len2bytes] error code len")
 	}
 	return buf
-}
\ No newline at end of file
+}
+func byte2len(buf []byte) int {
+	return int(buf[0])*256*256 + int(buf[1])*256 + int(buf[2])
+}
+
+func myReader(r io.Reader, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
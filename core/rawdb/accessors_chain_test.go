@@ -18,7 +18,9 @@ package rawdb
 
 import (
 	"bytes"
+	"io/ioutil"
 	"math/big"
+	"os"
 	"testing"
 
 	"github.com/ether-ark/etherark/common"
@@ -236,6 +238,7 @@ func TestHeadStorage(t *testing.T) {
 	blockHead := types.NewBlockWithHeader(&types.Header{Extra: []byte("test block header")})
 	blockFull := types.NewBlockWithHeader(&types.Header{Extra: []byte("test block full")})
 	blockFast := types.NewBlockWithHeader(&types.Header{Extra: []byte("test block fast")})
+	blockFinal := types.NewBlockWithHeader(&types.Header{Extra: []byte("test block finalized")})
 
 	// Check that no head entries are in a pristine database
 	if entry := ReadHeadHeaderHash(db); entry != (common.Hash{}) {
@@ -247,10 +250,14 @@ func TestHeadStorage(t *testing.T) {
 	if entry := ReadHeadFastBlockHash(db); entry != (common.Hash{}) {
 		t.Fatalf("Non fast head block entry returned: %v", entry)
 	}
+	if entry := ReadHeadFinalizedBlockHash(db); entry != (common.Hash{}) {
+		t.Fatalf("Non finalized head block entry returned: %v", entry)
+	}
 	// Assign separate entries for the head header and block
 	WriteHeadHeaderHash(db, blockHead.Hash())
 	WriteHeadBlockHash(db, blockFull.Hash())
 	WriteHeadFastBlockHash(db, blockFast.Hash())
+	WriteHeadFinalizedBlockHash(db, blockFinal.Hash())
 
 	// Check that both heads are present, and different (i.e. two heads maintained)
 	if entry := ReadHeadHeaderHash(db); entry != blockHead.Hash() {
@@ -262,6 +269,9 @@ func TestHeadStorage(t *testing.T) {
 	if entry := ReadHeadFastBlockHash(db); entry != blockFast.Hash() {
 		t.Fatalf("Fast head block hash mismatch: have %v, want %v", entry, blockFast.Hash())
 	}
+	if entry := ReadHeadFinalizedBlockHash(db); entry != blockFinal.Hash() {
+		t.Fatalf("Finalized head block hash mismatch: have %v, want %v", entry, blockFinal.Hash())
+	}
 }
 
 // Tests that receipts associated with a single block can be stored and retrieved.
@@ -289,6 +299,7 @@ func TestBlockReceiptStorage(t *testing.T) {
 		TxHash:          common.BytesToHash([]byte{0x22, 0x22}),
 		ContractAddress: common.BytesToAddress([]byte{0x02, 0x22, 0x22}),
 		GasUsed:         222222,
+		Power:           &types.PowerUsage{Before: big.NewInt(3), Charged: big.NewInt(2), Refunded: big.NewInt(1), After: big.NewInt(2)},
 	}
 	receipts := []*types.Receipt{receipt1, receipt2}
 
@@ -310,6 +321,13 @@ func TestBlockReceiptStorage(t *testing.T) {
 				t.Fatalf("receipt #%d: receipt mismatch: have %v, want %v", i, rs[i], receipts[i])
 			}
 		}
+		if rs[0].Power != nil {
+			t.Fatalf("receipt #0: unexpected power usage: %+v", rs[0].Power)
+		}
+		if have, want := rs[1].Power, receipt2.Power; have == nil || have.Before.Cmp(want.Before) != 0 || have.After.Cmp(want.After) != 0 ||
+			have.Charged.Cmp(want.Charged) != 0 || have.Refunded.Cmp(want.Refunded) != 0 {
+			t.Fatalf("receipt #1: power usage mismatch: have %+v, want %+v", have, want)
+		}
 	}
 	// Delete the receipt slice and check purge
 	DeleteReceipts(db, hash, 0)
@@ -317,3 +335,86 @@ func TestBlockReceiptStorage(t *testing.T) {
 		t.Fatalf("deleted receipts returned: %v", rs)
 	}
 }
+
+// Tests that blocks moved into the freezer are transparently retrieved by the
+// accessors once deleted from the key-value store.
+func TestAncientStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabaseWithFreezer(ethdb.NewMemDatabase(), dir, "")
+	if err != nil {
+		t.Fatalf("failed to create database with ancient backend: %v", err)
+	}
+	defer db.Close()
+
+	// Write a chain of blocks and freeze the canonical ones
+	var (
+		ancients = db.(ethdb.AncientStore)
+		blocks   []*types.Block
+		parent   common.Hash
+	)
+	for i := 0; i < 3; i++ {
+		block := types.NewBlockWithHeader(&types.Header{
+			Number:     big.NewInt(int64(i)),
+			ParentHash: parent,
+			Extra:      []byte("test block"),
+		})
+		parent = block.Hash()
+		blocks = append(blocks, block)
+
+		WriteBlock(db, block)
+		WriteTd(db, block.Hash(), block.NumberU64(), big.NewInt(int64(i+1)))
+		WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{{CumulativeGasUsed: uint64(i)}})
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	}
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		err := ancients.AppendAncient(number, hash.Bytes(), ReadHeaderRLP(db, hash, number), ReadBodyRLP(db, hash, number),
+			ReadReceiptsRLP(db, hash, number), ReadTdRLP(db, hash, number))
+		if err != nil {
+			t.Fatalf("failed to freeze block %d: %v", number, err)
+		}
+		DeleteBlockWithoutNumber(db, hash, number)
+		DeleteCanonicalHash(db, number)
+	}
+	if err := ancients.AppendAncient(5, nil, nil, nil, nil, nil); err != errOutOrderInsertion {
+		t.Fatalf("out of order freeze error mismatch: have %v, want %v", err, errOutOrderInsertion)
+	}
+	// Everything must be served from the freezer now
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		if have := ReadCanonicalHash(db, number); have != hash {
+			t.Errorf("block %d: canonical hash mismatch: have %x, want %x", number, have, hash)
+		}
+		if !HasHeader(db, hash, number) || !HasBody(db, hash, number) || !HasReceipts(db, hash, number) {
+			t.Errorf("block %d: frozen data not found", number)
+		}
+		if entry := ReadBlock(db, hash, number); entry == nil || entry.Hash() != hash {
+			t.Errorf("block %d: frozen block mismatch: have %v", number, entry)
+		}
+		if td := ReadTd(db, hash, number); td == nil || td.Uint64() != number+1 {
+			t.Errorf("block %d: frozen td mismatch: have %v", number, td)
+		}
+		if rs := ReadReceipts(db, hash, number); len(rs) != 1 || rs[0].CumulativeGasUsed != number {
+			t.Errorf("block %d: frozen receipts mismatch: have %v", number, rs)
+		}
+		// Other blocks at the same height must not be served from the freezer
+		if HasHeader(db, common.Hash{0x01}, number) || ReadHeaderRLP(db, common.Hash{0x01}, number) != nil {
+			t.Errorf("block %d: non-canonical block served from the freezer", number)
+		}
+	}
+	// Truncation must drop the blocks above the limit
+	if err := ancients.TruncateAncients(1); err != nil {
+		t.Fatalf("failed to truncate ancients: %v", err)
+	}
+	if HasHeader(db, blocks[1].Hash(), 1) {
+		t.Errorf("truncated block still present")
+	}
+	if frozen, _ := ancients.Ancients(); frozen != 1 {
+		t.Errorf("frozen count mismatch: have %d, want %d", frozen, 1)
+	}
+}
@@ -17,8 +17,12 @@
 package rawdb
 
 import (
+	"bytes"
+	"encoding/binary"
+
 	"github.com/ether-ark/etherark/common"
 	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/ethdb"
 	"github.com/ether-ark/etherark/log"
 	"github.com/ether-ark/etherark/rlp"
 )
@@ -92,6 +96,264 @@ func ReadReceipt(db DatabaseReader, hash common.Hash) (*types.Receipt, common.Ha
 	return receipts[receiptIndex], blockHash, blockNumber, receiptIndex
 }
 
+// ReadPowerHistory retrieves the power accounting entries of all transactions
+// sent by address in the given block.
+func ReadPowerHistory(db DatabaseReader, address common.Address, hash common.Hash, number uint64) []PowerHistoryEntry {
+	data, _ := db.Get(powerHistoryKey(address, number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	var entries []PowerHistoryEntry
+	if err := rlp.DecodeBytes(data, &entries); err != nil {
+		log.Error("Invalid power history RLP", "address", address, "hash", hash, "err", err)
+		return nil
+	}
+	return entries
+}
+
+// WritePowerHistory stores the power accounting entries of all transactions sent
+// by address in the given block.
+func WritePowerHistory(db DatabaseWriter, address common.Address, hash common.Hash, number uint64, entries []PowerHistoryEntry) {
+	data, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		log.Crit("Failed to encode power history", "err", err)
+	}
+	if err := db.Put(powerHistoryKey(address, number, hash), data); err != nil {
+		log.Crit("Failed to store power history", "err", err)
+	}
+}
+
+// DeletePowerHistory removes the power accounting entries of address in the
+// given block.
+func DeletePowerHistory(db DatabaseDeleter, address common.Address, hash common.Hash, number uint64) {
+	db.Delete(powerHistoryKey(address, number, hash))
+}
+
+// ReadPowerHistoryTail retrieves the number of the first block the power history
+// is available from, the blocks below it having been fast synced.
+func ReadPowerHistoryTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(powerHistoryTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WritePowerHistoryTail stores the number of the first block the power history
+// is available from.
+func WritePowerHistoryTail(db DatabaseWriter, number uint64) {
+	if err := db.Put(powerHistoryTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store power history tail", "err", err)
+	}
+}
+
+// WriteAddressTxEntry stores the roles an address takes in a transaction, or
+// block reward, of the given block.
+func WriteAddressTxEntry(db DatabaseWriter, address common.Address, entry AddressTxEntry) {
+	if err := db.Put(addressTxKey(address, entry.BlockNumber, entry.TxIndex, entry.BlockHash), []byte{entry.Roles}); err != nil {
+		log.Crit("Failed to store address transaction entry", "err", err)
+	}
+}
+
+// IterateAddressTxEntries iterates over the transaction entries of address,
+// newest first, starting at the given block number and transaction index. The
+// entries of all the blocks ever indexed are returned, canonical or not. The
+// iteration stops when the callback returns false.
+func IterateAddressTxEntries(db ethdb.Iteratee, address common.Address, number uint64, index uint32, fn func(AddressTxEntry) bool) error {
+	prefix := append(append([]byte{}, addressTxPrefix...), address.Bytes()...)
+
+	it := db.NewIteratorWithStart(addressTxKey(address, number, index, common.Hash{}))
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		if len(key) != len(prefix)+8+4+common.HashLength || len(it.Value()) != 1 {
+			continue
+		}
+		entry := AddressTxEntry{
+			BlockNumber: ^binary.BigEndian.Uint64(key[len(prefix):]),
+			TxIndex:     ^binary.BigEndian.Uint32(key[len(prefix)+8:]),
+			BlockHash:   common.BytesToHash(key[len(prefix)+12:]),
+			Roles:       it.Value()[0],
+		}
+		if !fn(entry) {
+			break
+		}
+	}
+	return it.Error()
+}
+
+// WriteTokenTransfer stores a token transfer in the transfer history of the
+// token and in those of both parties.
+func WriteTokenTransfer(db DatabaseWriter, transfer TokenTransfer) {
+	data, err := rlp.EncodeToBytes(transfer)
+	if err != nil {
+		log.Crit("Failed to encode token transfer", "err", err)
+	}
+	keys := [][]byte{
+		tokenTransferKey(tokenTransferPrefix, transfer.Token, transfer.BlockNumber, transfer.LogIndex, transfer.BlockHash),
+		tokenTransferKey(holderTransferPrefix, transfer.From, transfer.BlockNumber, transfer.LogIndex, transfer.BlockHash),
+	}
+	if transfer.To != transfer.From {
+		keys = append(keys, tokenTransferKey(holderTransferPrefix, transfer.To, transfer.BlockNumber, transfer.LogIndex, transfer.BlockHash))
+	}
+	for _, key := range keys {
+		if err := db.Put(key, data); err != nil {
+			log.Crit("Failed to store token transfer", "err", err)
+		}
+	}
+}
+
+// IterateTokenTransfers iterates over the transfers of a token, newest first,
+// starting at the given block number and log index. The transfers of all the
+// blocks ever indexed are returned, canonical or not. The iteration stops when
+// the callback returns false.
+func IterateTokenTransfers(db ethdb.Iteratee, token common.Address, number uint64, index uint32, fn func(TokenTransfer) bool) error {
+	return iterateTokenTransfers(db, tokenTransferPrefix, token, number, index, fn)
+}
+
+// IterateHolderTransfers iterates over the token transfers an address took part
+// in, newest first, starting at the given block number and log index. The
+// transfers of all the blocks ever indexed are returned, canonical or not. The
+// iteration stops when the callback returns false.
+func IterateHolderTransfers(db ethdb.Iteratee, holder common.Address, number uint64, index uint32, fn func(TokenTransfer) bool) error {
+	return iterateTokenTransfers(db, holderTransferPrefix, holder, number, index, fn)
+}
+
+// iterateTokenTransfers iterates over one of the token transfer tables.
+func iterateTokenTransfers(db ethdb.Iteratee, table []byte, address common.Address, number uint64, index uint32, fn func(TokenTransfer) bool) error {
+	prefix := append(append([]byte{}, table...), address.Bytes()...)
+
+	it := db.NewIteratorWithStart(tokenTransferKey(table, address, number, index, common.Hash{}))
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		if len(key) != len(prefix)+8+4+common.HashLength {
+			continue
+		}
+		var transfer TokenTransfer
+		if err := rlp.DecodeBytes(it.Value(), &transfer); err != nil {
+			log.Error("Invalid token transfer RLP", "key", common.Bytes2Hex(key), "err", err)
+			continue
+		}
+		transfer.BlockNumber = ^binary.BigEndian.Uint64(key[len(prefix):])
+		transfer.LogIndex = ^binary.BigEndian.Uint32(key[len(prefix)+8:])
+		transfer.BlockHash = common.BytesToHash(key[len(prefix)+12:])
+
+		if !fn(transfer) {
+			break
+		}
+	}
+	return it.Error()
+}
+
+// DeleteTokenTransfers removes the token transfers of all the blocks, canonical
+// or not, from the given number on, returning the number of entries deleted.
+func DeleteTokenTransfers(db ethdb.Database, number uint64) (int, error) {
+	var (
+		batch   = db.NewBatch()
+		deleted int
+	)
+	for _, table := range [][]byte{tokenTransferPrefix, holderTransferPrefix} {
+		it := db.NewIteratorWithPrefix(table)
+		for it.Next() {
+			key := it.Key()
+			if len(key) != len(table)+common.AddressLength+8+4+common.HashLength {
+				continue
+			}
+			if ^binary.BigEndian.Uint64(key[len(table)+common.AddressLength:]) < number {
+				continue
+			}
+			if err := batch.Delete(key); err != nil {
+				it.Release()
+				return deleted, err
+			}
+			deleted++
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return deleted, err
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, batch.Write()
+}
+
+// ReadInternalTransfers retrieves the internal value transfers made by the
+// transactions of a block, and whether the block was indexed at all.
+func ReadInternalTransfers(db DatabaseReader, hash common.Hash, number uint64) ([]InternalTransfer, bool) {
+	data, _ := db.Get(internalTxKey(number, hash))
+	if len(data) == 0 {
+		return nil, false
+	}
+	var transfers []InternalTransfer
+	if err := rlp.DecodeBytes(data, &transfers); err != nil {
+		log.Error("Invalid internal transfers RLP", "hash", hash, "err", err)
+		return nil, false
+	}
+	return transfers, true
+}
+
+// WriteInternalTransfers stores the internal value transfers made by the
+// transactions of a block, marking the block indexed even if there are none.
+func WriteInternalTransfers(db DatabaseWriter, hash common.Hash, number uint64, transfers []InternalTransfer) {
+	data, err := rlp.EncodeToBytes(transfers)
+	if err != nil {
+		log.Crit("Failed to encode internal transfers", "err", err)
+	}
+	if err := db.Put(internalTxKey(number, hash), data); err != nil {
+		log.Crit("Failed to store internal transfers", "err", err)
+	}
+}
+
+// DeleteInternalTransfers removes the internal value transfers of all the blocks,
+// canonical or not, below the given number, returning the number of blocks pruned.
+func DeleteInternalTransfers(db ethdb.Database, number uint64) (int, error) {
+	it := db.NewIteratorWithPrefix(internalTxPrefix)
+	defer it.Release()
+
+	var (
+		batch  = db.NewBatch()
+		pruned int
+	)
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(internalTxPrefix)+8+common.HashLength {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(internalTxPrefix):]) >= number {
+			break
+		}
+		if err := batch.Delete(key); err != nil {
+			return pruned, err
+		}
+		pruned++
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return pruned, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return pruned, err
+	}
+	return pruned, batch.Write()
+}
+
 // ReadBloomBits retrieves the compressed bloom bit vector belonging to the given
 // section and bit index from the.
 func ReadBloomBits(db DatabaseReader, bit uint, section uint64, head common.Hash) ([]byte, error) {
@@ -105,3 +367,28 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// ReadSupplyRecord retrieves the coin supply accounting of a block.
+func ReadSupplyRecord(db DatabaseReader, hash common.Hash, number uint64) *SupplyRecord {
+	data, _ := db.Get(supplyKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	record := new(SupplyRecord)
+	if err := rlp.DecodeBytes(data, record); err != nil {
+		log.Error("Invalid supply record RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return record
+}
+
+// WriteSupplyRecord stores the coin supply accounting of a block.
+func WriteSupplyRecord(db DatabaseWriter, hash common.Hash, number uint64, record *SupplyRecord) {
+	data, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		log.Crit("Failed to encode supply record", "err", err)
+	}
+	if err := db.Put(supplyKey(number, hash), data); err != nil {
+		log.Crit("Failed to store supply record", "err", err)
+	}
+}
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ether-ark/etherark/common"
@@ -66,3 +67,193 @@ func TestLookupStorage(t *testing.T) {
 		}
 	}
 }
+
+// Tests that per-account power history entries can be stored and retrieved.
+func TestPowerHistoryStorage(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	addr := common.BytesToAddress([]byte{0x11})
+	hash := common.BytesToHash([]byte{0x22})
+	entries := []PowerHistoryEntry{
+		{TxHash: common.BytesToHash([]byte{0x01}), TxIndex: 0, Usage: &types.PowerUsage{Before: big.NewInt(100), Charged: big.NewInt(30), Refunded: big.NewInt(10), After: big.NewInt(80)}},
+		{TxHash: common.BytesToHash([]byte{0x02}), TxIndex: 3, Usage: &types.PowerUsage{Before: big.NewInt(80), Charged: big.NewInt(80), Refunded: big.NewInt(0), After: big.NewInt(0)}},
+	}
+	if have := ReadPowerHistory(db, addr, hash, 314); have != nil {
+		t.Fatalf("non existent power history returned: %v", have)
+	}
+	WritePowerHistory(db, addr, hash, 314, entries)
+
+	have := ReadPowerHistory(db, addr, hash, 314)
+	if len(have) != len(entries) {
+		t.Fatalf("power history length mismatch: have %d, want %d", len(have), len(entries))
+	}
+	for i, entry := range have {
+		want := entries[i]
+		if entry.TxHash != want.TxHash || entry.TxIndex != want.TxIndex {
+			t.Fatalf("entry #%d: position mismatch: have %x/%d, want %x/%d", i, entry.TxHash, entry.TxIndex, want.TxHash, want.TxIndex)
+		}
+		if entry.Usage.Before.Cmp(want.Usage.Before) != 0 || entry.Usage.Charged.Cmp(want.Usage.Charged) != 0 ||
+			entry.Usage.Refunded.Cmp(want.Usage.Refunded) != 0 || entry.Usage.After.Cmp(want.Usage.After) != 0 {
+			t.Fatalf("entry #%d: usage mismatch: have %+v, want %+v", i, entry.Usage, want.Usage)
+		}
+	}
+	if other := ReadPowerHistory(db, common.BytesToAddress([]byte{0x33}), hash, 314); other != nil {
+		t.Fatalf("power history returned for unrelated account: %v", other)
+	}
+	DeletePowerHistory(db, addr, hash, 314)
+	if have := ReadPowerHistory(db, addr, hash, 314); have != nil {
+		t.Fatalf("deleted power history returned: %v", have)
+	}
+	if tail := ReadPowerHistoryTail(db); tail != 0 {
+		t.Fatalf("non existent power history tail returned: %d", tail)
+	}
+	WritePowerHistoryTail(db, 1024)
+	if tail := ReadPowerHistoryTail(db); tail != 1024 {
+		t.Fatalf("power history tail mismatch: have %d, want %d", tail, 1024)
+	}
+}
+
+// Tests that address transaction entries are iterated newest first, starting at
+// the requested position and without leaking into other addresses.
+func TestAddressTxEntries(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	var (
+		addr  = common.BytesToAddress([]byte{0x01})
+		other = common.BytesToAddress([]byte{0x02})
+	)
+	entries := []AddressTxEntry{
+		{BlockNumber: 10, BlockHash: common.Hash{0x10}, TxIndex: AddressTxReward, Roles: AddressTxCoinbase},
+		{BlockNumber: 10, BlockHash: common.Hash{0x10}, TxIndex: 3, Roles: AddressTxFrom | AddressTxTo},
+		{BlockNumber: 10, BlockHash: common.Hash{0x10}, TxIndex: 0, Roles: AddressTxCreate},
+		{BlockNumber: 2, BlockHash: common.Hash{0x02}, TxIndex: 1, Roles: AddressTxMasternode},
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		WriteAddressTxEntry(db, addr, entries[i])
+	}
+	WriteAddressTxEntry(db, other, AddressTxEntry{BlockNumber: 5, BlockHash: common.Hash{0x05}, Roles: AddressTxFrom})
+
+	collect := func(number uint64, index uint32) []AddressTxEntry {
+		var have []AddressTxEntry
+		if err := IterateAddressTxEntries(db, addr, number, index, func(entry AddressTxEntry) bool {
+			have = append(have, entry)
+			return true
+		}); err != nil {
+			t.Fatalf("failed to iterate entries: %v", err)
+		}
+		return have
+	}
+	if have := collect(100, AddressTxReward); !reflect.DeepEqual(have, entries) {
+		t.Fatalf("entries mismatch: have %v, want %v", have, entries)
+	}
+	if have := collect(10, 2); !reflect.DeepEqual(have, entries[2:]) {
+		t.Fatalf("resumed entries mismatch: have %v, want %v", have, entries[2:])
+	}
+	if have := collect(1, AddressTxReward); len(have) != 0 {
+		t.Fatalf("entries past the oldest one returned: %v", have)
+	}
+}
+
+// Tests that the internal transfers of blocks can be stored, retrieved and
+// pruned by age.
+func TestInternalTransfersStorage(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	transfers := []InternalTransfer{
+		{TxIndex: 0, Kind: InternalTransferCall, From: common.Address{0x01}, To: common.Address{0x02}, Value: big.NewInt(1)},
+		{TxIndex: 3, Kind: InternalTransferSelfDestruct, From: common.Address{0x03}, To: common.Address{0x04}, Value: big.NewInt(2)},
+	}
+	for number := uint64(1); number <= 4; number++ {
+		WriteInternalTransfers(db, common.Hash{byte(number)}, number, transfers)
+	}
+	WriteInternalTransfers(db, common.Hash{0xff}, 5, nil)
+
+	if have, ok := ReadInternalTransfers(db, common.Hash{0x02}, 2); !ok || !reflect.DeepEqual(have, transfers) {
+		t.Fatalf("transfers mismatch: have %v, want %v", have, transfers)
+	}
+	if have, ok := ReadInternalTransfers(db, common.Hash{0xff}, 5); !ok || len(have) != 0 {
+		t.Fatalf("empty block not indexed: have %v, indexed %v", have, ok)
+	}
+	if _, ok := ReadInternalTransfers(db, common.Hash{0xee}, 6); ok {
+		t.Fatalf("unknown block indexed")
+	}
+	if pruned, err := DeleteInternalTransfers(db, 3); err != nil || pruned != 2 {
+		t.Fatalf("pruning mismatch: have %d, %v, want 2", pruned, err)
+	}
+	for number := uint64(1); number <= 4; number++ {
+		if _, ok := ReadInternalTransfers(db, common.Hash{byte(number)}, number); ok != (number >= 3) {
+			t.Errorf("block #%d: indexed %v after pruning", number, ok)
+		}
+	}
+}
+
+// Tests that token transfers are iterated newest first per token and per holder,
+// and that iteration can be resumed at any transfer.
+func TestTokenTransferStorage(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	var (
+		token  = common.Address{0x7e}
+		holder = common.Address{0xaa}
+	)
+	transfers := []TokenTransfer{
+		{BlockNumber: 9, BlockHash: common.Hash{0x09}, LogIndex: 4, TxIndex: 2, Kind: TokenTransferERC721, Token: token, From: holder, To: holder, Value: big.NewInt(7)},
+		{BlockNumber: 9, BlockHash: common.Hash{0x09}, LogIndex: 1, TxIndex: 0, Kind: TokenTransferERC20, Token: token, From: common.Address{0x01}, To: holder, Value: big.NewInt(5)},
+		{BlockNumber: 3, BlockHash: common.Hash{0x03}, LogIndex: 0, TxIndex: 0, Kind: TokenTransferERC20, Token: token, From: holder, To: common.Address{0x02}, Value: big.NewInt(1)},
+	}
+	for _, transfer := range transfers {
+		WriteTokenTransfer(db, transfer)
+	}
+	WriteTokenTransfer(db, TokenTransfer{BlockNumber: 5, BlockHash: common.Hash{0x05}, Kind: TokenTransferERC20, Token: common.Address{0x7f}, From: common.Address{0x01}, To: common.Address{0x02}, Value: big.NewInt(1)})
+
+	collect := func(iterate func(ethdb.Iteratee, common.Address, uint64, uint32, func(TokenTransfer) bool) error, address common.Address, number uint64, index uint32) []TokenTransfer {
+		var have []TokenTransfer
+		if err := iterate(db, address, number, index, func(transfer TokenTransfer) bool {
+			have = append(have, transfer)
+			return true
+		}); err != nil {
+			t.Fatalf("failed to iterate transfers: %v", err)
+		}
+		return have
+	}
+	if have := collect(IterateTokenTransfers, token, 10, 0xffffffff); !reflect.DeepEqual(have, transfers) {
+		t.Fatalf("token transfers mismatch: have %v, want %v", have, transfers)
+	}
+	if have := collect(IterateHolderTransfers, holder, 10, 0xffffffff); !reflect.DeepEqual(have, transfers) {
+		t.Fatalf("holder transfers mismatch: have %v, want %v", have, transfers)
+	}
+	if have := collect(IterateHolderTransfers, holder, 9, 3); !reflect.DeepEqual(have, transfers[1:]) {
+		t.Fatalf("resumed transfers mismatch: have %v, want %v", have, transfers[1:])
+	}
+	if have := collect(IterateHolderTransfers, common.Address{0x02}, 4, 0); !reflect.DeepEqual(have, transfers[2:]) {
+		t.Fatalf("recipient transfers mismatch: have %v, want %v", have, transfers[2:])
+	}
+	// Deleting the transfers from a block on must leave the older ones only
+	if deleted, err := DeleteTokenTransfers(db, 5); err != nil || deleted != 8 {
+		t.Fatalf("deleted transfers mismatch: have %d/%v, want 8/nil", deleted, err)
+	}
+	if have := collect(IterateTokenTransfers, token, 10, 0xffffffff); !reflect.DeepEqual(have, transfers[2:]) {
+		t.Fatalf("remaining token transfers mismatch: have %v, want %v", have, transfers[2:])
+	}
+	if have := collect(IterateHolderTransfers, holder, 10, 0xffffffff); !reflect.DeepEqual(have, transfers[2:]) {
+		t.Fatalf("remaining holder transfers mismatch: have %v, want %v", have, transfers[2:])
+	}
+	if have := collect(IterateTokenTransfers, common.Address{0x7f}, 10, 0xffffffff); len(have) != 0 {
+		t.Fatalf("remaining transfers of deleted block: %v", have)
+	}
+}
+
+// Tests that the coin supply accounting of blocks can be stored and retrieved.
+func TestSupplyRecordStorage(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	record := &SupplyRecord{Rewards: big.NewInt(2), Issued: big.NewInt(1000), Tracked: true, Locked: big.NewInt(300), Burned: big.NewInt(4)}
+	WriteSupplyRecord(db, common.Hash{0x01}, 7, record)
+
+	if have := ReadSupplyRecord(db, common.Hash{0x01}, 7); !reflect.DeepEqual(have, record) {
+		t.Fatalf("record mismatch: have %v, want %v", have, record)
+	}
+	if have := ReadSupplyRecord(db, common.Hash{0x02}, 7); have != nil {
+		t.Fatalf("record of unknown block returned: %v", have)
+	}
+}
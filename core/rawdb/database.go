@@ -0,0 +1,253 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/log"
+	"github.com/olekukonko/tablewriter"
+)
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	ethdb.Database
+	*freezer
+}
+
+// Close implements ethdb.Database, closing both the fast key-value store as
+// well as the slow ancient tables.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving immutable chain segments into cold
+// storage at the given path.
+func NewDatabaseWithFreezer(db ethdb.Database, freezer string, namespace string) (ethdb.Database, error) {
+	frdb, err := newFreezer(freezer, namespace)
+	if err != nil {
+		return nil, err
+	}
+	// Since the freezer can be stored separately from the key-value store, make
+	// sure the two belong to the same chain: a frozen genesis must match the
+	// genesis of the key-value store, if it has one.
+	if frozen, _ := frdb.Ancients(); frozen > 0 {
+		if kvgenesis, _ := db.Get(headerHashKey(0)); len(kvgenesis) > 0 {
+			if frgenesis, _ := frdb.Ancient(freezerHashTable, 0); common.BytesToHash(frgenesis) != common.BytesToHash(kvgenesis) {
+				frdb.Close()
+				return nil, fmt.Errorf("genesis mismatch: %#x (leveldb) != %#x (ancients)", kvgenesis, frgenesis)
+			}
+		}
+	}
+	return &freezerdb{
+		Database: db,
+		freezer:  frdb,
+	}, nil
+}
+
+// KeyValueStore returns the key-value store backing a database, stripping the
+// freezer if one is attached.
+func KeyValueStore(db ethdb.Database) ethdb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
+
+// inspectStat accumulates the number and the total size of a category of
+// database entries.
+type inspectStat struct {
+	count int
+	size  common.StorageSize
+}
+
+func (s *inspectStat) add(size common.StorageSize) {
+	s.count++
+	s.size += size
+}
+
+// InspectDatabase traverses the entire database and prints the size of all the
+// different categories of data, as identified by the key prefixes of the schema.
+func InspectDatabase(db ethdb.Database) error {
+	it := db.NewIterator()
+	defer it.Release()
+
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		total  inspectStat
+
+		headers, tds, hashes, numbers       inspectStat
+		bodies, receipts, lookups, powers   inspectStat
+		addressTxs, bloomBits, tries        inspectStat
+		preimages, internalTxs              inspectStat
+		tokenTransfers, supplies            inspectStat
+		snapAccounts, snapStorage, configs  inspectStat
+		metadata, indexes, light, unmatched inspectStat
+	)
+	metaKeys := [][]byte{
+		databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey,
+		headFinalizedBlockKey, fastTrieProgressKey, snapshotRootKey, snapshotGeneratorKey,
+	}
+	lightPrefixes := [][]byte{[]byte("cht-"), []byte("chtIndex-"), []byte("blt-"), []byte("bltIndex-")}
+
+	hasPrefix := func(key []byte, prefix []byte, length int) bool {
+		return bytes.HasPrefix(key, prefix) && len(key) == length
+	}
+	for it.Next() {
+		var (
+			key  = it.Key()
+			size = common.StorageSize(len(key) + len(it.Value()))
+		)
+		total.add(size)
+
+		switch {
+		case hasPrefix(key, headerPrefix, len(headerPrefix)+8+common.HashLength):
+			headers.add(size)
+		case hasPrefix(key, headerPrefix, len(headerPrefix)+8+common.HashLength+len(headerTDSuffix)) && bytes.HasSuffix(key, headerTDSuffix):
+			tds.add(size)
+		case hasPrefix(key, headerPrefix, len(headerPrefix)+8+len(headerHashSuffix)) && bytes.HasSuffix(key, headerHashSuffix):
+			hashes.add(size)
+		case hasPrefix(key, headerNumberPrefix, len(headerNumberPrefix)+common.HashLength):
+			numbers.add(size)
+		case hasPrefix(key, blockBodyPrefix, len(blockBodyPrefix)+8+common.HashLength):
+			bodies.add(size)
+		case hasPrefix(key, blockReceiptsPrefix, len(blockReceiptsPrefix)+8+common.HashLength):
+			receipts.add(size)
+		case hasPrefix(key, txLookupPrefix, len(txLookupPrefix)+common.HashLength):
+			lookups.add(size)
+		case hasPrefix(key, powerHistoryPrefix, len(powerHistoryPrefix)+common.AddressLength+8+common.HashLength):
+			powers.add(size)
+		case hasPrefix(key, addressTxPrefix, len(addressTxPrefix)+common.AddressLength+8+4+common.HashLength):
+			addressTxs.add(size)
+		case hasPrefix(key, internalTxPrefix, len(internalTxPrefix)+8+common.HashLength):
+			internalTxs.add(size)
+		case hasPrefix(key, tokenTransferPrefix, len(tokenTransferPrefix)+common.AddressLength+8+4+common.HashLength):
+			tokenTransfers.add(size)
+		case hasPrefix(key, holderTransferPrefix, len(holderTransferPrefix)+common.AddressLength+8+4+common.HashLength):
+			tokenTransfers.add(size)
+		case hasPrefix(key, supplyPrefix, len(supplyPrefix)+8+common.HashLength):
+			supplies.add(size)
+		case hasPrefix(key, bloomBitsPrefix, len(bloomBitsPrefix)+2+8+common.HashLength):
+			bloomBits.add(size)
+		case hasPrefix(key, SnapshotAccountPrefix, len(SnapshotAccountPrefix)+common.HashLength):
+			snapAccounts.add(size)
+		case hasPrefix(key, SnapshotStoragePrefix, len(SnapshotStoragePrefix)+2*common.HashLength):
+			snapStorage.add(size)
+		case hasPrefix(key, preimagePrefix, len(preimagePrefix)+common.HashLength):
+			preimages.add(size)
+		case hasPrefix(key, configPrefix, len(configPrefix)+common.HashLength):
+			configs.add(size)
+		case len(key) == common.HashLength:
+			tries.add(size)
+		case bytes.HasPrefix(key, BloomBitsIndexPrefix) || bytes.HasPrefix(key, AddressTxIndexPrefix) || bytes.HasPrefix(key, TokenIndexPrefix) || bytes.HasPrefix(key, SupplyIndexPrefix):
+			indexes.add(size)
+		default:
+			matched := false
+			for _, meta := range metaKeys {
+				if bytes.Equal(key, meta) {
+					metadata.add(size)
+					matched = true
+					break
+				}
+			}
+			for _, prefix := range lightPrefixes {
+				if !matched && bytes.HasPrefix(key, prefix) {
+					light.add(size)
+					matched = true
+				}
+			}
+			if !matched {
+				unmatched.add(size)
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "count", total.count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	stats := [][]string{
+		{"Key-Value store", "Headers", headers.size.String(), fmt.Sprint(headers.count)},
+		{"Key-Value store", "Total difficulties", tds.size.String(), fmt.Sprint(tds.count)},
+		{"Key-Value store", "Canonical hashes", hashes.size.String(), fmt.Sprint(hashes.count)},
+		{"Key-Value store", "Header number index", numbers.size.String(), fmt.Sprint(numbers.count)},
+		{"Key-Value store", "Bodies", bodies.size.String(), fmt.Sprint(bodies.count)},
+		{"Key-Value store", "Receipts", receipts.size.String(), fmt.Sprint(receipts.count)},
+		{"Key-Value store", "Transaction lookups", lookups.size.String(), fmt.Sprint(lookups.count)},
+		{"Key-Value store", "Power history", powers.size.String(), fmt.Sprint(powers.count)},
+		{"Key-Value store", "Address transactions", addressTxs.size.String(), fmt.Sprint(addressTxs.count)},
+		{"Key-Value store", "Internal transfers", internalTxs.size.String(), fmt.Sprint(internalTxs.count)},
+		{"Key-Value store", "Token transfers", tokenTransfers.size.String(), fmt.Sprint(tokenTransfers.count)},
+		{"Key-Value store", "Coin supply", supplies.size.String(), fmt.Sprint(supplies.count)},
+		{"Key-Value store", "Bloom bits", bloomBits.size.String(), fmt.Sprint(bloomBits.count)},
+		{"Key-Value store", "Trie nodes and contract codes", tries.size.String(), fmt.Sprint(tries.count)},
+		{"Key-Value store", "Trie preimages", preimages.size.String(), fmt.Sprint(preimages.count)},
+		{"Key-Value store", "Snapshot accounts", snapAccounts.size.String(), fmt.Sprint(snapAccounts.count)},
+		{"Key-Value store", "Snapshot storage", snapStorage.size.String(), fmt.Sprint(snapStorage.count)},
+		{"Key-Value store", "Chain configs", configs.size.String(), fmt.Sprint(configs.count)},
+		{"Key-Value store", "Chain metadata", metadata.size.String(), fmt.Sprint(metadata.count)},
+		{"Key-Value store", "Chain indexes", indexes.size.String(), fmt.Sprint(indexes.count)},
+		{"Key-Value store", "Light client tables", light.size.String(), fmt.Sprint(light.count)},
+		{"Key-Value store", "Unaccounted", unmatched.size.String(), fmt.Sprint(unmatched.count)},
+	}
+	// Add the ancient tables if the database has a freezer
+	ancientTotal := common.StorageSize(0)
+	if ancients, ok := db.(ethdb.AncientStore); ok {
+		frozen, err := ancients.Ancients()
+		if err != nil {
+			return err
+		}
+		for _, table := range []struct {
+			kind string
+			name string
+		}{
+			{freezerHeaderTable, "Headers"},
+			{freezerBodiesTable, "Bodies"},
+			{freezerReceiptTable, "Receipts"},
+			{freezerDifficultyTable, "Total difficulties"},
+			{freezerHashTable, "Canonical hashes"},
+		} {
+			size, err := ancients.AncientSize(table.kind)
+			if err != nil {
+				return err
+			}
+			ancientTotal += common.StorageSize(size)
+			stats = append(stats, []string{"Ancient store", table.name, common.StorageSize(size).String(), fmt.Sprint(frozen)})
+		}
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoFormatHeaders(false) // Keep the sizes in the footer intact
+	table.SetHeader([]string{"Database", "Category", "Size", "Items"})
+	table.SetFooter([]string{"", "Total", (total.size + ancientTotal).String(), fmt.Sprint(total.count)})
+	table.AppendBulk(stats)
+	table.Render()
+
+	log.Info("Inspected database", "count", total.count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
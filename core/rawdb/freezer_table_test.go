@@ -0,0 +1,170 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ether-ark/etherark/metrics"
+)
+
+// getChunk returns a chunk of data of the given size, filled with b.
+func getChunk(size int, b int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(b)
+	}
+	return data
+}
+
+// Tests that items can be appended, retrieved and survive a reopen, with and
+// without compression.
+func TestFreezerBasics(t *testing.T) {
+	for _, noCompression := range []bool{true, false} {
+		dir, err := ioutil.TempDir("", "freezer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		f, err := newTable(dir, "test", metrics.NilMeter{}, metrics.NilMeter{}, noCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 255; i++ {
+			if err := f.Append(uint64(i), getChunk(15+i, i)); err != nil {
+				t.Fatalf("failed to append item %d: %v", i, err)
+			}
+		}
+		if err := f.Append(300, getChunk(15, 0)); err != errOutOrderInsertion {
+			t.Fatalf("out of order append error mismatch: have %v, want %v", err, errOutOrderInsertion)
+		}
+		f.Close()
+
+		if f, err = newTable(dir, "test", metrics.NilMeter{}, metrics.NilMeter{}, noCompression); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 255; i++ {
+			blob, err := f.Retrieve(uint64(i))
+			if err != nil {
+				t.Fatalf("failed to retrieve item %d: %v", i, err)
+			}
+			if !bytes.Equal(blob, getChunk(15+i, i)) {
+				t.Fatalf("item %d mismatch: have %x", i, blob)
+			}
+		}
+		if _, err := f.Retrieve(255); err != errOutOfBounds {
+			t.Fatalf("out of bounds error mismatch: have %v, want %v", err, errOutOfBounds)
+		}
+		f.Close()
+		if _, err := f.Retrieve(0); err != errClosed {
+			t.Fatalf("closed table error mismatch: have %v, want %v", err, errClosed)
+		}
+	}
+}
+
+// Tests that a table whose data file lost its tail (e.g. a crash before the
+// data was synced) is repaired to the last complete item on open.
+func TestFreezerRepairDanglingIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := newTable(dir, "test", metrics.NilMeter{}, metrics.NilMeter{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 9; i++ {
+		f.Append(uint64(i), getChunk(15, i))
+	}
+	f.Close()
+
+	// Chop off half of the last item and a trailing partial index entry
+	data := filepath.Join(dir, "test.rdat")
+	if err := os.Truncate(data, 8*15+7); err != nil {
+		t.Fatal(err)
+	}
+	index, _ := os.OpenFile(filepath.Join(dir, "test.ridx"), os.O_APPEND|os.O_WRONLY, 0644)
+	index.Write([]byte{0x01, 0x02, 0x03})
+	index.Close()
+
+	if f, err = newTable(dir, "test", metrics.NilMeter{}, metrics.NilMeter{}, true); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.items != 8 {
+		t.Fatalf("item count mismatch: have %d, want %d", f.items, 8)
+	}
+	if _, err := f.Retrieve(8); err != errOutOfBounds {
+		t.Fatalf("repaired item still retrievable: %v", err)
+	}
+	// The repaired table must be writable at the correct position
+	if err := f.Append(8, getChunk(15, 0xff)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+	for i := 0; i < 9; i++ {
+		want := getChunk(15, i)
+		if i == 8 {
+			want = getChunk(15, 0xff)
+		}
+		if blob, err := f.Retrieve(uint64(i)); err != nil || !bytes.Equal(blob, want) {
+			t.Fatalf("item %d mismatch: have %x (%v), want %x", i, blob, err, want)
+		}
+	}
+}
+
+// Tests that truncating a table drops the items above the limit and that new
+// items can be appended afterwards.
+func TestFreezerTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := newTable(dir, "test", metrics.NilMeter{}, metrics.NilMeter{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 30; i++ {
+		f.Append(uint64(i), getChunk(15, i))
+	}
+	if err := f.truncate(10); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	if _, err := f.Retrieve(10); err != errOutOfBounds {
+		t.Fatalf("truncated item still retrievable: %v", err)
+	}
+	if err := f.Append(10, getChunk(20, 0xaa)); err != nil {
+		t.Fatalf("failed to append after truncation: %v", err)
+	}
+	if blob, err := f.Retrieve(10); err != nil || !bytes.Equal(blob, getChunk(20, 0xaa)) {
+		t.Fatalf("appended item mismatch: have %x (%v)", blob, err)
+	}
+	if blob, err := f.Retrieve(9); err != nil || !bytes.Equal(blob, getChunk(15, 9)) {
+		t.Fatalf("retained item mismatch: have %x (%v)", blob, err)
+	}
+}
@@ -19,8 +19,11 @@ package rawdb
 
 import (
 	"encoding/binary"
+	"math"
+	"math/big"
 
 	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/types"
 	"github.com/ether-ark/etherark/metrics"
 )
 
@@ -38,9 +41,22 @@ var (
 	// headFastBlockKey tracks the latest known incomplete block's hash during fast sync.
 	headFastBlockKey = []byte("LastFast")
 
+	// headFinalizedBlockKey tracks the latest block that can no longer be reorged.
+	headFinalizedBlockKey = []byte("LastFinalized")
+
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// powerHistoryTailKey tracks the first block above the ones fast synced, the
+	// power history being unavailable below it.
+	powerHistoryTailKey = []byte("PowerHistoryTail")
+
+	// snapshotRootKey tracks the hash of the last snapshot.
+	snapshotRootKey = []byte("SnapshotRoot")
+
+	// snapshotGeneratorKey tracks the snapshot generation marker across restarts.
+	snapshotGeneratorKey = []byte("SnapshotGenerator")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -53,16 +69,57 @@ var (
 	txLookupPrefix  = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
 
+	powerHistoryPrefix = []byte("P") // powerHistoryPrefix + address + num (uint64 big endian) + hash -> power usage of the address' transactions
+	addressTxPrefix    = []byte("A") // addressTxPrefix + address + ^num (uint64 big endian) + ^tx index (uint32 big endian) + hash -> address involvement
+	internalTxPrefix   = []byte("I") // internalTxPrefix + num (uint64 big endian) + hash -> internal value transfers of the block
+
+	tokenTransferPrefix  = []byte("T") // tokenTransferPrefix + token + ^num (uint64 big endian) + ^log index (uint32 big endian) + hash -> token transfer
+	holderTransferPrefix = []byte("U") // holderTransferPrefix + holder + ^num (uint64 big endian) + ^log index (uint32 big endian) + hash -> token transfer
+	supplyPrefix         = []byte("Y") // supplyPrefix + num (uint64 big endian) + hash -> coin supply accounting of the block
+
+	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
+	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
+
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	AddressTxIndexPrefix = []byte("iA") // AddressTxIndexPrefix is the data table of the address transaction indexer to track its progress
+	TokenIndexPrefix     = []byte("iT") // TokenIndexPrefix is the data table of the token transfer indexer to track its progress
+	SupplyIndexPrefix    = []byte("iS") // SupplyIndexPrefix is the data table of the coin supply indexer to track its progress
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
 )
 
+const (
+	// freezerHeaderTable indicates the name of the freezer header table.
+	freezerHeaderTable = "headers"
+
+	// freezerHashTable indicates the name of the freezer canonical hash table.
+	freezerHashTable = "hashes"
+
+	// freezerBodiesTable indicates the name of the freezer block body table.
+	freezerBodiesTable = "bodies"
+
+	// freezerReceiptTable indicates the name of the freezer receipts table.
+	freezerReceiptTable = "receipts"
+
+	// freezerDifficultyTable indicates the name of the freezer total difficulty table.
+	freezerDifficultyTable = "diffs"
+)
+
+// freezerNoSnappy configures whether compression is disabled for the ancient-tables.
+// Hashes are random and don't compress, everything else does.
+var freezerNoSnappy = map[string]bool{
+	freezerHeaderTable:     false,
+	freezerHashTable:       true,
+	freezerBodiesTable:     false,
+	freezerReceiptTable:    false,
+	freezerDifficultyTable: false,
+}
+
 // TxLookupEntry is a positional metadata to help looking up the data content of
 // a transaction or receipt given only its hash.
 type TxLookupEntry struct {
@@ -71,6 +128,83 @@ type TxLookupEntry struct {
 	Index      uint64
 }
 
+// PowerHistoryEntry is the power accounting of a single transaction, indexed
+// by sender and block to allow auditing the power an account spent over time.
+type PowerHistoryEntry struct {
+	TxHash  common.Hash
+	TxIndex uint64
+	Usage   *types.PowerUsage
+}
+
+// AddressTxReward is the transaction index of the address transaction entries
+// recording block rewards, which are not part of any transaction.
+const AddressTxReward = math.MaxUint32
+
+// Roles an address can take in a transaction, or block, recorded in the address
+// transaction index.
+const (
+	AddressTxFrom       byte = 1 << iota // Sender of the transaction
+	AddressTxTo                          // Recipient of the transaction
+	AddressTxCreate                      // Contract created by the transaction
+	AddressTxInternal                    // Party to a value transfer made by a contract
+	AddressTxMasternode                  // Owner of a masternode joining or quitting
+	AddressTxCoinbase                    // Recipient of the block reward
+)
+
+// AddressTxEntry is a positional metadata of a transaction, or block reward,
+// involving an address.
+type AddressTxEntry struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxIndex     uint32 // Index of the transaction, AddressTxReward for block rewards
+	Roles       byte   // Bitmask of the roles the address takes
+}
+
+// Kinds of internal value transfers.
+const (
+	InternalTransferCall         byte = iota // Value sent along a message call
+	InternalTransferCreate                   // Value endowed to a contract created by a contract
+	InternalTransferSelfDestruct             // Balance swept to the beneficiary of a self-destructing contract
+)
+
+// InternalTransfer is a value transfer made from within the execution of a
+// transaction, invisible to its receipt and logs.
+type InternalTransfer struct {
+	TxIndex uint32
+	Kind    byte
+	From    common.Address
+	To      common.Address
+	Value   *big.Int
+}
+
+// Standards of the tokens whose transfers are indexed.
+const (
+	TokenTransferERC20  byte = iota // Fungible token, the value being the amount transferred
+	TokenTransferERC721             // Non-fungible token, the value being the id of the token transferred
+)
+
+// TokenTransfer is a transfer of tokens decoded from a standard Transfer event.
+type TokenTransfer struct {
+	BlockNumber uint64      `rlp:"-"`
+	BlockHash   common.Hash `rlp:"-"`
+	LogIndex    uint32      `rlp:"-"` // Index of the event in the block
+	TxIndex     uint32
+	Kind        byte
+	Token       common.Address
+	From        common.Address
+	To          common.Address
+	Value       *big.Int
+}
+
+// SupplyRecord is the coin supply accounting of a block.
+type SupplyRecord struct {
+	Rewards *big.Int // Coins minted by the block as rewards
+	Issued  *big.Int // Coins allocated in the genesis and minted up to the block
+	Tracked bool     // Whether the balances below are known, the state of the block being needed
+	Locked  *big.Int // Balance of the masternode contract at the block
+	Burned  *big.Int // Balance of the burn address at the block
+}
+
 // encodeBlockNumber encodes a block number as big endian uint64
 func encodeBlockNumber(number uint64) []byte {
 	enc := make([]byte, 8)
@@ -123,6 +257,63 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// powerHistoryKey = powerHistoryPrefix + address + num (uint64 big endian) + hash
+func powerHistoryKey(address common.Address, number uint64, hash common.Hash) []byte {
+	key := append(append(powerHistoryPrefix, address.Bytes()...), encodeBlockNumber(number)...)
+	return append(key, hash.Bytes()...)
+}
+
+// addressTxKey = addressTxPrefix + address + ^num (uint64 big endian) + ^tx index (uint32 big endian) + hash
+//
+// The position is inverted so that iteration yields the newest entries first.
+func addressTxKey(address common.Address, number uint64, index uint32, hash common.Hash) []byte {
+	key := make([]byte, len(addressTxPrefix)+common.AddressLength+8+4+common.HashLength)
+	copy(key, addressTxPrefix)
+	copy(key[len(addressTxPrefix):], address.Bytes())
+	binary.BigEndian.PutUint64(key[len(addressTxPrefix)+common.AddressLength:], ^number)
+	binary.BigEndian.PutUint32(key[len(addressTxPrefix)+common.AddressLength+8:], ^index)
+	copy(key[len(addressTxPrefix)+common.AddressLength+12:], hash.Bytes())
+	return key
+}
+
+// tokenTransferKey = prefix + address + ^num (uint64 big endian) + ^log index (uint32 big endian) + hash
+//
+// The position is inverted so that iteration yields the newest transfers first.
+func tokenTransferKey(prefix []byte, address common.Address, number uint64, index uint32, hash common.Hash) []byte {
+	key := make([]byte, len(prefix)+common.AddressLength+8+4+common.HashLength)
+	copy(key, prefix)
+	copy(key[len(prefix):], address.Bytes())
+	binary.BigEndian.PutUint64(key[len(prefix)+common.AddressLength:], ^number)
+	binary.BigEndian.PutUint32(key[len(prefix)+common.AddressLength+8:], ^index)
+	copy(key[len(prefix)+common.AddressLength+12:], hash.Bytes())
+	return key
+}
+
+// internalTxKey = internalTxPrefix + num (uint64 big endian) + hash
+func internalTxKey(number uint64, hash common.Hash) []byte {
+	return append(append(append([]byte{}, internalTxPrefix...), encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// supplyKey = supplyPrefix + num (uint64 big endian) + hash
+func supplyKey(number uint64, hash common.Hash) []byte {
+	return append(append(append([]byte{}, supplyPrefix...), encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// accountSnapshotKey = SnapshotAccountPrefix + hash
+func accountSnapshotKey(hash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotAccountPrefix...), hash.Bytes()...)
+}
+
+// storageSnapshotKey = SnapshotStoragePrefix + account hash + storage hash
+func storageSnapshotKey(accountHash, storageHash common.Hash) []byte {
+	return append(storageSnapshotsKey(accountHash), storageHash.Bytes()...)
+}
+
+// storageSnapshotsKey = SnapshotStoragePrefix + account hash
+func storageSnapshotsKey(accountHash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotStoragePrefix...), accountHash.Bytes()...)
+}
+
 // preimageKey = preimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
@@ -26,12 +26,14 @@ import (
 )
 
 type DumpAccount struct {
-	Balance  string            `json:"balance"`
-	Nonce    uint64            `json:"nonce"`
-	Root     string            `json:"root"`
-	CodeHash string            `json:"codeHash"`
-	Code     string            `json:"code"`
-	Storage  map[string]string `json:"storage"`
+	Balance     string            `json:"balance"`
+	Power       string            `json:"power"`
+	BlockNumber string            `json:"blockNumber"` // block at which power was last settled
+	Nonce       uint64            `json:"nonce"`
+	Root        string            `json:"root"`
+	CodeHash    string            `json:"codeHash"`
+	Code        string            `json:"code"`
+	Storage     map[string]string `json:"storage"`
 }
 
 type Dump struct {
@@ -55,12 +57,14 @@ func (self *StateDB) RawDump() Dump {
 
 		obj := newObject(nil, common.BytesToAddress(addr), data)
 		account := DumpAccount{
-			Balance:  data.Balance.String(),
-			Nonce:    data.Nonce,
-			Root:     common.Bytes2Hex(data.Root[:]),
-			CodeHash: common.Bytes2Hex(data.CodeHash),
-			Code:     common.Bytes2Hex(obj.Code(self.db)),
-			Storage:  make(map[string]string),
+			Balance:     data.Balance.String(),
+			Power:       obj.Power().String(),
+			BlockNumber: obj.BlockNumber().String(),
+			Nonce:       data.Nonce,
+			Root:        common.Bytes2Hex(data.Root[:]),
+			CodeHash:    common.Bytes2Hex(data.CodeHash),
+			Code:        common.Bytes2Hex(obj.Code(self.db)),
+			Storage:     make(map[string]string),
 		}
 		storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
 		for storageIt.Next() {
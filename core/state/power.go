@@ -35,7 +35,11 @@ func CalculatePower(prevBlock, newBlock, prevPower, balance *big.Int) *big.Int {
 	return power2
 }
 
+// MaxPower returns the most power an account holding balance can accumulate.
 func MaxPower(balance *big.Int) *big.Int {
+	if balance.Cmp(big.NewInt(1e+18)) < 0 {
+		return new(big.Int)
+	}
 	etz1 := new(big.Int).Div(balance, big.NewInt(1e+18))
 	etz2 := float64(etz1.Uint64())
 	max := math.Exp(-1/(etz2*50)*10000) * 10000000 + 200000
@@ -0,0 +1,144 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/ether-ark/etherark/common"
+)
+
+// stateBloomHashes is the number of hash functions of the state bloom. The keys
+// are all keccak hashes, so the functions are simply distinct 8 byte slices of
+// the key.
+const stateBloomHashes = 4
+
+// stateBloomMagic identifies a persisted state bloom file.
+var stateBloomMagic = []byte("statebloom\x01")
+
+// errInvalidBloom is returned if a persisted state bloom file is malformed.
+var errInvalidBloom = errors.New("invalid state bloom file")
+
+// stateBloom is a bloom filter of the trie nodes and contract codes reachable
+// from the pruning target. A false positive keeps a stale entry in the database,
+// which is harmless, a false negative cannot happen.
+type stateBloom struct {
+	root common.Hash // State root the bloom was built for
+	bits []byte      // Bit vector of the filter
+}
+
+// newStateBloom creates an empty state bloom of the given size in bytes.
+func newStateBloom(root common.Hash, size uint64) *stateBloom {
+	if size < 1 {
+		size = 1
+	}
+	return &stateBloom{root: root, bits: make([]byte, size)}
+}
+
+// positions returns the bit positions of a hash key in the filter.
+func (b *stateBloom) positions(key []byte) [stateBloomHashes]uint64 {
+	var (
+		pos  [stateBloomHashes]uint64
+		bits = uint64(len(b.bits)) * 8
+	)
+	for i := range pos {
+		pos[i] = binary.BigEndian.Uint64(key[i*8:]) % bits
+	}
+	return pos
+}
+
+// add marks a hash key as reachable.
+func (b *stateBloom) add(hash common.Hash) {
+	for _, pos := range b.positions(hash[:]) {
+		b.bits[pos/8] |= 1 << (pos % 8)
+	}
+}
+
+// contain reports whether a hash key may be reachable. Keys that aren't hashes
+// are never tracked by the filter.
+func (b *stateBloom) contain(key []byte) bool {
+	if len(key) != common.HashLength {
+		return false
+	}
+	for _, pos := range b.positions(key) {
+		if b.bits[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// commit persists the bloom into the given file. The data is first written
+// into a temporary file which is moved into place once synced, so an existing
+// bloom file is always complete.
+func (b *stateBloom) commit(filename string) error {
+	tmp := filename + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.Write(stateBloomMagic)
+	w.Write(b.root[:])
+	w.Write(b.bits)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// loadStateBloom loads a state bloom persisted by commit.
+func loadStateBloom(filename string) (*stateBloom, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	header := len(stateBloomMagic) + common.HashLength
+	if stat.Size() <= int64(header) {
+		return nil, errInvalidBloom
+	}
+	blob := make([]byte, stat.Size())
+	if _, err := io.ReadFull(f, blob); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(blob[:len(stateBloomMagic)], stateBloomMagic) {
+		return nil, errInvalidBloom
+	}
+	return &stateBloom{
+		root: common.BytesToHash(blob[len(stateBloomMagic):header]),
+		bits: blob[header:],
+	}, nil
+}
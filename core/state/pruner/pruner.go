@@ -0,0 +1,376 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements offline pruning of stale state trie nodes.
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/log"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rlp"
+	"github.com/ether-ark/etherark/trie"
+)
+
+const (
+	// stateBloomFileName is the name of the persisted state bloom. Its presence
+	// in the database directory signals an unfinished pruning run.
+	stateBloomFileName = "statebloom.bf"
+
+	// recentStateLimit is the number of recent canonical blocks searched for a
+	// state root present on disk to prune towards.
+	recentStateLimit = 128
+
+	// masternodeRetention is the number of blocks below the pruning target for
+	// which the masternode contract storage is retained. Circum looks up the
+	// masternode list at most 21 blocks behind the head.
+	masternodeRetention = 128
+
+	// logInterval is the time between two progress logs.
+	logInterval = 8 * time.Second
+)
+
+var (
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+
+	// errNoRecentState is returned if none of the recent canonical blocks has its
+	// state available on disk.
+	errNoRecentState = errors.New("no recent state available on disk")
+)
+
+// Pruner is an offline tool to delete the state trie nodes and contract codes
+// not reachable from a recent state root. All nodes reachable from the target
+// root are marked in a bloom filter, then every node missing from the filter is
+// swept from the database.
+//
+// The pruner must not run while the database is used by a live node.
+type Pruner struct {
+	db        ethdb.Database
+	datadir   string // Directory holding the persisted state bloom
+	bloomSize uint64 // Size of the state bloom in bytes
+}
+
+// NewPruner creates a state pruner for the given database. The state bloom of
+// bloomSize megabytes is persisted into datadir while sweeping.
+func NewPruner(db ethdb.Database, datadir string, bloomSize uint64) *Pruner {
+	if bloomSize < 256 {
+		log.Warn("Sanitizing state bloom size", "provided(MB)", bloomSize, "updated(MB)", 256)
+		bloomSize = 256
+	}
+	return &Pruner{
+		db:        db,
+		datadir:   datadir,
+		bloomSize: bloomSize * 1024 * 1024,
+	}
+}
+
+// Prune deletes all state not reachable from the given root. If root is empty,
+// the newest recent canonical block with its state on disk is used. The chain
+// head is rewound to the block of the target root.
+//
+// An interrupted pruning run is finished instead of starting a new one.
+func (p *Pruner) Prune(root common.Hash) error {
+	bloomPath := filepath.Join(p.datadir, stateBloomFileName)
+	if _, err := os.Stat(bloomPath); err == nil {
+		bloom, err := loadStateBloom(bloomPath)
+		if err != nil {
+			return err
+		}
+		if root != (common.Hash{}) && root != bloom.root {
+			log.Warn("Ignoring pruning target, resuming interrupted run", "requested", root, "resumed", bloom.root)
+		}
+		return sweep(p.db, bloom, bloomPath)
+	}
+	target, err := p.findTarget(root)
+	if err != nil {
+		return err
+	}
+	log.Info("Selected state pruning target", "number", target.Number, "hash", target.Hash(), "root", target.Root)
+
+	// Mark the target state, the genesis state and the masternode storage
+	// recently read by the consensus engine
+	start := time.Now()
+	bloom := newStateBloom(target.Root, p.bloomSize)
+	triedb := trie.NewDatabase(p.db)
+
+	if err := markState(triedb, bloom, target.Root); err != nil {
+		return err
+	}
+	genesis := rawdb.ReadCanonicalHash(p.db, 0)
+	if header := rawdb.ReadHeader(p.db, genesis, 0); header != nil && header.Root != target.Root {
+		if ok, _ := p.db.Has(header.Root[:]); ok {
+			if err := markState(triedb, bloom, header.Root); err != nil {
+				return err
+			}
+		}
+	}
+	if config := rawdb.ReadChainConfig(p.db, genesis); config != nil && config.Circum != nil {
+		p.markMasternodes(triedb, bloom, target)
+	}
+	log.Info("Marked reachable state", "root", target.Root, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Rewind the chain to the target block before anything is deleted, the state
+	// of the blocks above it is gone after the sweep
+	if head := rawdb.ReadHeadBlockHash(p.db); head != target.Hash() {
+		if number := rawdb.ReadHeaderNumber(p.db, head); number == nil || *number > target.Number.Uint64() {
+			rawdb.WriteHeadBlockHash(p.db, target.Hash())
+		}
+	}
+	if head := rawdb.ReadHeadFastBlockHash(p.db); head != target.Hash() {
+		if number := rawdb.ReadHeaderNumber(p.db, head); number == nil || *number > target.Number.Uint64() {
+			rawdb.WriteHeadFastBlockHash(p.db, target.Hash())
+		}
+	}
+	// Persist the bloom so that a crash during the sweep can be resumed
+	if err := bloom.commit(bloomPath); err != nil {
+		return err
+	}
+	return sweep(p.db, bloom, bloomPath)
+}
+
+// findTarget returns the header of the block to prune towards. If root is empty,
+// the newest recent canonical block with its state on disk is chosen, otherwise
+// the root must belong to one of the recent canonical blocks.
+func (p *Pruner) findTarget(root common.Hash) (*types.Header, error) {
+	headHash := rawdb.ReadHeadBlockHash(p.db)
+	number := rawdb.ReadHeaderNumber(p.db, headHash)
+	if number == nil {
+		return nil, errors.New("head block missing")
+	}
+	for i := uint64(0); i < recentStateLimit && i <= *number; i++ {
+		hash := rawdb.ReadCanonicalHash(p.db, *number-i)
+		header := rawdb.ReadHeader(p.db, hash, *number-i)
+		if header == nil {
+			return nil, fmt.Errorf("canonical header #%d missing", *number-i)
+		}
+		if root != (common.Hash{}) && header.Root != root {
+			continue
+		}
+		if ok, _ := p.db.Has(header.Root[:]); ok {
+			return header, nil
+		}
+		if root != (common.Hash{}) {
+			return nil, fmt.Errorf("state %x missing from disk", root)
+		}
+	}
+	if root != (common.Hash{}) {
+		return nil, fmt.Errorf("state %x is not in the recent %d canonical blocks", root, recentStateLimit)
+	}
+	return nil, errNoRecentState
+}
+
+// markMasternodes marks the masternode contract account, its storage and code
+// in the states of the blocks right below the target, which the Circum engine
+// reads when computing the signer schedule of the next blocks. The path to the
+// zero address, the default caller of those contract calls, is kept too.
+//
+// Only the states below the target are partially retained. The chain head is
+// rewound to the fully retained target, never to one of them.
+func (p *Pruner) markMasternodes(triedb *trie.Database, bloom *stateBloom, target *types.Header) {
+	marker := &bloomMarker{bloom: bloom}
+	for i := uint64(1); i <= masternodeRetention && i <= target.Number.Uint64(); i++ {
+		number := target.Number.Uint64() - i
+		header := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, number), number)
+		if header == nil || header.Root == target.Root {
+			continue
+		}
+		if ok, _ := p.db.Has(header.Root[:]); !ok {
+			continue
+		}
+		tr, err := trie.NewSecure(header.Root, triedb, 0)
+		if err != nil {
+			continue
+		}
+		if err := tr.Prove(crypto.Keccak256(common.Address{}.Bytes()), 0, marker); err != nil {
+			log.Debug("Failed to mark caller account", "number", number, "err", err)
+			continue
+		}
+		if err := tr.Prove(crypto.Keccak256(params.MasterndeContractAddress[:]), 0, marker); err != nil {
+			log.Debug("Failed to mark masternode account", "number", number, "err", err)
+			continue
+		}
+		enc, err := tr.TryGet(params.MasterndeContractAddress[:])
+		if err != nil || len(enc) == 0 {
+			continue
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(enc, &account); err != nil {
+			log.Debug("Failed to decode masternode account", "number", number, "err", err)
+			continue
+		}
+		if err := markTrie(triedb, bloom, account.Root); err != nil {
+			log.Debug("Failed to mark masternode storage", "number", number, "err", err)
+			continue
+		}
+		if !bytes.Equal(account.CodeHash, emptyCode[:]) {
+			bloom.add(common.BytesToHash(account.CodeHash))
+		}
+	}
+}
+
+// markState marks all trie nodes and contract codes of the state with the given
+// root in the bloom.
+func markState(triedb *trie.Database, bloom *stateBloom, root common.Hash) error {
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	var (
+		storages = make(map[common.Hash]struct{})
+		accounts int
+		nodes    int
+		logged   = time.Now()
+	)
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			bloom.add(hash)
+			nodes++
+		}
+		if !it.Leaf() {
+			continue
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+			return err
+		}
+		if _, ok := storages[account.Root]; !ok && account.Root != types.EmptyRootHash {
+			if err := markTrie(triedb, bloom, account.Root); err != nil {
+				return err
+			}
+			storages[account.Root] = struct{}{}
+		}
+		if !bytes.Equal(account.CodeHash, emptyCode[:]) {
+			bloom.add(common.BytesToHash(account.CodeHash))
+		}
+		accounts++
+		if time.Since(logged) > logInterval {
+			log.Info("Marking reachable state", "root", root, "accounts", accounts, "nodes", nodes, "at", it.LeafKey())
+			logged = time.Now()
+		}
+	}
+	return it.Error()
+}
+
+// markTrie marks all nodes of a single trie in the bloom.
+func markTrie(triedb *trie.Database, bloom *stateBloom, root common.Hash) error {
+	if root == types.EmptyRootHash {
+		return nil
+	}
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			bloom.add(hash)
+		}
+	}
+	return it.Error()
+}
+
+// bloomMarker is an ethdb.Putter marking the proof nodes written into it.
+type bloomMarker struct {
+	bloom *stateBloom
+}
+
+func (m *bloomMarker) Put(key []byte, value []byte) error {
+	m.bloom.add(common.BytesToHash(key))
+	return nil
+}
+
+// sweep deletes all trie nodes and contract codes missing from the bloom, then
+// compacts the database and removes the persisted bloom.
+func sweep(db ethdb.Database, bloom *stateBloom, bloomPath string) error {
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		batch   = db.NewBatch()
+		count   int
+		scanned int
+	)
+	it := db.NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		scanned++
+		if key := it.Key(); len(key) == common.HashLength && !bloom.contain(key) {
+			if err := batch.Delete(key); err != nil {
+				return err
+			}
+			count++
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		if time.Since(logged) > logInterval {
+			log.Info("Pruning state data", "scanned", scanned, "deleted", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Pruned state data", "root", bloom.root, "deleted", count, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	cstart := time.Now()
+	log.Info("Compacting database")
+	if err := db.Compact(nil, nil); err != nil {
+		return err
+	}
+	log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(cstart)))
+
+	return os.Remove(bloomPath)
+}
+
+// RecoverPruning finishes a pruning run interrupted by a crash, if the state
+// bloom of one is found in datadir. It must be called before the database is
+// used, the chain head already points to the pruning target.
+func RecoverPruning(datadir string, db ethdb.Database) error {
+	if datadir == "" {
+		return nil // ephemeral database, nothing to recover
+	}
+	bloomPath := filepath.Join(datadir, stateBloomFileName)
+	if _, err := os.Stat(bloomPath); os.IsNotExist(err) {
+		return nil
+	}
+	bloom, err := loadStateBloom(bloomPath)
+	if err != nil {
+		return err
+	}
+	log.Info("Resuming interrupted state pruning", "root", bloom.root)
+	return sweep(db, bloom, bloomPath)
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/params"
+)
+
+// makeStateChain creates a chain of three blocks on a Circum chain, each with
+// its own state. The state of block 1 holds a contract storage dropped in
+// block 2 and the masternode contract storage, which is changed in block 2.
+func makeStateChain(t *testing.T, db ethdb.Database) []*types.Header {
+	var (
+		sdb     = state.NewDatabase(db)
+		headers []*types.Header
+		parent  common.Hash
+	)
+	statedb, _ := state.New(common.Hash{}, sdb)
+	for i := 0; i < 3; i++ {
+		switch i {
+		case 0:
+			statedb.SetBalance(common.Address{0x01}, big.NewInt(1000), big.NewInt(0))
+		case 1:
+			statedb.SetCode(common.Address{0x02}, []byte{0x60, 0x00})
+			statedb.SetState(common.Address{0x02}, common.Hash{0x01}, common.Hash{0xaa})
+			statedb.SetCode(params.MasterndeContractAddress, []byte{0x60, 0x01})
+			statedb.SetState(params.MasterndeContractAddress, common.Hash{0x01}, common.Hash{0xbb})
+		case 2:
+			statedb.SetState(common.Address{0x02}, common.Hash{0x01}, common.Hash{})
+			statedb.SetState(params.MasterndeContractAddress, common.Hash{0x01}, common.Hash{0xcc})
+			statedb.SetBalance(common.Address{0x03}, big.NewInt(1), big.NewInt(2))
+		}
+		root, err := statedb.Commit(false)
+		if err != nil {
+			t.Fatalf("failed to commit state %d: %v", i, err)
+		}
+		if err := sdb.TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("failed to flush state %d: %v", i, err)
+		}
+		header := &types.Header{ParentHash: parent, Number: big.NewInt(int64(i)), Root: root}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+		rawdb.WriteHeadBlockHash(db, header.Hash())
+		rawdb.WriteHeadFastBlockHash(db, header.Hash())
+
+		headers = append(headers, header)
+		parent = header.Hash()
+	}
+	rawdb.WriteChainConfig(db, headers[0].Hash(), params.CircumChainConfig)
+	return headers
+}
+
+// Tests that pruning keeps the target and genesis states and the recent
+// masternode contract storage, while stale state is deleted.
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := ethdb.NewMemDatabase()
+	headers := makeStateChain(t, db)
+	before := db.Len()
+
+	pruner := &Pruner{db: db, datadir: dir, bloomSize: 1024 * 1024}
+	if err := pruner.Prune(common.Hash{}); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if db.Len() >= before {
+		t.Fatalf("nothing pruned: have %d entries, had %d", db.Len(), before)
+	}
+	if _, err := os.Stat(filepath.Join(dir, stateBloomFileName)); !os.IsNotExist(err) {
+		t.Fatalf("state bloom not removed: %v", err)
+	}
+	// The target and the genesis states must be fully intact
+	for _, header := range []*types.Header{headers[0], headers[2]} {
+		if err := markState(state.NewDatabase(db).TrieDB(), newStateBloom(header.Root, 1024), header.Root); err != nil {
+			t.Fatalf("state of block %d damaged: %v", header.Number, err)
+		}
+	}
+	// The masternode storage of the previous block must be readable, the stale
+	// contract storage must be gone
+	statedb, err := state.New(headers[1].Root, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("previous state root pruned: %v", err)
+	}
+	if value := statedb.GetState(params.MasterndeContractAddress, common.Hash{0x01}); value != (common.Hash{0xbb}) {
+		t.Fatalf("masternode storage mismatch: have %x, want %x", value, common.Hash{0xbb})
+	}
+	if code := statedb.GetCode(params.MasterndeContractAddress); len(code) == 0 {
+		t.Fatalf("masternode code pruned")
+	}
+	if value := statedb.GetState(common.Address{0x02}, common.Hash{0x01}); value == (common.Hash{0xaa}) {
+		t.Fatalf("stale storage not pruned")
+	}
+}
+
+// Tests that a pruning run interrupted after the state bloom was persisted is
+// finished on recovery.
+func TestRecoverPruning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := ethdb.NewMemDatabase()
+	headers := makeStateChain(t, db)
+	before := db.Len()
+
+	// Nothing to recover without a state bloom
+	if err := RecoverPruning(dir, db); err != nil {
+		t.Fatalf("failed to skip recovery: %v", err)
+	}
+	if db.Len() != before {
+		t.Fatalf("database modified without state bloom")
+	}
+	// Simulate a crash right after the state bloom was persisted
+	bloom := newStateBloom(headers[2].Root, 1024*1024)
+	if err := markState(state.NewDatabase(db).TrieDB(), bloom, headers[2].Root); err != nil {
+		t.Fatal(err)
+	}
+	if err := bloom.commit(filepath.Join(dir, stateBloomFileName)); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecoverPruning(dir, db); err != nil {
+		t.Fatalf("failed to recover pruning: %v", err)
+	}
+	if db.Len() >= before {
+		t.Fatalf("nothing pruned: have %d entries, had %d", db.Len(), before)
+	}
+	if _, err := state.New(headers[1].Root, state.NewDatabase(db)); err == nil {
+		t.Fatalf("stale state root not pruned")
+	}
+	if err := markState(state.NewDatabase(db).TrieDB(), newStateBloom(headers[2].Root, 1024), headers[2].Root); err != nil {
+		t.Fatalf("target state damaged: %v", err)
+	}
+}
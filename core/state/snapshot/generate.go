@@ -0,0 +1,300 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/log"
+	"github.com/ether-ark/etherark/rlp"
+	"github.com/ether-ark/etherark/trie"
+)
+
+// generatorLogInterval is the time between two generation progress logs.
+const generatorLogInterval = 8 * time.Second
+
+// snapAccount is the subset of the state account fields needed to walk the
+// storage trie of an account. It decodes the account RLP of the state package
+// without importing it.
+type snapAccount struct {
+	Nonce       uint64
+	Balance     []byte
+	Power       []byte
+	BlockNumber []byte
+	Root        common.Hash
+	CodeHash    []byte
+}
+
+// generate is a background thread that iterates over the state and storage tries
+// of the disk layer, constructing the flat state snapshot. The progress is saved
+// after each batch of accounts, so an aborted generation (due to a flattening
+// diff layer or a shutdown) can be resumed from the last marker.
+//
+// Once done, the flat state is checked against the state trie. An inconsistent
+// snapshot is left uncovered, all reads fall back to the trie.
+func (dl *diskLayer) generate() {
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		accounts int
+		slots    int
+	)
+	dl.lock.RLock()
+	marker := dl.genMarker
+	dl.lock.RUnlock()
+
+	// Wipe any leftover data beyond the marker, written by an earlier generator or
+	// belonging to an earlier snapshot
+	if err := wipeSnapshot(dl.diskdb, marker); err != nil {
+		log.Error("Failed to wipe state snapshot", "err", err)
+		dl.waitAbort()
+		return
+	}
+	accTrie, err := trie.NewSecure(dl.root, dl.triedb, 0)
+	if err != nil {
+		log.Error("Generator failed to access account trie", "root", dl.root, "err", err)
+		dl.waitAbort()
+		return
+	}
+	log.Info("Generating state snapshot", "root", dl.root, "at", common.BytesToHash(marker))
+
+	batch := dl.diskdb.NewBatch()
+	it := trie.NewIterator(accTrie.NodeIterator(marker))
+	for it.Next() {
+		if len(marker) > 0 && bytes.Compare(it.Key, marker) <= 0 {
+			continue
+		}
+		accountHash := common.BytesToHash(it.Key)
+		rawdb.WriteAccountSnapshot(batch, accountHash, it.Value)
+		accounts++
+
+		var acc snapAccount
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			log.Error("Invalid account encountered during snapshot generation", "hash", accountHash, "err", err)
+			dl.waitAbort()
+			return
+		}
+		if acc.Root != types.EmptyRootHash && acc.Root != (common.Hash{}) {
+			storeTrie, err := trie.NewSecure(acc.Root, dl.triedb, 0)
+			if err != nil {
+				log.Error("Generator failed to access storage trie", "account", accountHash, "root", acc.Root, "err", err)
+				dl.waitAbort()
+				return
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+			for storeIt.Next() {
+				rawdb.WriteStorageSnapshot(batch, accountHash, common.BytesToHash(storeIt.Key), storeIt.Value)
+				slots++
+
+				// Large storage tries are flushed midway, they are not covered
+				// until the account completes
+				if batch.ValueSize() > ethdb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						log.Crit("Failed to write state snapshot", "err", err)
+					}
+					batch.Reset()
+				}
+			}
+			if storeIt.Err != nil {
+				log.Error("Generator failed to iterate storage trie", "account", accountHash, "root", acc.Root, "err", storeIt.Err)
+				dl.waitAbort()
+				return
+			}
+		}
+		if time.Since(logged) > generatorLogInterval {
+			log.Info("Generating state snapshot", "at", accountHash, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		// Persist the progress once enough data accumulated, and give the tree a
+		// chance to abort the generation
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			marker = accountHash[:]
+			rawdb.WriteSnapshotGenerator(batch, marker)
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write state snapshot", "err", err)
+			}
+			batch.Reset()
+
+			dl.lock.Lock()
+			dl.genMarker = marker
+			dl.lock.Unlock()
+
+			select {
+			case abort := <-dl.genAbort:
+				abort <- marker
+				return
+			default:
+			}
+		}
+	}
+	if it.Err != nil {
+		log.Error("Generator failed to iterate account trie", "root", dl.root, "err", it.Err)
+		dl.waitAbort()
+		return
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write state snapshot", "err", err)
+	}
+	// Check the flat state against the trie before exposing it
+	if err := verifySnapshot(dl.diskdb, dl.root); err != nil {
+		log.Error("Generated state snapshot is inconsistent", "root", dl.root, "err", err)
+
+		rawdb.WriteSnapshotGenerator(dl.diskdb, []byte{})
+		dl.lock.Lock()
+		dl.genMarker = []byte{}
+		dl.lock.Unlock()
+
+		dl.waitAbort()
+		return
+	}
+	rawdb.DeleteSnapshotGenerator(dl.diskdb)
+
+	dl.lock.Lock()
+	dl.genMarker = nil
+	close(dl.genPending)
+	dl.lock.Unlock()
+
+	log.Info("Generated state snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	dl.waitAbort()
+}
+
+// waitAbort blocks the generator until it's aborted, reporting back the marker
+// reached.
+func (dl *diskLayer) waitAbort() {
+	abort := <-dl.genAbort
+
+	dl.lock.RLock()
+	marker := dl.genMarker
+	dl.lock.RUnlock()
+
+	abort <- marker
+}
+
+// wipeSnapshot deletes all the snapshot entries of the accounts beyond the
+// marker. Nothing beyond the marker belongs to the snapshot.
+func wipeSnapshot(db ethdb.Database, marker []byte) error {
+	var accountStart, storageStart []byte
+	if len(marker) > 0 {
+		accountStart = append(append(append([]byte{}, rawdb.SnapshotAccountPrefix...), marker...), 0x00)
+		storageStart = append(append(append([]byte{}, rawdb.SnapshotStoragePrefix...), marker...), bytes.Repeat([]byte{0xff}, common.HashLength+1)...)
+	}
+	batch := db.NewBatch()
+	for _, prefix := range []struct {
+		prefix []byte
+		start  []byte
+		length int
+	}{
+		{rawdb.SnapshotAccountPrefix, accountStart, len(rawdb.SnapshotAccountPrefix) + common.HashLength},
+		{rawdb.SnapshotStoragePrefix, storageStart, len(rawdb.SnapshotStoragePrefix) + 2*common.HashLength},
+	} {
+		err := iterateRange(db, prefix.prefix, prefix.start, func(key, value []byte) error {
+			// Trie nodes are keyed by their bare hash, skip the ones sharing the prefix
+			if len(key) != prefix.length {
+				return nil
+			}
+			if err := batch.Delete(key); err != nil {
+				return err
+			}
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return err
+				}
+				batch.Reset()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// verifySnapshot rebuilds the account and storage tries from the flat snapshot
+// and checks that they hash to the expected roots.
+func verifySnapshot(db ethdb.Database, root common.Hash) error {
+	accTrie, err := trie.New(common.Hash{}, trie.NewDatabase(ethdb.NewMemDatabase()))
+	if err != nil {
+		return err
+	}
+	err = iterateRange(db, rawdb.SnapshotAccountPrefix, nil, func(key, value []byte) error {
+		if len(key) != len(rawdb.SnapshotAccountPrefix)+common.HashLength {
+			return nil
+		}
+		accountHash := key[len(rawdb.SnapshotAccountPrefix):]
+		if err := accTrie.TryUpdate(accountHash, value); err != nil {
+			return err
+		}
+		var acc snapAccount
+		if err := rlp.DecodeBytes(value, &acc); err != nil {
+			return err
+		}
+		storeTrie, err := trie.New(common.Hash{}, trie.NewDatabase(ethdb.NewMemDatabase()))
+		if err != nil {
+			return err
+		}
+		prefix := rawdb.StorageSnapshotsPrefix(common.BytesToHash(accountHash))
+		if err := iterateRange(db, prefix, nil, func(key, value []byte) error {
+			return storeTrie.TryUpdate(key[len(prefix):], value)
+		}); err != nil {
+			return err
+		}
+		want := acc.Root
+		if want == (common.Hash{}) {
+			want = types.EmptyRootHash
+		}
+		if have := storeTrie.Hash(); have != want {
+			return fmt.Errorf("storage root mismatch of account %x: have %x, want %x", accountHash, have, want)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if have := accTrie.Hash(); have != root {
+		return fmt.Errorf("state root mismatch: have %x, want %x", have, root)
+	}
+	return nil
+}
+
+// iterateRange calls fn with every database entry with the given prefix, in
+// ascending key order, starting at the given key.
+func iterateRange(db ethdb.Database, prefix []byte, start []byte, fn func(key, value []byte) error) error {
+	var it ethdb.Iterator
+	if start == nil {
+		it = db.NewIteratorWithPrefix(prefix)
+	} else {
+		it = db.NewIteratorWithStart(start)
+	}
+	defer it.Release()
+
+	for it.Next() {
+		if !bytes.HasPrefix(it.Key(), prefix) {
+			break
+		}
+		if err := fn(common.CopyBytes(it.Key()), common.CopyBytes(it.Value())); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
@@ -0,0 +1,416 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat, key-value snapshot of the state trie
+// accelerating account and storage reads.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/log"
+	"github.com/ether-ark/etherark/trie"
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the underlying snapshot
+	// is being generated currently and the requested data item is not yet in the
+	// range of accounts covered.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+// The returned values are the raw trie leaves, the RLP encoded accounts and
+// storage slots, nil if the item doesn't exist.
+type Snapshot interface {
+	// Root returns the root hash for which this snapshot was made.
+	Root() common.Hash
+
+	// AccountRLP directly retrieves the account RLP associated with a particular
+	// hash in the snapshot slim data format.
+	AccountRLP(hash common.Hash) ([]byte, error)
+
+	// Storage directly retrieves the storage data associated with a particular hash,
+	// within a particular account.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports some
+// additional methods compared to the public API.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of a snapshot, or nil if the base was
+	// reached.
+	Parent() snapshot
+
+	// Update creates a new layer on top of the existing snapshot diff tree with
+	// the specified data items.
+	Update(blockRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer
+
+	// Stale return whether this layer has become stale (was flattened across) or
+	// if it's still live.
+	Stale() bool
+}
+
+// Tree is an Ethereum state snapshot tree. It consists of one persistent base
+// layer backed by a key-value store, on top of which arbitrarily many in-memory
+// diff layers are topped, one per block. The memory diffs can form a tree with
+// branching, but the disk layer is singleton and common to all. If a reorg goes
+// deeper than the disk layer, the affected diff layers are discarded.
+//
+// The goal of the state snapshot is to allow direct access to account and
+// storage data to avoid expensive multi-level trie lookups.
+type Tree struct {
+	diskdb ethdb.Database           // Persistent database to store the snapshot
+	triedb *trie.Database           // In-memory cache to access the trie through
+	layers map[common.Hash]snapshot // Collection of all known layers
+	lock   sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store, ensuring that the head of the snapshot matches the expected one. The
+// diff layers aren't persisted, they are flattened into the disk layer when the
+// tree is shut down cleanly.
+//
+// If the snapshot is missing or inconsistent, the entirety is deleted and will
+// be reconstructed from scratch based on the tries in the key-value store, on a
+// background thread.
+func New(diskdb ethdb.Database, triedb *trie.Database, root common.Hash) *Tree {
+	snap := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		layers: make(map[common.Hash]snapshot),
+	}
+	head, err := loadSnapshot(diskdb, triedb, root)
+	if err != nil {
+		log.Warn("Failed to load snapshot, regenerating", "err", err)
+		snap.Rebuild(root)
+		return snap
+	}
+	snap.layers[head.root] = head
+	return snap
+}
+
+// loadSnapshot loads the persisted disk layer, resuming its generation if it
+// was interrupted.
+func loadSnapshot(diskdb ethdb.Database, triedb *trie.Database, root common.Hash) (*diskLayer, error) {
+	baseRoot := rawdb.ReadSnapshotRoot(diskdb)
+	if baseRoot == (common.Hash{}) {
+		return nil, errors.New("missing or corrupted snapshot")
+	}
+	if baseRoot != root {
+		return nil, fmt.Errorf("head doesn't match snapshot: have %#x, want %#x", baseRoot, root)
+	}
+	base := &diskLayer{
+		diskdb:    diskdb,
+		triedb:    triedb,
+		root:      baseRoot,
+		genMarker: rawdb.ReadSnapshotGenerator(diskdb),
+	}
+	if base.genMarker != nil {
+		base.startGeneration()
+	}
+	log.Info("Loaded state snapshot", "root", baseRoot, "complete", base.genMarker == nil)
+	return base, nil
+}
+
+// Snapshot retrieves a snapshot belonging to the given block root, or nil if no
+// snapshot is maintained for that block.
+func (t *Tree) Snapshot(blockRoot common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if snap := t.layers[blockRoot]; snap != nil {
+		return snap
+	}
+	return nil
+}
+
+// Update adds a new snapshot into the tree, if that can be linked to an existing
+// old parent. It is disallowed to insert a disk layer (the origin of all).
+func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	// Reject noop updates to avoid self-loops in the snapshot tree
+	if blockRoot == parentRoot {
+		return errSnapshotCycle
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	parent := t.layers[parentRoot]
+	if parent == nil {
+		return fmt.Errorf("parent [%#x] snapshot missing", parentRoot)
+	}
+	t.layers[blockRoot] = parent.Update(blockRoot, destructs, accounts, storage)
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from a head block hash until the
+// number of allowed layers are crossed. All layers beyond the permitted number
+// are flattened downwards into the disk layer. With zero layers allowed, the
+// whole chain of diffs is flattened, making the given root the disk layer.
+//
+// All diff layers not descending from the new disk layer are discarded.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	snap := t.layers[root]
+	if snap == nil {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := snap.(*diffLayer)
+	if !ok {
+		return nil // Already the disk layer, nothing to flatten
+	}
+	var base *diskLayer
+	if layers == 0 {
+		base = t.persist(diff)
+	} else {
+		// Find the lowest diff layer to retain, bail out if there aren't enough
+		for i := 0; i < layers-1; i++ {
+			parent, ok := diff.Parent().(*diffLayer)
+			if !ok {
+				return nil
+			}
+			diff = parent
+		}
+		bottom, ok := diff.Parent().(*diffLayer)
+		if !ok {
+			return nil
+		}
+		base = t.persist(bottom)
+
+		diff.lock.Lock()
+		diff.parent = base
+		diff.lock.Unlock()
+	}
+	// Drop all the layers which don't descend from the new disk layer any more
+	for root, snap := range t.layers {
+		layer := snap
+		for {
+			if parent := layer.Parent(); parent != nil {
+				layer = parent
+				continue
+			}
+			break
+		}
+		if layer != snapshot(base) {
+			if diff, ok := snap.(*diffLayer); ok {
+				atomic.StoreUint32(&diff.stale, 1)
+			}
+			delete(t.layers, root)
+		}
+	}
+	t.layers[base.root] = base
+	return nil
+}
+
+// persist merges the given diff layer and all its ancestors into the disk
+// layer, returning the new disk layer. The old disk layer and the merged diffs
+// are marked stale.
+//
+// The method must be called with the tree lock held.
+func (t *Tree) persist(bottom *diffLayer) *diskLayer {
+	// Collect the layers down to the disk, newest first
+	var (
+		diffs []*diffLayer
+		base  *diskLayer
+		snap  snapshot = bottom
+	)
+	for base == nil {
+		switch layer := snap.(type) {
+		case *diffLayer:
+			diffs = append(diffs, layer)
+			snap = layer.Parent()
+		case *diskLayer:
+			base = layer
+		}
+	}
+	// Pause any running generation, only the already covered range is written
+	marker := base.stopGeneration()
+
+	base.lock.Lock()
+	base.stale = true
+	base.lock.Unlock()
+
+	// Merge the diffs oldest first, a destruction drops all the earlier storage
+	var (
+		destructs = make(map[common.Hash]struct{})
+		accounts  = make(map[common.Hash][]byte)
+		storage   = make(map[common.Hash]map[common.Hash][]byte)
+	)
+	for i := len(diffs) - 1; i >= 0; i-- {
+		diff := diffs[i]
+		atomic.StoreUint32(&diff.stale, 1)
+
+		for hash := range diff.destructSet {
+			destructs[hash] = struct{}{}
+			delete(accounts, hash)
+			delete(storage, hash)
+		}
+		for hash, data := range diff.accountData {
+			accounts[hash] = data
+		}
+		for hash, slots := range diff.storageData {
+			if _, ok := storage[hash]; !ok {
+				storage[hash] = make(map[common.Hash][]byte, len(slots))
+			}
+			for slot, data := range slots {
+				storage[hash][slot] = data
+			}
+		}
+	}
+	covered := func(hash common.Hash) bool {
+		return marker == nil || bytes.Compare(hash[:], marker) <= 0
+	}
+	// Invalidate the persisted snapshot until all the diffs are written
+	batch := base.diskdb.NewBatch()
+	rawdb.DeleteSnapshotRoot(batch)
+
+	flush := func() {
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write state snapshot", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	for hash := range destructs {
+		if !covered(hash) {
+			continue
+		}
+		rawdb.DeleteAccountSnapshot(batch, hash)
+		if err := iterateRange(base.diskdb, rawdb.StorageSnapshotsPrefix(hash), nil, func(key, value []byte) error {
+			return batch.Delete(key)
+		}); err != nil {
+			log.Crit("Failed to wipe destructed storage snapshot", "err", err)
+		}
+		flush()
+	}
+	for hash, data := range accounts {
+		if !covered(hash) {
+			continue
+		}
+		if len(data) == 0 {
+			rawdb.DeleteAccountSnapshot(batch, hash)
+		} else {
+			rawdb.WriteAccountSnapshot(batch, hash, data)
+		}
+		flush()
+	}
+	for hash, slots := range storage {
+		if !covered(hash) {
+			continue
+		}
+		for slot, data := range slots {
+			if len(data) == 0 {
+				rawdb.DeleteStorageSnapshot(batch, hash, slot)
+			} else {
+				rawdb.WriteStorageSnapshot(batch, hash, slot, data)
+			}
+		}
+		flush()
+	}
+	rawdb.WriteSnapshotRoot(batch, bottom.root)
+	if marker != nil {
+		rawdb.WriteSnapshotGenerator(batch, marker)
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write state snapshot", "err", err)
+	}
+	log.Debug("Flattened state snapshot", "root", bottom.root, "layers", len(diffs), "accounts", len(accounts), "storages", len(storage))
+
+	res := &diskLayer{
+		diskdb:    base.diskdb,
+		triedb:    base.triedb,
+		root:      bottom.root,
+		genMarker: marker,
+	}
+	if marker != nil {
+		res.startGeneration()
+	}
+	return res
+}
+
+// Rebuild wipes all available snapshot data from the persistent database and
+// discards all caches and diff layers. Afterwards, it starts a new snapshot
+// generator with the given root hash.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Stop any generation and mark all the layers stale
+	for _, layer := range t.layers {
+		switch layer := layer.(type) {
+		case *diskLayer:
+			layer.stopGeneration()
+			layer.lock.Lock()
+			layer.stale = true
+			layer.lock.Unlock()
+
+		case *diffLayer:
+			atomic.StoreUint32(&layer.stale, 1)
+		}
+	}
+	// Start generating a new snapshot from scratch, the old data is wiped by the
+	// generator as nothing is covered by an empty marker
+	batch := t.diskdb.NewBatch()
+	rawdb.WriteSnapshotRoot(batch, root)
+	rawdb.WriteSnapshotGenerator(batch, []byte{})
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to reset state snapshot", "err", err)
+	}
+	log.Info("Rebuilding state snapshot", "root", root)
+
+	base := &diskLayer{
+		diskdb:    t.diskdb,
+		triedb:    t.triedb,
+		root:      root,
+		genMarker: []byte{},
+	}
+	base.startGeneration()
+	t.layers = map[common.Hash]snapshot{root: base}
+}
+
+// Stop terminates the background generation of the disk layer, persisting its
+// progress. The tree must not be used afterwards.
+func (t *Tree) Stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, layer := range t.layers {
+		if disk, ok := layer.(*diskLayer); ok {
+			disk.stopGeneration()
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/rlp"
+	"github.com/ether-ark/etherark/trie"
+)
+
+// makeState creates a state trie of three accounts, the second one with two
+// storage slots, and returns its root.
+func makeState(t *testing.T, triedb *trie.Database) common.Hash {
+	storeTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+	storeTrie.Update(common.Hash{0x01}.Bytes(), []byte{0x0a})
+	storeTrie.Update(common.Hash{0x02}.Bytes(), []byte{0x0b})
+	storeRoot, err := storeTrie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit storage trie: %v", err)
+	}
+	accTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+	for i, root := range []common.Hash{types.EmptyRootHash, storeRoot, types.EmptyRootHash} {
+		acc, _ := rlp.EncodeToBytes(&snapAccount{Nonce: uint64(i), Root: root, CodeHash: crypto.Keccak256(nil)})
+		accTrie.Update(common.Address{byte(i + 1)}.Bytes(), acc)
+	}
+	root, err := accTrie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit account trie: %v", err)
+	}
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	return root
+}
+
+// accountHash returns the snapshot key of the test account with the given
+// index.
+func accountHash(i int) common.Hash {
+	return crypto.Keccak256Hash(common.Address{byte(i + 1)}.Bytes())
+}
+
+// waitGeneration blocks until the disk layer of the tree is generated.
+func waitGeneration(t *testing.T, tree *Tree, root common.Hash) {
+	tree.lock.RLock()
+	disk := tree.layers[root].(*diskLayer)
+	tree.lock.RUnlock()
+
+	<-disk.genPending
+}
+
+// Tests that a snapshot is generated from the state trie and that its reads
+// match the trie.
+func TestGenerateSnapshot(t *testing.T) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		triedb = trie.NewDatabase(db)
+		root   = makeState(t, triedb)
+	)
+	tree := New(db, triedb, root)
+	defer tree.Stop()
+
+	waitGeneration(t, tree, root)
+	if marker := rawdb.ReadSnapshotGenerator(db); marker != nil {
+		t.Fatalf("generator marker not cleared: %x", marker)
+	}
+	if have := rawdb.ReadSnapshotRoot(db); have != root {
+		t.Fatalf("snapshot root mismatch: have %x, want %x", have, root)
+	}
+	accTrie, _ := trie.NewSecure(root, triedb, 0)
+	snap := tree.Snapshot(root)
+	for i := 0; i < 3; i++ {
+		have, err := snap.AccountRLP(accountHash(i))
+		if err != nil {
+			t.Fatalf("account %d: failed to read: %v", i, err)
+		}
+		if want := accTrie.Get(common.Address{byte(i + 1)}.Bytes()); !bytes.Equal(have, want) {
+			t.Fatalf("account %d: mismatch: have %x, want %x", i, have, want)
+		}
+	}
+	if data, err := snap.Storage(accountHash(1), crypto.Keccak256Hash(common.Hash{0x02}.Bytes())); err != nil || !bytes.Equal(data, []byte{0x0b}) {
+		t.Fatalf("storage mismatch: have %x, %v, want %x", data, err, []byte{0x0b})
+	}
+	if data, err := snap.AccountRLP(common.Hash{0xff}); err != nil || data != nil {
+		t.Fatalf("missing account mismatch: have %x, %v", data, err)
+	}
+	// A reloaded tree must reuse the complete snapshot
+	tree.Stop()
+	tree = New(db, triedb, root)
+	if marker := tree.layers[root].(*diskLayer).genMarker; marker != nil {
+		t.Fatalf("snapshot regenerated on reload, marker %x", marker)
+	}
+}
+
+// Tests that diff layers shadow their parents, that they are flattened into the
+// disk layer when capped and that the layers of other branches are discarded.
+func TestDiffLayers(t *testing.T) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		triedb = trie.NewDatabase(db)
+		base   = makeState(t, triedb)
+	)
+	tree := New(db, triedb, base)
+	defer tree.Stop()
+	waitGeneration(t, tree, base)
+
+	slot := crypto.Keccak256Hash(common.Hash{0x01}.Bytes())
+	var (
+		root1 = common.Hash{0x01}
+		root2 = common.Hash{0x02}
+		side  = common.Hash{0x03}
+	)
+	// Layer 1 modifies the first account and destructs the one with storage
+	err := tree.Update(root1, base, map[common.Hash]struct{}{accountHash(1): {}}, map[common.Hash][]byte{accountHash(0): {0x01}}, nil)
+	if err != nil {
+		t.Fatalf("failed to create layer 1: %v", err)
+	}
+	// Layer 2 recreates the destructed account with new storage
+	err = tree.Update(root2, root1, nil, map[common.Hash][]byte{accountHash(1): {0x02}}, map[common.Hash]map[common.Hash][]byte{accountHash(1): {slot: {0x0c}}})
+	if err != nil {
+		t.Fatalf("failed to create layer 2: %v", err)
+	}
+	if err := tree.Update(side, base, nil, map[common.Hash][]byte{accountHash(2): {0x03}}, nil); err != nil {
+		t.Fatalf("failed to create side layer: %v", err)
+	}
+	if err := tree.Update(root1, root1, nil, nil, nil); err != errSnapshotCycle {
+		t.Fatalf("cycle not rejected: %v", err)
+	}
+	check := func(snap Snapshot) {
+		if data, _ := snap.AccountRLP(accountHash(0)); !bytes.Equal(data, []byte{0x01}) {
+			t.Fatalf("modified account mismatch: have %x", data)
+		}
+		if data, _ := snap.AccountRLP(accountHash(1)); !bytes.Equal(data, []byte{0x02}) {
+			t.Fatalf("recreated account mismatch: have %x", data)
+		}
+		if data, _ := snap.Storage(accountHash(1), slot); !bytes.Equal(data, []byte{0x0c}) {
+			t.Fatalf("recreated slot mismatch: have %x", data)
+		}
+		if data, err := snap.Storage(accountHash(1), crypto.Keccak256Hash(common.Hash{0x02}.Bytes())); err != nil || data != nil {
+			t.Fatalf("destructed slot not cleared: have %x, %v", data, err)
+		}
+	}
+	check(tree.Snapshot(root2))
+
+	// Flattening the first layer must discard the side branch
+	sideSnap := tree.Snapshot(side)
+	if err := tree.Cap(root2, 1); err != nil {
+		t.Fatalf("failed to cap tree: %v", err)
+	}
+	if _, err := sideSnap.AccountRLP(accountHash(2)); err != ErrSnapshotStale {
+		t.Fatalf("side layer not stale: %v", err)
+	}
+	if tree.Snapshot(side) != nil {
+		t.Fatalf("side layer not discarded")
+	}
+	if _, ok := tree.Snapshot(root1).(*diskLayer); !ok {
+		t.Fatalf("layer 1 not flattened into disk")
+	}
+	check(tree.Snapshot(root2))
+
+	// Flattening everything must persist the head
+	if err := tree.Cap(root2, 0); err != nil {
+		t.Fatalf("failed to flatten tree: %v", err)
+	}
+	if have := rawdb.ReadSnapshotRoot(db); have != root2 {
+		t.Fatalf("snapshot root mismatch: have %x, want %x", have, root2)
+	}
+	check(tree.Snapshot(root2))
+	if data := rawdb.ReadStorageSnapshot(db, accountHash(1), slot); !bytes.Equal(data, []byte{0x0c}) {
+		t.Fatalf("persisted slot mismatch: have %x", data)
+	}
+}
+
+// Tests that a mismatching snapshot is regenerated from scratch, wiping the
+// leftovers of the old one.
+func TestRebuildSnapshot(t *testing.T) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		triedb = trie.NewDatabase(db)
+		root   = makeState(t, triedb)
+	)
+	// Leftovers of an earlier snapshot must be wiped by the generator, but trie
+	// nodes sharing the snapshot prefixes must be left alone
+	rawdb.WriteAccountSnapshot(db, common.Hash{0xff}, []byte{0x01})
+	rawdb.WriteSnapshotRoot(db, common.Hash{0xee})
+
+	nodes := []common.Hash{{'a', 0x01}, {'o', 0x01}}
+	for _, node := range nodes {
+		db.Put(node[:], []byte{0x01})
+	}
+
+	tree := New(db, triedb, root)
+	defer tree.Stop()
+	waitGeneration(t, tree, root)
+
+	if data := rawdb.ReadAccountSnapshot(db, common.Hash{0xff}); data != nil {
+		t.Fatalf("stale snapshot entry not wiped: %x", data)
+	}
+	for _, node := range nodes {
+		if ok, _ := db.Has(node[:]); !ok {
+			t.Fatalf("trie node %x wiped", node)
+		}
+	}
+	tree.Rebuild(root)
+	waitGeneration(t, tree, root)
+
+	if data, err := tree.Snapshot(root).AccountRLP(accountHash(0)); err != nil || len(data) == 0 {
+		t.Fatalf("rebuilt account missing: %x, %v", data, err)
+	}
+}
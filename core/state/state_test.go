@@ -40,6 +40,7 @@ func (s *StateSuite) TestDump(c *checker.C) {
 	// generate a few entries
 	obj1 := s.state.GetOrNewStateObject(toAddr([]byte{0x01}))
 	obj1.AddBalance(big.NewInt(22), big.NewInt(1))
+	obj1.SetPowerAndBlock(big.NewInt(33), big.NewInt(2))
 	obj2 := s.state.GetOrNewStateObject(toAddr([]byte{0x01, 0x02}))
 	obj2.SetCode(crypto.Keccak256Hash([]byte{3, 3, 3, 3, 3, 3, 3}), []byte{3, 3, 3, 3, 3, 3, 3})
 	obj3 := s.state.GetOrNewStateObject(toAddr([]byte{0x02}))
@@ -53,10 +54,12 @@ func (s *StateSuite) TestDump(c *checker.C) {
 	// check that dump contains the state objects that are in trie
 	got := string(s.state.Dump())
 	want := `{
-    "root": "71edff0130dd2385947095001c73d9e28d862fc286fca2b922ca6f6f3cddfdd2",
+    "root": "c5c1f4cf045a9e6d9fffbbb4453603f10baadb4380fbf7871d2f787c4b28738a",
     "accounts": {
         "0000000000000000000000000000000000000001": {
             "balance": "22",
+            "power": "33",
+            "blockNumber": "2",
             "nonce": 0,
             "root": "56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
             "codeHash": "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
@@ -65,6 +68,8 @@ func (s *StateSuite) TestDump(c *checker.C) {
         },
         "0000000000000000000000000000000000000002": {
             "balance": "44",
+            "power": "0",
+            "blockNumber": "1",
             "nonce": 0,
             "root": "56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
             "codeHash": "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
@@ -73,6 +78,8 @@ func (s *StateSuite) TestDump(c *checker.C) {
         },
         "0000000000000000000000000000000000000102": {
             "balance": "0",
+            "power": "0",
+            "blockNumber": "0",
             "nonce": 0,
             "root": "56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
             "codeHash": "87874902497a5bb968da31a2998d8f22e949d1ef6214bcdedd8bae24cca4b9e3",
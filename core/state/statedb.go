@@ -24,6 +24,7 @@ import (
 	"sort"
 
 	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/state/snapshot"
 	"github.com/ether-ark/etherark/core/types"
 	"github.com/ether-ark/etherark/crypto"
 	"github.com/ether-ark/etherark/log"
@@ -44,6 +45,10 @@ var (
 	emptyCode = crypto.Keccak256Hash(nil)
 )
 
+// snapshotLayers is the number of diff layers kept in memory by the snapshot
+// tree, deep enough to survive any reasonable reorg.
+const snapshotLayers = 128
+
 type proofList [][]byte
 
 func (n *proofList) Put(key []byte, value []byte) error {
@@ -60,6 +65,14 @@ type StateDB struct {
 	db   Database
 	trie Trie
 
+	// The flat state snapshot of the state root, read before the trie, and the
+	// modifications to feed into the snapshot tree on commit.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
@@ -84,6 +97,10 @@ type StateDB struct {
 
 	preimages map[common.Hash][]byte
 
+	// Accounts and storage slots declared by the access list of the current
+	// transaction. They are paid for upfront and priced as warm by the EVM.
+	accessList map[common.Address]map[common.Hash]struct{}
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -93,20 +110,43 @@ type StateDB struct {
 
 // Create a new state from a given trie.
 func New(root common.Hash, db Database) (*StateDB, error) {
+	return NewWithSnapshot(root, db, nil)
+}
+
+// NewWithSnapshot creates a new state from a given trie, reading accounts and
+// storage from the flat snapshot of the root first if the tree has one.
+func NewWithSnapshot(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error) {
 	tr, err := db.OpenTrie(root)
 	if err != nil {
 		return nil, err
 	}
-	return &StateDB{
+	sdb := &StateDB{
 		db:                db,
 		trie:              tr,
+		snaps:             snaps,
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		logs:              make(map[common.Hash][]*types.Log),
 		intxs:             make(map[common.Hash][]*types.Intx),
 		preimages:         make(map[common.Hash][]byte),
 		journal:           newJournal(),
-	}, nil
+	}
+	sdb.openSnapshot(root)
+	return sdb, nil
+}
+
+// openSnapshot attaches the snapshot of the given root, if any, and resets the
+// modifications collected for it.
+func (self *StateDB) openSnapshot(root common.Hash) {
+	self.snap, self.snapDestructs, self.snapAccounts, self.snapStorage = nil, nil, nil, nil
+	if self.snaps == nil {
+		return
+	}
+	if self.snap = self.snaps.Snapshot(root); self.snap != nil {
+		self.snapDestructs = make(map[common.Hash]struct{})
+		self.snapAccounts = make(map[common.Hash][]byte)
+		self.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
 }
 
 // setError remembers the first non-nil error it is called with.
@@ -128,6 +168,7 @@ func (self *StateDB) Reset(root common.Hash) error {
 		return err
 	}
 	self.trie = tr
+	self.openSnapshot(root)
 	self.stateObjects = make(map[common.Address]*stateObject)
 	self.stateObjectsDirty = make(map[common.Address]struct{})
 	self.thash = common.Hash{}
@@ -387,6 +428,16 @@ func (self *StateDB) SetPower(addr common.Address, amount *big.Int) {
 	}
 }
 
+// SetPowerAndBlock overwrites the stored power of an account together with the
+// block number it was last settled at, bypassing regeneration. It is meant for
+// seeding state (genesis allocations, state tests), not for block processing.
+func (self *StateDB) SetPowerAndBlock(addr common.Address, power, blockNumber *big.Int) {
+	stateObject := self.GetOrNewStateObject(addr)
+	if stateObject != nil {
+		stateObject.SetPowerAndBlock(power, blockNumber)
+	}
+}
+
 func (self *StateDB) SetNonce(addr common.Address, nonce uint64) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
@@ -441,6 +492,10 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+
+	if self.snap != nil {
+		self.snapAccounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -448,6 +503,12 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	if self.snap != nil {
+		self.snapDestructs[stateObject.addrHash] = struct{}{}
+		delete(self.snapAccounts, stateObject.addrHash)
+		delete(self.snapStorage, stateObject.addrHash)
+	}
 }
 
 // Retrieve a state object given by the address. Returns nil if not found.
@@ -460,8 +521,18 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot if it covers the account, falling back
+	// to the trie otherwise
+	var (
+		enc []byte
+		err error
+	)
+	if self.snap != nil {
+		enc, err = self.snap.AccountRLP(crypto.Keccak256Hash(addr[:]))
+	}
+	if self.snap == nil || err != nil {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -499,7 +570,16 @@ func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObjec
 	if prev == nil {
 		self.journal.append(createObjectChange{account: &addr})
 	} else {
-		self.journal.append(resetObjectChange{prev: prev})
+		// The storage of the overwritten account is gone, the snapshot must not
+		// be consulted for it any more
+		var prevdestruct bool
+		if self.snap != nil {
+			_, prevdestruct = self.snapDestructs[prev.addrHash]
+			if !prevdestruct {
+				self.snapDestructs[prev.addrHash] = struct{}{}
+			}
+		}
+		self.journal.append(resetObjectChange{prev: prev, prevdestruct: prevdestruct})
 	}
 	self.setStateObject(newobj)
 	return newobj, prev
@@ -545,6 +625,8 @@ func (self *StateDB) Copy() *StateDB {
 	state := &StateDB{
 		db:                self.db,
 		trie:              self.db.CopyTrie(self.trie),
+		snaps:             self.snaps,
+		snap:              self.snap,
 		stateObjects:      make(map[common.Address]*stateObject, len(self.journal.dirties)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.journal.dirties)),
 		refund:            self.refund,
@@ -594,9 +676,70 @@ func (self *StateDB) Copy() *StateDB {
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	if self.snap != nil {
+		state.snapDestructs = make(map[common.Hash]struct{}, len(self.snapDestructs))
+		for hash := range self.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(self.snapAccounts))
+		for hash, data := range self.snapAccounts {
+			state.snapAccounts[hash] = data
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(self.snapStorage))
+		for hash, slots := range self.snapStorage {
+			state.snapStorage[hash] = make(map[common.Hash][]byte, len(slots))
+			for slot, data := range slots {
+				state.snapStorage[hash][slot] = data
+			}
+		}
+	}
+	if self.accessList != nil {
+		state.accessList = make(map[common.Address]map[common.Hash]struct{}, len(self.accessList))
+		for addr, slots := range self.accessList {
+			state.accessList[addr] = make(map[common.Hash]struct{}, len(slots))
+			for slot := range slots {
+				state.accessList[addr][slot] = struct{}{}
+			}
+		}
+	}
 	return state
 }
 
+// PrepareAccessList replaces the access list with the entries of the given
+// transaction access list. It must be called before executing every
+// transaction, with a nil list for legacy ones.
+func (self *StateDB) PrepareAccessList(list types.AccessList) {
+	self.accessList = nil
+	if len(list) == 0 {
+		return
+	}
+	self.accessList = make(map[common.Address]map[common.Hash]struct{}, len(list))
+	for _, tuple := range list {
+		slots, ok := self.accessList[tuple.Address]
+		if !ok {
+			slots = make(map[common.Hash]struct{}, len(tuple.StorageKeys))
+			self.accessList[tuple.Address] = slots
+		}
+		for _, key := range tuple.StorageKeys {
+			slots[key] = struct{}{}
+		}
+	}
+}
+
+// AddressInAccessList reports whether the account is declared in the access
+// list of the current transaction.
+func (self *StateDB) AddressInAccessList(addr common.Address) bool {
+	_, ok := self.accessList[addr]
+	return ok
+}
+
+// SlotInAccessList reports whether the storage slot of the account is declared
+// in the access list of the current transaction.
+func (self *StateDB) SlotInAccessList(addr common.Address, slot common.Hash) bool {
+	_, ok := self.accessList[addr][slot]
+	return ok
+}
+
 // Snapshot returns an identifier for the current revision of the state.
 func (self *StateDB) Snapshot() int {
 	id := self.nextRevisionId
@@ -669,6 +812,11 @@ func (self *StateDB) Prepare(thash, bhash common.Hash, ti int) {
 	self.txIndex = ti
 }
 
+// TxIndex returns the index of the current transaction, as set by Prepare.
+func (self *StateDB) TxIndex() int {
+	return self.txIndex
+}
+
 func (s *StateDB) clearJournalAndRefund() {
 	s.journal = newJournal()
 	s.validRevisions = s.validRevisions[:0]
@@ -721,5 +869,18 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (root common.Hash, err error)
 		return nil
 	})
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
+
+	// Feed the modifications into the snapshot tree, keeping a limited number of
+	// diff layers in memory
+	if err == nil && s.snap != nil {
+		if parent := s.snap.Root(); parent != root {
+			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+				log.Warn("Failed to update snapshot tree", "from", parent, "to", root, "err", err)
+			} else if err := s.snaps.Cap(root, snapshotLayers); err != nil {
+				log.Warn("Failed to cap snapshot tree", "root", root, "layers", snapshotLayers, "err", err)
+			}
+		}
+		s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
+	}
 	return root, err
 }
@@ -27,11 +27,14 @@ import (
 	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 
 	check "gopkg.in/check.v1"
 
 	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/state/snapshot"
 	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/crypto"
 	"github.com/ether-ark/etherark/ethdb"
 )
 
@@ -435,3 +438,57 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that a state backed by a snapshot reads the same data as the trie, and
+// that committing it feeds the modifications into the snapshot tree.
+func TestSnapshotReads(t *testing.T) {
+	var (
+		db    = ethdb.NewMemDatabase()
+		sdb   = NewDatabase(db)
+		addr1 = common.Address{0x01}
+		addr2 = common.Address{0x02}
+	)
+	state, _ := New(common.Hash{}, sdb)
+	state.SetBalance(addr1, big.NewInt(42), big.NewInt(1))
+	state.SetState(addr2, common.Hash{0x01}, common.Hash{0xaa})
+	state.SetState(addr2, common.Hash{0x02}, common.Hash{0xbb})
+	root, _ := state.Commit(false)
+	sdb.TrieDB().Commit(root, false)
+
+	// Wait for the generation of the snapshot
+	snaps := snapshot.New(db, sdb.TrieDB(), root)
+	defer snaps.Stop()
+	for i := 0; ; i++ {
+		if _, err := snaps.Snapshot(root).AccountRLP(crypto.Keccak256Hash(addr1[:])); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("snapshot not generated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	state, _ = NewWithSnapshot(root, sdb, snaps)
+	if balance := state.GetBalance(addr1); balance.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("balance mismatch: have %v, want 42", balance)
+	}
+	if value := state.GetState(addr2, common.Hash{0x01}); value != (common.Hash{0xaa}) {
+		t.Fatalf("storage mismatch: have %x, want %x", value, common.Hash{0xaa})
+	}
+	// Modify the state and check the new snapshot layer against the trie
+	state.SetState(addr2, common.Hash{0x01}, common.Hash{0xcc})
+	state.Suicide(addr1)
+	root2, _ := state.Commit(true)
+	if snaps.Snapshot(root2) == nil {
+		t.Fatalf("snapshot layer missing for the new root")
+	}
+	snapState, _ := NewWithSnapshot(root2, sdb, snaps)
+	trieState, _ := New(root2, sdb)
+	if snapState.Exist(addr1) || trieState.Exist(addr1) {
+		t.Fatalf("destructed account still exists")
+	}
+	for _, key := range []common.Hash{{0x01}, {0x02}} {
+		if have, want := snapState.GetState(addr2, key), trieState.GetState(addr2, key); have != want {
+			t.Fatalf("slot %x mismatch: have %x, want %x", key, have, want)
+		}
+	}
+}
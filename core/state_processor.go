@@ -17,6 +17,8 @@
 package core
 
 import (
+	"math/big"
+
 	"github.com/ether-ark/etherark/common"
 	"github.com/ether-ark/etherark/consensus"
 	"github.com/ether-ark/etherark/consensus/misc"
@@ -99,6 +101,8 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
+	// Snapshot the sender's regenerated power for the receipt's power accounting
+	powerBefore := statedb.GetPower(msg.From(), header.Number)
 	// Apply the transaction to the current state (included in the env)
 	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
 	if err != nil {
@@ -116,6 +120,7 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
 	// based on the eip phase, we're passing whether the root touch-delete accounts.
 	receipt := types.NewReceipt(root, failed, *usedGas)
+	receipt.Type = tx.Type()
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = gas
 	// if the transaction created a contract, store the creation address in the receipt.
@@ -126,6 +131,12 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	receipt.Logs = statedb.GetLogs(tx.Hash())
 	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
 	receipt.Intxs = statedb.GetIntxs(tx.Hash())
+	receipt.Power = &types.PowerUsage{
+		Before:   powerBefore,
+		Charged:  new(big.Int).Mul(new(big.Int).SetUint64(msg.Gas()), msg.GasPrice()),
+		Refunded: new(big.Int).Mul(new(big.Int).SetUint64(msg.Gas()-gas), msg.GasPrice()),
+		After:    statedb.GetPower(msg.From(), header.Number),
+	}
 
 	return receipt, gas, err
 }
@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/types"
 	"github.com/ether-ark/etherark/core/vm"
 	"github.com/ether-ark/etherark/log"
 	"github.com/ether-ark/etherark/params"
@@ -73,10 +74,12 @@ type Message interface {
 	Nonce() uint64
 	CheckNonce() bool
 	Data() []byte
+	AccessList() types.AccessList
 }
 
-// IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error) {
+// IntrinsicGas computes the 'intrinsic gas' for a message with the given data
+// and access list.
+func IntrinsicGas(data []byte, accessList types.AccessList, contractCreation, homestead bool, isEIP2028 bool) (uint64, error) {
 	// Set the starting gas for the raw transaction
 	var gas uint64
 	if contractCreation && homestead {
@@ -94,10 +97,14 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error)
 			}
 		}
 		// Make sure we don't exceed uint64 for all data combinations
-		if (math.MaxUint64-gas)/params.TxDataNonZeroGas < nz {
+		nonZeroGas := params.TxDataNonZeroGas
+		if isEIP2028 {
+			nonZeroGas = params.TxDataNonZeroGasEIP2028
+		}
+		if (math.MaxUint64-gas)/nonZeroGas < nz {
 			return 0, vm.ErrOutOfGas
 		}
-		gas += nz * params.TxDataNonZeroGas
+		gas += nz * nonZeroGas
 
 		z := uint64(len(data)) - nz
 		if (math.MaxUint64-gas)/params.TxDataZeroGas < z {
@@ -105,6 +112,18 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error)
 		}
 		gas += z * params.TxDataZeroGas
 	}
+	if accessList != nil {
+		addresses, keys := uint64(len(accessList)), uint64(accessList.StorageKeys())
+		if (math.MaxUint64-gas)/params.TxAccessListAddressGas < addresses {
+			return 0, vm.ErrOutOfGas
+		}
+		gas += addresses * params.TxAccessListAddressGas
+
+		if (math.MaxUint64-gas)/params.TxAccessListStorageKeyGas < keys {
+			return 0, vm.ErrOutOfGas
+		}
+		gas += keys * params.TxAccessListStorageKeyGas
+	}
 	return gas, nil
 }
 
@@ -188,10 +207,17 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 	msg := st.msg
 	sender := vm.AccountRef(msg.From())
 	homestead := st.evm.ChainConfig().IsHomestead(st.evm.BlockNumber)
+	istanbul := st.evm.ChainConfig().IsIstanbul(st.evm.BlockNumber)
 	contractCreation := msg.To() == nil
 
+	// Access lists are only honoured, and paid for, after the typed transaction fork
+	var accessList types.AccessList
+	if st.evm.ChainConfig().IsTypedTx(st.evm.BlockNumber) {
+		accessList = msg.AccessList()
+		st.state.PrepareAccessList(accessList)
+	}
 	// Pay intrinsic gas
-	gas, err := IntrinsicGas(st.data, contractCreation, homestead)
+	gas, err := IntrinsicGas(st.data, accessList, contractCreation, homestead, istanbul)
 	if err != nil {
 		return nil, 0, false, err
 	}
@@ -29,6 +29,7 @@ import (
 )
 
 //go:generate gencodec -type Receipt -field-override receiptMarshaling -out gen_receipt_json.go
+//go:generate gencodec -type PowerUsage -field-override powerUsageMarshaling -out gen_power_usage_json.go
 
 var (
 	receiptStatusFailedRLP     = []byte{}
@@ -42,6 +43,24 @@ type Intx struct {
 	Value big.Int        `json:"value" gencodec:"required"`
 }
 
+// PowerUsage records how a transaction changed the power of its sender. Before
+// is the regenerated power at the including block, Charged is the upfront
+// gas*price deduction, Refunded is the unused gas credited back and After is
+// the power left once the transaction (including any value transfer) settled.
+type PowerUsage struct {
+	Before   *big.Int `json:"before"   gencodec:"required"`
+	Charged  *big.Int `json:"charged"  gencodec:"required"`
+	Refunded *big.Int `json:"refunded" gencodec:"required"`
+	After    *big.Int `json:"after"    gencodec:"required"`
+}
+
+type powerUsageMarshaling struct {
+	Before   *hexutil.Big
+	Charged  *hexutil.Big
+	Refunded *hexutil.Big
+	After    *hexutil.Big
+}
+
 const (
 	// ReceiptStatusFailed is the status code of a transaction if execution failed.
 	ReceiptStatusFailed = uint64(0)
@@ -53,6 +72,7 @@ const (
 // Receipt represents the results of a transaction.
 type Receipt struct {
 	// Consensus fields
+	Type              uint8  `json:"type,omitempty"`
 	PostState         []byte `json:"root"`
 	Status            uint64 `json:"status"`
 	CumulativeGasUsed uint64 `json:"cumulativeGasUsed" gencodec:"required"`
@@ -64,9 +84,11 @@ type Receipt struct {
 	TxHash          common.Hash    `json:"transactionHash" gencodec:"required"`
 	ContractAddress common.Address `json:"contractAddress"`
 	GasUsed         uint64         `json:"gasUsed" gencodec:"required"`
+	Power           *PowerUsage    `json:"power,omitempty"`
 }
 
 type receiptMarshaling struct {
+	Type              hexutil.Uint64
 	PostState         hexutil.Bytes
 	Status            hexutil.Uint64
 	CumulativeGasUsed hexutil.Uint64
@@ -90,6 +112,11 @@ type receiptStorageRLP struct {
 	Logs              []*LogForStorage
 	Intxs             []*Intx
 	GasUsed           uint64
+
+	// Optional trailing fields, absent in receipts stored before they were
+	// introduced: the power usage as a zero or one element list, followed by
+	// the transaction type.
+	Extra []rlp.RawValue `rlp:"tail"`
 }
 
 // NewReceipt creates a barebone transaction receipt, copying the init fields.
@@ -105,17 +132,81 @@ func NewReceipt(root []byte, failed bool, cumulativeGasUsed uint64) *Receipt {
 
 // EncodeRLP implements rlp.Encoder, and flattens the consensus fields of a receipt
 // into an RLP stream. If no post state is present, byzantium fork is assumed.
+// Receipts of typed transactions are encoded as an RLP string holding their
+// type prefixed envelope.
 func (r *Receipt) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs})
+	data := &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
+	if r.Type == LegacyTxType {
+		return rlp.Encode(w, data)
+	}
+	enc, err := r.encodeTyped(data)
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, enc)
 }
 
 // DecodeRLP implements rlp.Decoder, and loads the consensus fields of a receipt
 // from an RLP stream.
 func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
+	kind, _, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	if kind == rlp.List {
+		var dec receiptRLP
+		if err := s.Decode(&dec); err != nil {
+			return err
+		}
+		r.Type = LegacyTxType
+		return r.setFromRLP(dec)
+	}
+	b, err := s.Bytes()
+	if err != nil {
+		return err
+	}
+	return r.decodeTyped(b)
+}
+
+// MarshalBinary returns the consensus encoding of the receipt, the RLP list of
+// legacy receipts and the envelope of typed ones.
+func (r *Receipt) MarshalBinary() ([]byte, error) {
+	data := &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
+	if r.Type == LegacyTxType {
+		return rlp.EncodeToBytes(data)
+	}
+	return r.encodeTyped(data)
+}
+
+// encodeTyped assembles the type prefixed envelope of a typed receipt.
+func (r *Receipt) encodeTyped(data *receiptRLP) ([]byte, error) {
+	if r.Type != AccessListTxType {
+		return nil, ErrTxTypeNotSupported
+	}
+	payload, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{r.Type}, payload...), nil
+}
+
+// decodeTyped decodes a type prefixed receipt envelope.
+func (r *Receipt) decodeTyped(b []byte) error {
+	if len(b) == 0 {
+		return errEmptyTypedTx
+	}
+	if b[0] != AccessListTxType {
+		return ErrTxTypeNotSupported
+	}
 	var dec receiptRLP
-	if err := s.Decode(&dec); err != nil {
+	if err := rlp.DecodeBytes(b[1:], &dec); err != nil {
 		return err
 	}
+	r.Type = b[0]
+	return r.setFromRLP(dec)
+}
+
+func (r *Receipt) setFromRLP(dec receiptRLP) error {
 	if err := r.setStatus(dec.PostStateOrStatus); err != nil {
 		return err
 	}
@@ -157,6 +248,9 @@ func (r *Receipt) Size() common.StorageSize {
 		size += common.StorageSize(len(log.Topics)*common.HashLength + len(log.Data))
 	}
 	size += common.StorageSize(len(r.Intxs)) * common.StorageSize(unsafe.Sizeof(Intx{}))
+	if r.Power != nil {
+		size += common.StorageSize(unsafe.Sizeof(PowerUsage{}))
+	}
 	return size
 }
 
@@ -177,6 +271,24 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 		Intxs:             r.Intxs,
 		GasUsed:           r.GasUsed,
 	}
+	if r.Power != nil || r.Type != LegacyTxType {
+		var power []*PowerUsage
+		if r.Power != nil {
+			power = []*PowerUsage{r.Power}
+		}
+		extra, err := rlp.EncodeToBytes(power)
+		if err != nil {
+			return err
+		}
+		enc.Extra = append(enc.Extra, extra)
+	}
+	if r.Type != LegacyTxType {
+		extra, err := rlp.EncodeToBytes(r.Type)
+		if err != nil {
+			return err
+		}
+		enc.Extra = append(enc.Extra, extra)
+	}
 	for i, log := range r.Logs {
 		enc.Logs[i] = (*LogForStorage)(log)
 	}
@@ -202,6 +314,20 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	r.Intxs = dec.Intxs
 	// Assign the implementation fields
 	r.TxHash, r.ContractAddress, r.GasUsed = dec.TxHash, dec.ContractAddress, dec.GasUsed
+	if len(dec.Extra) > 0 {
+		var power []*PowerUsage
+		if err := rlp.DecodeBytes(dec.Extra[0], &power); err != nil {
+			return err
+		}
+		if len(power) > 0 {
+			r.Power = power[0]
+		}
+	}
+	if len(dec.Extra) > 1 {
+		if err := rlp.DecodeBytes(dec.Extra[1], &r.Type); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -211,9 +337,10 @@ type Receipts []*Receipt
 // Len returns the number of receipts in this list.
 func (r Receipts) Len() int { return len(r) }
 
-// GetRlp returns the RLP encoding of one receipt from the list.
+// GetRlp returns the consensus encoding of one receipt from the list, the RLP
+// list for legacy and the envelope for typed receipts.
 func (r Receipts) GetRlp(i int) []byte {
-	bytes, err := rlp.EncodeToBytes(r[i])
+	bytes, err := r[i].MarshalBinary()
 	if err != nil {
 		panic(err)
 	}
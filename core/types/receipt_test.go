@@ -0,0 +1,94 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/rlp"
+)
+
+// Tests that typed receipts are enveloped in the consensus encoding and keep
+// their type in the storage encoding, while legacy receipts are unchanged.
+func TestTypedReceiptEncoding(t *testing.T) {
+	legacy := NewReceipt(nil, false, 21000)
+	legacy.Logs = []*Log{}
+	typed := NewReceipt(nil, false, 42000)
+	typed.Logs = []*Log{}
+	typed.Type = AccessListTxType
+
+	enc, err := rlp.EncodeToBytes(legacy)
+	if err != nil {
+		t.Fatalf("legacy encoding failed: %v", err)
+	}
+	if blob, _ := legacy.MarshalBinary(); !bytes.Equal(blob, enc) {
+		t.Errorf("legacy binary encoding mismatch: have %x, want %x", blob, enc)
+	}
+	blob, err := typed.MarshalBinary()
+	if err != nil {
+		t.Fatalf("typed encoding failed: %v", err)
+	}
+	if blob[0] != AccessListTxType {
+		t.Fatalf("envelope type byte mismatch: have %#x, want %#x", blob[0], AccessListTxType)
+	}
+	enc, err = rlp.EncodeToBytes(Receipts{legacy, typed})
+	if err != nil {
+		t.Fatalf("list encoding failed: %v", err)
+	}
+	var receipts Receipts
+	if err := rlp.DecodeBytes(enc, &receipts); err != nil {
+		t.Fatalf("list decoding failed: %v", err)
+	}
+	if receipts[0].Type != LegacyTxType || receipts[1].Type != AccessListTxType {
+		t.Errorf("consensus type mismatch: have %d, %d", receipts[0].Type, receipts[1].Type)
+	}
+	if receipts[1].CumulativeGasUsed != 42000 {
+		t.Errorf("consensus fields mismatch: have %d gas", receipts[1].CumulativeGasUsed)
+	}
+	// Storage round trip, with and without power usage
+	typed.Power = &PowerUsage{Before: big.NewInt(4), Charged: big.NewInt(3), Refunded: big.NewInt(2), After: big.NewInt(1)}
+	for _, want := range []*Receipt{legacy, typed} {
+		enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(want))
+		if err != nil {
+			t.Fatalf("storage encoding failed: %v", err)
+		}
+		have := new(ReceiptForStorage)
+		if err := rlp.DecodeBytes(enc, have); err != nil {
+			t.Fatalf("storage decoding failed: %v", err)
+		}
+		if have.Type != want.Type {
+			t.Errorf("stored type mismatch: have %d, want %d", have.Type, want.Type)
+		}
+		if (have.Power == nil) != (want.Power == nil) || (have.Power != nil && have.Power.After.Cmp(want.Power.After) != 0) {
+			t.Errorf("stored power mismatch: have %v, want %v", have.Power, want.Power)
+		}
+	}
+	// Receipts stored before the trailing fields existed still decode
+	old, _ := rlp.EncodeToBytes([]interface{}{
+		[]byte{0x01}, uint64(21000), Bloom{}, common.Hash{}, common.Address{}, []*LogForStorage{}, []*Intx{}, uint64(21000),
+	})
+	have := new(ReceiptForStorage)
+	if err := rlp.DecodeBytes(old, have); err != nil {
+		t.Fatalf("legacy storage decoding failed: %v", err)
+	}
+	if have.Type != LegacyTxType || have.Power != nil {
+		t.Errorf("legacy storage mismatch: have type %d, power %v", have.Type, have.Power)
+	}
+}
@@ -224,3 +224,112 @@ func TestTransactionJSON(t *testing.T) {
 		}
 	}
 }
+
+// Tests that access list transactions survive the binary, RLP and JSON
+// encodings and that only typed-aware signers accept them.
+func TestAccessListTransaction(t *testing.T) {
+	key, addr := defaultTestKey()
+	to := common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	accesses := AccessList{{
+		Address:     to,
+		StorageKeys: []common.Hash{{0x01}, {0x02}},
+	}}
+	signer := NewEIP2930Signer(big.NewInt(1))
+
+	tx, err := SignTx(NewAccessListTransaction(big.NewInt(1), 3, &to, big.NewInt(10), 25000, big.NewInt(1), common.FromHex("5544"), accesses), signer, key)
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	if tx.Type() != AccessListTxType {
+		t.Fatalf("transaction type mismatch: have %d, want %d", tx.Type(), AccessListTxType)
+	}
+	if from, err := Sender(signer, tx); err != nil || from != addr {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", from, err, addr)
+	}
+	if _, err := Sender(NewEIP155Signer(big.NewInt(1)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("legacy signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	if _, err := Sender(NewEIP2930Signer(big.NewInt(2)), tx); err != ErrInvalidChainId {
+		t.Errorf("foreign chain error mismatch: have %v, want %v", err, ErrInvalidChainId)
+	}
+	// The binary encoding is the bare envelope
+	blob, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("binary encoding failed: %v", err)
+	}
+	if blob[0] != AccessListTxType {
+		t.Fatalf("envelope type byte mismatch: have %#x, want %#x", blob[0], AccessListTxType)
+	}
+	if tx.Hash() != crypto.Keccak256Hash(blob) {
+		t.Errorf("hash is not the hash of the envelope")
+	}
+	check := func(name string, parsed *Transaction) {
+		if parsed.Hash() != tx.Hash() {
+			t.Errorf("%s: hash mismatch: have %x, want %x", name, parsed.Hash(), tx.Hash())
+		}
+		if parsed.Type() != tx.Type() || parsed.ChainId().Cmp(tx.ChainId()) != 0 {
+			t.Errorf("%s: envelope mismatch: have type %d chain %v", name, parsed.Type(), parsed.ChainId())
+		}
+		if have := parsed.AccessList(); len(have) != 1 || have.StorageKeys() != 2 || have[0].Address != to {
+			t.Errorf("%s: access list mismatch: have %v", name, have)
+		}
+		if from, err := Sender(signer, parsed); err != nil || from != addr {
+			t.Errorf("%s: sender mismatch: have %x (%v), want %x", name, from, err, addr)
+		}
+	}
+	parsed := new(Transaction)
+	if err := parsed.UnmarshalBinary(blob); err != nil {
+		t.Fatalf("binary decoding failed: %v", err)
+	}
+	check("binary", parsed)
+
+	// Inside RLP lists (e.g. block bodies) the envelope is a string
+	enc, err := rlp.EncodeToBytes(Transactions{tx, rightvrsTx})
+	if err != nil {
+		t.Fatalf("rlp encoding failed: %v", err)
+	}
+	var txs Transactions
+	if err := rlp.DecodeBytes(enc, &txs); err != nil {
+		t.Fatalf("rlp decoding failed: %v", err)
+	}
+	check("rlp", txs[0])
+	if txs[1].Hash() != rightvrsTx.Hash() || txs[1].Type() != LegacyTxType {
+		t.Errorf("legacy transaction mangled next to a typed one")
+	}
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("json encoding failed: %v", err)
+	}
+	parsed = new(Transaction)
+	if err := json.Unmarshal(data, parsed); err != nil {
+		t.Fatalf("json decoding failed: %v", err)
+	}
+	check("json", parsed)
+}
+
+// Tests that access list transactions created without a chain ID are bound to
+// the chain of the signer, so their sender can be recovered.
+func TestAccessListTransactionSignerChainID(t *testing.T) {
+	key, addr := defaultTestKey()
+	to := common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	signer := NewEIP2930Signer(big.NewInt(7))
+
+	unsigned := NewAccessListTransaction(nil, 0, &to, big.NewInt(10), 25000, big.NewInt(1), nil, nil)
+	tx, err := SignTx(unsigned, signer, key)
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	if tx.ChainId().Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("chain ID mismatch: have %v, want 7", tx.ChainId())
+	}
+	if unsigned.ChainId().Sign() != 0 {
+		t.Errorf("unsigned transaction modified: chain ID %v", unsigned.ChainId())
+	}
+	if from, err := Sender(signer, tx); err != nil || from != addr {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", from, err, addr)
+	}
+	// Transactions bound to a chain can't be signed for another one
+	if _, err := SignTx(tx, NewEIP2930Signer(big.NewInt(8)), key); err != ErrInvalidChainId {
+		t.Errorf("foreign chain error mismatch: have %v, want %v", err, ErrInvalidChainId)
+	}
+}
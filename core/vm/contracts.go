@@ -18,12 +18,15 @@ package vm
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"math/big"
 
 	"github.com/ether-ark/etherark/common"
 	"github.com/ether-ark/etherark/common/math"
 	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/crypto/blake2b"
+	"github.com/ether-ark/etherark/crypto/bls"
 	"github.com/ether-ark/etherark/crypto/bn256"
 	"github.com/ether-ark/etherark/params"
 	"golang.org/x/crypto/ripemd160"
@@ -56,13 +59,41 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{3}): &ripemd160hash{},
 	common.BytesToAddress([]byte{4}): &dataCopy{},
 	common.BytesToAddress([]byte{5}): &bigModExp{},
-	common.BytesToAddress([]byte{6}): &bn256Add{},
-	common.BytesToAddress([]byte{7}): &bn256ScalarMul{},
-	common.BytesToAddress([]byte{8}): &bn256Pairing{},
+	common.BytesToAddress([]byte{6}): &bn256AddByzantium{},
+	common.BytesToAddress([]byte{7}): &bn256ScalarMulByzantium{},
+	common.BytesToAddress([]byte{8}): &bn256PairingByzantium{},
 	common.BytesToAddress([]byte{9}): &peeridrecover{},
 	common.BytesToAddress([]byte{11}): &ecrecoverByPublicKey{},
 }
 
+// PrecompiledContractsIstanbul contains the default set of pre-compiled Ethereum
+// contracts used in the Istanbul release, with the elliptic curve operations
+// repriced by EIP-1108. Since 0x09 already hosts peeridrecover, the EIP-152
+// BLAKE2 F compression lives at 0x0a.
+var PrecompiledContractsIstanbul = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}):  &ecrecover{},
+	common.BytesToAddress([]byte{2}):  &sha256hash{},
+	common.BytesToAddress([]byte{3}):  &ripemd160hash{},
+	common.BytesToAddress([]byte{4}):  &dataCopy{},
+	common.BytesToAddress([]byte{5}):  &bigModExp{},
+	common.BytesToAddress([]byte{6}):  &bn256AddIstanbul{},
+	common.BytesToAddress([]byte{7}):  &bn256ScalarMulIstanbul{},
+	common.BytesToAddress([]byte{8}):  &bn256PairingIstanbul{},
+	common.BytesToAddress([]byte{9}):  &peeridrecover{},
+	common.BytesToAddress([]byte{10}): &blake2F{},
+	common.BytesToAddress([]byte{11}): &ecrecoverByPublicKey{},
+}
+
+// PrecompiledContractsBLS contains the BLS12-381 signature verification and
+// public key aggregation contracts, placed right after peeridrecover and
+// ecrecoverByPublicKey. They are available on top of the set of the release
+// in effect once the BLS fork activates.
+var PrecompiledContractsBLS = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{12}): &blsVerify{},
+	common.BytesToAddress([]byte{13}): &blsVerifyAggregate{},
+	common.BytesToAddress([]byte{14}): &blsAggregatePubkeys{},
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
@@ -275,15 +306,9 @@ func newTwistPoint(blob []byte) (*bn256.G2, error) {
 	return p, nil
 }
 
-// bn256Add implements a native elliptic curve point addition.
-type bn256Add struct{}
-
-// RequiredGas returns the gas required to execute the pre-compiled contract.
-func (c *bn256Add) RequiredGas(input []byte) uint64 {
-	return params.Bn256AddGas
-}
-
-func (c *bn256Add) Run(input []byte) ([]byte, error) {
+// runBn256Add implements the Bn256Add precompile, referenced by both
+// Byzantium and Istanbul operations.
+func runBn256Add(input []byte) ([]byte, error) {
 	x, err := newCurvePoint(getData(input, 0, 64))
 	if err != nil {
 		return nil, err
@@ -297,15 +322,35 @@ func (c *bn256Add) Run(input []byte) ([]byte, error) {
 	return res.Marshal(), nil
 }
 
-// bn256ScalarMul implements a native elliptic curve scalar multiplication.
-type bn256ScalarMul struct{}
+// bn256AddByzantium implements a native elliptic curve point addition
+// conforming to Byzantium consensus rules.
+type bn256AddByzantium struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256AddByzantium) RequiredGas(input []byte) uint64 {
+	return params.Bn256AddGasByzantium
+}
+
+func (c *bn256AddByzantium) Run(input []byte) ([]byte, error) {
+	return runBn256Add(input)
+}
+
+// bn256AddIstanbul implements a native elliptic curve point addition
+// conforming to Istanbul consensus rules.
+type bn256AddIstanbul struct{}
 
 // RequiredGas returns the gas required to execute the pre-compiled contract.
-func (c *bn256ScalarMul) RequiredGas(input []byte) uint64 {
-	return params.Bn256ScalarMulGas
+func (c *bn256AddIstanbul) RequiredGas(input []byte) uint64 {
+	return params.Bn256AddGasIstanbul
 }
 
-func (c *bn256ScalarMul) Run(input []byte) ([]byte, error) {
+func (c *bn256AddIstanbul) Run(input []byte) ([]byte, error) {
+	return runBn256Add(input)
+}
+
+// runBn256ScalarMul implements the Bn256ScalarMul precompile, referenced by
+// both Byzantium and Istanbul operations.
+func runBn256ScalarMul(input []byte) ([]byte, error) {
 	p, err := newCurvePoint(getData(input, 0, 64))
 	if err != nil {
 		return nil, err
@@ -315,6 +360,32 @@ func (c *bn256ScalarMul) Run(input []byte) ([]byte, error) {
 	return res.Marshal(), nil
 }
 
+// bn256ScalarMulByzantium implements a native elliptic curve scalar
+// multiplication conforming to Byzantium consensus rules.
+type bn256ScalarMulByzantium struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256ScalarMulByzantium) RequiredGas(input []byte) uint64 {
+	return params.Bn256ScalarMulGasByzantium
+}
+
+func (c *bn256ScalarMulByzantium) Run(input []byte) ([]byte, error) {
+	return runBn256ScalarMul(input)
+}
+
+// bn256ScalarMulIstanbul implements a native elliptic curve scalar
+// multiplication conforming to Istanbul consensus rules.
+type bn256ScalarMulIstanbul struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256ScalarMulIstanbul) RequiredGas(input []byte) uint64 {
+	return params.Bn256ScalarMulGasIstanbul
+}
+
+func (c *bn256ScalarMulIstanbul) Run(input []byte) ([]byte, error) {
+	return runBn256ScalarMul(input)
+}
+
 var (
 	// true32Byte is returned if the bn256 pairing check succeeds.
 	true32Byte = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
@@ -326,15 +397,9 @@ var (
 	errBadPairingInput = errors.New("bad elliptic curve pairing size")
 )
 
-// bn256Pairing implements a pairing pre-compile for the bn256 curve
-type bn256Pairing struct{}
-
-// RequiredGas returns the gas required to execute the pre-compiled contract.
-func (c *bn256Pairing) RequiredGas(input []byte) uint64 {
-	return params.Bn256PairingBaseGas + uint64(len(input)/192)*params.Bn256PairingPerPointGas
-}
-
-func (c *bn256Pairing) Run(input []byte) ([]byte, error) {
+// runBn256Pairing implements the Bn256Pairing precompile, referenced by both
+// Byzantium and Istanbul operations.
+func runBn256Pairing(input []byte) ([]byte, error) {
 	// Handle some corner cases cheaply
 	if len(input)%192 > 0 {
 		return nil, errBadPairingInput
@@ -363,6 +428,96 @@ func (c *bn256Pairing) Run(input []byte) ([]byte, error) {
 	return false32Byte, nil
 }
 
+// bn256PairingByzantium implements a pairing pre-compile for the bn256 curve
+// conforming to Byzantium consensus rules.
+type bn256PairingByzantium struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256PairingByzantium) RequiredGas(input []byte) uint64 {
+	return params.Bn256PairingBaseGasByzantium + uint64(len(input)/192)*params.Bn256PairingPerPointGasByzantium
+}
+
+func (c *bn256PairingByzantium) Run(input []byte) ([]byte, error) {
+	return runBn256Pairing(input)
+}
+
+// bn256PairingIstanbul implements a pairing pre-compile for the bn256 curve
+// conforming to Istanbul consensus rules.
+type bn256PairingIstanbul struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256PairingIstanbul) RequiredGas(input []byte) uint64 {
+	return params.Bn256PairingBaseGasIstanbul + uint64(len(input)/192)*params.Bn256PairingPerPointGasIstanbul
+}
+
+func (c *bn256PairingIstanbul) Run(input []byte) ([]byte, error) {
+	return runBn256Pairing(input)
+}
+
+// blake2F implements the EIP-152 BLAKE2 F compression function as a native contract.
+type blake2F struct{}
+
+const (
+	blake2FInputLength        = 213
+	blake2FFinalBlockBytes    = byte(1)
+	blake2FNonFinalBlockBytes = byte(0)
+)
+
+var (
+	errBlake2FInvalidInputLength = errors.New("invalid input length")
+	errBlake2FInvalidFinalFlag   = errors.New("invalid final flag")
+)
+
+// RequiredGas returns the gas required to execute the pre-compiled contract,
+// which is linear in the requested number of rounds.
+func (c *blake2F) RequiredGas(input []byte) uint64 {
+	// If the input is malformed, we can't calculate the gas, return 0 and let the
+	// actual call choke and fault.
+	if len(input) != blake2FInputLength {
+		return 0
+	}
+	return uint64(binary.BigEndian.Uint32(input[0:4])) * params.Blake2FPerRoundGas
+}
+
+func (c *blake2F) Run(input []byte) ([]byte, error) {
+	// Make sure the input is valid (correct length and final flag)
+	if len(input) != blake2FInputLength {
+		return nil, errBlake2FInvalidInputLength
+	}
+	if input[212] != blake2FNonFinalBlockBytes && input[212] != blake2FFinalBlockBytes {
+		return nil, errBlake2FInvalidFinalFlag
+	}
+	// Parse the input into the Blake2b call parameters
+	var (
+		rounds = binary.BigEndian.Uint32(input[0:4])
+		final  = (input[212] == blake2FFinalBlockBytes)
+
+		h [8]uint64
+		m [16]uint64
+		t [2]uint64
+	)
+	for i := 0; i < 8; i++ {
+		offset := 4 + i*8
+		h[i] = binary.LittleEndian.Uint64(input[offset : offset+8])
+	}
+	for i := 0; i < 16; i++ {
+		offset := 68 + i*8
+		m[i] = binary.LittleEndian.Uint64(input[offset : offset+8])
+	}
+	t[0] = binary.LittleEndian.Uint64(input[196:204])
+	t[1] = binary.LittleEndian.Uint64(input[204:212])
+
+	// Execute the compression function, extract and return the result
+	blake2b.F(&h, m, t, final, rounds)
+
+	output := make([]byte, 64)
+	for i := 0; i < 8; i++ {
+		offset := i * 8
+		binary.LittleEndian.PutUint64(output[offset:offset+8], h[i])
+	}
+	return output, nil
+}
+
 type peeridrecover struct{}
 
 func (c *peeridrecover) RequiredGas(input []byte) uint64 {
@@ -400,4 +555,126 @@ func (c *ecrecoverByPublicKey) Run(input []byte) ([]byte, error) {
 	}
 	addr := crypto.PubkeyToAddress(*p)
 	return common.LeftPadBytes(addr[:], 32), nil
-}
\ No newline at end of file
+}
+
+const (
+	blsMessageLength   = 32 // Length of a signed BLS message hash
+	blsDomainLength    = 8  // Length of a BLS signature domain (domain type and fork version)
+	blsPubkeyLength    = 48 // Length of a compressed BLS12-381 public key
+	blsSignatureLength = 96 // Length of a compressed BLS12-381 signature
+)
+
+var (
+	errBlsInvalidInputLength = errors.New("invalid bls input length")
+	errBlsInvalidPubkey      = errors.New("invalid bls public key")
+	errBlsInvalidSignature   = errors.New("invalid bls signature")
+)
+
+// blsPubkeys decodes a concatenation of compressed public keys. At least one
+// key must be present.
+func blsPubkeys(input []byte) ([]*bls.PublicKey, error) {
+	if len(input) == 0 || len(input)%blsPubkeyLength != 0 {
+		return nil, errBlsInvalidInputLength
+	}
+	keys := make([]*bls.PublicKey, 0, len(input)/blsPubkeyLength)
+	for i := 0; i < len(input); i += blsPubkeyLength {
+		key, err := bls.PublicKeyFromBytes(input[i : i+blsPubkeyLength])
+		if err != nil {
+			return nil, errBlsInvalidPubkey
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// blsVerify implements a BLS12-381 signature verification pre-compile.
+//
+// Input: message (32 bytes) | domain (8 bytes) | pubkey (48 bytes) | signature (96 bytes)
+type blsVerify struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *blsVerify) RequiredGas(input []byte) uint64 {
+	return params.BlsVerifyGas
+}
+
+func (c *blsVerify) Run(input []byte) ([]byte, error) {
+	if len(input) != blsMessageLength+blsDomainLength+blsPubkeyLength+blsSignatureLength {
+		return nil, errBlsInvalidInputLength
+	}
+	msg, domain := input[:blsMessageLength], binary.LittleEndian.Uint64(input[blsMessageLength:])
+	input = input[blsMessageLength+blsDomainLength:]
+
+	key, err := bls.PublicKeyFromBytes(input[:blsPubkeyLength])
+	if err != nil {
+		return nil, errBlsInvalidPubkey
+	}
+	sig, err := bls.SignatureFromBytes(input[blsPubkeyLength:])
+	if err != nil {
+		return nil, errBlsInvalidSignature
+	}
+	if sig.Verify(msg, key, domain) {
+		return true32Byte, nil
+	}
+	return false32Byte, nil
+}
+
+// blsVerifyAggregate implements a pre-compile verifying an aggregate BLS12-381
+// signature of several public keys over a common message, as produced by
+// masternodes. Callers must only pass keys with a proven possession of the
+// secret key, otherwise the check is open to rogue key attacks.
+//
+// Input: message (32 bytes) | domain (8 bytes) | signature (96 bytes) | pubkeys (48 bytes each)
+type blsVerifyAggregate struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *blsVerifyAggregate) RequiredGas(input []byte) uint64 {
+	var keys uint64
+	if len(input) > blsMessageLength+blsDomainLength+blsSignatureLength {
+		keys = uint64(len(input)-blsMessageLength-blsDomainLength-blsSignatureLength) / blsPubkeyLength
+	}
+	return params.BlsAggregateBaseGas + keys*params.BlsPerPubkeyGas
+}
+
+func (c *blsVerifyAggregate) Run(input []byte) ([]byte, error) {
+	if len(input) < blsMessageLength+blsDomainLength+blsSignatureLength {
+		return nil, errBlsInvalidInputLength
+	}
+	msg, domain := input[:blsMessageLength], binary.LittleEndian.Uint64(input[blsMessageLength:])
+	input = input[blsMessageLength+blsDomainLength:]
+
+	sig, err := bls.SignatureFromBytes(input[:blsSignatureLength])
+	if err != nil {
+		return nil, errBlsInvalidSignature
+	}
+	keys, err := blsPubkeys(input[blsSignatureLength:])
+	if err != nil {
+		return nil, err
+	}
+	if sig.VerifyAggregate(keys, msg, domain) {
+		return true32Byte, nil
+	}
+	return false32Byte, nil
+}
+
+// blsAggregatePubkeys implements a BLS12-381 public key aggregation pre-compile,
+// returning the compressed aggregate of the given keys.
+//
+// Input: pubkeys (48 bytes each)
+type blsAggregatePubkeys struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *blsAggregatePubkeys) RequiredGas(input []byte) uint64 {
+	return params.BlsAggregatePubkeysGas + uint64(len(input)/blsPubkeyLength)*params.BlsPerPubkeyGas
+}
+
+func (c *blsAggregatePubkeys) Run(input []byte) ([]byte, error) {
+	keys, err := blsPubkeys(input)
+	if err != nil {
+		return nil, err
+	}
+	agg := keys[0]
+	for _, key := range keys[1:] {
+		agg = agg.Aggregate(key)
+	}
+	return agg.Marshal(), nil
+}
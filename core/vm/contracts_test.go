@@ -17,13 +17,31 @@
 package vm
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io/ioutil"
 	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/crypto/bls"
+	"github.com/ether-ark/etherark/params"
+	"gopkg.in/yaml.v2"
 )
 
+// precompiledFailureTest defines the input/error pairs for precompiled
+// contract failure tests.
+type precompiledFailureTest struct {
+	input         string
+	expectedError error
+	name          string
+}
+
 // precompiledTest defines the input/output pairs for precompiled contract tests.
 type precompiledTest struct {
 	input, expected string
@@ -32,6 +50,29 @@ type precompiledTest struct {
 	noBenchmark     bool // Benchmark primarily the worst-cases
 }
 
+// allPrecompiles does not map to the actual set of precompiles, as it also
+// contains the repriced Istanbul versions of the elliptic curve precompiles at
+// addresses of their own.
+var allPrecompiles = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}):    &ecrecover{},
+	common.BytesToAddress([]byte{2}):    &sha256hash{},
+	common.BytesToAddress([]byte{3}):    &ripemd160hash{},
+	common.BytesToAddress([]byte{4}):    &dataCopy{},
+	common.BytesToAddress([]byte{5}):    &bigModExp{},
+	common.BytesToAddress([]byte{6}):    &bn256AddByzantium{},
+	common.BytesToAddress([]byte{7}):    &bn256ScalarMulByzantium{},
+	common.BytesToAddress([]byte{8}):    &bn256PairingByzantium{},
+	common.BytesToAddress([]byte{9}):    &peeridrecover{},
+	common.BytesToAddress([]byte{10}):   &blake2F{},
+	common.BytesToAddress([]byte{11}):   &ecrecoverByPublicKey{},
+	common.BytesToAddress([]byte{12}):   &blsVerify{},
+	common.BytesToAddress([]byte{13}):   &blsVerifyAggregate{},
+	common.BytesToAddress([]byte{14}):   &blsAggregatePubkeys{},
+	common.BytesToAddress([]byte{0xf6}): &bn256AddIstanbul{},
+	common.BytesToAddress([]byte{0xf7}): &bn256ScalarMulIstanbul{},
+	common.BytesToAddress([]byte{0xf8}): &bn256PairingIstanbul{},
+}
+
 // modexpTests are the test and benchmark data for the modexp precompiled contract.
 var modexpTests = []precompiledTest{
 	{
@@ -336,12 +377,254 @@ var bn256PairingTests = []precompiledTest{
 	},
 }
 
+// blake2FMalformedInputTests are the EIP-152 test vectors for malformed input.
+var blake2FMalformedInputTests = []precompiledFailureTest{
+	{
+		input:         "",
+		expectedError: errBlake2FInvalidInputLength,
+		name:          "vector 0: empty input",
+	},
+	{
+		input:         "00000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b616263000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000003000000000000000000000000000000",
+		expectedError: errBlake2FInvalidInputLength,
+		name:          "vector 1: less than 213 bytes input",
+	},
+	{
+		input:         "0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b6162630000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000030000000000000000000000000000000100",
+		expectedError: errBlake2FInvalidInputLength,
+		name:          "vector 2: more than 213 bytes input",
+	},
+	{
+		input:         "0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000002",
+		expectedError: errBlake2FInvalidFinalFlag,
+		name:          "vector 3: malformed final block indicator flag",
+	},
+}
+
+// blake2FTests are the EIP-152 test vectors for the BLAKE2 F precompile,
+// compressing the "abc" message of RFC 7693.
+var blake2FTests = []precompiledTest{
+	{
+		input:    "0000000048c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "08c9bcf367e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d282e6ad7f520e511f6c3e2b8c68059b9442be0454267ce079217e1319cde05b",
+		name:     "vector 4",
+	},
+	{
+		input:    "0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+		name:     "vector 5",
+	},
+	{
+		input:    "0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000000",
+		expected: "75ab69d3190a562c51aef8d88f1c2775876944407270c42c9844252c26d2875298743e7f6d5ea2f2d3e8d226039cd31b4e426ac4f2d3d666a610c2116fde4735",
+		name:     "vector 6",
+	},
+	{
+		input:    "0000000148c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "b63a380cb2897d521994a85234ee2c181b5f844d2c624c002677e9703449d2fba551b3a8333bcdf5f2f7e08993d53923de3d64fcc68c034e717b9293fed7a421",
+		name:     "vector 7",
+	},
+}
+
+// blsMalformedInputTests are malformed inputs to the BLS12-381 precompiles.
+var blsMalformedInputTests = map[string][]precompiledFailureTest{
+	"0c": {
+		{input: "", expectedError: errBlsInvalidInputLength, name: "verify: empty input"},
+		{input: common.Bytes2Hex(make([]byte, 183)), expectedError: errBlsInvalidInputLength, name: "verify: less than 184 bytes input"},
+		{input: common.Bytes2Hex(make([]byte, 185)), expectedError: errBlsInvalidInputLength, name: "verify: more than 184 bytes input"},
+	},
+	"0d": {
+		{input: common.Bytes2Hex(make([]byte, 135)), expectedError: errBlsInvalidInputLength, name: "aggregate: less than 136 bytes input"},
+	},
+	"0e": {
+		{input: "", expectedError: errBlsInvalidInputLength, name: "pubkeys: empty input"},
+		{input: common.Bytes2Hex(make([]byte, 47)), expectedError: errBlsInvalidInputLength, name: "pubkeys: truncated key"},
+		{input: common.Bytes2Hex(make([]byte, 97)), expectedError: errBlsInvalidInputLength, name: "pubkeys: trailing bytes"},
+	},
+}
+
+// blsSpecTestFile resolves a BLS test file of the eth2 spec tests, which the
+// vectors of crypto/bls/spectest are generated from. The location of the spec
+// tests is taken from ETH2_SPEC_TESTS, or from the runfiles of a Bazel test.
+func blsSpecTestFile(file string) (string, bool) {
+	root := os.Getenv("ETH2_SPEC_TESTS")
+	if root == "" && os.Getenv("TEST_SRCDIR") != "" {
+		root = filepath.Join(os.Getenv("TEST_SRCDIR"), "eth2_spec_tests")
+	}
+	if root == "" {
+		return "", false
+	}
+	path := filepath.Join(root, "tests", "bls", file)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// loadBlsSpecTest decodes the test cases of a BLS spec test file, skipping the
+// test if the spec tests are not available.
+func loadBlsSpecTest(t testing.TB, file string, cases interface{}) {
+	path, ok := blsSpecTestFile(file)
+	if !ok {
+		t.Skipf("BLS spec test %s not available, set ETH2_SPEC_TESTS", file)
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", file, err)
+	}
+	if err := yaml.Unmarshal(blob, cases); err != nil {
+		t.Fatalf("failed to decode %s: %v", file, err)
+	}
+}
+
+// blsSpecDomain converts the domain of a spec test case into the little endian
+// encoding the precompiles expect. Older spec releases list domains as integers
+// rather than bytes.
+func blsSpecDomain(t testing.TB, domain string) []byte {
+	if strings.HasPrefix(domain, "0x") {
+		blob := common.FromHex(domain)
+		if len(blob) != blsDomainLength {
+			t.Fatalf("invalid spec test domain %s", domain)
+		}
+		return blob
+	}
+	n, err := strconv.ParseUint(domain, 0, 64)
+	if err != nil {
+		t.Fatalf("invalid spec test domain %s: %v", domain, err)
+	}
+	blob := make([]byte, blsDomainLength)
+	binary.LittleEndian.PutUint64(blob, n)
+	return blob
+}
+
+// blsTests assembles the verification and aggregation vectors of the BLS12-381
+// precompiles, keyed by precompile address, from the sign_msg and
+// aggregate_pubkeys spec tests of crypto/bls/spectest.
+func blsTests(t testing.TB) map[string][]precompiledTest {
+	var signs struct {
+		TestCases []struct {
+			Input struct {
+				Privkey string `yaml:"privkey"`
+				Message string `yaml:"message"`
+				Domain  string `yaml:"domain"`
+			} `yaml:"input"`
+			Output string `yaml:"output"`
+		} `yaml:"test_cases"`
+	}
+	loadBlsSpecTest(t, "sign_msg/sign_msg.yaml", &signs)
+
+	var aggregates struct {
+		TestCases []struct {
+			Input  []string `yaml:"input"`
+			Output string   `yaml:"output"`
+		} `yaml:"test_cases"`
+	}
+	loadBlsSpecTest(t, "aggregate_pubkeys/aggregate_pubkeys.yaml", &aggregates)
+
+	// Signatures of the same message and domain are aggregated to check them
+	// against the keys which signed them
+	type group struct {
+		msg, domain []byte
+		sigs        []*bls.Signature
+		pubkeys     []byte
+	}
+	var (
+		tests  = make(map[string][]precompiledTest)
+		groups = make(map[string]*group)
+		order  []string
+	)
+	for i, tt := range signs.TestCases {
+		var (
+			msg    = common.FromHex(tt.Input.Message)
+			domain = blsSpecDomain(t, tt.Input.Domain)
+			sig    = common.FromHex(tt.Output)
+		)
+		if len(msg) != blsMessageLength {
+			continue // Only message hashes can be verified on chain
+		}
+		key, err := bls.SecretKeyFromBytes(common.FromHex(tt.Input.Privkey))
+		if err != nil {
+			t.Fatalf("sign_msg %d: invalid private key: %v", i, err)
+		}
+		pubkey := key.PublicKey().Marshal()
+
+		wrong := common.CopyBytes(msg)
+		wrong[0] ^= 0xff
+		tests["0c"] = append(tests["0c"],
+			precompiledTest{
+				input:    common.Bytes2Hex(concat(msg, domain, pubkey, sig)),
+				expected: common.Bytes2Hex(true32Byte),
+				name:     fmt.Sprintf("sign_msg_%d", i),
+			},
+			precompiledTest{
+				input:       common.Bytes2Hex(concat(wrong, domain, pubkey, sig)),
+				expected:    common.Bytes2Hex(false32Byte),
+				name:        fmt.Sprintf("sign_msg_%d_wrong_message", i),
+				noBenchmark: true,
+			},
+		)
+		parsed, err := bls.SignatureFromBytes(sig)
+		if err != nil {
+			t.Fatalf("sign_msg %d: invalid signature: %v", i, err)
+		}
+		id := string(msg) + string(domain)
+		if groups[id] == nil {
+			groups[id] = &group{msg: msg, domain: domain}
+			order = append(order, id)
+		}
+		groups[id].sigs = append(groups[id].sigs, parsed)
+		groups[id].pubkeys = append(groups[id].pubkeys, pubkey...)
+	}
+	for i, id := range order {
+		g := groups[id]
+		sig := bls.AggregateSignatures(g.sigs).Marshal()
+
+		tests["0d"] = append(tests["0d"], precompiledTest{
+			input:    common.Bytes2Hex(concat(g.msg, g.domain, sig, g.pubkeys)),
+			expected: common.Bytes2Hex(true32Byte),
+			name:     fmt.Sprintf("sign_msg_group_%d_%d", i, len(g.sigs)),
+		})
+		if len(g.sigs) > 1 {
+			tests["0d"] = append(tests["0d"], precompiledTest{
+				input:       common.Bytes2Hex(concat(g.msg, g.domain, sig, g.pubkeys[blsPubkeyLength:])),
+				expected:    common.Bytes2Hex(false32Byte),
+				name:        fmt.Sprintf("sign_msg_group_%d_missing_key", i),
+				noBenchmark: true,
+			})
+		}
+	}
+	for i, tt := range aggregates.TestCases {
+		var pubkeys []byte
+		for _, pubkey := range tt.Input {
+			pubkeys = append(pubkeys, common.FromHex(pubkey)...)
+		}
+		tests["0e"] = append(tests["0e"], precompiledTest{
+			input:    common.Bytes2Hex(pubkeys),
+			expected: common.Bytes2Hex(common.FromHex(tt.Output)),
+			name:     fmt.Sprintf("aggregate_pubkeys_%d", i),
+		})
+	}
+	return tests
+}
+
+// concat joins the given byte slices into a fresh one.
+func concat(parts ...[]byte) []byte {
+	var blob []byte
+	for _, part := range parts {
+		blob = append(blob, part...)
+	}
+	return blob
+}
+
 func testPrecompiled(addr string, test precompiledTest, t *testing.T) {
-	p := PrecompiledContractsByzantium[common.HexToAddress(addr)]
+	p := allPrecompiles[common.HexToAddress(addr)]
 	in := common.Hex2Bytes(test.input)
 	contract := NewContract(AccountRef(common.HexToAddress("1337")),
 		nil, new(big.Int), p.RequiredGas(in))
 	t.Run(fmt.Sprintf("%s-Gas=%d", test.name, contract.Gas), func(t *testing.T) {
+		if test.gas != 0 && contract.Gas != test.gas {
+			t.Errorf("Expected gas %v, got %v", test.gas, contract.Gas)
+		}
 		if res, err := RunPrecompiledContract(p, in, contract); err != nil {
 			t.Error(err)
 		} else if common.Bytes2Hex(res) != test.expected {
@@ -350,11 +633,24 @@ func testPrecompiled(addr string, test precompiledTest, t *testing.T) {
 	})
 }
 
+func testPrecompiledFailure(addr string, test precompiledFailureTest, t *testing.T) {
+	p := allPrecompiles[common.HexToAddress(addr)]
+	in := common.Hex2Bytes(test.input)
+	contract := NewContract(AccountRef(common.HexToAddress("31337")),
+		nil, new(big.Int), p.RequiredGas(in))
+	t.Run(test.name, func(t *testing.T) {
+		_, err := RunPrecompiledContract(p, in, contract)
+		if err != test.expectedError {
+			t.Errorf("Expected error [%v], got [%v]", test.expectedError, err)
+		}
+	})
+}
+
 func benchmarkPrecompiled(addr string, test precompiledTest, bench *testing.B) {
 	if test.noBenchmark {
 		return
 	}
-	p := PrecompiledContractsByzantium[common.HexToAddress(addr)]
+	p := allPrecompiles[common.HexToAddress(addr)]
 	in := common.Hex2Bytes(test.input)
 	reqGas := p.RequiredGas(in)
 	contract := NewContract(AccountRef(common.HexToAddress("1337")),
@@ -481,3 +777,133 @@ func BenchmarkPrecompiledBn256Pairing(bench *testing.B) {
 		benchmarkPrecompiled("08", test, bench)
 	}
 }
+
+// Tests that the elliptic curve precompiles are repriced by EIP 1108 in the
+// Istanbul release, leaving their results untouched.
+func TestPrecompiledBn256Istanbul(t *testing.T) {
+	tests := []struct {
+		addr  string
+		tests []precompiledTest
+		gas   func(input []byte) uint64
+	}{
+		{"f6", bn256AddTests, func([]byte) uint64 { return 150 }},
+		{"f7", bn256ScalarMulTests, func([]byte) uint64 { return 6000 }},
+		{"f8", bn256PairingTests, func(input []byte) uint64 { return 45000 + uint64(len(input)/192)*34000 }},
+	}
+	for _, tt := range tests {
+		for _, test := range tt.tests {
+			test.gas = tt.gas(common.Hex2Bytes(test.input))
+			testPrecompiled(tt.addr, test, t)
+		}
+	}
+}
+
+// Tests that the precompile sets of the Byzantium and Istanbul releases price
+// the elliptic curve operations according to their own rules.
+func TestPrecompiledBn256Gas(t *testing.T) {
+	pairings := make([]byte, 2*192)
+	tests := []struct {
+		addr      byte
+		input     []byte
+		byzantium uint64
+		istanbul  uint64
+	}{
+		{6, nil, 500, 150},
+		{7, nil, 40000, 6000},
+		{8, nil, 100000, 45000},
+		{8, pairings[:192], 180000, 79000},
+		{8, pairings, 260000, 113000},
+	}
+	for _, test := range tests {
+		addr := common.BytesToAddress([]byte{test.addr})
+		if gas := PrecompiledContractsByzantium[addr].RequiredGas(test.input); gas != test.byzantium {
+			t.Errorf("precompile %x, %d bytes: Byzantium gas mismatch: have %d, want %d", test.addr, len(test.input), gas, test.byzantium)
+		}
+		if gas := PrecompiledContractsIstanbul[addr].RequiredGas(test.input); gas != test.istanbul {
+			t.Errorf("precompile %x, %d bytes: Istanbul gas mismatch: have %d, want %d", test.addr, len(test.input), gas, test.istanbul)
+		}
+	}
+}
+
+// Tests the sample inputs from the BLAKE2 F compression EIP 152.
+func TestPrecompiledBlake2F(t *testing.T) {
+	for _, test := range blake2FTests {
+		testPrecompiled("0a", test, t)
+	}
+}
+
+// Tests that malformed BLAKE2 F inputs are rejected.
+func TestPrecompileBlake2FMalformedInput(t *testing.T) {
+	for _, test := range blake2FMalformedInputTests {
+		testPrecompiledFailure("0a", test, t)
+	}
+}
+
+// Benchmarks the sample inputs from the BLAKE2 F compression EIP 152.
+func BenchmarkPrecompiledBlake2F(bench *testing.B) {
+	for _, test := range blake2FTests {
+		benchmarkPrecompiled("0a", test, bench)
+	}
+}
+
+// Tests the BLS12-381 verification and aggregation precompiles against the
+// vectors of the eth2 BLS spec tests.
+func TestPrecompiledBls(t *testing.T) {
+	for addr, tests := range blsTests(t) {
+		for _, test := range tests {
+			testPrecompiled(addr, test, t)
+		}
+	}
+}
+
+// Tests that malformed BLS12-381 precompile inputs are rejected.
+func TestPrecompiledBlsMalformedInput(t *testing.T) {
+	for addr, tests := range blsMalformedInputTests {
+		for _, test := range tests {
+			testPrecompiledFailure(addr, test, t)
+		}
+	}
+}
+
+// Tests that the BLS12-381 precompiles only become callable at the BLS fork,
+// on top of the precompiles of the release in effect.
+func TestPrecompiledBlsActivation(t *testing.T) {
+	config := &params.ChainConfig{
+		ByzantiumBlock:      big.NewInt(0),
+		ConstantinopleBlock: big.NewInt(0),
+		IstanbulBlock:       big.NewInt(10),
+		BLSBlock:            big.NewInt(20),
+	}
+	tests := []struct {
+		number uint64
+		addr   byte
+		want   PrecompiledContract
+	}{
+		{0, 6, &bn256AddByzantium{}},
+		{0, 10, nil},
+		{0, 12, nil},
+		{10, 6, &bn256AddIstanbul{}},
+		{10, 10, &blake2F{}},
+		{10, 12, nil},
+		{20, 6, &bn256AddIstanbul{}},
+		{20, 10, &blake2F{}},
+		{20, 12, &blsVerify{}},
+		{20, 13, &blsVerifyAggregate{}},
+		{20, 14, &blsAggregatePubkeys{}},
+	}
+	for _, tt := range tests {
+		evm := NewEVM(Context{BlockNumber: new(big.Int).SetUint64(tt.number)}, nil, config, Config{})
+		if have := evm.precompile(common.BytesToAddress([]byte{tt.addr})); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("block %d, precompile %x: have %T, want %T", tt.number, tt.addr, have, tt.want)
+		}
+	}
+}
+
+// Benchmarks the BLS12-381 verification and aggregation precompiles.
+func BenchmarkPrecompiledBls(bench *testing.B) {
+	for addr, tests := range blsTests(bench) {
+		for _, test := range tests {
+			benchmarkPrecompiled(addr, test, bench)
+		}
+	}
+}
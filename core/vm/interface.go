@@ -61,6 +61,10 @@ type StateDB interface {
 	// is defined according to EIP161 (balance = nonce = code = 0).
 	Empty(common.Address) bool
 
+	PrepareAccessList(types.AccessList)
+	AddressInAccessList(common.Address) bool
+	SlotInAccessList(common.Address, common.Hash) bool
+
 	RevertToSnapshot(int)
 	Snapshot() int
 
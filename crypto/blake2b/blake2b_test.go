@@ -0,0 +1,56 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package blake2b
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// Tests the compression function against the BLAKE2b-512("abc") example of
+// RFC 7693, appendix A.
+func TestF(t *testing.T) {
+	h := iv
+	h[0] ^= 0x01010040 // no key, 64 byte digest
+
+	var m [16]uint64
+	m[0] = 0x636261 // "abc"
+
+	F(&h, m, [2]uint64{3, 0}, true, 12)
+
+	digest := make([]byte, 64)
+	for i, word := range h {
+		binary.LittleEndian.PutUint64(digest[i*8:], word)
+	}
+	want := "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"
+	if have := hex.EncodeToString(digest); have != want {
+		t.Fatalf("digest mismatch: have %s, want %s", have, want)
+	}
+}
+
+// Tests that with zero rounds the feed-forward cancels the state out, leaving
+// the initialization vector.
+func TestFZeroRounds(t *testing.T) {
+	h := iv
+	F(&h, [16]uint64{}, [2]uint64{}, false, 0)
+	for i := range h {
+		if want := iv[i]; h[i] != want {
+			t.Fatalf("word %d: have %x, want %x", i, h[i], want)
+		}
+	}
+}
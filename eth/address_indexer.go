@@ -0,0 +1,162 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/params"
+)
+
+const (
+	// addressIndexThrottling is the time to wait between processing two consecutive
+	// address index sections. It's useful during the initial indexing of a chain
+	// to prevent disk overload.
+	addressIndexThrottling = 100 * time.Millisecond
+)
+
+var (
+	// masternodeJoinEvent and masternodeQuitEvent are the topics of the events
+	// the masternode contract emits when a masternode joins or quits, both having
+	// the masternode id and its owner as data.
+	masternodeJoinEvent = crypto.Keccak256Hash([]byte("join(bytes8,address)"))
+	masternodeQuitEvent = crypto.Keccak256Hash([]byte("quit(bytes8,address)"))
+)
+
+// AddressIndexer implements a core.ChainIndexer, building up an index of the
+// transactions and block rewards every address takes part in.
+type AddressIndexer struct {
+	db     ethdb.Database      // database instance to write index data and metadata into
+	config *params.ChainConfig // chain configuration to derive transaction senders with
+	batch  ethdb.Batch         // batch collecting the entries of the section being processed
+}
+
+// NewAddressIndexer returns a chain indexer that generates the address transaction
+// index of the canonical chain.
+func NewAddressIndexer(db ethdb.Database, config *params.ChainConfig, size, confirms uint64) *core.ChainIndexer {
+	backend := &AddressIndexer{
+		db:     db,
+		config: config,
+	}
+	table := ethdb.NewTable(db, string(rawdb.AddressTxIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, confirms, addressIndexThrottling, "addresses")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new address index section.
+func (b *AddressIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	b.batch = b.db.NewBatch()
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the addresses taking part
+// in a new block into the index.
+func (b *AddressIndexer) Process(ctx context.Context, header *types.Header) error {
+	hash, number := header.Hash(), header.Number.Uint64()
+
+	body := rawdb.ReadBody(b.db, hash, number)
+	if body == nil {
+		return fmt.Errorf("block #%d [%x…] body not found", number, hash[:4])
+	}
+	receipts := rawdb.ReadReceipts(b.db, hash, number)
+	if len(receipts) != len(body.Transactions) {
+		return fmt.Errorf("block #%d [%x…] receipts not found", number, hash[:4])
+	}
+	block := types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
+
+	for address, roles := range addressRoles(block, receipts, types.MakeSigner(b.config, header.Number)) {
+		for index, role := range roles {
+			rawdb.WriteAddressTxEntry(b.batch, address, rawdb.AddressTxEntry{
+				BlockNumber: number,
+				BlockHash:   hash,
+				TxIndex:     index,
+				Roles:       role,
+			})
+		}
+	}
+	if b.batch.ValueSize() >= ethdb.IdealBatchSize {
+		if err := b.batch.Write(); err != nil {
+			return err
+		}
+		b.batch.Reset()
+	}
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing the address index section
+// out into the database.
+func (b *AddressIndexer) Commit() error {
+	return b.batch.Write()
+}
+
+// addressRoles returns the roles every address takes in the transactions of a
+// block, by transaction index, the block reward being indexed at
+// rawdb.AddressTxReward.
+func addressRoles(block *types.Block, receipts types.Receipts, signer types.Signer) map[common.Address]map[uint32]byte {
+	roles := make(map[common.Address]map[uint32]byte)
+
+	add := func(address common.Address, index uint32, role byte) {
+		if roles[address] == nil {
+			roles[address] = make(map[uint32]byte)
+		}
+		roles[address][index] |= role
+	}
+	for i, tx := range block.Transactions() {
+		index := uint32(i)
+
+		if from, err := types.Sender(signer, tx); err == nil {
+			add(from, index, rawdb.AddressTxFrom)
+		}
+		if to := tx.To(); to != nil {
+			add(*to, index, rawdb.AddressTxTo)
+		}
+		if i >= len(receipts) {
+			continue
+		}
+		receipt := receipts[i]
+		if tx.To() == nil && receipt.ContractAddress != (common.Address{}) {
+			add(receipt.ContractAddress, index, rawdb.AddressTxCreate)
+		}
+		// Value transferred by contracts, such as the masternode contract paying
+		// the masternode account and refunding quitting owners
+		for _, intx := range receipt.Intxs {
+			add(intx.From, index, rawdb.AddressTxInternal)
+			add(intx.To, index, rawdb.AddressTxInternal)
+		}
+		// Masternodes may be registered on behalf of another owner
+		for _, log := range receipt.Logs {
+			if log.Address != params.MasterndeContractAddress || len(log.Topics) == 0 || len(log.Data) != 64 {
+				continue
+			}
+			if log.Topics[0] == masternodeJoinEvent || log.Topics[0] == masternodeQuitEvent {
+				add(common.BytesToAddress(log.Data[32:]), index, rawdb.AddressTxMasternode)
+			}
+		}
+	}
+	if block.NumberU64() > 0 {
+		add(block.Coinbase(), rawdb.AddressTxReward, rawdb.AddressTxCoinbase)
+	}
+	return roles
+}
@@ -263,9 +263,12 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 		return stateDb.RawDump(), nil
 	}
 	var block *types.Block
-	if blockNr == rpc.LatestBlockNumber {
+	switch blockNr {
+	case rpc.LatestBlockNumber:
 		block = api.eth.blockchain.CurrentBlock()
-	} else {
+	case rpc.FinalizedBlockNumber:
+		block = api.eth.blockchain.CurrentFinalizedBlock()
+	default:
 		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
 	if block == nil {
@@ -460,3 +463,28 @@ func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Bloc
 	}
 	return dirty, nil
 }
+
+// maxIndexTail is the maximum number of blocks past a chain index to process on
+// demand, while the index is still being generated.
+const maxIndexTail = 1024
+
+// errIndexing is returned if blocks far past a chain index are requested while
+// the index is still being generated.
+var errIndexing = errors.New("index still being generated")
+
+// checkIndexTail returns the first block not yet covered by the sections of the
+// indexer, failing if the blocks from..number reach more than maxIndexTail blocks
+// past it.
+func checkIndexTail(indexer *core.ChainIndexer, sectionSize, from, number uint64) (uint64, error) {
+	sections, _, _ := indexer.Sections()
+	indexed := sections*sectionSize + params.GenesisBlockNumber
+
+	tail := indexed
+	if from > tail {
+		tail = from
+	}
+	if number >= tail && number-tail >= maxIndexTail {
+		return indexed, errIndexing
+	}
+	return indexed, nil
+}
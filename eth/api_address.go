@@ -0,0 +1,251 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/internal/ethapi"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+const (
+	// defaultAddressTxLimit is the number of entries returned in a page of address
+	// transactions when the query doesn't specify any.
+	defaultAddressTxLimit = 100
+
+	// maxAddressTxLimit is the maximum number of entries returned in a page of
+	// address transactions.
+	maxAddressTxLimit = 1000
+)
+
+// errInvalidCursor is returned if the cursor of an address transaction query
+// wasn't returned by a previous query.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// addressTxRoles are the names of the roles an address takes in a transaction,
+// indexed by bit.
+var addressTxRoles = []string{"from", "to", "create", "internal", "masternode", "coinbase"}
+
+// AddressTxQuery selects a page of the transaction history of an address.
+type AddressTxQuery struct {
+	FromBlock *rpc.BlockNumber `json:"fromBlock"` // Oldest block to return the entries of, genesis if nil
+	ToBlock   *rpc.BlockNumber `json:"toBlock"`   // Newest block to return the entries of, latest if nil
+	Cursor    hexutil.Bytes    `json:"cursor"`    // Position to resume at, as returned by a previous query
+	Limit     hexutil.Uint64   `json:"limit"`     // Maximum number of entries to return
+	FullTx    bool             `json:"fullTx"`    // Whether to return the full transactions
+}
+
+// AddressTx is a transaction, or block reward, involving an address.
+type AddressTx struct {
+	BlockNumber      hexutil.Uint64         `json:"blockNumber"`
+	BlockHash        common.Hash            `json:"blockHash"`
+	TransactionIndex *hexutil.Uint64        `json:"transactionIndex"` // nil for block rewards
+	TransactionHash  *common.Hash           `json:"transactionHash"`  // nil for block rewards
+	Roles            []string               `json:"roles"`
+	Transaction      *ethapi.RPCTransaction `json:"transaction,omitempty"`
+}
+
+// AddressTxPage is a page of the transaction history of an address, newest first.
+type AddressTxPage struct {
+	Transactions []*AddressTx  `json:"transactions"`
+	Cursor       hexutil.Bytes `json:"cursor"` // Position of the next page, nil if this is the last one
+}
+
+// PublicAddressAPI provides an API to access the transaction history of addresses,
+// as recorded by the address transaction index.
+type PublicAddressAPI struct {
+	eth *Ethereum
+}
+
+// NewPublicAddressAPI creates a new address transaction history API.
+func NewPublicAddressAPI(eth *Ethereum) *PublicAddressAPI {
+	return &PublicAddressAPI{eth: eth}
+}
+
+// GetTransactionsByAddress returns the canonical transactions and block rewards
+// the address takes part in, newest first. Besides sending and receiving them,
+// these include the contracts it created, the value transfers made to and by
+// it from within contracts and the masternodes it owns joining or quitting.
+//
+// Blocks past the address index are scanned on demand, which fails if there
+// are too many of them while the index is still being generated.
+func (api *PublicAddressAPI) GetTransactionsByAddress(ctx context.Context, address common.Address, query *AddressTxQuery) (*AddressTxPage, error) {
+	if query == nil {
+		query = new(AddressTxQuery)
+	}
+	limit := defaultAddressTxLimit
+	if query.Limit > 0 {
+		limit = int(query.Limit)
+		if limit > maxAddressTxLimit {
+			limit = maxAddressTxLimit
+		}
+	}
+	var (
+		chain = api.eth.blockchain
+		head  = chain.CurrentBlock().NumberU64()
+		from  = resolveBlockNumber(chain, query.FromBlock, 0)
+		to    = resolveBlockNumber(chain, query.ToBlock, head)
+	)
+	if to > head {
+		to = head
+	}
+	number, index := to, uint32(rawdb.AddressTxReward)
+	if len(query.Cursor) > 0 {
+		if len(query.Cursor) != 12 {
+			return nil, errInvalidCursor
+		}
+		number = binary.BigEndian.Uint64(query.Cursor)
+		index = binary.BigEndian.Uint32(query.Cursor[8:])
+		if number > to {
+			number, index = to, rawdb.AddressTxReward
+		}
+	}
+	page := &AddressTxPage{Transactions: []*AddressTx{}}
+	if from > number {
+		return page, nil
+	}
+	// Entries are collected until one more than the limit is found, that one
+	// becoming the cursor of the next page
+	add := func(block *types.Block, index uint32, roles byte) bool {
+		if len(page.Transactions) == limit {
+			page.Cursor = make(hexutil.Bytes, 12)
+			binary.BigEndian.PutUint64(page.Cursor, block.NumberU64())
+			binary.BigEndian.PutUint32(page.Cursor[8:], index)
+			return false
+		}
+		page.Transactions = append(page.Transactions, newAddressTx(block, index, roles, query.FullTx))
+		return true
+	}
+	// Scan the blocks not covered by the index yet directly, unless there are
+	// too many of them
+	indexed, err := checkIndexTail(api.eth.addressIndexer, params.AddressIndexBlocks, from, number)
+	if err != nil {
+		return nil, err
+	}
+	for ; number >= indexed && number >= from; number, index = number-1, rawdb.AddressTxReward {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			return nil, errors.New("block not found")
+		}
+		receipts := chain.GetReceiptsByHash(block.Hash())
+		roles := addressRoles(block, receipts, types.MakeSigner(api.eth.chainConfig, block.Number()))[address]
+
+		indexes := make([]uint32, 0, len(roles))
+		for i := range roles {
+			if i <= index {
+				indexes = append(indexes, i)
+			}
+		}
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] > indexes[j] })
+		for _, i := range indexes {
+			if !add(block, i, roles[i]) {
+				return page, nil
+			}
+		}
+		if number == 0 {
+			return page, nil
+		}
+	}
+	if number < from {
+		return page, nil
+	}
+	// Iterate the index, skipping the entries of blocks reorged out
+	var (
+		db      = api.eth.ChainDb()
+		failure error
+	)
+	err = rawdb.IterateAddressTxEntries(db, address, number, index, func(entry rawdb.AddressTxEntry) bool {
+		if entry.BlockNumber < from {
+			return false
+		}
+		if rawdb.ReadCanonicalHash(db, entry.BlockNumber) != entry.BlockHash {
+			return true
+		}
+		if failure = ctx.Err(); failure != nil {
+			return false
+		}
+		block := chain.GetBlock(entry.BlockHash, entry.BlockNumber)
+		if block == nil || (entry.TxIndex != rawdb.AddressTxReward && int(entry.TxIndex) >= len(block.Transactions())) {
+			failure = errors.New("indexed transaction not found")
+			return false
+		}
+		return add(block, entry.TxIndex, entry.Roles)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return page, nil
+}
+
+// resolveBlockNumber returns the block number a query bound refers to, def if
+// nil.
+func resolveBlockNumber(chain *core.BlockChain, number *rpc.BlockNumber, def uint64) uint64 {
+	switch {
+	case number == nil:
+		return def
+	case *number == rpc.FinalizedBlockNumber:
+		if finalized := chain.CurrentFinalizedBlock(); finalized != nil {
+			return finalized.NumberU64()
+		}
+		return 0
+	case *number < 0:
+		return chain.CurrentBlock().NumberU64()
+	}
+	return uint64(*number)
+}
+
+// newAddressTx assembles the RPC representation of an address transaction entry.
+func newAddressTx(block *types.Block, index uint32, roles byte, fullTx bool) *AddressTx {
+	entry := &AddressTx{
+		BlockNumber: hexutil.Uint64(block.NumberU64()),
+		BlockHash:   block.Hash(),
+		Roles:       []string{},
+	}
+	for bit, name := range addressTxRoles {
+		if roles&(1<<uint(bit)) != 0 {
+			entry.Roles = append(entry.Roles, name)
+		}
+	}
+	if index == rawdb.AddressTxReward {
+		return entry
+	}
+	tx := block.Transactions()[index]
+	hash, pos := tx.Hash(), hexutil.Uint64(index)
+
+	entry.TransactionIndex = &pos
+	entry.TransactionHash = &hash
+	if fullTx {
+		entry.Transaction = ethapi.NewRPCTransaction(tx, block.Hash(), block.NumberU64(), uint64(index))
+	}
+	return entry
+}
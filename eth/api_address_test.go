@@ -0,0 +1,157 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/consensus/ethash"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+// Tests that the transaction history of an address is paged through correctly,
+// both over the indexed sections and the blocks past them.
+func TestGetTransactionsByAddress(t *testing.T) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		engine = ethash.NewFaker()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000)}},
+		}
+		genesis   = gspec.MustCommit(db)
+		recipient = common.Address{0x01}
+		miner     = common.Address{0x02}
+		signer    = types.HomesteadSigner{}
+	)
+	// Send a transaction to the recipient every third block and let it mine
+	// every fifth one
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 150, func(i int, block *core.BlockGen) {
+		if i%5 == 0 {
+			block.SetCoinbase(recipient)
+		} else {
+			block.SetCoinbase(miner)
+		}
+		if i%3 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), recipient, big.NewInt(1), params.TxGas, new(big.Int), nil), signer, testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	indexer := NewAddressIndexer(db, gspec.Config, params.AddressIndexBlocks, 0)
+	defer indexer.Close()
+	indexer.Start(blockchain)
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if sections, _, _ := indexer.Sections(); sections == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("address index not generated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Assemble the expected history of the recipient, newest first
+	var want []*AddressTx
+	for _, block := range chain {
+		i := int(block.NumberU64()) - 1
+		if i%3 == 0 {
+			hash, index := block.Transactions()[0].Hash(), hexutil.Uint64(0)
+			want = append([]*AddressTx{{
+				BlockNumber:      hexutil.Uint64(block.NumberU64()),
+				BlockHash:        block.Hash(),
+				TransactionIndex: &index,
+				TransactionHash:  &hash,
+				Roles:            []string{"to"},
+			}}, want...)
+		}
+		if i%5 == 0 {
+			want = append([]*AddressTx{{
+				BlockNumber: hexutil.Uint64(block.NumberU64()),
+				BlockHash:   block.Hash(),
+				Roles:       []string{"coinbase"},
+			}}, want...)
+		}
+	}
+	api := NewPublicAddressAPI(&Ethereum{
+		chainConfig:    gspec.Config,
+		chainDb:        db,
+		blockchain:     blockchain,
+		addressIndexer: indexer,
+	})
+	// Page through the whole history, crossing into the indexed sections
+	var (
+		have  []*AddressTx
+		query = &AddressTxQuery{Limit: 7}
+	)
+	for {
+		page, err := api.GetTransactionsByAddress(context.Background(), recipient, query)
+		if err != nil {
+			t.Fatalf("failed to retrieve transactions: %v", err)
+		}
+		have = append(have, page.Transactions...)
+		if page.Cursor == nil {
+			break
+		}
+		if len(page.Transactions) != 7 {
+			t.Fatalf("partial page with cursor: have %d entries", len(page.Transactions))
+		}
+		query.Cursor = page.Cursor
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("history mismatch: have %d entries, want %d", len(have), len(want))
+	}
+	// Restrict the history to a block range spanning both sources
+	from, to := rpc.BlockNumber(100), rpc.BlockNumber(140)
+	page, err := api.GetTransactionsByAddress(context.Background(), recipient, &AddressTxQuery{FromBlock: &from, ToBlock: &to})
+	if err != nil {
+		t.Fatalf("failed to retrieve ranged transactions: %v", err)
+	}
+	var ranged []*AddressTx
+	for _, entry := range want {
+		if entry.BlockNumber >= 100 && entry.BlockNumber <= 140 {
+			ranged = append(ranged, entry)
+		}
+	}
+	if !reflect.DeepEqual(page.Transactions, ranged) {
+		t.Fatalf("ranged history mismatch: have %d entries, want %d", len(page.Transactions), len(ranged))
+	}
+	// Ensure the sender is indexed too and full transactions are returned
+	page, err = api.GetTransactionsByAddress(context.Background(), testBank, &AddressTxQuery{Limit: 1, FullTx: true})
+	if err != nil {
+		t.Fatalf("failed to retrieve sender transactions: %v", err)
+	}
+	if len(page.Transactions) != 1 || page.Transactions[0].Transaction == nil || page.Transactions[0].Roles[0] != "from" {
+		t.Fatalf("unexpected sender transactions: %v", page.Transactions)
+	}
+}
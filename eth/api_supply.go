@@ -0,0 +1,207 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+// RPCSupply is the coin supply at a block. The balances held by the masternode
+// contract and the burn address are nil if the state of the block was not
+// available when the block was indexed.
+type RPCSupply struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Rewards     *hexutil.Big   `json:"rewards"`           // Coins minted by the block as rewards
+	Issued      *hexutil.Big   `json:"issued"`            // Coins allocated in the genesis and minted up to the block
+	Locked      *hexutil.Big   `json:"locked"`            // Coins deposited in the masternode contract
+	Burned      *hexutil.Big   `json:"burned"`            // Coins sent to the burn address
+	Supply      *hexutil.Big   `json:"supply"`            // Coins issued and not burned
+	Circulating *hexutil.Big   `json:"circulatingSupply"` // Coins issued and neither burned nor locked
+}
+
+// RPCIssuance is the change of the coin supply over a range of blocks. The
+// balance changes are nil if the state of either end of the range was not
+// available when it was indexed.
+type RPCIssuance struct {
+	FromBlock hexutil.Uint64 `json:"fromBlock"`
+	ToBlock   hexutil.Uint64 `json:"toBlock"`
+	Issued    *hexutil.Big   `json:"issued"` // Coins minted in the range, including the genesis allocation if it starts at genesis
+	Locked    *hexutil.Big   `json:"locked"` // Change of the coins deposited in the masternode contract
+	Burned    *hexutil.Big   `json:"burned"` // Coins burned in the range
+	Supply    *hexutil.Big   `json:"supply"` // Change of the coins issued and not burned
+}
+
+// PublicSupplyAPI provides an API to access the coin supply accounting recorded
+// by the supply index.
+type PublicSupplyAPI struct {
+	eth *Ethereum
+}
+
+// NewPublicSupplyAPI creates a new coin supply API.
+func NewPublicSupplyAPI(eth *Ethereum) *PublicSupplyAPI {
+	return &PublicSupplyAPI{eth: eth}
+}
+
+// GetSupply returns the coins issued, locked in the masternode contract, burned
+// and circulating at the given block.
+func (api *PublicSupplyAPI) GetSupply(ctx context.Context, number rpc.BlockNumber) (*RPCSupply, error) {
+	block, err := api.resolve(number)
+	if err != nil {
+		return nil, err
+	}
+	hash, record, err := api.supply(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	supply := &RPCSupply{
+		BlockNumber: hexutil.Uint64(block),
+		BlockHash:   hash,
+		Rewards:     (*hexutil.Big)(record.Rewards),
+		Issued:      (*hexutil.Big)(record.Issued),
+	}
+	if record.Tracked {
+		available := new(big.Int).Sub(record.Issued, record.Burned)
+
+		supply.Locked = (*hexutil.Big)(record.Locked)
+		supply.Burned = (*hexutil.Big)(record.Burned)
+		supply.Supply = (*hexutil.Big)(available)
+		supply.Circulating = (*hexutil.Big)(new(big.Int).Sub(available, record.Locked))
+	}
+	return supply, nil
+}
+
+// GetIssuance returns the coins minted, locked in the masternode contract and
+// burned by the blocks of the given range, both ends included.
+func (api *PublicSupplyAPI) GetIssuance(ctx context.Context, fromBlock, toBlock rpc.BlockNumber) (*RPCIssuance, error) {
+	from, err := api.resolve(fromBlock)
+	if err != nil {
+		return nil, err
+	}
+	to, err := api.resolve(toBlock)
+	if err != nil {
+		return nil, err
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid block range #%d-#%d", from, to)
+	}
+	_, last, err := api.supply(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	// Starting at genesis, compare to nothing having been issued yet
+	first := &rawdb.SupplyRecord{Issued: new(big.Int), Tracked: true, Locked: new(big.Int), Burned: new(big.Int)}
+	if from > params.GenesisBlockNumber {
+		if _, first, err = api.supply(ctx, from-1); err != nil {
+			return nil, err
+		}
+	}
+	issuance := &RPCIssuance{
+		FromBlock: hexutil.Uint64(from),
+		ToBlock:   hexutil.Uint64(to),
+		Issued:    (*hexutil.Big)(new(big.Int).Sub(last.Issued, first.Issued)),
+	}
+	if first.Tracked && last.Tracked {
+		burned := new(big.Int).Sub(last.Burned, first.Burned)
+
+		issuance.Locked = (*hexutil.Big)(new(big.Int).Sub(last.Locked, first.Locked))
+		issuance.Burned = (*hexutil.Big)(burned)
+		issuance.Supply = (*hexutil.Big)(new(big.Int).Sub(issuance.Issued.ToInt(), burned))
+	}
+	return issuance, nil
+}
+
+// resolve returns the number of the canonical block a query refers to.
+func (api *PublicSupplyAPI) resolve(number rpc.BlockNumber) (uint64, error) {
+	chain := api.eth.blockchain
+
+	switch number {
+	case rpc.PendingBlockNumber:
+		return 0, errors.New("supply of pending block not available")
+	case rpc.LatestBlockNumber:
+		return chain.CurrentBlock().NumberU64(), nil
+	case rpc.FinalizedBlockNumber:
+		if finalized := chain.CurrentFinalizedBlock(); finalized != nil {
+			return finalized.NumberU64(), nil
+		}
+		return 0, errors.New("no finalized block")
+	}
+	if uint64(number) > chain.CurrentBlock().NumberU64() {
+		return 0, fmt.Errorf("block #%d not found", number)
+	}
+	return uint64(number), nil
+}
+
+// supply retrieves the supply accounting of a canonical block, reading it from
+// the index or deriving it from the last indexed block if past it.
+func (api *PublicSupplyAPI) supply(ctx context.Context, number uint64) (common.Hash, *rawdb.SupplyRecord, error) {
+	var (
+		chain = api.eth.blockchain
+		db    = api.eth.ChainDb()
+	)
+	// The supply past the index is derived from the last indexed block on
+	indexed, err := checkIndexTail(api.eth.supplyIndexer, params.SupplyIndexBlocks, params.GenesisBlockNumber, number)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	if number < indexed {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		record := rawdb.ReadSupplyRecord(db, hash, number)
+		if record == nil {
+			return common.Hash{}, nil, fmt.Errorf("block #%d supply not indexed", number)
+		}
+		return hash, record, nil
+	}
+	// Accumulate the rewards of the blocks past the index
+	var issued *big.Int
+	if indexed > params.GenesisBlockNumber {
+		hash := rawdb.ReadCanonicalHash(db, indexed-1)
+		record := rawdb.ReadSupplyRecord(db, hash, indexed-1)
+		if record == nil {
+			return common.Hash{}, nil, fmt.Errorf("block #%d supply not indexed", indexed-1)
+		}
+		issued = record.Issued
+	}
+	rewards := supplyRewards(api.eth.chainConfig, api.eth.engine)
+	for n := indexed; ; n++ {
+		if err := ctx.Err(); err != nil {
+			return common.Hash{}, nil, err
+		}
+		header := chain.GetHeaderByNumber(n)
+		if header == nil {
+			return common.Hash{}, nil, fmt.Errorf("block #%d not found", n)
+		}
+		record, err := blockSupply(chain, rewards, header, issued)
+		if err != nil {
+			return common.Hash{}, nil, err
+		}
+		if n == number {
+			trackSupply(chain, header, record)
+			return header.Hash(), record, nil
+		}
+		issued = record.Issued
+	}
+}
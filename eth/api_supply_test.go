@@ -0,0 +1,144 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/consensus/ethash"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+// Tests that the coin supply is accounted correctly, both over the indexed
+// sections and the blocks past them.
+func TestGetSupply(t *testing.T) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		engine = ethash.NewFaker()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		miner   = common.Address{0x02}
+		signer  = types.HomesteadSigner{}
+	)
+	// Burn some coins every third block and lock some every fifth one
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 150, func(i int, block *core.BlockGen) {
+		block.SetCoinbase(miner)
+		if i%3 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), supplyBurnAddress, big.NewInt(10), params.TxGas, new(big.Int), nil), signer, testBankKey)
+			block.AddTx(tx)
+		}
+		if i%5 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), params.MasterndeContractAddress, big.NewInt(100), params.TxGas, new(big.Int), nil), signer, testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	indexer := NewSupplyIndexer(blockchain, db, supplyRewards(gspec.Config, engine), params.SupplyIndexBlocks, 0)
+	defer indexer.Close()
+	indexer.Start(blockchain)
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if sections, _, _ := indexer.Sections(); sections == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("supply index not generated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	api := NewPublicSupplyAPI(&Ethereum{
+		chainConfig:   gspec.Config,
+		chainDb:       db,
+		blockchain:    blockchain,
+		engine:        engine,
+		supplyIndexer: indexer,
+	})
+	// Every coin in existence is held by one of the accounts touched
+	for _, number := range []uint64{0, 1, 64, 100, 128, 140, 150} {
+		supply, err := api.GetSupply(context.Background(), rpc.BlockNumber(number))
+		if err != nil {
+			t.Fatalf("block #%d: failed to retrieve supply: %v", number, err)
+		}
+		statedb, _ := blockchain.StateAt(blockchain.GetBlockByNumber(number).Root())
+
+		issued := new(big.Int)
+		for _, account := range []common.Address{testBank, miner, supplyBurnAddress, params.MasterndeContractAddress} {
+			issued.Add(issued, statedb.GetBalance(account))
+		}
+		if supply.Issued.ToInt().Cmp(issued) != 0 {
+			t.Errorf("block #%d: issued mismatch: have %v, want %v", number, supply.Issued, issued)
+		}
+		if supply.Locked == nil || supply.Locked.ToInt().Cmp(statedb.GetBalance(params.MasterndeContractAddress)) != 0 {
+			t.Errorf("block #%d: locked mismatch: have %v, want %v", number, supply.Locked, statedb.GetBalance(params.MasterndeContractAddress))
+		}
+		if supply.Burned == nil || supply.Burned.ToInt().Cmp(statedb.GetBalance(supplyBurnAddress)) != 0 {
+			t.Errorf("block #%d: burned mismatch: have %v, want %v", number, supply.Burned, statedb.GetBalance(supplyBurnAddress))
+		}
+		circulating := new(big.Int).Add(statedb.GetBalance(testBank), statedb.GetBalance(miner))
+		if supply.Circulating == nil || supply.Circulating.ToInt().Cmp(circulating) != 0 {
+			t.Errorf("block #%d: circulating mismatch: have %v, want %v", number, supply.Circulating, circulating)
+		}
+	}
+	// Without a finality depth there is no finalized block to answer for
+	if _, err := api.GetSupply(context.Background(), rpc.FinalizedBlockNumber); err == nil {
+		t.Errorf("supply of missing finalized block returned")
+	}
+	// Ensure the issuance over a range spanning both sources adds up
+	issuance, err := api.GetIssuance(context.Background(), rpc.BlockNumber(10), rpc.BlockNumber(140))
+	if err != nil {
+		t.Fatalf("failed to retrieve issuance: %v", err)
+	}
+	rewards := new(big.Int).Mul(ethash.BlockRewards(gspec.Config, chain[0].Header(), nil), big.NewInt(131))
+	if issuance.Issued.ToInt().Cmp(rewards) != 0 {
+		t.Errorf("issued mismatch: have %v, want %v", issuance.Issued, rewards)
+	}
+	var burned, locked int64
+	for i := 9; i < 140; i++ {
+		if i%3 == 0 {
+			burned += 10
+		}
+		if i%5 == 0 {
+			locked += 100
+		}
+	}
+	if issuance.Burned == nil || issuance.Burned.ToInt().Int64() != burned {
+		t.Errorf("burned mismatch: have %v, want %d", issuance.Burned, burned)
+	}
+	if issuance.Locked == nil || issuance.Locked.ToInt().Int64() != locked {
+		t.Errorf("locked mismatch: have %v, want %d", issuance.Locked, locked)
+	}
+	if want := new(big.Int).Sub(rewards, big.NewInt(burned)); issuance.Supply.ToInt().Cmp(want) != 0 {
+		t.Errorf("supply change mismatch: have %v, want %v", issuance.Supply, want)
+	}
+}
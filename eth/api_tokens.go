@@ -0,0 +1,421 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/log"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+const (
+	// defaultTokenTransferLimit is the number of entries returned in a page of
+	// token transfers when the query doesn't specify any.
+	defaultTokenTransferLimit = 100
+
+	// maxTokenTransferLimit is the maximum number of entries returned in a page
+	// of token transfers.
+	maxTokenTransferLimit = 1000
+)
+
+var (
+	// errNoTokenFilter is returned if a token transfer query selects neither a
+	// holder nor a token.
+	errNoTokenFilter = errors.New("holder or token required")
+
+	// balanceOfSelector is the method id of balanceOf(address), shared by the
+	// ERC-20 and ERC-721 token standards.
+	balanceOfSelector = crypto.Keccak256([]byte("balanceOf(address)"))[:4]
+)
+
+// tokenStandards are the names of the token standards, indexed by transfer kind.
+var tokenStandards = map[byte]string{
+	rawdb.TokenTransferERC20:  "erc20",
+	rawdb.TokenTransferERC721: "erc721",
+}
+
+// TokenTransferQuery selects a page of the token transfers of a holder, of a
+// token, or of a holder in a single token.
+type TokenTransferQuery struct {
+	Holder    *common.Address  `json:"holder"`    // Party to the transfers to return
+	Token     *common.Address  `json:"token"`     // Token of the transfers to return
+	FromBlock *rpc.BlockNumber `json:"fromBlock"` // Oldest block to return the transfers of, genesis if nil
+	ToBlock   *rpc.BlockNumber `json:"toBlock"`   // Newest block to return the transfers of, latest if nil
+	Cursor    hexutil.Bytes    `json:"cursor"`    // Position to resume at, as returned by a previous query
+	Limit     hexutil.Uint64   `json:"limit"`     // Maximum number of transfers to return
+}
+
+// TokenBalanceQuery selects a page of the balance history of a holder.
+type TokenBalanceQuery struct {
+	FromBlock *rpc.BlockNumber `json:"fromBlock"` // Oldest block to return the balances of, genesis if nil
+	ToBlock   *rpc.BlockNumber `json:"toBlock"`   // Newest block to return the balances of, latest if nil
+	Cursor    hexutil.Bytes    `json:"cursor"`    // Position to resume at, as returned by a previous query
+	Limit     hexutil.Uint64   `json:"limit"`     // Maximum number of balances to return
+}
+
+// RPCTokenTransfer is a transfer of tokens decoded from a Transfer event.
+type RPCTokenTransfer struct {
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	LogIndex         hexutil.Uint64 `json:"logIndex"`
+	Standard         string         `json:"standard"`
+	Token            common.Address `json:"token"`
+	From             common.Address `json:"from"`
+	To               common.Address `json:"to"`
+	Value            *hexutil.Big   `json:"value"` // Amount transferred, or the token id for ERC-721
+}
+
+// TokenTransferPage is a page of token transfers, newest first.
+type TokenTransferPage struct {
+	Transfers []*RPCTokenTransfer `json:"transfers"`
+	Cursor    hexutil.Bytes       `json:"cursor"` // Position of the next page, nil if this is the last one
+}
+
+// TokenBalance is the balance of a holder right after a transfer of the token.
+type TokenBalance struct {
+	*RPCTokenTransfer
+	Balance *hexutil.Big `json:"balance"`
+}
+
+// TokenBalancePage is a page of the balance history of a holder, newest first.
+type TokenBalancePage struct {
+	Balances []*TokenBalance `json:"balances"`
+	Cursor   hexutil.Bytes   `json:"cursor"` // Position of the next page, nil if this is the last one
+}
+
+// PublicTokenAPI provides an API to access the token transfers recorded by the
+// token transfer index.
+type PublicTokenAPI struct {
+	eth *Ethereum
+}
+
+// NewPublicTokenAPI creates a new token transfer API.
+func NewPublicTokenAPI(eth *Ethereum) *PublicTokenAPI {
+	return &PublicTokenAPI{eth: eth}
+}
+
+// GetTokenTransfers returns the canonical ERC-20 and ERC-721 transfers of a
+// holder, of a token, or of a holder in a single token, newest first.
+func (api *PublicTokenAPI) GetTokenTransfers(ctx context.Context, query TokenTransferQuery) (*TokenTransferPage, error) {
+	if query.Holder == nil && query.Token == nil {
+		return nil, errNoTokenFilter
+	}
+	if len(query.Cursor) != 0 && len(query.Cursor) != 12 {
+		return nil, errInvalidCursor
+	}
+	from, number, index := api.window(query.FromBlock, query.ToBlock, query.Cursor)
+
+	var (
+		limit = tokenPageLimit(query.Limit)
+		page  = &TokenTransferPage{Transfers: []*RPCTokenTransfer{}}
+	)
+	err := api.iterate(ctx, query.Holder, query.Token, from, number, index, func(transfer rawdb.TokenTransfer, block *types.Block) bool {
+		if len(page.Transfers) == limit {
+			page.Cursor = tokenCursor(transfer)
+			return false
+		}
+		page.Transfers = append(page.Transfers, newRPCTokenTransfer(block, transfer))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetTokenBalanceHistory returns the balances of a holder in a token right after
+// each of its canonical transfers, newest first. The history is derived from the
+// balance reported by the token at the newest block selected, so the state of
+// that block needs to be available. Tokens changing balances without emitting
+// Transfer events are not tracked accurately.
+func (api *PublicTokenAPI) GetTokenBalanceHistory(ctx context.Context, holder common.Address, token common.Address, query *TokenBalanceQuery) (*TokenBalancePage, error) {
+	if query == nil {
+		query = new(TokenBalanceQuery)
+	}
+	from, number, index := api.window(query.FromBlock, query.ToBlock, query.Cursor)
+
+	// Resume from the balance of the previous page, or query the token
+	var balance *big.Int
+	if len(query.Cursor) > 0 {
+		if len(query.Cursor) < 13 || query.Cursor[12] > 1 {
+			return nil, errInvalidCursor
+		}
+		balance = new(big.Int).SetBytes(query.Cursor[13:])
+		if query.Cursor[12] == 1 {
+			balance.Neg(balance)
+		}
+	} else {
+		var err error
+		if balance, err = api.balanceOf(holder, token, number); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		limit = tokenPageLimit(query.Limit)
+		page  = &TokenBalancePage{Balances: []*TokenBalance{}}
+	)
+	err := api.iterate(ctx, &holder, &token, from, number, index, func(transfer rawdb.TokenTransfer, block *types.Block) bool {
+		if len(page.Balances) == limit {
+			page.Cursor = tokenCursor(transfer)
+			if balance.Sign() < 0 {
+				page.Cursor = append(page.Cursor, 1)
+			} else {
+				page.Cursor = append(page.Cursor, 0)
+			}
+			page.Cursor = append(page.Cursor, balance.Bytes()...)
+			return false
+		}
+		page.Balances = append(page.Balances, &TokenBalance{
+			RPCTokenTransfer: newRPCTokenTransfer(block, transfer),
+			Balance:          (*hexutil.Big)(new(big.Int).Set(balance)),
+		})
+		// Undo the transfer to get the balance before it
+		amount := transfer.Value
+		if transfer.Kind == rawdb.TokenTransferERC721 {
+			amount = common.Big1
+		}
+		if transfer.To == holder {
+			balance.Sub(balance, amount)
+		}
+		if transfer.From == holder {
+			balance.Add(balance, amount)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// window resolves the block range of a query, returning the oldest block to
+// return the transfers of and the position to start returning them at.
+func (api *PublicTokenAPI) window(fromBlock, toBlock *rpc.BlockNumber, cursor hexutil.Bytes) (uint64, uint64, uint32) {
+	var (
+		chain = api.eth.blockchain
+		head  = chain.CurrentBlock().NumberU64()
+		from  = resolveBlockNumber(chain, fromBlock, 0)
+		to    = resolveBlockNumber(chain, toBlock, head)
+	)
+	if to > head {
+		to = head
+	}
+	number, index := to, uint32(math.MaxUint32)
+	if len(cursor) >= 12 {
+		if n := binary.BigEndian.Uint64(cursor); n <= to {
+			number, index = n, binary.BigEndian.Uint32(cursor[8:])
+		}
+	}
+	return from, number, index
+}
+
+// iterate calls fn with the canonical token transfers of a holder and/or token,
+// newest first, starting at the given block number and log index, until fn
+// returns false or the transfers of the oldest block are exhausted. Blocks past
+// the token index are scanned on demand, which fails if there are too many of
+// them while the index is still being generated.
+func (api *PublicTokenAPI) iterate(ctx context.Context, holder, token *common.Address, from, number uint64, index uint32, fn func(rawdb.TokenTransfer, *types.Block) bool) error {
+	if from > number {
+		return nil
+	}
+	match := func(transfer rawdb.TokenTransfer) bool {
+		if token != nil && transfer.Token != *token {
+			return false
+		}
+		if holder != nil && transfer.From != *holder && transfer.To != *holder {
+			return false
+		}
+		return true
+	}
+	// Scan the blocks not covered by the index yet directly, unless there are
+	// too many of them
+	chain := api.eth.blockchain
+	indexed, err := checkIndexTail(api.eth.tokenIndexer, params.TokenIndexBlocks, from, number)
+	if err != nil {
+		return err
+	}
+	for ; number >= indexed && number >= from; number, index = number-1, math.MaxUint32 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			return errors.New("block not found")
+		}
+		transfers := tokenTransfers(block.Hash(), number, chain.GetReceiptsByHash(block.Hash()))
+		for i := len(transfers) - 1; i >= 0; i-- {
+			if transfers[i].LogIndex > index || !match(transfers[i]) {
+				continue
+			}
+			if !fn(transfers[i], block) {
+				return nil
+			}
+		}
+		if number == 0 {
+			return nil
+		}
+	}
+	if number < from {
+		return nil
+	}
+	// Iterate the index, skipping the transfers of blocks reorged out
+	var (
+		db      = api.eth.ChainDb()
+		failure error
+	)
+	visit := func(transfer rawdb.TokenTransfer) bool {
+		if transfer.BlockNumber < from {
+			return false
+		}
+		if rawdb.ReadCanonicalHash(db, transfer.BlockNumber) != transfer.BlockHash || !match(transfer) {
+			return true
+		}
+		if failure = ctx.Err(); failure != nil {
+			return false
+		}
+		block := chain.GetBlock(transfer.BlockHash, transfer.BlockNumber)
+		if block == nil || int(transfer.TxIndex) >= len(block.Transactions()) {
+			failure = errors.New("indexed transfer not found")
+			return false
+		}
+		return fn(transfer, block)
+	}
+	if holder != nil {
+		err = rawdb.IterateHolderTransfers(db, *holder, number, index, visit)
+	} else {
+		err = rawdb.IterateTokenTransfers(db, *token, number, index, visit)
+	}
+	if err != nil {
+		return err
+	}
+	return failure
+}
+
+// balanceOf calls the balanceOf method of a token on the state of a block.
+func (api *PublicTokenAPI) balanceOf(holder, token common.Address, number uint64) (*big.Int, error) {
+	chain := api.eth.blockchain
+
+	block := chain.GetBlockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	statedb, err := chain.StateAt(block.Root())
+	if err != nil {
+		return nil, fmt.Errorf("state of block #%d not available: %v", number, err)
+	}
+	var (
+		data = append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(holder.Bytes(), 32)...)
+		msg  = types.NewMessage(common.Address{}, &token, 0, new(big.Int), block.GasLimit(), new(big.Int), data, nil, false)
+		evm  = vm.NewEVM(core.NewEVMContext(msg, block.Header(), chain, nil), statedb, api.eth.chainConfig, vm.Config{})
+	)
+	ret, _, failed, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(block.GasLimit()))
+	if err != nil {
+		return nil, err
+	}
+	if failed || len(ret) < 32 {
+		return nil, fmt.Errorf("balance of token %#x not available", token)
+	}
+	return new(big.Int).SetBytes(ret[:32]), nil
+}
+
+// PrivateTokenAPI provides an API to maintain the token transfer index.
+type PrivateTokenAPI struct {
+	eth *Ethereum
+}
+
+// NewPrivateTokenAPI creates a new token transfer index maintenance API.
+func NewPrivateTokenAPI(eth *Ethereum) *PrivateTokenAPI {
+	return &PrivateTokenAPI{eth: eth}
+}
+
+// RebuildTokenIndex discards the token transfer index from the section holding
+// the given block on, indexing the chain again from there in the background.
+// The transfers indexed from that section on are deleted beforehand, including
+// those of blocks reorged out since.
+func (api *PrivateTokenAPI) RebuildTokenIndex(number hexutil.Uint64) (bool, error) {
+	head := api.eth.blockchain.CurrentBlock().NumberU64()
+	if uint64(number) > head {
+		return false, fmt.Errorf("block #%d not yet imported", number)
+	}
+	// Stop the indexer at the section of the block before deleting the transfers
+	// from there on, so no section being processed writes them back
+	start := uint64(number) - uint64(number)%params.TokenIndexBlocks
+	err := api.eth.tokenIndexer.Rebuild(start, head, func() error {
+		deleted, err := rawdb.DeleteTokenTransfers(api.eth.ChainDb(), start)
+		if err != nil {
+			return err
+		}
+		log.Info("Deleted token transfers for rebuild", "number", start, "entries", deleted)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// tokenPageLimit returns the number of entries to return in a page of token
+// transfers.
+func tokenPageLimit(limit hexutil.Uint64) int {
+	switch {
+	case limit == 0:
+		return defaultTokenTransferLimit
+	case limit > maxTokenTransferLimit:
+		return maxTokenTransferLimit
+	}
+	return int(limit)
+}
+
+// tokenCursor encodes the position of a token transfer into a page cursor.
+func tokenCursor(transfer rawdb.TokenTransfer) hexutil.Bytes {
+	cursor := make(hexutil.Bytes, 12)
+	binary.BigEndian.PutUint64(cursor, transfer.BlockNumber)
+	binary.BigEndian.PutUint32(cursor[8:], transfer.LogIndex)
+	return cursor
+}
+
+// newRPCTokenTransfer assembles the RPC representation of a token transfer.
+func newRPCTokenTransfer(block *types.Block, transfer rawdb.TokenTransfer) *RPCTokenTransfer {
+	return &RPCTokenTransfer{
+		BlockNumber:      hexutil.Uint64(transfer.BlockNumber),
+		BlockHash:        transfer.BlockHash,
+		TransactionHash:  block.Transactions()[transfer.TxIndex].Hash(),
+		TransactionIndex: hexutil.Uint64(transfer.TxIndex),
+		LogIndex:         hexutil.Uint64(transfer.LogIndex),
+		Standard:         tokenStandards[transfer.Kind],
+		Token:            transfer.Token,
+		From:             transfer.From,
+		To:               transfer.To,
+		Value:            (*hexutil.Big)(transfer.Value),
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/consensus/ethash"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+// Tests that token transfers are paged through correctly, both over the indexed
+// sections and the blocks past them, and that balance histories are derived.
+func TestGetTokenTransfers(t *testing.T) {
+	// The token emits Transfer(from, to, value) with the words of the call data,
+	// and reports a balance of 100 for everyone
+	code := common.FromHex("36602414603a57604035600052602035600035" +
+		"7f" + tokenTransferEvent.Hex()[2:] +
+		"60206000a3005b606460005260206000f3")
+
+	var (
+		db     = ethdb.NewMemDatabase()
+		engine = ethash.NewFaker()
+		token  = common.Address{0x7e}
+		holder = common.Address{0xaa}
+		other  = common.Address{0xbb}
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000)},
+				token:    {Code: code, Balance: new(big.Int)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	transfers := map[int][3]common.Address{
+		9:   {{}, holder},    // mint 60 to the holder
+		69:  {holder, other}, // send 10 away
+		139: {other, holder}, // receive 50
+	}
+	values := map[int]int64{9: 60, 69: 10, 139: 50}
+
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 150, func(i int, block *core.BlockGen) {
+		parties, ok := transfers[i]
+		if !ok {
+			return
+		}
+		data := append(common.LeftPadBytes(parties[0].Bytes(), 32), common.LeftPadBytes(parties[1].Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(big.NewInt(values[i]).Bytes(), 32)...)
+
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), token, new(big.Int), 100000, new(big.Int), data), signer, testBankKey)
+		block.AddTx(tx)
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	indexer := NewTokenIndexer(db, params.TokenIndexBlocks, 0)
+	defer indexer.Close()
+	indexer.Start(blockchain)
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if sections, _, _ := indexer.Sections(); sections == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("token index not generated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	api := NewPublicTokenAPI(&Ethereum{
+		chainConfig:  gspec.Config,
+		chainDb:      db,
+		blockchain:   blockchain,
+		tokenIndexer: indexer,
+	})
+	// Page through the transfers of the holder one by one, crossing into the
+	// indexed sections
+	var (
+		have  []*RPCTokenTransfer
+		query = TokenTransferQuery{Holder: &holder, Limit: 1}
+	)
+	for {
+		page, err := api.GetTokenTransfers(context.Background(), query)
+		if err != nil {
+			t.Fatalf("failed to retrieve transfers: %v", err)
+		}
+		have = append(have, page.Transfers...)
+		if page.Cursor == nil {
+			break
+		}
+		query.Cursor = page.Cursor
+	}
+	if len(have) != 3 {
+		t.Fatalf("transfer count mismatch: have %d, want 3", len(have))
+	}
+	for i, number := range []uint64{140, 70, 10} {
+		var (
+			block   = chain[number-1]
+			parties = transfers[int(number)-1]
+		)
+		want := &RPCTokenTransfer{
+			BlockNumber:     hexutil.Uint64(number),
+			BlockHash:       block.Hash(),
+			TransactionHash: block.Transactions()[0].Hash(),
+			Standard:        "erc20",
+			Token:           token,
+			From:            parties[0],
+			To:              parties[1],
+		}
+		if have[i].BlockNumber != want.BlockNumber || have[i].BlockHash != want.BlockHash || have[i].TransactionHash != want.TransactionHash ||
+			have[i].Standard != want.Standard || have[i].Token != want.Token || have[i].From != want.From || have[i].To != want.To ||
+			have[i].Value.ToInt().Int64() != values[int(number)-1] {
+			t.Errorf("transfer %d mismatch: have %+v, want %+v", i, have[i], want)
+		}
+	}
+	// Filter the transfers by token and block range
+	from, to := rpc.BlockNumber(50), rpc.BlockNumber(145)
+	page, err := api.GetTokenTransfers(context.Background(), TokenTransferQuery{Token: &token, FromBlock: &from, ToBlock: &to})
+	if err != nil {
+		t.Fatalf("failed to retrieve token transfers: %v", err)
+	}
+	if len(page.Transfers) != 2 || page.Transfers[0].BlockNumber != 140 || page.Transfers[1].BlockNumber != 70 {
+		t.Fatalf("unexpected ranged token transfers: %v", page.Transfers)
+	}
+	if _, err := api.GetTokenTransfers(context.Background(), TokenTransferQuery{}); err != errNoTokenFilter {
+		t.Fatalf("unfiltered query error mismatch: have %v, want %v", err, errNoTokenFilter)
+	}
+	// Page through the balance history of the holder, anchored at the balance
+	// reported by the token
+	var (
+		balances []int64
+		bquery   = &TokenBalanceQuery{Limit: 2}
+	)
+	for {
+		page, err := api.GetTokenBalanceHistory(context.Background(), holder, token, bquery)
+		if err != nil {
+			t.Fatalf("failed to retrieve balance history: %v", err)
+		}
+		for _, balance := range page.Balances {
+			balances = append(balances, balance.Balance.ToInt().Int64())
+		}
+		if page.Cursor == nil {
+			break
+		}
+		bquery.Cursor = page.Cursor
+	}
+	if want := []int64{100, 50, 60}; len(balances) != len(want) || balances[0] != want[0] || balances[1] != want[1] || balances[2] != want[2] {
+		t.Fatalf("balance history mismatch: have %v, want %v", balances, want)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/consensus/ethash"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/internal/ethapi"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+// Tests that calls are traced on top of the state of the requested block, with
+// the state overrides applied, without modifying the state of the chain.
+func TestTraceCall(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		engine  = ethash.NewFaker()
+		counter = common.HexToAddress("0xc0ffee")
+		// Increments storage slot 0, returning its new value
+		code  = common.FromHex("0x6000546001018060005560005260206000f3")
+		gspec = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000)},
+				counter: {
+					Balance: new(big.Int),
+					Code:    code,
+					Storage: map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(5))},
+				},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 2, func(i int, block *core.BlockGen) {
+		if i == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), counter, new(big.Int), 100000, new(big.Int), nil), signer, testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	api := NewPrivateDebugAPI(gspec.Config, &Ethereum{
+		chainConfig: gspec.Config,
+		chainDb:     db,
+		blockchain:  blockchain,
+		engine:      engine,
+	})
+	decrement := hexutil.Bytes(common.FromHex("0x6001600054038060005560005260206000f3"))
+	tests := []struct {
+		block     rpc.BlockNumber
+		overrides *ethapi.StateOverride
+		want      int64
+	}{
+		{0, nil, 6},
+		{rpc.LatestBlockNumber, nil, 7},
+		{rpc.LatestBlockNumber, &ethapi.StateOverride{
+			counter: ethapi.OverrideAccount{StateDiff: map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(9))}},
+		}, 10},
+		{rpc.LatestBlockNumber, &ethapi.StateOverride{
+			// Decrements storage slot 0 instead, returning its new value
+			counter: ethapi.OverrideAccount{Code: &decrement},
+		}, 5},
+	}
+	for i, tt := range tests {
+		args := ethapi.CallArgs{From: testBank, To: &counter}
+		result, err := api.TraceCall(context.Background(), args, rpc.BlockNumberOrHashWithNumber(tt.block), &TraceCallConfig{StateOverrides: tt.overrides})
+		if err != nil {
+			t.Fatalf("test %d: failed to trace call: %v", i, err)
+		}
+		res, ok := result.(*ethapi.ExecutionResult)
+		if !ok {
+			t.Fatalf("test %d: result type mismatch: have %T, want %T", i, result, res)
+		}
+		if res.Failed || len(res.StructLogs) == 0 {
+			t.Errorf("test %d: execution mismatch: failed %v, %d steps", i, res.Failed, len(res.StructLogs))
+		}
+		if want := common.Bytes2Hex(common.BigToHash(big.NewInt(tt.want)).Bytes()); res.ReturnValue != want {
+			t.Errorf("test %d: return value mismatch: have %s, want %s", i, res.ReturnValue, want)
+		}
+	}
+	// Tracing must have left the state of the chain untouched
+	for number, want := range []int64{5, 6, 6} {
+		statedb, _ := blockchain.StateAt(blockchain.GetBlockByNumber(uint64(number)).Root())
+		if have := statedb.GetState(counter, common.Hash{}); have != common.BigToHash(big.NewInt(want)) {
+			t.Errorf("block #%d: counter mismatch: have %x, want %d", number, have, want)
+		}
+		if have := statedb.GetCode(counter); !bytes.Equal(have, code) {
+			t.Errorf("block #%d: counter code mismatch: have %x, want %x", number, have, code)
+		}
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != chain[len(chain)-1].Hash() {
+		t.Errorf("head mismatch: have #%d, want #%d", head.NumberU64(), len(chain))
+	}
+}
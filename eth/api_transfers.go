@@ -0,0 +1,144 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+// errTransfersNotIndexed is returned if the internal transfers of a block were
+// not recorded, because the block was not executed by the node or was pruned.
+var errTransfersNotIndexed = errors.New("internal transfers not indexed")
+
+// internalTransferKinds are the names of the kinds of internal transfers.
+var internalTransferKinds = map[byte]string{
+	rawdb.InternalTransferCall:         "call",
+	rawdb.InternalTransferCreate:       "create",
+	rawdb.InternalTransferSelfDestruct: "selfdestruct",
+}
+
+// RPCInternalTransfer is a value transfer made from within the execution of a
+// transaction.
+type RPCInternalTransfer struct {
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	Type             string         `json:"type"`
+	From             common.Address `json:"from"`
+	To               common.Address `json:"to"`
+	Value            *hexutil.Big   `json:"value"`
+}
+
+// PublicTransferAPI provides an API to access the value transfers made from
+// within contract executions, as recorded while importing blocks.
+type PublicTransferAPI struct {
+	eth *Ethereum
+}
+
+// NewPublicTransferAPI creates a new internal transfer API.
+func NewPublicTransferAPI(eth *Ethereum) *PublicTransferAPI {
+	return &PublicTransferAPI{eth: eth}
+}
+
+// GetInternalTransfers returns the value transfers made from within the
+// execution of a transaction, such as the payments and refunds of the
+// masternode contract.
+func (api *PublicTransferAPI) GetInternalTransfers(ctx context.Context, hash common.Hash) ([]*RPCInternalTransfer, error) {
+	tx, blockHash, number, index := rawdb.ReadTransaction(api.eth.ChainDb(), hash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %#x not found", hash)
+	}
+	block := api.eth.blockchain.GetBlock(blockHash, number)
+	if block == nil {
+		return nil, fmt.Errorf("block %#x not found", blockHash)
+	}
+	transfers, err := api.blockTransfers(block)
+	if err != nil {
+		return nil, err
+	}
+	result := []*RPCInternalTransfer{}
+	for _, transfer := range transfers {
+		if uint64(transfer.TransactionIndex) == index {
+			result = append(result, transfer)
+		}
+	}
+	return result, nil
+}
+
+// GetInternalTransfersByBlock returns the value transfers made from within the
+// executions of the transactions of a block.
+func (api *PublicTransferAPI) GetInternalTransfersByBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*RPCInternalTransfer, error) {
+	var block *types.Block
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block = api.eth.blockchain.GetBlockByHash(hash)
+		if block == nil {
+			return nil, fmt.Errorf("block %#x not found", hash)
+		}
+		if blockNrOrHash.RequireCanonical && rawdb.ReadCanonicalHash(api.eth.ChainDb(), block.NumberU64()) != hash {
+			return nil, fmt.Errorf("block %#x not canonical", hash)
+		}
+	} else {
+		number, _ := blockNrOrHash.Number()
+		switch number {
+		case rpc.PendingBlockNumber:
+			return nil, errTransfersNotIndexed
+		case rpc.LatestBlockNumber:
+			block = api.eth.blockchain.CurrentBlock()
+		case rpc.FinalizedBlockNumber:
+			block = api.eth.blockchain.CurrentFinalizedBlock()
+		default:
+			block = api.eth.blockchain.GetBlockByNumber(uint64(number))
+		}
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+	}
+	return api.blockTransfers(block)
+}
+
+// blockTransfers retrieves the RPC representation of the internal transfers of
+// a block.
+func (api *PublicTransferAPI) blockTransfers(block *types.Block) ([]*RPCInternalTransfer, error) {
+	transfers, ok := rawdb.ReadInternalTransfers(api.eth.ChainDb(), block.Hash(), block.NumberU64())
+	if !ok {
+		return nil, errTransfersNotIndexed
+	}
+	txs := block.Transactions()
+
+	result := make([]*RPCInternalTransfer, 0, len(transfers))
+	for _, transfer := range transfers {
+		if int(transfer.TxIndex) >= len(txs) {
+			return nil, fmt.Errorf("invalid internal transfer of transaction %d", transfer.TxIndex)
+		}
+		result = append(result, &RPCInternalTransfer{
+			TransactionHash:  txs[transfer.TxIndex].Hash(),
+			TransactionIndex: hexutil.Uint64(transfer.TxIndex),
+			Type:             internalTransferKinds[transfer.Kind],
+			From:             transfer.From,
+			To:               transfer.To,
+			Value:            (*hexutil.Big)(transfer.Value),
+		})
+	}
+	return result, nil
+}
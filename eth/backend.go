@@ -36,6 +36,7 @@ import (
 	"github.com/ether-ark/etherark/core"
 	"github.com/ether-ark/etherark/core/bloombits"
 	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/state/pruner"
 	"github.com/ether-ark/etherark/core/types"
 	"github.com/ether-ark/etherark/core/vm"
 	"github.com/ether-ark/etherark/eth/downloader"
@@ -84,6 +85,11 @@ type Ethereum struct {
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
 
+	addressIndexer  *core.ChainIndexer // Address transaction indexer, nil if disabled
+	tokenIndexer    *core.ChainIndexer // Token transfer indexer, nil if disabled
+	supplyIndexer   *core.ChainIndexer // Coin supply indexer, nil if disabled
+	transferIndexer *TransferIndexer   // Internal value transfer indexer, nil if disabled
+
 	APIBackend *EthAPIBackend
 
 	miner     *miner.Miner
@@ -121,6 +127,10 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Finish a state pruning run interrupted by a crash before using the state
+	if err := pruner.RecoverPruning(ctx.ResolvePath("blocks"), chainDb); err != nil {
+		return nil, fmt.Errorf("failed to recover state pruning: %v", err)
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -160,7 +170,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 			EWASMInterpreter:        config.EWASMInterpreter,
 			EVMInterpreter:          config.EVMInterpreter,
 		}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieDirtyLimit: config.TrieDirtyCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieDirtyLimit: config.TrieDirtyCache, TrieTimeLimit: config.TrieTimeout, Snapshot: config.Snapshot}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig, eth.shouldPreserve)
 	if err != nil {
@@ -173,6 +183,26 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	if config.AddressIndex {
+		eth.addressIndexer = NewAddressIndexer(chainDb, eth.chainConfig, params.AddressIndexBlocks, params.AddressIndexConfirms)
+		eth.addressIndexer.Start(eth.blockchain)
+	}
+	if config.TokenIndex {
+		eth.tokenIndexer = NewTokenIndexer(chainDb, params.TokenIndexBlocks, params.TokenIndexConfirms)
+		eth.tokenIndexer.Start(eth.blockchain)
+	}
+	if config.SupplyIndex {
+		if rewards := supplyRewards(eth.chainConfig, eth.engine); rewards != nil {
+			eth.supplyIndexer = NewSupplyIndexer(eth.blockchain, chainDb, rewards, params.SupplyIndexBlocks, params.SupplyIndexConfirms)
+			eth.supplyIndexer.Start(eth.blockchain)
+		} else {
+			log.Warn("Coin supply accounting not supported by the consensus engine")
+		}
+	}
+	if config.InternalTransfers {
+		eth.transferIndexer = NewTransferIndexer(eth.blockchain, chainDb, eth.eventMux, config.InternalTransfersRetention)
+		eth.transferIndexer.Start()
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
@@ -193,6 +223,11 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.MinerExtraData))
+	ordering, err := miner.NewOrderingPolicy(config.MinerOrdering, config.MinerGasShare)
+	if err != nil {
+		return nil, err
+	}
+	eth.miner.SetOrdering(ordering)
 
 	eth.APIBackend = &EthAPIBackend{eth, nil}
 	gpoParams := config.GPO
@@ -223,14 +258,7 @@ func makeExtraData(extra []byte) []byte {
 
 // CreateDB creates the chain database.
 func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Database, error) {
-	db, err := ctx.OpenDatabase(name, config.DatabaseCache, config.DatabaseHandles)
-	if err != nil {
-		return nil, err
-	}
-	if db, ok := db.(*ethdb.LDBDatabase); ok {
-		db.Meter("eth/db/chaindata/")
-	}
-	return db, nil
+	return ctx.OpenDatabaseWithFreezer(name, config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/")
 }
 
 // CreateConsensusEngine creates the required type of consensus engine instance for an Ethereum service
@@ -271,11 +299,55 @@ func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainCo
 // APIs return the collection of RPC services the ethereum package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *Ethereum) APIs() []rpc.API {
+	logsLimits := filters.Config{
+		BlockLimit:  s.config.RPCLogsBlockLimit,
+		ResultLimit: s.config.RPCLogsResultLimit,
+	}
 	apis := ethapi.GetAPIs(s.APIBackend)
 
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the address transaction history if indexed
+	if s.addressIndexer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicAddressAPI(s),
+			Public:    true,
+		})
+	}
+	// Append the token transfers if indexed
+	if s.tokenIndexer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicTokenAPI(s),
+			Public:    true,
+		}, rpc.API{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateTokenAPI(s),
+		})
+	}
+	// Append the coin supply if accounted
+	if s.supplyIndexer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicSupplyAPI(s),
+			Public:    true,
+		})
+	}
+	// Append the internal value transfers if recorded
+	if s.transferIndexer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicTransferAPI(s),
+			Public:    true,
+		})
+	}
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -301,7 +373,7 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.APIBackend, false),
+			Service:   filters.NewPublicFilterAPI(s.APIBackend, false, logsLimits),
 			Public:    true,
 		}, {
 			Namespace: "admin",
@@ -555,6 +627,18 @@ func (s *Ethereum) startMasternode(srvr *p2p.Server) {
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
 	s.bloomIndexer.Close()
+	if s.addressIndexer != nil {
+		s.addressIndexer.Close()
+	}
+	if s.tokenIndexer != nil {
+		s.tokenIndexer.Close()
+	}
+	if s.supplyIndexer != nil {
+		s.supplyIndexer.Close()
+	}
+	if s.transferIndexer != nil {
+		s.transferIndexer.Stop()
+	}
 	s.blockchain.Stop()
 	s.engine.Close()
 	s.protocolManager.Stop()
@@ -30,6 +30,7 @@ import (
 	"github.com/ether-ark/etherark/core"
 	"github.com/ether-ark/etherark/eth/downloader"
 	"github.com/ether-ark/etherark/eth/gasprice"
+	"github.com/ether-ark/etherark/miner"
 	"github.com/ether-ark/etherark/params"
 )
 
@@ -53,6 +54,8 @@ var DefaultConfig = Config{
 	MinerGasCeil:   8000000,
 	MinerGasPrice:  big.NewInt(params.GWei),
 	MinerRecommit:  1 * time.Second,
+	MinerOrdering:  miner.PriceOrdering,
+	MinerGasShare:  10,
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -83,9 +86,17 @@ type Config struct {
 	Genesis *core.Genesis `toml:",omitempty"`
 
 	// Protocol options
-	NetworkId uint64 // Network ID to use for selecting peers to connect to
-	SyncMode  downloader.SyncMode
-	NoPruning bool
+	NetworkId    uint64 // Network ID to use for selecting peers to connect to
+	SyncMode     downloader.SyncMode
+	NoPruning    bool
+	Snapshot     bool
+	AddressIndex bool // Whether to index the transactions of every address
+	TokenIndex   bool // Whether to index the token transfers of every token and holder
+	SupplyIndex  bool // Whether to account the coin supply at every block
+
+	// Internal value transfer indexing options
+	InternalTransfers          bool   // Whether to record the value transfers made by contracts
+	InternalTransfersRetention uint64 // Number of recent blocks to keep the transfers of, 0 to keep all
 
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
@@ -98,6 +109,7 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string
 	TrieCleanCache     int
 	TrieDirtyCache     int
 	TrieTimeout        time.Duration
@@ -112,6 +124,8 @@ type Config struct {
 	MinerGasPrice  *big.Int
 	MinerRecommit  time.Duration
 	MinerNoverify  bool
+	MinerOrdering  string // Transaction ordering policy used when building blocks
+	MinerGasShare  uint64 // Percentage of the block gas limit one account may use (gasshare ordering)
 
 	// Ethash options
 	Ethash ethash.Config
@@ -139,6 +153,12 @@ type Config struct {
 
 	// RPCGasCap is the global gas cap for eth-call variants.
 	RPCGasCap *big.Int `toml:",omitempty"`
+
+	// RPCLogsBlockLimit is the maximum number of blocks a log query may span.
+	RPCLogsBlockLimit uint64 `toml:",omitempty"`
+
+	// RPCLogsResultLimit is the maximum number of logs a log query may return.
+	RPCLogsResultLimit uint64 `toml:",omitempty"`
 }
 
 type configMarshaling struct {
@@ -55,6 +55,20 @@ func NewContractBackend(eth *Ethereum) *ContractBackend {
 	return backend
 }
 
+// NewChainContractBackend creates a contract backend serving calls from a chain
+// alone, for the commands operating on a chain without a running node. Having
+// no transaction pool, it can't send transactions.
+func NewChainContractBackend(db ethdb.Database, chain *core.BlockChain) *ContractBackend {
+	backend := &ContractBackend{
+		database:   db,
+		blockchain: chain,
+		config:     chain.Config(),
+		events:     filters.NewEventSystem(new(event.TypeMux), &filterBackend{db, chain}, false),
+	}
+	backend.rollback()
+	return backend
+}
+
 // Rollback aborts all pending transactions, reverting to the last committed state.
 func (b *ContractBackend) Rollback() {
 	b.mu.Lock()
@@ -369,14 +383,15 @@ type callmsg struct {
 	ethereum.CallMsg
 }
 
-func (m callmsg) From() common.Address { return m.CallMsg.From }
-func (m callmsg) Nonce() uint64        { return 0 }
-func (m callmsg) CheckNonce() bool     { return false }
-func (m callmsg) To() *common.Address  { return m.CallMsg.To }
-func (m callmsg) GasPrice() *big.Int   { return m.CallMsg.GasPrice }
-func (m callmsg) Gas() uint64          { return m.CallMsg.Gas }
-func (m callmsg) Value() *big.Int      { return m.CallMsg.Value }
-func (m callmsg) Data() []byte         { return m.CallMsg.Data }
+func (m callmsg) From() common.Address         { return m.CallMsg.From }
+func (m callmsg) Nonce() uint64                { return 0 }
+func (m callmsg) CheckNonce() bool             { return false }
+func (m callmsg) To() *common.Address          { return m.CallMsg.To }
+func (m callmsg) GasPrice() *big.Int           { return m.CallMsg.GasPrice }
+func (m callmsg) Gas() uint64                  { return m.CallMsg.Gas }
+func (m callmsg) Value() *big.Int              { return m.CallMsg.Value }
+func (m callmsg) Data() []byte                 { return m.CallMsg.Data }
+func (m callmsg) AccessList() types.AccessList { return m.CallMsg.AccessList }
 
 // filterBackend implements filters.Backend to support filtering for logs without
 // taking bloom-bits acceleration structures into account.
@@ -399,6 +414,10 @@ func (fb *filterBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*t
 	return fb.bc.GetHeaderByHash(hash), nil
 }
 
+func (fb *filterBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return fb.bc.GetBlockByHash(hash), nil
+}
+
 func (fb *filterBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	number := rawdb.ReadHeaderNumber(fb.db, hash)
 	if number == nil {
@@ -31,6 +31,7 @@ import (
 	"github.com/ether-ark/etherark/core/types"
 	"github.com/ether-ark/etherark/ethdb"
 	"github.com/ether-ark/etherark/event"
+	"github.com/ether-ark/etherark/internal/ethapi"
 	"github.com/ether-ark/etherark/rpc"
 )
 
@@ -49,6 +50,12 @@ type filter struct {
 	s        *Subscription // associated subscription in event system
 }
 
+// Config are the limits applied to the log queries served over RPC.
+type Config struct {
+	BlockLimit  uint64 // Maximum number of blocks a log query may span, 0 for no limit
+	ResultLimit uint64 // Maximum number of logs a log query may return, 0 for no limit
+}
+
 // PublicFilterAPI offers support to create and manage filters. This will allow external clients to retrieve various
 // information related to the Ethereum protocol such als blocks, transactions and logs.
 type PublicFilterAPI struct {
@@ -59,16 +66,18 @@ type PublicFilterAPI struct {
 	events    *EventSystem
 	filtersMu sync.Mutex
 	filters   map[rpc.ID]*filter
+	config    Config
 }
 
 // NewPublicFilterAPI returns a new PublicFilterAPI instance.
-func NewPublicFilterAPI(backend Backend, lightMode bool) *PublicFilterAPI {
+func NewPublicFilterAPI(backend Backend, lightMode bool, config Config) *PublicFilterAPI {
 	api := &PublicFilterAPI{
 		backend: backend,
 		mux:     backend.EventMux(),
 		chainDb: backend.ChainDb(),
 		events:  NewEventSystem(backend.EventMux(), backend, lightMode),
 		filters: make(map[rpc.ID]*filter),
+		config:  config,
 	}
 	go api.timeoutLoop()
 
@@ -104,7 +113,7 @@ func (api *PublicFilterAPI) timeoutLoop() {
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_newpendingtransactionfilter
 func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 	var (
-		pendingTxs   = make(chan []common.Hash)
+		pendingTxs   = make(chan []*types.Transaction)
 		pendingTxSub = api.events.SubscribePendingTxs(pendingTxs)
 	)
 
@@ -115,10 +124,12 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 	go func() {
 		for {
 			select {
-			case ph := <-pendingTxs:
+			case pTx := <-pendingTxs:
 				api.filtersMu.Lock()
 				if f, found := api.filters[pendingTxSub.ID]; found {
-					f.hashes = append(f.hashes, ph...)
+					for _, tx := range pTx {
+						f.hashes = append(f.hashes, tx.Hash())
+					}
 				}
 				api.filtersMu.Unlock()
 			case <-pendingTxSub.Err():
@@ -133,27 +144,64 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 	return pendingTxSub.ID
 }
 
+// PendingTransactionsCriteria selects the transactions a pending transactions
+// subscription is notified of, and whether in full or by hash only.
+type PendingTransactionsCriteria struct {
+	FullTx bool             `json:"fullTx"` // Whether to notify of full transactions instead of hashes
+	From   []common.Address `json:"from"`   // Senders to notify of the transactions of, any if empty
+	To     []common.Address `json:"to"`     // Recipients to notify of the transactions of, any if empty
+}
+
+// matches returns whether a transaction sent by the given address is selected.
+func (crit *PendingTransactionsCriteria) matches(tx *types.Transaction, from common.Address) bool {
+	if len(crit.From) > 0 && !includes(crit.From, from) {
+		return false
+	}
+	if len(crit.To) > 0 && (tx.To() == nil || !includes(crit.To, *tx.To())) {
+		return false
+	}
+	return true
+}
+
 // NewPendingTransactions creates a subscription that is triggered each time a transaction
 // enters the transaction pool and was signed from one of the transactions this nodes manages.
-func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+// The optional criteria restrict it to the transactions of some senders or recipients and
+// select whether full transactions or their hashes are sent.
+func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context, crit *PendingTransactionsCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	if crit == nil {
+		crit = new(PendingTransactionsCriteria)
+	}
+	filtered := len(crit.From) > 0 || len(crit.To) > 0
 
 	rpcSub := notifier.CreateSubscription()
 
 	go func() {
-		txHashes := make(chan []common.Hash, 128)
-		pendingTxSub := api.events.SubscribePendingTxs(txHashes)
+		txs := make(chan []*types.Transaction, 128)
+		pendingTxSub := api.events.SubscribePendingTxs(txs)
 
 		for {
 			select {
-			case hashes := <-txHashes:
-				// To keep the original behaviour, send a single tx hash in one notification.
-				// TODO(rjl493456442) Send a batch of tx hashes in one notification
-				for _, h := range hashes {
-					notifier.Notify(rpcSub.ID, h)
+			case pTx := <-txs:
+				// To keep the original behaviour, send a single tx in one notification.
+				// TODO(rjl493456442) Send a batch of txs in one notification
+				for _, tx := range pTx {
+					if !crit.FullTx && !filtered {
+						notifier.Notify(rpcSub.ID, tx.Hash())
+						continue
+					}
+					rpcTx := ethapi.NewRPCPendingTransaction(tx)
+					if !crit.matches(tx, rpcTx.From) {
+						continue
+					}
+					if crit.FullTx {
+						notifier.Notify(rpcSub.ID, rpcTx)
+					} else {
+						notifier.Notify(rpcSub.ID, tx.Hash())
+					}
 				}
 			case <-rpcSub.Err():
 				pendingTxSub.Unsubscribe()
@@ -271,6 +319,70 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	return rpcSub, nil
 }
 
+// ReceiptsCriteria selects the receipts a receipts subscription is notified of. A
+// receipt is selected if either its transaction or one of its addresses is, or if
+// no transactions nor addresses are given at all.
+type ReceiptsCriteria struct {
+	TransactionHashes []common.Hash    `json:"transactionHashes"` // Transactions to notify of the receipts of
+	Addresses         []common.Address `json:"addresses"`         // Senders, recipients or created contracts to notify of the receipts of
+}
+
+// matches returns whether the receipt of a transaction sent by the given address
+// is selected.
+func (crit *ReceiptsCriteria) matches(tx *types.Transaction, from common.Address, receipt *types.Receipt) bool {
+	if len(crit.TransactionHashes) == 0 && len(crit.Addresses) == 0 {
+		return true
+	}
+	hash := tx.Hash()
+	for _, h := range crit.TransactionHashes {
+		if h == hash {
+			return true
+		}
+	}
+	if includes(crit.Addresses, from) {
+		return true
+	}
+	if to := tx.To(); to != nil {
+		return includes(crit.Addresses, *to)
+	}
+	return includes(crit.Addresses, receipt.ContractAddress)
+}
+
+// TransactionReceipts creates a subscription that fires for the receipts matching the
+// given criteria as blocks are imported. In case blocks are removed (chain reorg) the
+// receipts previously sent are sent again with the removed property set to true.
+func (api *PublicFilterAPI) TransactionReceipts(ctx context.Context, crit ReceiptsCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	var (
+		rpcSub          = notifier.CreateSubscription()
+		matchedReceipts = make(chan []map[string]interface{})
+		receiptsSub     = api.events.SubscribeReceipts(crit, matchedReceipts)
+	)
+
+	go func() {
+		for {
+			select {
+			case receipts := <-matchedReceipts:
+				for _, receipt := range receipts {
+					notifier.Notify(rpcSub.ID, receipt)
+				}
+			case <-rpcSub.Err(): // client send an unsubscribe request
+				receiptsSub.Unsubscribe()
+				return
+			case <-notifier.Closed(): // connection dropped
+				receiptsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // FilterCriteria represents a request to create a new filter.
 // Same as ethereum.FilterQuery but with UnmarshalJSON() method.
 type FilterCriteria ethereum.FilterQuery
@@ -320,10 +432,65 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 	return logsSub.ID, nil
 }
 
+// LogsPage is a page of the logs matching a query, with the position to continue
+// the query at if the limits of the node cut it short.
+type LogsPage struct {
+	Logs          []*types.Log    `json:"logs"`
+	NextFromBlock *hexutil.Uint64 `json:"nextFromBlock"` // Block to continue the query from, nil if complete
+	LogIndex      *hexutil.Uint   `json:"logIndex"`      // Index of the first log to return from that block
+}
+
 // GetLogs returns logs matching the given argument that are stored within the state.
+// Queries exceeding the limits of the node fail with a structured error.
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getlogs
 func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	// Run the filter and return all the logs
+	logs, err := api.newFilter(crit).Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), err
+}
+
+// GetLogsPage returns the logs matching the given argument as far as the limits of
+// the node allow, along with the position to continue the query at. Passing it
+// back as the from block of the query and the log index continues the query.
+func (api *PublicFilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria, logIndex *hexutil.Uint) (*LogsPage, error) {
+	filter := api.newFilter(crit)
+	if logIndex != nil {
+		filter.Resume(uint(*logIndex))
+	}
+	logs, err := filter.Logs(ctx)
+
+	// Narrow down queries spanning too many blocks to the widest range allowed
+	var next *hexutil.Uint64
+	if limit, ok := err.(*LimitError); ok && limit.BlockLimit > 0 {
+		to := limit.ToBlock + 1
+		next = &to
+
+		filter = NewRangeFilter(api.backend, int64(limit.FromBlock), int64(limit.ToBlock), crit.Addresses, crit.Topics)
+		filter.SetLimits(0, api.config.ResultLimit)
+		if logIndex != nil {
+			filter.Resume(uint(*logIndex))
+		}
+		logs, err = filter.Logs(ctx)
+	}
+	// Return the logs up to the result limit, continuing at the first left out
+	page := &LogsPage{Logs: returnLogs(logs), NextFromBlock: next}
+	if err != nil {
+		limit, ok := err.(*LimitError)
+		if !ok {
+			return nil, err
+		}
+		page.NextFromBlock, page.LogIndex = limit.NextFromBlock, limit.LogIndex
+	}
+	return page, nil
+}
+
+// newFilter creates a single-shot filter for the given criteria, restricted to
+// the limits of the node.
+func (api *PublicFilterAPI) newFilter(crit FilterCriteria) *Filter {
 	var filter *Filter
 	if crit.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
@@ -341,12 +508,8 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 		// Construct the range filter
 		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
 	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return returnLogs(logs), err
+	filter.SetLimits(api.config.BlockLimit, api.config.ResultLimit)
+	return filter
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -379,25 +542,8 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		return nil, fmt.Errorf("filter not found")
 	}
 
-	var filter *Filter
-	if f.crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *f.crit.BlockHash, f.crit.Addresses, f.crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if f.crit.FromBlock != nil {
-			begin = f.crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if f.crit.ToBlock != nil {
-			end = f.crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
-	}
 	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
+	logs, err := api.newFilter(f.crit).Logs(ctx)
 	if err != nil {
 		return nil, err
 	}
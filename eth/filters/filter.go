@@ -19,9 +19,11 @@ package filters
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
 	"github.com/ether-ark/etherark/core"
 	"github.com/ether-ark/etherark/core/bloombits"
 	"github.com/ether-ark/etherark/core/types"
@@ -35,6 +37,7 @@ type Backend interface {
 	EventMux() *event.TypeMux
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
 	HeaderByHash(ctx context.Context, blockHash common.Hash) (*types.Header, error)
+	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 	GetLogs(ctx context.Context, blockHash common.Hash) ([][]*types.Log, error)
 
@@ -47,6 +50,32 @@ type Backend interface {
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
+// LimitError is returned if a log query spans more blocks or matches more logs
+// than the node serves in a single query. It is returned over RPC as a structured
+// error, with its fields telling how to narrow down or continue the query.
+type LimitError struct {
+	BlockLimit    uint64          `json:"blockLimit,omitempty"`    // Block range limit exceeded, if any
+	ResultLimit   uint64          `json:"resultLimit,omitempty"`   // Result limit exceeded, if any
+	FromBlock     hexutil.Uint64  `json:"fromBlock"`               // First block of the query
+	ToBlock       hexutil.Uint64  `json:"toBlock"`                 // Last block the query may span within the limits
+	NextFromBlock *hexutil.Uint64 `json:"nextFromBlock,omitempty"` // Block of the first log left out
+	LogIndex      *hexutil.Uint   `json:"logIndex,omitempty"`      // Index of the first log left out within its block
+}
+
+// Error implements error.
+func (e *LimitError) Error() string {
+	if e.BlockLimit > 0 {
+		return fmt.Sprintf("query exceeds limit of %d blocks", e.BlockLimit)
+	}
+	return fmt.Sprintf("query exceeds limit of %d results", e.ResultLimit)
+}
+
+// ErrorCode implements rpc.Error, reporting a limit exceeded.
+func (e *LimitError) ErrorCode() int { return -32005 }
+
+// ErrorData implements rpc.DataError, returning the limit and continuation data.
+func (e *LimitError) ErrorData() interface{} { return e }
+
 // Filter can be used to retrieve and filter logs.
 type Filter struct {
 	backend Backend
@@ -58,6 +87,11 @@ type Filter struct {
 	block      common.Hash // Block hash if filtering a single block
 	begin, end int64       // Range interval if filtering multiple blocks
 
+	blockLimit  uint64 // Maximum number of blocks to filter in a single run, 0 for no limit
+	resultLimit uint64 // Maximum number of logs to return from a single run, 0 for no limit
+	first       uint64 // First block to filter, the one logIndex applies to
+	logIndex    uint   // Index of the first log to return from the first block
+
 	matcher *bloombits.Matcher
 }
 
@@ -114,6 +148,20 @@ func newFilter(backend Backend, addresses []common.Address, topics [][]common.Ha
 	}
 }
 
+// SetLimits caps the number of blocks the filter may span and the number of logs
+// it may return, 0 meaning no limit. Exceeding either makes Logs fail with a
+// *LimitError.
+func (f *Filter) SetLimits(blocks, results uint64) {
+	f.blockLimit = blocks
+	f.resultLimit = results
+}
+
+// Resume skips the logs of the first block of the filter with an index below the
+// given one, continuing a run cut short by the result limit.
+func (f *Filter) Resume(logIndex uint) {
+	f.logIndex = logIndex
+}
+
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
@@ -126,7 +174,8 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		if header == nil {
 			return nil, errors.New("unknown block")
 		}
-		return f.blockLogs(ctx, header)
+		f.first = header.Number.Uint64()
+		return f.blockLogs(ctx, nil, header)
 	}
 	// Figure out the limits of the filter range
 	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
@@ -142,6 +191,33 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == -1 {
 		end = head
 	}
+	// Resolve the finalized tag, which may be unknown if the chain has no finality
+	if f.begin == rpc.FinalizedBlockNumber.Int64() || f.end == rpc.FinalizedBlockNumber.Int64() {
+		finalized, _ := f.backend.HeaderByNumber(ctx, rpc.FinalizedBlockNumber)
+		if finalized == nil {
+			return nil, nil
+		}
+		if f.begin == rpc.FinalizedBlockNumber.Int64() {
+			f.begin = finalized.Number.Int64()
+		}
+		if f.end == rpc.FinalizedBlockNumber.Int64() {
+			end = finalized.Number.Uint64()
+		}
+	}
+	// Refuse spanning more blocks than allowed, pointing at the widest range that is
+	f.first = uint64(f.begin)
+	if last := end; f.blockLimit > 0 && f.begin >= 0 {
+		if last > head {
+			last = head
+		}
+		if last >= f.first && last-f.first >= f.blockLimit {
+			return nil, &LimitError{
+				BlockLimit: f.blockLimit,
+				FromBlock:  hexutil.Uint64(f.first),
+				ToBlock:    hexutil.Uint64(f.first + f.blockLimit - 1),
+			}
+		}
+	}
 	// Gather all indexed logs, and finish with non indexed ones
 	var (
 		logs []*types.Log
@@ -202,7 +278,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 			if err != nil {
 				return logs, err
 			}
-			logs = append(logs, found...)
+			if logs, err = f.collect(logs, found); err != nil {
+				return logs, err
+			}
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -216,27 +294,52 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 	var logs []*types.Log
 
 	for ; f.begin <= int64(end); f.begin++ {
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
 			return logs, err
 		}
-		found, err := f.blockLogs(ctx, header)
-		if err != nil {
+		if logs, err = f.blockLogs(ctx, logs, header); err != nil {
 			return logs, err
 		}
-		logs = append(logs, found...)
 	}
 	return logs, nil
 }
 
-// blockLogs returns the logs matching the filter criteria within a single block.
-func (f *Filter) blockLogs(ctx context.Context, header *types.Header) (logs []*types.Log, err error) {
+// blockLogs appends the logs matching the filter criteria within a single block
+// to the ones gathered so far.
+func (f *Filter) blockLogs(ctx context.Context, logs []*types.Log, header *types.Header) ([]*types.Log, error) {
 	if bloomFilter(header.Bloom, f.addresses, f.topics) {
 		found, err := f.checkMatches(ctx, header)
 		if err != nil {
 			return logs, err
 		}
-		logs = append(logs, found...)
+		return f.collect(logs, found)
+	}
+	return logs, nil
+}
+
+// collect appends the logs found in a block to the ones gathered so far, skipping
+// the ones returned by a previous run. If the result limit is reached while logs
+// are left, it fails with a *LimitError pointing at the first one left out.
+func (f *Filter) collect(logs, found []*types.Log) ([]*types.Log, error) {
+	for _, log := range found {
+		if log.BlockNumber == f.first && log.Index < f.logIndex {
+			continue
+		}
+		if f.resultLimit > 0 && uint64(len(logs)) == f.resultLimit {
+			next, index := hexutil.Uint64(log.BlockNumber), hexutil.Uint(log.Index)
+			return logs, &LimitError{
+				ResultLimit:   f.resultLimit,
+				FromBlock:     hexutil.Uint64(f.first),
+				ToBlock:       hexutil.Uint64(log.BlockNumber),
+				NextFromBlock: &next,
+				LogIndex:      &index,
+			}
+		}
+		logs = append(logs, log)
 	}
 	return logs, nil
 }
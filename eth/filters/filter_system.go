@@ -31,6 +31,7 @@ import (
 	"github.com/ether-ark/etherark/core/rawdb"
 	"github.com/ether-ark/etherark/core/types"
 	"github.com/ether-ark/etherark/event"
+	"github.com/ether-ark/etherark/internal/ethapi"
 	"github.com/ether-ark/etherark/log"
 	"github.com/ether-ark/etherark/rpc"
 )
@@ -48,11 +49,13 @@ const (
 	PendingLogsSubscription
 	// MinedAndPendingLogsSubscription queries for logs in mined and pending blocks.
 	MinedAndPendingLogsSubscription
-	// PendingTransactionsSubscription queries tx hashes for pending
-	// transactions entering the pending state
+	// PendingTransactionsSubscription queries for pending transactions
+	// entering the pending state
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// ReceiptsSubscription queries for new or removed (chain reorg) receipts
+	ReceiptsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -75,15 +78,17 @@ var (
 )
 
 type subscription struct {
-	id        rpc.ID
-	typ       Type
-	created   time.Time
-	logsCrit  ethereum.FilterQuery
-	logs      chan []*types.Log
-	hashes    chan []common.Hash
-	headers   chan *types.Header
-	installed chan struct{} // closed when the filter is installed
-	err       chan error    // closed when the filter is uninstalled
+	id           rpc.ID
+	typ          Type
+	created      time.Time
+	logsCrit     ethereum.FilterQuery
+	receiptsCrit ReceiptsCriteria
+	logs         chan []*types.Log
+	txs          chan []*types.Transaction
+	headers      chan *types.Header
+	receipts     chan []map[string]interface{}
+	installed    chan struct{} // closed when the filter is installed
+	err          chan error    // closed when the filter is uninstalled
 }
 
 // EventSystem creates subscriptions, processes events and broadcasts them to the
@@ -94,6 +99,8 @@ type EventSystem struct {
 	lightMode bool
 	lastHead  *types.Header
 
+	receiptsHead *types.Header // Last head the receipts subscriptions were served for
+
 	// Subscriptions
 	txsSub        event.Subscription         // Subscription for new transaction event
 	logsSub       event.Subscription         // Subscription for new log event
@@ -173,8 +180,9 @@ func (sub *Subscription) Unsubscribe() {
 			case sub.es.uninstall <- sub.f:
 				break uninstallLoop
 			case <-sub.f.logs:
-			case <-sub.f.hashes:
+			case <-sub.f.txs:
 			case <-sub.f.headers:
+			case <-sub.f.receipts:
 			}
 		}
 
@@ -240,8 +248,9 @@ func (es *EventSystem) subscribeMinedPendingLogs(crit ethereum.FilterQuery, logs
 		logsCrit:  crit,
 		created:   time.Now(),
 		logs:      logs,
-		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   make(chan *types.Header),
+		receipts:  make(chan []map[string]interface{}),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -257,8 +266,9 @@ func (es *EventSystem) subscribeLogs(crit ethereum.FilterQuery, logs chan []*typ
 		logsCrit:  crit,
 		created:   time.Now(),
 		logs:      logs,
-		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   make(chan *types.Header),
+		receipts:  make(chan []map[string]interface{}),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -274,8 +284,9 @@ func (es *EventSystem) subscribePendingLogs(crit ethereum.FilterQuery, logs chan
 		logsCrit:  crit,
 		created:   time.Now(),
 		logs:      logs,
-		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   make(chan *types.Header),
+		receipts:  make(chan []map[string]interface{}),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -290,30 +301,50 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscripti
 		typ:       BlocksSubscription,
 		created:   time.Now(),
 		logs:      make(chan []*types.Log),
-		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   headers,
+		receipts:  make(chan []map[string]interface{}),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
 	return es.subscribe(sub)
 }
 
-// SubscribePendingTxs creates a subscription that writes transaction hashes for
+// SubscribePendingTxs creates a subscription that writes transactions for
 // transactions that enter the transaction pool.
-func (es *EventSystem) SubscribePendingTxs(hashes chan []common.Hash) *Subscription {
+func (es *EventSystem) SubscribePendingTxs(txs chan []*types.Transaction) *Subscription {
 	sub := &subscription{
 		id:        rpc.NewID(),
 		typ:       PendingTransactionsSubscription,
 		created:   time.Now(),
 		logs:      make(chan []*types.Log),
-		hashes:    hashes,
+		txs:       txs,
 		headers:   make(chan *types.Header),
+		receipts:  make(chan []map[string]interface{}),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
 	return es.subscribe(sub)
 }
 
+// SubscribeReceipts creates a subscription that writes the receipts matching the
+// given criteria of the blocks imported into or removed from the canonical chain.
+func (es *EventSystem) SubscribeReceipts(crit ReceiptsCriteria, receipts chan []map[string]interface{}) *Subscription {
+	sub := &subscription{
+		id:           rpc.NewID(),
+		typ:          ReceiptsSubscription,
+		receiptsCrit: crit,
+		created:      time.Now(),
+		logs:         make(chan []*types.Log),
+		txs:          make(chan []*types.Transaction),
+		headers:      make(chan *types.Header),
+		receipts:     receipts,
+		installed:    make(chan struct{}),
+		err:          make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 type filterIndex map[Type]map[rpc.ID]*subscription
 
 // broadcast event to filters that match criteria.
@@ -348,12 +379,8 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 			}
 		}
 	case core.NewTxsEvent:
-		hashes := make([]common.Hash, 0, len(e.Txs))
-		for _, tx := range e.Txs {
-			hashes = append(hashes, tx.Hash())
-		}
 		for _, f := range filters[PendingTransactionsSubscription] {
-			f.hashes <- hashes
+			f.txs <- e.Txs
 		}
 	case core.ChainEvent:
 		for _, f := range filters[BlocksSubscription] {
@@ -368,6 +395,13 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 				}
 			})
 		}
+		if len(filters[ReceiptsSubscription]) > 0 {
+			es.receiptsNewHead(e.Block.Header(), func(header *types.Header, remove bool) {
+				es.broadcastReceipts(filters[ReceiptsSubscription], header, remove)
+			})
+		} else {
+			es.receiptsHead = nil
+		}
 	}
 }
 
@@ -377,7 +411,25 @@ func (es *EventSystem) lightFilterNewHead(newHeader *types.Header, callBack func
 	if oldh == nil {
 		return
 	}
-	newh := newHeader
+	es.walkNewHead(oldh, newHeader, callBack)
+}
+
+// receiptsNewHead calls back with the blocks removed from and added to the chain
+// since the last head the receipts subscriptions were served for, or with the new
+// head only if they were not served before.
+func (es *EventSystem) receiptsNewHead(newHeader *types.Header, callBack func(*types.Header, bool)) {
+	oldh := es.receiptsHead
+	es.receiptsHead = newHeader
+	if oldh == nil {
+		callBack(newHeader, false)
+		return
+	}
+	es.walkNewHead(oldh, newHeader, callBack)
+}
+
+// walkNewHead calls back with the blocks rolled back from the old head down to the
+// common ancestor with the new head, then with the blocks leading up to the new one.
+func (es *EventSystem) walkNewHead(oldh, newh *types.Header, callBack func(*types.Header, bool)) {
 	// find common ancestor, create list of rolled back and new block hashes
 	var oldHeaders, newHeaders []*types.Header
 	for oldh.Hash() != newh.Hash() {
@@ -404,6 +456,53 @@ func (es *EventSystem) lightFilterNewHead(newHeader *types.Header, callBack func
 	}
 }
 
+// broadcastReceipts writes the receipts of a block matching the criteria of the
+// given subscriptions to them, flagged as removed if the block was rolled back.
+func (es *EventSystem) broadcastReceipts(filters map[rpc.ID]*subscription, header *types.Header, remove bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	hash, number := header.Hash(), header.Number.Uint64()
+	block, err := es.backend.GetBlock(ctx, hash)
+	if block == nil || err != nil {
+		return
+	}
+	receipts, err := es.backend.GetReceipts(ctx, hash)
+	if err != nil || len(receipts) != len(block.Transactions()) {
+		return
+	}
+	matches := make(map[rpc.ID][]map[string]interface{})
+	for i, tx := range block.Transactions() {
+		var (
+			receipt = receipts[i]
+			from    = ethapi.TransactionSender(tx)
+			fields  map[string]interface{}
+		)
+		for id, f := range filters {
+			if !f.receiptsCrit.matches(tx, from, receipt) {
+				continue
+			}
+			if fields == nil {
+				fields = ethapi.RPCMarshalReceipt(receipt, tx, hash, number, uint64(i))
+				if remove {
+					logs := make([]*types.Log, len(receipt.Logs))
+					for j, log := range receipt.Logs {
+						logcopy := *log
+						logcopy.Removed = true
+						logs[j] = &logcopy
+					}
+					fields["logs"] = logs
+				}
+				fields["removed"] = remove
+			}
+			matches[id] = append(matches[id], fields)
+		}
+	}
+	for id, found := range matches {
+		filters[id].receipts <- found
+	}
+}
+
 // filter logs of a single header in light client mode
 func (es *EventSystem) lightFilterLogs(header *types.Header, addresses []common.Address, topics [][]common.Hash, remove bool) []*types.Log {
 	if bloomFilter(header.Bloom, addresses, topics) {
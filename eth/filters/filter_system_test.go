@@ -32,8 +32,10 @@ import (
 	"github.com/ether-ark/etherark/core/bloombits"
 	"github.com/ether-ark/etherark/core/rawdb"
 	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/crypto"
 	"github.com/ether-ark/etherark/ethdb"
 	"github.com/ether-ark/etherark/event"
+	"github.com/ether-ark/etherark/internal/ethapi"
 	"github.com/ether-ark/etherark/params"
 	"github.com/ether-ark/etherark/rpc"
 )
@@ -83,6 +85,13 @@ func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*type
 	return rawdb.ReadHeader(b.db, hash, *number), nil
 }
 
+func (b *testBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if number := rawdb.ReadHeaderNumber(b.db, hash); number != nil {
+		return rawdb.ReadBlock(b.db, hash, *number), nil
+	}
+	return nil, nil
+}
+
 func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	if number := rawdb.ReadHeaderNumber(b.db, hash); number != nil {
 		return rawdb.ReadReceipts(b.db, hash, *number), nil
@@ -167,7 +176,7 @@ func TestBlockSubscription(t *testing.T) {
 		logsFeed    = new(event.Feed)
 		chainFeed   = new(event.Feed)
 		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api         = NewPublicFilterAPI(backend, false)
+		api         = NewPublicFilterAPI(backend, false, Config{})
 		genesis     = new(core.Genesis).MustCommit(db)
 		chain, _    = core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {})
 		chainEvents = []core.ChainEvent{}
@@ -224,7 +233,7 @@ func TestPendingTxFilter(t *testing.T) {
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
 		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		api        = NewPublicFilterAPI(backend, false, Config{})
 
 		transactions = []*types.Transaction{
 			types.NewTransaction(0, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), 0, new(big.Int), nil),
@@ -273,6 +282,144 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestPendingTxCriteria tests whether pending transactions are selected by their
+// senders and recipients.
+func TestPendingTxCriteria(t *testing.T) {
+	t.Parallel()
+
+	var (
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		other     = common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+		transfer, _ = types.SignTx(types.NewTransaction(0, recipient, new(big.Int), 21000, new(big.Int), nil), types.HomesteadSigner{}, key)
+		creation, _ = types.SignTx(types.NewContractCreation(1, new(big.Int), 100000, new(big.Int), nil), types.HomesteadSigner{}, key)
+	)
+	testCases := []struct {
+		crit     PendingTransactionsCriteria
+		tx       *types.Transaction
+		expected bool
+	}{
+		{PendingTransactionsCriteria{}, transfer, true},
+		{PendingTransactionsCriteria{From: []common.Address{sender}}, transfer, true},
+		{PendingTransactionsCriteria{From: []common.Address{other}}, transfer, false},
+		{PendingTransactionsCriteria{To: []common.Address{recipient}}, transfer, true},
+		{PendingTransactionsCriteria{To: []common.Address{other, recipient}}, transfer, true},
+		{PendingTransactionsCriteria{From: []common.Address{sender}, To: []common.Address{other}}, transfer, false},
+		{PendingTransactionsCriteria{From: []common.Address{sender}}, creation, true},
+		{PendingTransactionsCriteria{To: []common.Address{recipient}}, creation, false},
+	}
+	for i, test := range testCases {
+		if have := test.crit.matches(test.tx, ethapi.TransactionSender(test.tx)); have != test.expected {
+			t.Errorf("test %d: match mismatch: have %v, want %v", i, have, test.expected)
+		}
+	}
+}
+
+// TestReceiptsSubscription tests whether receipts subscriptions are notified of the
+// receipts of the selected transactions as blocks are imported, and of the removal
+// of the ones rolled back by a chain reorg.
+func TestReceiptsSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux        = new(event.TypeMux)
+		db         = ethdb.NewMemDatabase()
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		api        = NewPublicFilterAPI(backend, false, Config{})
+
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		other     = common.HexToAddress("0x0000000000000000000000000000000000000001")
+		genesis   = core.GenesisBlockForTesting(db, sender, big.NewInt(params.Ether))
+	)
+	// Create a chain sending to the recipient in every block, and a fork of it
+	// sending to someone else in its second block only
+	generate := func(fork bool) ([]*types.Block, []types.Receipts) {
+		return core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 3, func(i int, gen *core.BlockGen) {
+			to := recipient
+			if fork && i > 0 {
+				gen.SetCoinbase(other)
+				if i == 1 {
+					to = other
+				}
+			}
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(sender), to, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, key)
+			gen.AddTx(tx)
+		})
+	}
+	chain, receipts := generate(false)
+	forkChain, forkReceipts := generate(true)
+
+	for i := range chain {
+		rawdb.WriteBlock(db, chain[i])
+		rawdb.WriteReceipts(db, chain[i].Hash(), chain[i].NumberU64(), receipts[i])
+		rawdb.WriteBlock(db, forkChain[i])
+		rawdb.WriteReceipts(db, forkChain[i].Hash(), forkChain[i].NumberU64(), forkReceipts[i])
+	}
+	if chain[0].Hash() != forkChain[0].Hash() {
+		t.Fatalf("fork mismatch: chains diverge at their first block")
+	}
+	type expected struct {
+		tx      common.Hash
+		removed bool
+	}
+	var (
+		txHash = func(block *types.Block) common.Hash { return block.Transactions()[0].Hash() }
+
+		addressCh  = make(chan []map[string]interface{})
+		addressSub = api.events.SubscribeReceipts(ReceiptsCriteria{Addresses: []common.Address{recipient}}, addressCh)
+		addressExp = []expected{
+			{txHash(chain[0]), false}, {txHash(chain[1]), false}, {txHash(chain[2]), false},
+			{txHash(chain[2]), true}, {txHash(chain[1]), true}, {txHash(forkChain[2]), false},
+		}
+		hashCh  = make(chan []map[string]interface{})
+		hashSub = api.events.SubscribeReceipts(ReceiptsCriteria{TransactionHashes: []common.Hash{txHash(chain[1])}}, hashCh)
+		hashExp = []expected{{txHash(chain[1]), false}, {txHash(chain[1]), true}}
+	)
+	// Import the chain, and then switch over to the fork
+	go func() {
+		for _, block := range chain {
+			chainFeed.Send(core.ChainEvent{Block: block, Hash: block.Hash()})
+		}
+		chainFeed.Send(core.ChainEvent{Block: forkChain[2], Hash: forkChain[2].Hash()})
+	}()
+
+	check := func(name string, ch chan []map[string]interface{}, sub *Subscription, exp []expected) {
+		var have []expected
+		timeout := time.After(5 * time.Second)
+		for len(have) < len(exp) {
+			select {
+			case receipts := <-ch:
+				for _, receipt := range receipts {
+					have = append(have, expected{receipt["transactionHash"].(common.Hash), receipt["removed"].(bool)})
+				}
+			case <-timeout:
+				t.Errorf("%s: timeout waiting for receipts, have %d, want %d", name, len(have), len(exp))
+				return
+			}
+		}
+		sub.Unsubscribe()
+
+		if !reflect.DeepEqual(have, exp) {
+			t.Errorf("%s: receipts mismatch:\nhave %v\nwant %v", name, have, exp)
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		check("hashes", hashCh, hashSub, hashExp)
+		close(done)
+	}()
+	check("addresses", addressCh, addressSub, addressExp)
+	<-done
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
@@ -284,7 +431,7 @@ func TestLogFilterCreation(t *testing.T) {
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
 		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		api        = NewPublicFilterAPI(backend, false, Config{})
 
 		testCases = []struct {
 			crit    FilterCriteria
@@ -333,7 +480,7 @@ func TestInvalidLogFilterCreation(t *testing.T) {
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
 		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		api        = NewPublicFilterAPI(backend, false, Config{})
 	)
 
 	// different situations where log filter creation should fail.
@@ -360,7 +507,7 @@ func TestInvalidGetLogsRequest(t *testing.T) {
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
 		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		api        = NewPublicFilterAPI(backend, false, Config{})
 		blockHash  = common.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
 	)
 
@@ -390,7 +537,7 @@ func TestLogFilter(t *testing.T) {
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
 		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		api        = NewPublicFilterAPI(backend, false, Config{})
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
 		secondAddr     = common.HexToAddress("0x2222222222222222222222222222222222222222")
@@ -509,7 +656,7 @@ func TestPendingLogsSubscription(t *testing.T) {
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
 		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		api        = NewPublicFilterAPI(backend, false, Config{})
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
 		secondAddr     = common.HexToAddress("0x2222222222222222222222222222222222222222")
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
 	"github.com/ether-ark/etherark/consensus/ethash"
 	"github.com/ether-ark/etherark/core"
 	"github.com/ether-ark/etherark/core/rawdb"
@@ -230,3 +231,116 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+func TestFilterLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filtertest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		db, _      = ethdb.NewLDBDatabase(dir, 0, 0)
+		mux        = new(event.TypeMux)
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr       = crypto.PubkeyToAddress(key1.PublicKey)
+	)
+	defer db.Close()
+
+	// Emit three logs in every tenth block, thirty in total
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 100, func(i int, gen *core.BlockGen) {
+		if number := gen.Number().Uint64(); number%10 == 0 {
+			receipt := types.NewReceipt(nil, false, 0)
+			for index := uint(0); index < 3; index++ {
+				receipt.Logs = append(receipt.Logs, &types.Log{Address: addr, BlockNumber: number, Index: index})
+			}
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Ranges wider than the block limit should be refused with the widest allowed
+	filter := NewRangeFilter(backend, 5, -1, []common.Address{addr}, nil)
+	filter.SetLimits(50, 0)
+
+	_, err = filter.Logs(context.Background())
+	limit, ok := err.(*LimitError)
+	if !ok {
+		t.Fatalf("block limit error mismatch: have %v, want *LimitError", err)
+	}
+	if limit.BlockLimit != 50 || limit.FromBlock != 5 || limit.ToBlock != 54 || limit.NextFromBlock != nil {
+		t.Errorf("block limit error mismatch: have %+v", limit)
+	}
+	filter = NewRangeFilter(backend, 51, -1, []common.Address{addr}, nil)
+	filter.SetLimits(50, 0)
+
+	if logs, err := filter.Logs(context.Background()); err != nil || len(logs) != 15 {
+		t.Errorf("logs mismatch: have %d, %v, want 15", len(logs), err)
+	}
+	// Results beyond the result limit should be cut, pointing at the first left out
+	filter = NewRangeFilter(backend, 0, -1, []common.Address{addr}, nil)
+	filter.SetLimits(0, 4)
+
+	logs, err := filter.Logs(context.Background())
+	if limit, ok = err.(*LimitError); !ok {
+		t.Fatalf("result limit error mismatch: have %v, want *LimitError", err)
+	}
+	if len(logs) != 4 {
+		t.Errorf("logs mismatch: have %d, want 4", len(logs))
+	}
+	if limit.NextFromBlock == nil || *limit.NextFromBlock != 20 || limit.LogIndex == nil || *limit.LogIndex != 1 {
+		t.Errorf("result limit error mismatch: have %+v", limit)
+	}
+	// Resuming should skip the logs of the first block already returned
+	filter = NewRangeFilter(backend, 20, 20, []common.Address{addr}, nil)
+	filter.Resume(1)
+
+	logs, err = filter.Logs(context.Background())
+	if err != nil || len(logs) != 2 || logs[0].Index != 1 {
+		t.Errorf("resumed logs mismatch: have %v, %v", logs, err)
+	}
+	// Paging through the logs should return every one of them exactly once
+	api := NewPublicFilterAPI(backend, false, Config{BlockLimit: 25, ResultLimit: 4})
+
+	_, err = api.GetLogs(context.Background(), FilterCriteria{FromBlock: big.NewInt(0), Addresses: []common.Address{addr}})
+	if _, ok := err.(*LimitError); !ok {
+		t.Fatalf("limit error mismatch: have %v, want *LimitError", err)
+	}
+	var (
+		from  = big.NewInt(0)
+		index *hexutil.Uint
+		found []*types.Log
+	)
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatalf("paging did not terminate, %d logs found", len(found))
+		}
+		page, err := api.GetLogsPage(context.Background(), FilterCriteria{FromBlock: from, Addresses: []common.Address{addr}}, index)
+		if err != nil {
+			t.Fatalf("page %d: failed to retrieve logs: %v", pages, err)
+		}
+		found = append(found, page.Logs...)
+		if page.NextFromBlock == nil {
+			break
+		}
+		from, index = new(big.Int).SetUint64(uint64(*page.NextFromBlock)), page.LogIndex
+	}
+	if len(found) != 30 {
+		t.Fatalf("paged logs mismatch: have %d, want 30", len(found))
+	}
+	for i, log := range found {
+		if want := uint64(i/3+1) * 10; log.BlockNumber != want || log.Index != uint(i%3) {
+			t.Errorf("log %d: position mismatch: have #%d/%d, want #%d/%d", i, log.BlockNumber, log.Index, want, i%3)
+		}
+	}
+}
@@ -147,6 +147,30 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return price, nil
 }
 
+// SuggestPowerPrice returns the recommended gas price for a transaction using
+// the given amount of gas. On Circum chains gas is paid from the sender's power
+// rather than its balance, so the regular suggestion is capped to the highest
+// price the sender's power can ever cover.
+func (gpo *Oracle) SuggestPowerPrice(ctx context.Context, gas uint64, maxPower *big.Int) (*big.Int, error) {
+	price, err := gpo.SuggestPrice(ctx)
+	if err != nil {
+		return price, err
+	}
+	return capPowerPrice(price, gas, maxPower), nil
+}
+
+// capPowerPrice lowers price so that gas*price does not exceed maxPower.
+func capPowerPrice(price *big.Int, gas uint64, maxPower *big.Int) *big.Int {
+	if gas == 0 || maxPower == nil {
+		return price
+	}
+	limit := new(big.Int).Div(maxPower, new(big.Int).SetUint64(gas))
+	if price.Cmp(limit) > 0 {
+		return limit
+	}
+	return price
+}
+
 type getBlockPricesResult struct {
 	price *big.Int
 	err   error
@@ -0,0 +1,42 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCapPowerPrice(t *testing.T) {
+	tests := []struct {
+		price    int64
+		gas      uint64
+		maxPower *big.Int
+		want     int64
+	}{
+		{price: 10, gas: 21000, maxPower: nil, want: 10},
+		{price: 10, gas: 0, maxPower: big.NewInt(5), want: 10},
+		{price: 10, gas: 21000, maxPower: big.NewInt(21000 * 10), want: 10},
+		{price: 10, gas: 21000, maxPower: big.NewInt(21000*10 - 1), want: 9},
+		{price: 10, gas: 21000, maxPower: big.NewInt(0), want: 0},
+	}
+	for i, tt := range tests {
+		if have := capPowerPrice(big.NewInt(tt.price), tt.gas, tt.maxPower); have.Int64() != tt.want {
+			t.Errorf("test %d: price mismatch: have %v, want %d", i, have, tt.want)
+		}
+	}
+}
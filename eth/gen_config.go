@@ -19,44 +19,60 @@ var _ = (*configMarshaling)(nil)
 // MarshalTOML marshals as TOML.
 func (c Config) MarshalTOML() (interface{}, error) {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               uint64
-		SyncMode                downloader.SyncMode
-		NoPruning               bool
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
-		DatabaseCache           int
-		TrieCleanCache          int
-		TrieDirtyCache          int
-		TrieTimeout             time.Duration
-		Etherbase               common.Address `toml:",omitempty"`
-		MinerNotify             []string       `toml:",omitempty"`
-		MinerExtraData          hexutil.Bytes  `toml:",omitempty"`
-		MinerGasFloor           uint64
-		MinerGasCeil            uint64
-		MinerGasPrice           *big.Int
-		MinerRecommit           time.Duration
-		MinerNoverify           bool
-		Ethash                  ethash.Config
-		TxPool                  core.TxPoolConfig
-		GPO                     gasprice.Config
-		EnablePreimageRecording bool
-		DocRoot                 string `toml:"-"`
-		EWASMInterpreter        string
-		EVMInterpreter          string
+		Genesis                    *core.Genesis `toml:",omitempty"`
+		NetworkId                  uint64
+		SyncMode                   downloader.SyncMode
+		NoPruning                  bool
+		Snapshot                   bool
+		AddressIndex               bool
+		TokenIndex                 bool
+		SupplyIndex                bool
+		InternalTransfers          bool
+		InternalTransfersRetention uint64
+		LightServ                  int  `toml:",omitempty"`
+		LightPeers                 int  `toml:",omitempty"`
+		SkipBcVersionCheck         bool `toml:"-"`
+		DatabaseHandles            int  `toml:"-"`
+		DatabaseCache              int
+		DatabaseFreezer            string
+		TrieCleanCache             int
+		TrieDirtyCache             int
+		TrieTimeout                time.Duration
+		Etherbase                  common.Address `toml:",omitempty"`
+		MinerNotify                []string       `toml:",omitempty"`
+		MinerExtraData             hexutil.Bytes  `toml:",omitempty"`
+		MinerGasFloor              uint64
+		MinerGasCeil               uint64
+		MinerGasPrice              *big.Int
+		MinerRecommit              time.Duration
+		MinerNoverify              bool
+		MinerOrdering              string
+		MinerGasShare              uint64
+		Ethash                     ethash.Config
+		TxPool                     core.TxPoolConfig
+		GPO                        gasprice.Config
+		EnablePreimageRecording    bool
+		DocRoot                    string `toml:"-"`
+		EWASMInterpreter           string
+		EVMInterpreter             string
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
 	enc.NoPruning = c.NoPruning
+	enc.Snapshot = c.Snapshot
+	enc.AddressIndex = c.AddressIndex
+	enc.TokenIndex = c.TokenIndex
+	enc.SupplyIndex = c.SupplyIndex
+	enc.InternalTransfers = c.InternalTransfers
+	enc.InternalTransfersRetention = c.InternalTransfersRetention
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.DatabaseFreezer = c.DatabaseFreezer
 	enc.TrieCleanCache = c.TrieCleanCache
 	enc.TrieDirtyCache = c.TrieDirtyCache
 	enc.TrieTimeout = c.TrieTimeout
@@ -68,6 +84,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerGasPrice = c.MinerGasPrice
 	enc.MinerRecommit = c.MinerRecommit
 	enc.MinerNoverify = c.MinerNoverify
+	enc.MinerOrdering = c.MinerOrdering
+	enc.MinerGasShare = c.MinerGasShare
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
@@ -81,33 +99,42 @@ func (c Config) MarshalTOML() (interface{}, error) {
 // UnmarshalTOML unmarshals from TOML.
 func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               *uint64
-		SyncMode                *downloader.SyncMode
-		NoPruning               *bool
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
-		DatabaseCache           *int
-		TrieCleanCache          *int
-		TrieDirtyCache          *int
-		TrieTimeout             *time.Duration
-		Etherbase               *common.Address `toml:",omitempty"`
-		MinerNotify             []string        `toml:",omitempty"`
-		MinerExtraData          *hexutil.Bytes  `toml:",omitempty"`
-		MinerGasFloor           *uint64
-		MinerGasCeil            *uint64
-		MinerGasPrice           *big.Int
-		MinerRecommit           *time.Duration
-		MinerNoverify           *bool
-		Ethash                  *ethash.Config
-		TxPool                  *core.TxPoolConfig
-		GPO                     *gasprice.Config
-		EnablePreimageRecording *bool
-		DocRoot                 *string `toml:"-"`
-		EWASMInterpreter        *string
-		EVMInterpreter          *string
+		Genesis                    *core.Genesis `toml:",omitempty"`
+		NetworkId                  *uint64
+		SyncMode                   *downloader.SyncMode
+		NoPruning                  *bool
+		Snapshot                   *bool
+		AddressIndex               *bool
+		TokenIndex                 *bool
+		SupplyIndex                *bool
+		InternalTransfers          *bool
+		InternalTransfersRetention *uint64
+		LightServ                  *int  `toml:",omitempty"`
+		LightPeers                 *int  `toml:",omitempty"`
+		SkipBcVersionCheck         *bool `toml:"-"`
+		DatabaseHandles            *int  `toml:"-"`
+		DatabaseCache              *int
+		DatabaseFreezer            *string
+		TrieCleanCache             *int
+		TrieDirtyCache             *int
+		TrieTimeout                *time.Duration
+		Etherbase                  *common.Address `toml:",omitempty"`
+		MinerNotify                []string        `toml:",omitempty"`
+		MinerExtraData             *hexutil.Bytes  `toml:",omitempty"`
+		MinerGasFloor              *uint64
+		MinerGasCeil               *uint64
+		MinerGasPrice              *big.Int
+		MinerRecommit              *time.Duration
+		MinerNoverify              *bool
+		MinerOrdering              *string
+		MinerGasShare              *uint64
+		Ethash                     *ethash.Config
+		TxPool                     *core.TxPoolConfig
+		GPO                        *gasprice.Config
+		EnablePreimageRecording    *bool
+		DocRoot                    *string `toml:"-"`
+		EWASMInterpreter           *string
+		EVMInterpreter             *string
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -125,6 +152,24 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPruning != nil {
 		c.NoPruning = *dec.NoPruning
 	}
+	if dec.Snapshot != nil {
+		c.Snapshot = *dec.Snapshot
+	}
+	if dec.AddressIndex != nil {
+		c.AddressIndex = *dec.AddressIndex
+	}
+	if dec.TokenIndex != nil {
+		c.TokenIndex = *dec.TokenIndex
+	}
+	if dec.SupplyIndex != nil {
+		c.SupplyIndex = *dec.SupplyIndex
+	}
+	if dec.InternalTransfers != nil {
+		c.InternalTransfers = *dec.InternalTransfers
+	}
+	if dec.InternalTransfersRetention != nil {
+		c.InternalTransfersRetention = *dec.InternalTransfersRetention
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -140,6 +185,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.DatabaseFreezer != nil {
+		c.DatabaseFreezer = *dec.DatabaseFreezer
+	}
 	if dec.TrieCleanCache != nil {
 		c.TrieCleanCache = *dec.TrieCleanCache
 	}
@@ -173,6 +221,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.MinerNoverify != nil {
 		c.MinerNoverify = *dec.MinerNoverify
 	}
+	if dec.MinerOrdering != nil {
+		c.MinerOrdering = *dec.MinerOrdering
+	}
+	if dec.MinerGasShare != nil {
+		c.MinerGasShare = *dec.MinerGasShare
+	}
 	if dec.Ethash != nil {
 		c.Ethash = *dec.Ethash
 	}
@@ -0,0 +1,204 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/consensus"
+	"github.com/ether-ark/etherark/consensus/circum"
+	"github.com/ether-ark/etherark/consensus/ethash"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rlp"
+	"github.com/ether-ark/etherark/trie"
+)
+
+const (
+	// supplyIndexThrottling is the time to wait between processing two consecutive
+	// supply index sections. It's useful during the initial indexing of a chain
+	// to prevent disk overload.
+	supplyIndexThrottling = 100 * time.Millisecond
+)
+
+// supplyBurnAddress is the address coins are sent to in order to take them out
+// of circulation for good.
+var supplyBurnAddress = common.Address{}
+
+// blockRewardsFn returns the coins minted by a block as rewards.
+type blockRewardsFn func(header *types.Header, uncles []*types.Header) *big.Int
+
+// supplyRewards returns the function calculating the rewards minted by the blocks
+// of the given consensus engine, nil if the engine is not supported.
+func supplyRewards(config *params.ChainConfig, engine consensus.Engine) blockRewardsFn {
+	switch engine.(type) {
+	case *circum.Circum:
+		return func(header *types.Header, uncles []*types.Header) *big.Int {
+			return circum.BlockReward(header.Number)
+		}
+	case *ethash.Ethash:
+		return func(header *types.Header, uncles []*types.Header) *big.Int {
+			return ethash.BlockRewards(config, header, uncles)
+		}
+	}
+	return nil
+}
+
+// SupplyIndexer implements a core.ChainIndexer, accounting the coins issued,
+// locked in the masternode contract and burned at every block.
+type SupplyIndexer struct {
+	chain   *core.BlockChain // blockchain to read the states of the blocks from
+	db      ethdb.Database   // database instance to write index data and metadata into
+	rewards blockRewardsFn   // function calculating the rewards minted by a block
+	size    uint64           // number of blocks in a section
+	batch   ethdb.Batch      // batch collecting the records of the section being processed
+	issued  *big.Int         // coins issued up to the last processed block
+}
+
+// NewSupplyIndexer returns a chain indexer that generates the coin supply index
+// of the canonical chain.
+func NewSupplyIndexer(chain *core.BlockChain, db ethdb.Database, rewards blockRewardsFn, size, confirms uint64) *core.ChainIndexer {
+	backend := &SupplyIndexer{
+		chain:   chain,
+		db:      db,
+		rewards: rewards,
+		size:    size,
+	}
+	table := ethdb.NewTable(db, string(rawdb.SupplyIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, confirms, supplyIndexThrottling, "supply")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new supply index section
+// from the coins issued up to the head of the previous one.
+func (b *SupplyIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	b.batch = b.db.NewBatch()
+	b.issued = nil
+
+	if section > 0 {
+		number := section*b.size - 1 + params.GenesisBlockNumber
+		record := rawdb.ReadSupplyRecord(b.db, lastSectionHead, number)
+		if record == nil {
+			return fmt.Errorf("block #%d [%x…] supply not indexed", number, lastSectionHead[:4])
+		}
+		b.issued = record.Issued
+	}
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, accounting the coin supply of a
+// new block.
+func (b *SupplyIndexer) Process(ctx context.Context, header *types.Header) error {
+	record, err := blockSupply(b.chain, b.rewards, header, b.issued)
+	if err != nil {
+		return err
+	}
+	trackSupply(b.chain, header, record)
+	rawdb.WriteSupplyRecord(b.batch, header.Hash(), header.Number.Uint64(), record)
+	b.issued = record.Issued
+
+	if b.batch.ValueSize() >= ethdb.IdealBatchSize {
+		if err := b.batch.Write(); err != nil {
+			return err
+		}
+		b.batch.Reset()
+	}
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing the supply index section
+// out into the database.
+func (b *SupplyIndexer) Commit() error {
+	return b.batch.Write()
+}
+
+// blockSupply accounts the coins minted by a block on top of the coins issued up
+// to its parent. The genesis block issues the coins allocated in it.
+func blockSupply(chain *core.BlockChain, rewards blockRewardsFn, header *types.Header, issued *big.Int) (*rawdb.SupplyRecord, error) {
+	hash, number := header.Hash(), header.Number.Uint64()
+
+	record := &rawdb.SupplyRecord{
+		Rewards: new(big.Int),
+		Locked:  new(big.Int),
+		Burned:  new(big.Int),
+	}
+	if number == params.GenesisBlockNumber {
+		allocated, err := genesisAllocation(chain, header.Root)
+		if err != nil {
+			return nil, err
+		}
+		record.Issued = allocated
+		return record, nil
+	}
+	if issued == nil {
+		return nil, fmt.Errorf("block #%d [%x…] parent supply unknown", number, hash[:4])
+	}
+	var uncles []*types.Header
+	if header.UncleHash != types.EmptyUncleHash {
+		body := chain.GetBody(hash)
+		if body == nil {
+			return nil, fmt.Errorf("block #%d [%x…] body not found", number, hash[:4])
+		}
+		uncles = body.Uncles
+	}
+	record.Rewards = rewards(header, uncles)
+	record.Issued = new(big.Int).Add(issued, record.Rewards)
+	return record, nil
+}
+
+// trackSupply fills in the balances held by the masternode contract and the burn
+// address at a block, if the state of the block is available.
+func trackSupply(chain *core.BlockChain, header *types.Header, record *rawdb.SupplyRecord) {
+	statedb, err := chain.StateAt(header.Root)
+	if err != nil {
+		return
+	}
+	record.Tracked = true
+	record.Locked = statedb.GetBalance(params.MasterndeContractAddress)
+	record.Burned = statedb.GetBalance(supplyBurnAddress)
+}
+
+// genesisAllocation sums the balances of all the accounts of the genesis state.
+func genesisAllocation(chain *core.BlockChain, root common.Hash) (*big.Int, error) {
+	tr, err := chain.StateCache().OpenTrie(root)
+	if err != nil {
+		return nil, fmt.Errorf("genesis state not available: %v", err)
+	}
+	var (
+		allocated = new(big.Int)
+		it        = trie.NewIterator(tr.NodeIterator(nil))
+	)
+	for it.Next() {
+		var account state.Account
+		if err := rlp.DecodeBytes(it.Value, &account); err != nil {
+			return nil, err
+		}
+		allocated.Add(allocated, account.Balance)
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	return allocated, nil
+}
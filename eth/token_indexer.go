@@ -0,0 +1,136 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/ethdb"
+)
+
+const (
+	// tokenIndexThrottling is the time to wait between processing two consecutive
+	// token index sections. It's useful during the initial indexing of a chain
+	// to prevent disk overload.
+	tokenIndexThrottling = 100 * time.Millisecond
+)
+
+// tokenTransferEvent is the topic of the Transfer event shared by the ERC-20
+// and ERC-721 token standards, which only differ in the ERC-721 one indexing
+// the transferred token id too.
+var tokenTransferEvent = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// TokenIndexer implements a core.ChainIndexer, building up an index of the
+// token transfers of every token and every holder.
+type TokenIndexer struct {
+	db    ethdb.Database // database instance to write index data and metadata into
+	batch ethdb.Batch    // batch collecting the transfers of the section being processed
+}
+
+// NewTokenIndexer returns a chain indexer that generates the token transfer index
+// of the canonical chain.
+func NewTokenIndexer(db ethdb.Database, size, confirms uint64) *core.ChainIndexer {
+	backend := &TokenIndexer{
+		db: db,
+	}
+	table := ethdb.NewTable(db, string(rawdb.TokenIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, confirms, tokenIndexThrottling, "tokens")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new token index section.
+func (b *TokenIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	b.batch = b.db.NewBatch()
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the token transfers of a
+// new block into the index.
+func (b *TokenIndexer) Process(ctx context.Context, header *types.Header) error {
+	hash, number := header.Hash(), header.Number.Uint64()
+	if header.ReceiptHash == types.EmptyRootHash {
+		return nil
+	}
+	receipts := rawdb.ReadReceipts(b.db, hash, number)
+	if receipts == nil {
+		return fmt.Errorf("block #%d [%x…] receipts not found", number, hash[:4])
+	}
+	for _, transfer := range tokenTransfers(hash, number, receipts) {
+		rawdb.WriteTokenTransfer(b.batch, transfer)
+	}
+	if b.batch.ValueSize() >= ethdb.IdealBatchSize {
+		if err := b.batch.Write(); err != nil {
+			return err
+		}
+		b.batch.Reset()
+	}
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing the token index section
+// out into the database.
+func (b *TokenIndexer) Commit() error {
+	return b.batch.Write()
+}
+
+// tokenTransfers decodes the standard token Transfer events emitted in a block.
+// Events not matching the layout of either standard are skipped.
+func tokenTransfers(hash common.Hash, number uint64, receipts types.Receipts) []rawdb.TokenTransfer {
+	var (
+		transfers []rawdb.TokenTransfer
+		index     uint32
+	)
+	for i, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			index++
+			if len(log.Topics) < 3 || log.Topics[0] != tokenTransferEvent {
+				continue
+			}
+			transfer := rawdb.TokenTransfer{
+				BlockNumber: number,
+				BlockHash:   hash,
+				LogIndex:    index - 1,
+				TxIndex:     uint32(i),
+				Token:       log.Address,
+				From:        common.BytesToAddress(log.Topics[1].Bytes()),
+				To:          common.BytesToAddress(log.Topics[2].Bytes()),
+			}
+			switch {
+			case len(log.Topics) == 3 && len(log.Data) == 32:
+				transfer.Kind = rawdb.TokenTransferERC20
+				transfer.Value = new(big.Int).SetBytes(log.Data)
+
+			case len(log.Topics) == 4 && len(log.Data) == 0:
+				transfer.Kind = rawdb.TokenTransferERC721
+				transfer.Value = log.Topics[3].Big()
+
+			default:
+				continue
+			}
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers
+}
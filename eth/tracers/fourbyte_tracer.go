@@ -0,0 +1,114 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/core/vm"
+)
+
+// fourByteTracer is the native counterpart of 4byte_tracer.js, collecting the
+// 4byte method identifiers called along with the size of the supplied data, so
+// a reversed signature can be matched against the size of the data.
+type fourByteTracer struct {
+	interrupter
+
+	ids   *orderedObject // 4byte ids found, with the number of their occurrences
+	input []byte         // Input data of the outer call
+	err   error
+}
+
+func newFourByteTracer() NativeTracer {
+	return &fourByteTracer{ids: newOrderedObject()}
+}
+
+// store saves the given identifier and data size.
+func (t *fourByteTracer) store(id []byte, size float64) {
+	key := hexutil.Encode(id) + "-" + formatJSNumber(size)
+	if count, ok := t.ids.get(key); ok {
+		t.ids.set(key, count.(int)+1)
+		return
+	}
+	t.ids.set(key, 1)
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *fourByteTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.input = input
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *fourByteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.err != nil {
+		return nil
+	}
+	if t.stopped() {
+		t.err = t.reason
+		return nil
+	}
+	// Skip any opcodes that are not internal calls, finding the input data
+	// offset of the ones that are
+	var ct int
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		ct = 3 // gas, addr, val, memin, meminsz, memout, memoutsz
+	case vm.DELEGATECALL, vm.STATICCALL:
+		ct = 2 // gas, addr, memin, meminsz, memout, memoutsz
+	default:
+		return nil
+	}
+	// Skip any pre-compile invocations, those are just fancy opcodes
+	if isPrecompiled(common.BigToAddress(peek(stack, 1))) {
+		return nil
+	}
+	// Gather internal call details
+	if inSz := jsNumber(peek(stack, ct+1)); inSz >= 4 {
+		inOff := jsNumber(peek(stack, ct))
+		t.store(memorySlice(memory, inOff, inOff+4), inSz-4)
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *fourByteTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, duration time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the 4byte identifiers found, keyed by identifier and data
+// size.
+func (t *fourByteTracer) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	// Save the outer calldata also
+	if len(t.input) >= 4 {
+		t.store(t.input[:4], float64(len(t.input)-4))
+	}
+	return encodeJS(t.ids)
+}
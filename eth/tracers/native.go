@@ -0,0 +1,250 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/vm"
+)
+
+// NativeTracer is a transaction tracer implemented in Go. The built in native
+// tracers produce the same output as the JavaScript tracers of the same name,
+// without the cost of running an interpreter for every opcode.
+type NativeTracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON encoded result of the trace, or the error that
+	// interrupted it.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates execution of the tracer at the first opportune moment.
+	Stop(err error)
+}
+
+// natives contains all the built in native tracers by name.
+var natives = map[string]func() NativeTracer{
+	"callTracer":     newCallTracer,
+	"prestateTracer": newPrestateTracer,
+	"4byteTracer":    newFourByteTracer,
+	"noopTracer":     newNoopTracer,
+}
+
+// NewNative creates the built in native tracer of the given name, returning false
+// if there is none, in which case a JavaScript tracer must be used.
+func NewNative(name string) (NativeTracer, bool) {
+	if ctor, ok := natives[name]; ok {
+		return ctor(), true
+	}
+	return nil, false
+}
+
+// interrupter implements the interruption of native tracers.
+type interrupter struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (i *interrupter) Stop(err error) {
+	i.reason = err
+	atomic.StoreUint32(&i.interrupt, 1)
+}
+
+// stopped reports whether the tracer was interrupted.
+func (i *interrupter) stopped() bool {
+	return atomic.LoadUint32(&i.interrupt) > 0
+}
+
+// noopTracer is the native counterpart of noop_tracer.js.
+type noopTracer struct {
+	interrupter
+}
+
+func newNoopTracer() NativeTracer {
+	return new(noopTracer)
+}
+
+func (t *noopTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+func (t *noopTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (t *noopTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (t *noopTracer) CaptureEnd(output []byte, gasUsed uint64, duration time.Duration, err error) error {
+	return nil
+}
+
+func (t *noopTracer) GetResult() (json.RawMessage, error) {
+	if t.stopped() {
+		return nil, t.reason
+	}
+	return json.RawMessage(`{}`), nil
+}
+
+// The helpers below mirror the accessors the JavaScript tracers are given, so
+// that the native tracers see the exact same values, out of bound accesses
+// included.
+
+// peek returns the nth-from-the-top element of the stack, or zero if the stack
+// is not deep enough.
+func peek(stack *vm.Stack, n int) *big.Int {
+	data := stack.Data()
+	if len(data) <= n {
+		return new(big.Int)
+	}
+	return data[len(data)-n-1]
+}
+
+// jsNumber converts a big integer to the closest float, as valueOf does on the
+// JavaScript big integers.
+func jsNumber(n *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return f
+}
+
+// formatJSNumber formats a float the way JavaScript converts numbers to strings.
+func formatJSNumber(f float64) string {
+	if math.Abs(f) < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// memorySlice returns the memory between begin and end, or nothing if the range
+// is out of bounds.
+func memorySlice(memory *vm.Memory, begin, end float64) []byte {
+	if begin < 0 || begin > end || float64(memory.Len()) < end {
+		return nil
+	}
+	return memory.Get(int64(begin), int64(end)-int64(begin))
+}
+
+// jsHex formats an integer as the JavaScript tracers do with '0x' + toString(16).
+func jsHex(n int64) string {
+	if n < 0 {
+		return "0x-" + strconv.FormatInt(-n, 16)
+	}
+	return "0x" + strconv.FormatInt(n, 16)
+}
+
+// jsHexBig formats a big integer as the JavaScript tracers do with '0x' +
+// toString(16).
+func jsHexBig(n *big.Int) string {
+	if n.Sign() < 0 {
+		return "0x-" + new(big.Int).Neg(n).Text(16)
+	}
+	return "0x" + n.Text(16)
+}
+
+// isPrecompiled reports whether the address is a precompiled contract in any
+// of the releases.
+func isPrecompiled(addr common.Address) bool {
+	if _, ok := vm.PrecompiledContractsIstanbul[addr]; ok {
+		return true
+	}
+	_, ok := vm.PrecompiledContractsBLS[addr]
+	return ok
+}
+
+// encodeJS encodes a result into JSON the way the JavaScript engine does, that
+// is without escaping HTML characters.
+func encodeJS(v interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(bytes.TrimRight(buf.Bytes(), "\n")), nil
+}
+
+// orderedObject is a JSON object keeping its keys in insertion order, as the
+// objects of the JavaScript engine do.
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedObject() *orderedObject {
+	return &orderedObject{values: make(map[string]interface{})}
+}
+
+// get returns the value of a key, if present.
+func (o *orderedObject) get(key string) (interface{}, bool) {
+	val, ok := o.values[key]
+	return val, ok
+}
+
+// set updates the value of a key, appending it if new.
+func (o *orderedObject) set(key string, val interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = val
+}
+
+// delete removes a key.
+func (o *orderedObject) delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// MarshalJSON implements json.Marshaler, encoding the keys in insertion order.
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := encodeJS(key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := encodeJS(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/rlp"
+	"github.com/ether-ark/etherark/tests"
+)
+
+// resultTracer is a tracer able to return the result of its trace.
+type resultTracer interface {
+	vm.Tracer
+	GetResult() (json.RawMessage, error)
+}
+
+// timeRegexp matches the execution time reported by the call tracers, which
+// naturally differs between runs.
+var timeRegexp = regexp.MustCompile(`,"time":"[^"]*"`)
+
+// runTracerTest executes the transaction of a test case on its prestate with the
+// given tracer attached, returning the result of the trace.
+func runTracerTest(t *testing.T, test *callTracerTest, tracer resultTracer) json.RawMessage {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+	origin, _ := signer.Sender(tx)
+
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      origin,
+		Coinbase:    test.Context.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		GasLimit:    uint64(test.Context.GasLimit),
+		GasPrice:    tx.GasPrice(),
+	}
+	statedb := tests.MakePreState(ethdb.NewMemDatabase(), test.Genesis.Alloc)
+	evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+
+	msg, err := freeMessage(tx, signer)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, _, _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res
+}
+
+// Tests that the native tracers produce the exact same output as the JavaScript
+// tracers of the same name over the tracer test harness.
+func TestNativeTracers(t *testing.T) {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), "call_tracer_") {
+			continue
+		}
+		blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
+		if err != nil {
+			t.Fatalf("failed to read testcase: %v", err)
+		}
+		test := new(callTracerTest)
+		if err := json.Unmarshal(blob, test); err != nil {
+			t.Fatalf("failed to parse testcase: %v", err)
+		}
+		for _, name := range []string{"callTracer", "prestateTracer", "4byteTracer", "noopTracer"} {
+			name := name // capture range variable
+			t.Run(camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json"))+"/"+name, func(t *testing.T) {
+				jsTracer, err := New(name)
+				if err != nil {
+					t.Fatalf("failed to create JavaScript tracer: %v", err)
+				}
+				nativeTracer, ok := NewNative(name)
+				if !ok {
+					t.Fatalf("native tracer missing")
+				}
+				want := runTracerTest(t, test, jsTracer)
+				have := runTracerTest(t, test, nativeTracer)
+
+				if name == "callTracer" {
+					want, have = timeRegexp.ReplaceAll(want, nil), timeRegexp.ReplaceAll(have, nil)
+
+					ret := new(callTrace)
+					if err := json.Unmarshal(have, ret); err != nil {
+						t.Fatalf("failed to unmarshal trace result: %v", err)
+					}
+					if !reflect.DeepEqual(ret, test.Result) {
+						t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", ret, test.Result)
+					}
+				}
+				if string(have) != string(want) {
+					t.Fatalf("output mismatch:\nhave %s\nwant %s", have, want)
+				}
+			})
+		}
+	}
+}
+
+// Tests that native tracers can be interrupted.
+func TestNativeTracerStop(t *testing.T) {
+	timeout := errors.New("stahp")
+
+	tracer, _ := NewNative("callTracer")
+	tracer.Stop(timeout)
+	tracer.CaptureState(nil, 0, vm.STOP, 0, 0, nil, nil, nil, 0, nil)
+
+	if _, err := tracer.GetResult(); err != timeout {
+		t.Fatalf("interruption mismatch: have %v, want %v", err, timeout)
+	}
+}
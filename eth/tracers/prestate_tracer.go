@@ -0,0 +1,178 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/crypto"
+)
+
+// prestateAccount is an account of the prestate tracer. Its fields are declared
+// in the order the JavaScript tracer serializes them.
+type prestateAccount struct {
+	Balance string         `json:"balance"`
+	Nonce   int64          `json:"nonce"`
+	Code    string         `json:"code"`
+	Storage *orderedObject `json:"storage"`
+
+	balance *big.Int
+}
+
+// prestateTracer is the native counterpart of prestate_tracer.js, gathering the
+// state a transaction accesses as it was before its execution.
+type prestateTracer struct {
+	interrupter
+
+	prestate *orderedObject // Accounts accessed by the transaction, by address
+	ctx      outerCall      // Transaction context gathered throughout execution
+	db       vm.StateDB
+	err      error
+}
+
+func newPrestateTracer() NativeTracer {
+	return new(prestateTracer)
+}
+
+// lookupAccount injects the specified account into the prestate object.
+func (t *prestateTracer) lookupAccount(addr common.Address) *prestateAccount {
+	acc := hexutil.Encode(addr.Bytes())
+	if account, ok := t.prestate.get(acc); ok {
+		return account.(*prestateAccount)
+	}
+	balance := t.db.GetBalance(addr)
+	account := &prestateAccount{
+		Balance: hexutil.EncodeBig(balance),
+		Nonce:   int64(t.db.GetNonce(addr)),
+		Code:    hexutil.Encode(t.db.GetCode(addr)),
+		Storage: newOrderedObject(),
+		balance: new(big.Int).Set(balance),
+	}
+	t.prestate.set(acc, account)
+	return account
+}
+
+// lookupStorage injects the specified storage entry of the given account into
+// the prestate object.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	account := t.lookupAccount(addr)
+
+	idx := hexutil.Encode(key.Bytes())
+	if _, ok := account.Storage.get(idx); !ok {
+		account.Storage.set(idx, hexutil.Encode(t.db.GetState(addr, key).Bytes()))
+	}
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.ctx.typ = "CALL"
+	if create {
+		t.ctx.typ = "CREATE"
+	}
+	t.ctx.from, t.ctx.to, t.ctx.value = from, to, value
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.err != nil {
+		return nil
+	}
+	if t.stopped() {
+		t.err = t.reason
+		return nil
+	}
+	t.db = env.StateDB
+
+	// Add the current account if we just started tracing
+	if t.prestate == nil {
+		t.prestate = newOrderedObject()
+
+		// Balance will potentially be wrong here, since this will include the value
+		// sent along with the message. We fix that in GetResult.
+		t.lookupAccount(contract.Address())
+	}
+	// Whenever new state is accessed, add it to the prestate
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.BALANCE:
+		t.lookupAccount(common.BigToAddress(peek(stack, 0)))
+
+	case vm.CREATE:
+		from := contract.Address()
+		t.lookupAccount(crypto.CreateAddress(from, t.db.GetNonce(from)))
+
+	case vm.CREATE2:
+		// stack: salt, size, offset, endowment
+		offset := jsNumber(peek(stack, 1))
+		end := offset + jsNumber(peek(stack, 2))
+
+		codeHash := crypto.Keccak256(memorySlice(memory, offset, end))
+		t.lookupAccount(crypto.CreateAddress2(contract.Address(), common.BigToHash(peek(stack, 3)), codeHash))
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(common.BigToAddress(peek(stack, 1)))
+
+	case vm.SSTORE, vm.SLOAD:
+		t.lookupStorage(contract.Address(), common.BigToHash(peek(stack, 0)))
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, duration time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the accounts accessed by the transaction, as they were
+// before its execution.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	// Transactions not running any code never accessed the state database
+	if t.prestate == nil {
+		return nil, errors.New("no code executed, prestate unavailable")
+	}
+	// At this point, we need to deduct the 'value' from the outer transaction,
+	// and move it back to the origin
+	from, to := t.lookupAccount(t.ctx.from), t.lookupAccount(t.ctx.to)
+	fromBal, toBal := from.balance, to.balance
+
+	to.Balance = jsHexBig(new(big.Int).Sub(toBal, t.ctx.value))
+	from.Balance = jsHexBig(new(big.Int).Add(fromBal, t.ctx.value))
+
+	// Decrement the caller's nonce, and remove empty create targets
+	from.Nonce--
+	if t.ctx.typ == "CREATE" {
+		// We can blindly delete the contract prestate, as any existing state would
+		// have caused the transaction to be rejected as invalid in the first place.
+		t.prestate.delete(hexutil.Encode(t.ctx.to.Bytes()))
+	}
+	return encodeJS(t.prestate)
+}
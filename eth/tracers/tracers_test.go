@@ -120,6 +120,16 @@ type callTracerTest struct {
 	Result  *callTrace    `json:"result"`
 }
 
+// freeMessage converts a transaction into a message paying no gas, so that it
+// can be executed without the sender holding the power it would otherwise need.
+func freeMessage(tx *types.Transaction, signer types.Signer) (types.Message, error) {
+	msg, err := tx.AsMessage(signer)
+	if err != nil {
+		return msg, err
+	}
+	return types.NewMessage(msg.From(), msg.To(), msg.Nonce(), msg.Value(), msg.Gas(), new(big.Int), msg.Data(), msg.AccessList(), true), nil
+}
+
 func TestPrestateTracerCreate2(t *testing.T) {
 	unsigned_tx := types.NewTransaction(1, common.HexToAddress("0x00000000000000000000000000000000deadbeef"),
 		new(big.Int), 5000000, big.NewInt(1), []byte{})
@@ -173,9 +183,9 @@ func TestPrestateTracerCreate2(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create call tracer: %v", err)
 	}
-	evm := vm.NewEVM(context, statedb, params.MainnetChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	evm := vm.NewEVM(context, statedb, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
 
-	msg, err := tx.AsMessage(signer)
+	msg, err := freeMessage(tx, signer)
 	if err != nil {
 		t.Fatalf("failed to prepare transaction for tracing: %v", err)
 	}
@@ -249,7 +259,7 @@ func TestCallTracer(t *testing.T) {
 			}
 			evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
 
-			msg, err := tx.AsMessage(signer)
+			msg, err := freeMessage(tx, signer)
 			if err != nil {
 				t.Fatalf("failed to prepare transaction for tracing: %v", err)
 			}
@@ -0,0 +1,268 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/consensus"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/event"
+	"github.com/ether-ark/etherark/log"
+)
+
+// transferFrame collects the value transfers made within a call frame, which
+// only take effect if the frame, and all the ones it was called from, succeed.
+type transferFrame struct {
+	transfer  *rawdb.InternalTransfer // Value sent by the call opening the frame, nil if none
+	transfers []rawdb.InternalTransfer
+}
+
+// transferTracer is a vm.Tracer recording the value moved by the CALL, CREATE
+// and SELFDESTRUCT operations of the transactions of a block. It only inspects
+// the operations opening and closing call frames, keeping the cost of tracing
+// block imports low.
+type transferTracer struct {
+	frames    []transferFrame // Open call frames of the current transaction
+	index     uint32          // Index of the current transaction in the block
+	transfers []rawdb.InternalTransfer
+}
+
+// CaptureStart implements vm.Tracer, opening the root frame of a transaction.
+func (t *transferTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.frames = append(t.frames[:0], transferFrame{})
+	return nil
+}
+
+// CaptureState implements vm.Tracer, closing the frames of the calls returned
+// from and opening the ones of the calls made.
+func (t *transferTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if len(t.frames) == 0 {
+		return nil
+	}
+	// Calls place their result on the stack of the caller, zero if they failed
+	for len(t.frames) > depth && len(t.frames) > 1 {
+		child := t.frames[len(t.frames)-1]
+		t.frames = t.frames[:len(t.frames)-1]
+
+		if len(stack.Data()) == 0 || stack.Back(0).Sign() == 0 {
+			continue
+		}
+		parent := &t.frames[len(t.frames)-1]
+		if child.transfer != nil {
+			if child.transfer.Kind == rawdb.InternalTransferCreate {
+				child.transfer.To = common.BigToAddress(stack.Back(0))
+			}
+			parent.transfers = append(parent.transfers, *child.transfer)
+		}
+		parent.transfers = append(parent.transfers, child.transfers...)
+	}
+	if err != nil {
+		return nil
+	}
+	if statedb, ok := env.StateDB.(*state.StateDB); ok {
+		t.index = uint32(statedb.TxIndex())
+	}
+	switch op {
+	case vm.CALL:
+		frame := transferFrame{}
+		if value := stack.Back(2); value.Sign() > 0 {
+			frame.transfer = &rawdb.InternalTransfer{
+				TxIndex: t.index,
+				Kind:    rawdb.InternalTransferCall,
+				From:    contract.Address(),
+				To:      common.BigToAddress(stack.Back(1)),
+				Value:   new(big.Int).Set(value),
+			}
+		}
+		t.frames = append(t.frames, frame)
+
+	case vm.CREATE, vm.CREATE2:
+		frame := transferFrame{}
+		if value := stack.Back(0); value.Sign() > 0 {
+			frame.transfer = &rawdb.InternalTransfer{
+				TxIndex: t.index,
+				Kind:    rawdb.InternalTransferCreate,
+				From:    contract.Address(),
+				Value:   new(big.Int).Set(value),
+			}
+		}
+		t.frames = append(t.frames, frame)
+
+	case vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		// Value sent with CALLCODE stays with the calling contract
+		t.frames = append(t.frames, transferFrame{})
+
+	case vm.SELFDESTRUCT:
+		if value := env.StateDB.GetBalance(contract.Address()); value.Sign() > 0 {
+			frame := &t.frames[len(t.frames)-1]
+			frame.transfers = append(frame.transfers, rawdb.InternalTransfer{
+				TxIndex: t.index,
+				Kind:    rawdb.InternalTransferSelfDestruct,
+				From:    contract.Address(),
+				To:      common.BigToAddress(stack.Back(0)),
+				Value:   new(big.Int).Set(value),
+			})
+		}
+	}
+	return nil
+}
+
+// CaptureFault implements vm.Tracer. Failed operations abort their frame, which
+// is noticed from the result the caller receives.
+func (t *transferTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer, keeping the transfers of the transaction if
+// its execution succeeded.
+func (t *transferTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	if err == nil && len(t.frames) > 0 {
+		t.transfers = append(t.transfers, t.frames[0].transfers...)
+	}
+	t.frames = t.frames[:0]
+	return nil
+}
+
+// transferProcessor is a core.Processor tracing the internal value transfers
+// of the blocks it processes into the database.
+type transferProcessor struct {
+	core.Processor
+	db ethdb.Database
+}
+
+// Process implements core.Processor, processing the block with a transfer tracer
+// attached. The transfers are stored keyed by block hash, so those of blocks
+// failing validation or being reorged out are never served.
+func (p *transferProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	if cfg.Debug {
+		return p.Processor.Process(block, statedb, cfg)
+	}
+	tracer := new(transferTracer)
+	cfg.Debug, cfg.Tracer = true, tracer
+
+	receipts, logs, usedGas, err := p.Processor.Process(block, statedb, cfg)
+	if err != nil {
+		return receipts, logs, usedGas, err
+	}
+	rawdb.WriteInternalTransfers(p.db, block.Hash(), block.NumberU64(), tracer.transfers)
+	return receipts, logs, usedGas, nil
+}
+
+// TransferIndexer maintains the internal value transfers of the blocks executed
+// by the node, pruning those older than the retention period.
+type TransferIndexer struct {
+	chain     *core.BlockChain
+	db        ethdb.Database
+	processor *transferProcessor
+	retention uint64 // Number of recent blocks to keep the transfers of, 0 to keep all
+
+	headSub  event.Subscription
+	minedSub *event.TypeMuxSubscription
+	quit     chan struct{}
+}
+
+// NewTransferIndexer installs a transfer tracing processor on the chain and
+// creates an indexer to complete and prune its output.
+func NewTransferIndexer(chain *core.BlockChain, db ethdb.Database, mux *event.TypeMux, retention uint64) *TransferIndexer {
+	processor := &transferProcessor{Processor: chain.Processor(), db: db}
+	chain.SetProcessor(processor)
+
+	return &TransferIndexer{
+		chain:     chain,
+		db:        db,
+		processor: processor,
+		retention: retention,
+		minedSub:  mux.Subscribe(core.NewMinedBlockEvent{}),
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start launches the event loop of the indexer.
+func (t *TransferIndexer) Start() {
+	heads := make(chan core.ChainHeadEvent, 10)
+	t.headSub = t.chain.SubscribeChainHeadEvent(heads)
+
+	go t.loop(heads)
+}
+
+// Stop terminates the event loop of the indexer.
+func (t *TransferIndexer) Stop() {
+	t.headSub.Unsubscribe()
+	t.minedSub.Unsubscribe()
+	close(t.quit)
+}
+
+// loop traces the blocks mined locally, which are assembled without going
+// through the chain's processor, and prunes the transfers of old blocks.
+func (t *TransferIndexer) loop(heads chan core.ChainHeadEvent) {
+	for {
+		select {
+		case obj := <-t.minedSub.Chan():
+			if obj == nil {
+				return
+			}
+			if ev, ok := obj.Data.(core.NewMinedBlockEvent); ok {
+				if err := t.trace(ev.Block); err != nil {
+					log.Warn("Failed to trace internal transfers", "number", ev.Block.Number(), "hash", ev.Block.Hash(), "err", err)
+				}
+			}
+
+		case ev := <-heads:
+			number := ev.Block.NumberU64()
+			if t.retention == 0 || number <= t.retention {
+				continue
+			}
+			pruned, err := rawdb.DeleteInternalTransfers(t.db, number-t.retention)
+			if err != nil {
+				log.Warn("Failed to prune internal transfers", "err", err)
+			} else if pruned > 0 {
+				log.Debug("Pruned internal transfers", "blocks", pruned, "below", number-t.retention)
+			}
+
+		case <-t.headSub.Err():
+			return
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// trace re-executes a block on top of its parent's state to record its internal
+// transfers, unless they were already recorded.
+func (t *TransferIndexer) trace(block *types.Block) error {
+	if _, ok := rawdb.ReadInternalTransfers(t.db, block.Hash(), block.NumberU64()); ok {
+		return nil
+	}
+	parent := t.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	statedb, err := t.chain.StateAt(parent.Root())
+	if err != nil {
+		return err
+	}
+	_, _, _, err = t.processor.Process(block, statedb, *t.chain.GetVMConfig())
+	return err
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/consensus/ethash"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/event"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+// Tests that the value transfers made by contracts are recorded during block
+// imports, leaving out those of reverted frames.
+func TestInternalTransfers(t *testing.T) {
+	var (
+		payee       = common.HexToAddress("0xbb")
+		forwarder   = common.HexToAddress("0xf1")
+		reverter    = common.HexToAddress("0xf2")
+		caller      = common.HexToAddress("0xf3")
+		creator     = common.HexToAddress("0xf4")
+		destructor  = common.HexToAddress("0xf5")
+		forwardCode = append(append([]byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x34, 0x73}, payee.Bytes()...), 0x5a, 0xf1, 0x50)
+	)
+	var (
+		db     = ethdb.NewMemDatabase()
+		engine = ethash.NewFaker()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000)},
+				// Forward the value received to the payee
+				forwarder: {Balance: new(big.Int), Code: append(forwardCode, 0x00)},
+				// Forward the value received to the payee, then revert
+				reverter: {Balance: new(big.Int), Code: append(forwardCode, 0x60, 0x00, 0x60, 0x00, 0xfd)},
+				// Forward the value received to the reverter, ignoring its failure
+				caller: {Balance: new(big.Int), Code: append(append([]byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x34, 0x73}, reverter.Bytes()...), 0x5a, 0xf1, 0x50, 0x00)},
+				// Endow the value received to a new empty contract
+				creator: {Balance: new(big.Int), Code: []byte{0x60, 0x00, 0x60, 0x00, 0x34, 0xf0, 0x50, 0x00}},
+				// Self-destruct, sweeping the balance to the payee
+				destructor: {Balance: big.NewInt(7), Code: append(append([]byte{0x73}, payee.Bytes()...), 0xff)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	targets := []common.Address{forwarder, reverter, caller, creator, destructor}
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, block *core.BlockGen) {
+		for _, target := range targets {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), target, big.NewInt(10), 100000, new(big.Int), nil), signer, testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	indexer := NewTransferIndexer(blockchain, db, new(event.TypeMux), 0)
+	indexer.Start()
+	defer indexer.Stop()
+
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	api := NewPublicTransferAPI(&Ethereum{chainDb: db, blockchain: blockchain})
+
+	have, err := api.GetInternalTransfersByBlock(context.Background(), rpc.BlockNumberOrHashWithNumber(1))
+	if err != nil {
+		t.Fatalf("failed to retrieve internal transfers: %v", err)
+	}
+	txs := chain[0].Transactions()
+	want := []*RPCInternalTransfer{
+		{TransactionHash: txs[0].Hash(), TransactionIndex: 0, Type: "call", From: forwarder, To: payee, Value: (*hexutil.Big)(big.NewInt(10))},
+		{TransactionHash: txs[3].Hash(), TransactionIndex: 3, Type: "create", From: creator, To: crypto.CreateAddress(creator, 0), Value: (*hexutil.Big)(big.NewInt(10))},
+		{TransactionHash: txs[4].Hash(), TransactionIndex: 4, Type: "selfdestruct", From: destructor, To: payee, Value: (*hexutil.Big)(big.NewInt(17))},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("internal transfers mismatch:\nhave %v\nwant %v", dumper.Sdump(have), dumper.Sdump(want))
+	}
+	// Ensure the transfers of a single transaction can be retrieved
+	have, err = api.GetInternalTransfers(context.Background(), txs[4].Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve transaction transfers: %v", err)
+	}
+	if !reflect.DeepEqual(have, want[2:]) {
+		t.Fatalf("transaction transfers mismatch:\nhave %v\nwant %v", dumper.Sdump(have), dumper.Sdump(want[2:]))
+	}
+	// Blocks not executed by the node are reported as such
+	if _, err := api.GetInternalTransfersByBlock(context.Background(), rpc.BlockNumberOrHashWithNumber(0)); err != errTransfersNotIndexed {
+		t.Fatalf("genesis transfers error mismatch: have %v, want %v", err, errTransfersNotIndexed)
+	}
+}
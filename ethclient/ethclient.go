@@ -28,7 +28,6 @@ import (
 	"github.com/ether-ark/etherark/common"
 	"github.com/ether-ark/etherark/common/hexutil"
 	"github.com/ether-ark/etherark/core/types"
-	"github.com/ether-ark/etherark/rlp"
 	"github.com/ether-ark/etherark/rpc"
 )
 
@@ -267,6 +266,62 @@ func (ec *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*
 	return r, err
 }
 
+// AddressTransaction is a transaction, or block reward, an address takes part in.
+type AddressTransaction struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxIndex     *uint        // nil for block rewards
+	TxHash      *common.Hash // nil for block rewards
+	Roles       []string
+}
+
+// TransactionsByAddress returns a page of the transactions and block rewards the
+// address takes part in between the given blocks, newest first. A nil fromBlock
+// selects the genesis block and a nil toBlock the latest one. Next pages are
+// retrieved by passing the returned cursor, which is nil after the last page.
+// The node needs to maintain the address transaction index.
+func (ec *Client) TransactionsByAddress(ctx context.Context, address common.Address, fromBlock, toBlock *big.Int, cursor []byte, limit int) ([]AddressTransaction, []byte, error) {
+	arg := map[string]interface{}{
+		"toBlock": toBlockNumArg(toBlock),
+	}
+	if fromBlock != nil {
+		arg["fromBlock"] = toBlockNumArg(fromBlock)
+	}
+	if cursor != nil {
+		arg["cursor"] = hexutil.Bytes(cursor)
+	}
+	if limit > 0 {
+		arg["limit"] = hexutil.Uint64(limit)
+	}
+	var page struct {
+		Transactions []struct {
+			BlockNumber      hexutil.Uint64  `json:"blockNumber"`
+			BlockHash        common.Hash     `json:"blockHash"`
+			TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+			TransactionHash  *common.Hash    `json:"transactionHash"`
+			Roles            []string        `json:"roles"`
+		} `json:"transactions"`
+		Cursor hexutil.Bytes `json:"cursor"`
+	}
+	if err := ec.c.CallContext(ctx, &page, "eth_getTransactionsByAddress", address, arg); err != nil {
+		return nil, nil, err
+	}
+	txs := make([]AddressTransaction, len(page.Transactions))
+	for i, tx := range page.Transactions {
+		txs[i] = AddressTransaction{
+			BlockNumber: uint64(tx.BlockNumber),
+			BlockHash:   tx.BlockHash,
+			TxHash:      tx.TransactionHash,
+			Roles:       tx.Roles,
+		}
+		if tx.TransactionIndex != nil {
+			index := uint(*tx.TransactionIndex)
+			txs[i].TxIndex = &index
+		}
+	}
+	return txs, page.Cursor, nil
+}
+
 func toBlockNumArg(number *big.Int) string {
 	if number == nil {
 		return "latest"
@@ -460,6 +515,29 @@ func (ec *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockN
 	return hex, nil
 }
 
+// OverrideAccount specifies the fields of an account to override in the state
+// a call is executed against. Nil fields are left untouched.
+type OverrideAccount struct {
+	Nonce     *uint64
+	Code      []byte
+	Balance   *big.Int
+	Power     *big.Int
+	StateDiff map[common.Hash]common.Hash
+}
+
+// CallContractWithOverrides executes a message call transaction like CallContract
+// does, with the given accounts overridden in the state it is executed against.
+// It allows simulating a call as if, for example, the sender had enough power
+// or a contract had different code.
+func (ec *Client) CallContractWithOverrides(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int, overrides map[common.Address]OverrideAccount) ([]byte, error) {
+	var hex hexutil.Bytes
+	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber), toOverrideArg(overrides))
+	if err != nil {
+		return nil, err
+	}
+	return hex, nil
+}
+
 // PendingCallContract executes a message call transaction using the EVM.
 // The state seen by the contract call is the pending state.
 func (ec *Client) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
@@ -494,12 +572,23 @@ func (ec *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64
 	return uint64(hex), nil
 }
 
+// EstimateGasWithOverrides estimates the gas needed to execute a transaction like
+// EstimateGas does, with the given accounts overridden in the pending state.
+func (ec *Client) EstimateGasWithOverrides(ctx context.Context, msg ethereum.CallMsg, overrides map[common.Address]OverrideAccount) (uint64, error) {
+	var hex hexutil.Uint64
+	err := ec.c.CallContext(ctx, &hex, "eth_estimateGas", toCallArg(msg), "pending", toOverrideArg(overrides))
+	if err != nil {
+		return 0, err
+	}
+	return uint64(hex), nil
+}
+
 // SendTransaction injects a signed transaction into the pending pool for execution.
 //
 // If the transaction was a contract creation use the TransactionReceipt method to get the
 // contract address after the transaction has been mined.
 func (ec *Client) SendTransaction(ctx context.Context, tx *types.Transaction) error {
-	data, err := rlp.EncodeToBytes(tx)
+	data, err := tx.MarshalBinary()
 	if err != nil {
 		return err
 	}
@@ -523,5 +612,35 @@ func toCallArg(msg ethereum.CallMsg) interface{} {
 	if msg.GasPrice != nil {
 		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
 	}
+	if msg.AccessList != nil {
+		arg["accessList"] = msg.AccessList
+	}
+	return arg
+}
+
+func toOverrideArg(overrides map[common.Address]OverrideAccount) interface{} {
+	if overrides == nil {
+		return nil
+	}
+	arg := make(map[common.Address]map[string]interface{}, len(overrides))
+	for addr, account := range overrides {
+		fields := make(map[string]interface{})
+		if account.Nonce != nil {
+			fields["nonce"] = hexutil.Uint64(*account.Nonce)
+		}
+		if account.Code != nil {
+			fields["code"] = hexutil.Bytes(account.Code)
+		}
+		if account.Balance != nil {
+			fields["balance"] = (*hexutil.Big)(account.Balance)
+		}
+		if account.Power != nil {
+			fields["power"] = (*hexutil.Big)(account.Power)
+		}
+		if account.StateDiff != nil {
+			fields["stateDiff"] = account.StateDiff
+		}
+		arg[addr] = fields
+	}
 	return arg
 }
@@ -17,6 +17,7 @@
 package ethclient
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"reflect"
@@ -24,6 +25,7 @@ import (
 
 	"github.com/ether-ark/etherark"
 	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/internal/ethapi"
 )
 
 // Verify that Client implements the ethereum interfaces.
@@ -150,3 +152,54 @@ func TestToFilterArg(t *testing.T) {
 		})
 	}
 }
+
+// Tests that state overrides are encoded in the format the RPC server expects.
+func TestToOverrideArg(t *testing.T) {
+	var (
+		addr    = common.HexToAddress("0xD36722ADeC3EdCB29c8e7b5a47f352D701393462")
+		nonce   = uint64(7)
+		slot    = common.HexToHash("0x01")
+		value   = common.HexToHash("0x02")
+		balance = big.NewInt(1e18)
+		power   = big.NewInt(21000 * 1e9)
+	)
+	input := map[common.Address]OverrideAccount{
+		addr: {
+			Nonce:     &nonce,
+			Code:      []byte{0x60, 0x00},
+			Balance:   balance,
+			Power:     power,
+			StateDiff: map[common.Hash]common.Hash{slot: value},
+		},
+	}
+	blob, err := json.Marshal(toOverrideArg(input))
+	if err != nil {
+		t.Fatalf("failed to encode overrides: %v", err)
+	}
+	var output ethapi.StateOverride
+	if err := json.Unmarshal(blob, &output); err != nil {
+		t.Fatalf("failed to decode overrides: %v", err)
+	}
+	account, ok := output[addr]
+	if !ok {
+		t.Fatalf("missing overridden account %x", addr)
+	}
+	if account.Nonce == nil || uint64(*account.Nonce) != nonce {
+		t.Errorf("nonce mismatch: have %v, want %d", account.Nonce, nonce)
+	}
+	if account.Code == nil || !reflect.DeepEqual([]byte(*account.Code), []byte{0x60, 0x00}) {
+		t.Errorf("code mismatch: have %v", account.Code)
+	}
+	if account.Balance == nil || account.Balance.ToInt().Cmp(balance) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", account.Balance, balance)
+	}
+	if account.Power == nil || account.Power.ToInt().Cmp(power) != 0 {
+		t.Errorf("power mismatch: have %v, want %v", account.Power, power)
+	}
+	if !reflect.DeepEqual(account.StateDiff, map[common.Hash]common.Hash{slot: value}) {
+		t.Errorf("state diff mismatch: have %v", account.StateDiff)
+	}
+	if toOverrideArg(nil) != nil {
+		t.Errorf("nil overrides should encode to nil")
+	}
+}
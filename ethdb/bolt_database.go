@@ -0,0 +1,384 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !js
+
+package ethdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// boltFileName is the name of the bolt file inside the database directory.
+	boltFileName = "bolt.db"
+
+	// boltIteratorChunk is the amount of data an iterator loads in one read
+	// transaction.
+	boltIteratorChunk = 1024 * 1024
+
+	// boltSyncInterval is the time between flushes of the single writes to disk.
+	boltSyncInterval = time.Second
+)
+
+var (
+	// boltBucket is the single bucket holding all the database content.
+	boltBucket = []byte("ethdb")
+
+	// boltKeyPrefix is prepended to all the keys, bolt rejects empty ones.
+	boltKeyPrefix = []byte{0x00}
+
+	errBoltNotFound = errors.New("not found")
+)
+
+// BoltDatabase is a persistent key-value store backed by a single B+tree file.
+// Unlike LevelDB, written data isn't rewritten by background compactions,
+// which keeps the write amplification of large imports low.
+//
+// Every write is a bolt transaction of its own, so the transactions are not
+// synced to disk on commit. Batches are synced right after being written, the
+// single writes in between are flushed periodically in the background.
+type BoltDatabase struct {
+	fn  string   // directory holding the bolt file
+	db  *bolt.DB // bolt instance
+	log log.Logger
+
+	dirty    uint32          // Whether single writes are awaiting a sync (atomic)
+	quitLock sync.Mutex      // Mutex protecting the quit channel access
+	quitChan chan chan error // Quit channel to stop the background syncing
+}
+
+// NewBoltDatabase returns a bolt database stored in the given directory. The
+// cache and handles allowances are unused, bolt relies on the OS page cache.
+func NewBoltDatabase(file string, cache int, handles int) (*BoltDatabase, error) {
+	logger := log.New("database", file)
+
+	if err := os.MkdirAll(file, 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(file, boltFileName), 0600, &bolt.Options{
+		Timeout:        time.Second,
+		NoSync:         true,
+		NoFreelistSync: true,
+		FreelistType:   bolt.FreelistMapType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	logger.Info("Opened bolt database")
+
+	bdb := &BoltDatabase{
+		fn:       file,
+		db:       db,
+		log:      logger,
+		quitChan: make(chan chan error),
+	}
+	go bdb.syncLoop()
+
+	return bdb, nil
+}
+
+// syncLoop periodically flushes the single writes to disk until the database is
+// closed, flushing them a last time then.
+func (db *BoltDatabase) syncLoop() {
+	ticker := time.NewTicker(boltSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if atomic.SwapUint32(&db.dirty, 0) == 1 {
+				if err := db.db.Sync(); err != nil {
+					db.log.Error("Failed to sync database", "err", err)
+				}
+			}
+		case errc := <-db.quitChan:
+			var err error
+			if atomic.SwapUint32(&db.dirty, 0) == 1 {
+				err = db.db.Sync()
+			}
+			errc <- err
+			return
+		}
+	}
+}
+
+// boltKey returns the internal key of a database key.
+func boltKey(key []byte) []byte {
+	return append(append(make([]byte, 0, len(boltKeyPrefix)+len(key)), boltKeyPrefix...), key...)
+}
+
+// Path returns the path to the database directory.
+func (db *BoltDatabase) Path() string {
+	return db.fn
+}
+
+// Put puts the given key / value into the database.
+func (db *BoltDatabase) Put(key []byte, value []byte) error {
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(boltKey(key), common.CopyBytes(value))
+	})
+	atomic.StoreUint32(&db.dirty, 1)
+	return err
+}
+
+// Has returns whether the given key is present in the database.
+func (db *BoltDatabase) Has(key []byte) (bool, error) {
+	var found bool
+	err := db.db.View(func(tx *bolt.Tx) error {
+		k, _ := tx.Bucket(boltBucket).Cursor().Seek(boltKey(key))
+		found = k != nil && bytes.Equal(k[len(boltKeyPrefix):], key)
+		return nil
+	})
+	return found, err
+}
+
+// Get returns the value of the given key if it's present.
+func (db *BoltDatabase) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := db.db.View(func(tx *bolt.Tx) error {
+		k, v := tx.Bucket(boltBucket).Cursor().Seek(boltKey(key))
+		if k == nil || !bytes.Equal(k[len(boltKeyPrefix):], key) {
+			return errBoltNotFound
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Delete deletes the key from the database.
+func (db *BoltDatabase) Delete(key []byte) error {
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete(boltKey(key))
+	})
+	atomic.StoreUint32(&db.dirty, 1)
+	return err
+}
+
+// NewIterator returns an iterator over the entire database content.
+func (db *BoltDatabase) NewIterator() Iterator {
+	return db.newIterator(nil, nil)
+}
+
+// NewIteratorWithStart returns an iterator over the database content starting
+// at a particular key.
+func (db *BoltDatabase) NewIteratorWithStart(start []byte) Iterator {
+	return db.newIterator(nil, start)
+}
+
+// NewIteratorWithPrefix returns an iterator over the database content with a
+// particular key prefix.
+func (db *BoltDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return db.newIterator(prefix, nil)
+}
+
+func (db *BoltDatabase) newIterator(prefix []byte, start []byte) Iterator {
+	next := boltKey(prefix)
+	if bytes.Compare(start, prefix) > 0 {
+		next = boltKey(start)
+	}
+	return &boltIterator{
+		db:     db.db,
+		prefix: boltKey(prefix),
+		next:   next,
+		index:  -1,
+	}
+}
+
+// Stat returns a particular internal stat of the database. The only supported
+// property is "bolt.stats".
+func (db *BoltDatabase) Stat(property string) (string, error) {
+	if property != "bolt.stats" {
+		return "", fmt.Errorf("unknown property %q", property)
+	}
+	stats := db.db.Stats()
+	return fmt.Sprintf("Free pages: %d\nPending pages: %d\nFree allocated: %s\nFreelist in use: %s\nRead transactions: %d (open %d)\n"+
+		"Pages allocated: %d (%s)\nNodes split: %d\nNodes rebalanced: %d\nWrites: %d (%v)\n",
+		stats.FreePageN, stats.PendingPageN, common.StorageSize(stats.FreeAlloc), common.StorageSize(stats.FreelistInuse),
+		stats.TxN, stats.OpenTxN, stats.TxStats.PageCount, common.StorageSize(stats.TxStats.PageAlloc),
+		stats.TxStats.Split, stats.TxStats.Rebalance, stats.TxStats.Write, stats.TxStats.WriteTime), nil
+}
+
+// Compact is a noop, bolt reuses the pages freed by deletions in place. The
+// file itself never shrinks, converting the database rewrites it compactly.
+func (db *BoltDatabase) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+// Close flushes the pending single writes and closes the database file.
+func (db *BoltDatabase) Close() {
+	db.quitLock.Lock()
+	defer db.quitLock.Unlock()
+
+	if db.quitChan != nil {
+		errc := make(chan error)
+		db.quitChan <- errc
+		if err := <-errc; err != nil {
+			db.log.Error("Failed to sync database", "err", err)
+		}
+		db.quitChan = nil
+	}
+	if err := db.db.Close(); err != nil {
+		db.log.Error("Failed to close database", "err", err)
+	} else {
+		db.log.Info("Database closed")
+	}
+}
+
+// NewBatch returns a batch collecting writes to apply in a single transaction.
+func (db *BoltDatabase) NewBatch() Batch {
+	return &boltBatch{db: db.db}
+}
+
+type boltBatch struct {
+	db     *bolt.DB
+	writes []kv
+	size   int
+}
+
+func (b *boltBatch) Put(key, value []byte) error {
+	b.writes = append(b.writes, kv{boltKey(key), common.CopyBytes(value), false})
+	b.size += len(value)
+	return nil
+}
+
+func (b *boltBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{boltKey(key), nil, true})
+	b.size += 1
+	return nil
+}
+
+func (b *boltBatch) Write() error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, kv := range b.writes {
+			var err error
+			if kv.del {
+				err = bucket.Delete(kv.k)
+			} else {
+				err = bucket.Put(kv.k, kv.v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return b.db.Sync()
+}
+
+func (b *boltBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *boltBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// boltIterator iterates over the database in chunks, each loaded in a short
+// read transaction. A long lived transaction would block the writers growing
+// the file, so the iterator sees the writes made after its creation.
+type boltIterator struct {
+	db     *bolt.DB
+	prefix []byte // internal key prefix to iterate over
+	next   []byte // internal key to load the next chunk from, nil if done
+
+	keys   [][]byte
+	values [][]byte
+	index  int
+	err    error
+}
+
+func (it *boltIterator) Next() bool {
+	if it.index+1 < len(it.keys) {
+		it.index++
+		return true
+	}
+	if it.next == nil || it.err != nil {
+		it.index = len(it.keys)
+		return false
+	}
+	it.keys, it.values, it.index = nil, nil, 0
+	it.err = it.db.View(func(tx *bolt.Tx) error {
+		var (
+			c    = tx.Bucket(boltBucket).Cursor()
+			size int
+		)
+		for k, v := c.Seek(it.next); k != nil; k, v = c.Next() {
+			if !bytes.HasPrefix(k, it.prefix) {
+				break
+			}
+			if size >= boltIteratorChunk {
+				it.next = append([]byte{}, k...)
+				return nil
+			}
+			it.keys = append(it.keys, append([]byte{}, k[len(boltKeyPrefix):]...))
+			it.values = append(it.values, append([]byte{}, v...))
+			size += len(k) + len(v)
+		}
+		it.next = nil
+		return nil
+	})
+	return it.err == nil && len(it.keys) > 0
+}
+
+func (it *boltIterator) Error() error {
+	return it.err
+}
+
+func (it *boltIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.keys[it.index]
+}
+
+func (it *boltIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+func (it *boltIterator) Release() {
+	it.keys, it.values, it.next = nil, nil, nil
+}
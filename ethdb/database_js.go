@@ -66,3 +66,28 @@ func (db *LDBDatabase) Meter(prefix string) {
 func (db *LDBDatabase) NewBatch() Batch {
 	return nil
 }
+
+func (db *LDBDatabase) NewIterator() Iterator {
+	return nil
+}
+
+func (db *LDBDatabase) NewIteratorWithStart(start []byte) Iterator {
+	return nil
+}
+
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return nil
+}
+
+func (db *LDBDatabase) Stat(property string) (string, error) {
+	return "", errNotSupported
+}
+
+func (db *LDBDatabase) Compact(start []byte, limit []byte) error {
+	return errNotSupported
+}
+
+// NewDatabase opens a persistent database, which isn't supported in the browser.
+func NewDatabase(engine string, file string, cache int, handles int) (Database, error) {
+	return nil, errNotSupported
+}
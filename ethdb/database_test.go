@@ -46,6 +46,22 @@ func newTestLDB() (*ethdb.LDBDatabase, func()) {
 	}
 }
 
+func newTestBolt() (*ethdb.BoltDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "ethdb_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	db, err := ethdb.NewBoltDatabase(dirname, 0, 0)
+	if err != nil {
+		panic("failed to create test database: " + err.Error())
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
 var test_values = []string{"", "a", "1251", "\x00123\x00"}
 
 func TestLDB_PutGet(t *testing.T) {
@@ -54,6 +70,12 @@ func TestLDB_PutGet(t *testing.T) {
 	testPutGet(db, t)
 }
 
+func TestBolt_PutGet(t *testing.T) {
+	db, remove := newTestBolt()
+	defer remove()
+	testPutGet(db, t)
+}
+
 func TestMemoryDB_PutGet(t *testing.T) {
 	testPutGet(ethdb.NewMemDatabase(), t)
 }
@@ -153,6 +175,12 @@ func TestLDB_ParallelPutGet(t *testing.T) {
 	testParallelPutGet(db, t)
 }
 
+func TestBolt_ParallelPutGet(t *testing.T) {
+	db, remove := newTestBolt()
+	defer remove()
+	testParallelPutGet(db, t)
+}
+
 func TestMemoryDB_ParallelPutGet(t *testing.T) {
 	testParallelPutGet(ethdb.NewMemDatabase(), t)
 }
@@ -212,3 +240,97 @@ func testParallelPutGet(db ethdb.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func TestLDB_Iterators(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testIterators(db, t)
+}
+
+func TestBolt_Iterators(t *testing.T) {
+	db, remove := newTestBolt()
+	defer remove()
+	testIterators(db, t)
+}
+
+// Tests that bolt iterators resume correctly across the chunks loaded in
+// separate read transactions.
+func TestBolt_IteratorChunks(t *testing.T) {
+	db, remove := newTestBolt()
+	defer remove()
+
+	value := make([]byte, 300*1024)
+	for i := 0; i < 10; i++ {
+		db.Put([]byte{byte(i)}, value)
+	}
+	it := db.NewIteratorWithStart([]byte{0x02})
+	defer it.Release()
+
+	for i := 2; i < 10; i++ {
+		if !it.Next() {
+			t.Fatalf("iterator ended at item %d: %v", i, it.Error())
+		}
+		if !bytes.Equal(it.Key(), []byte{byte(i)}) || len(it.Value()) != len(value) {
+			t.Fatalf("item %d: key %x, value size %d", i, it.Key(), len(it.Value()))
+		}
+	}
+	if it.Next() {
+		t.Fatalf("iterator not exhausted, key %x", it.Key())
+	}
+}
+
+func TestMemoryDB_Iterators(t *testing.T) {
+	testIterators(ethdb.NewMemDatabase(), t)
+}
+
+func TestTable_Iterators(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	db.Put([]byte("o"), []byte("outside"))
+	db.Put([]byte("tac"), []byte("outside"))
+	testIterators(ethdb.NewTable(db, "tab"), t)
+}
+
+func testIterators(db ethdb.Database, t *testing.T) {
+	keys := []string{"1", "2", "20", "21", "3"}
+	for _, k := range keys {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	collect := func(it ethdb.Iterator) []string {
+		defer it.Release()
+
+		var res []string
+		for it.Next() {
+			if value := string(it.Value()); value != "v"+string(it.Key()) {
+				t.Fatalf("value mismatch for key %q: %q", it.Key(), value)
+			}
+			res = append(res, string(it.Key()))
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("iteration failed: %v", err)
+		}
+		return res
+	}
+	tests := []struct {
+		it   ethdb.Iterator
+		want []string
+	}{
+		{db.NewIterator(), keys},
+		{db.NewIteratorWithStart([]byte("20")), []string{"20", "21", "3"}},
+		{db.NewIteratorWithStart([]byte("4")), nil},
+		{db.NewIteratorWithPrefix([]byte("2")), []string{"2", "20", "21"}},
+		{db.NewIteratorWithPrefix([]byte("4")), nil},
+	}
+	for i, tt := range tests {
+		if have := collect(tt.it); fmt.Sprint(have) != fmt.Sprint(tt.want) {
+			t.Errorf("test %d: keys mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	if err := db.Compact(nil, nil); err != nil {
+		t.Fatalf("compaction failed: %v", err)
+	}
+	if have := collect(db.NewIterator()); fmt.Sprint(have) != fmt.Sprint(keys) {
+		t.Errorf("keys mismatch after compaction: have %v, want %v", have, keys)
+	}
+}
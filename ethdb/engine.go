@@ -0,0 +1,76 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !js
+
+package ethdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Supported persistent database engines.
+const (
+	EngineLevelDB = "leveldb"
+	EngineBolt    = "bolt"
+)
+
+// DetectEngine returns the engine of the database stored in the given directory,
+// or an empty string if there is no database yet.
+func DetectEngine(file string) string {
+	if _, err := os.Stat(filepath.Join(file, boltFileName)); err == nil {
+		return EngineBolt
+	}
+	if _, err := os.Stat(filepath.Join(file, "CURRENT")); err == nil {
+		return EngineLevelDB
+	}
+	return ""
+}
+
+// NewDatabase opens the persistent database stored in the given directory with
+// the requested engine. An existing database must have been created by the same
+// engine, databases of different engines are never mixed. An empty engine opens
+// existing databases with their own engine and creates new ones with LevelDB.
+func NewDatabase(engine string, file string, cache int, handles int) (Database, error) {
+	existing := DetectEngine(file)
+	switch {
+	case engine == "":
+		engine = existing
+		if engine == "" {
+			engine = EngineLevelDB
+		}
+	case existing != "" && existing != engine:
+		return nil, fmt.Errorf("database %s was created by %s, refusing to open it with %s", file, existing, engine)
+	}
+	switch engine {
+	case EngineLevelDB:
+		db, err := NewLDBDatabase(file, cache, handles)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case EngineBolt:
+		db, err := NewBoltDatabase(file, cache, handles)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unknown database engine %q", engine)
+	}
+}
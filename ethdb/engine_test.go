@@ -0,0 +1,133 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !js
+
+package ethdb_test
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ether-ark/etherark/ethdb"
+)
+
+// Tests that databases are reopened with the engine that created them and that
+// opening them with another engine is refused.
+func TestNewDatabaseEngines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethdb_engine_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		engine string // engine creating the database
+		open   string // engine reopening it without mismatch
+		other  string // engine that must be refused
+		want   interface{}
+	}{
+		{"", "", ethdb.EngineBolt, &ethdb.LDBDatabase{}},
+		{ethdb.EngineLevelDB, ethdb.EngineLevelDB, ethdb.EngineBolt, &ethdb.LDBDatabase{}},
+		{ethdb.EngineBolt, "", ethdb.EngineLevelDB, &ethdb.BoltDatabase{}},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, tt.engine+"db")
+
+		db, err := ethdb.NewDatabase(tt.engine, path, 0, 0)
+		if err != nil {
+			t.Fatalf("test %d: failed to create database: %v", i, err)
+		}
+		db.Put([]byte("key"), []byte("value"))
+		db.Close()
+
+		if _, err := ethdb.NewDatabase(tt.other, path, 0, 0); err == nil {
+			t.Fatalf("test %d: database opened with mismatching engine %s", i, tt.other)
+		}
+		db, err = ethdb.NewDatabase(tt.open, path, 0, 0)
+		if err != nil {
+			t.Fatalf("test %d: failed to reopen database: %v", i, err)
+		}
+		switch tt.want.(type) {
+		case *ethdb.LDBDatabase:
+			_, ok := db.(*ethdb.LDBDatabase)
+			if !ok {
+				t.Errorf("test %d: database type mismatch: have %T, want leveldb", i, db)
+			}
+		case *ethdb.BoltDatabase:
+			_, ok := db.(*ethdb.BoltDatabase)
+			if !ok {
+				t.Errorf("test %d: database type mismatch: have %T, want bolt", i, db)
+			}
+		}
+		if value, err := db.Get([]byte("key")); err != nil || string(value) != "value" {
+			t.Errorf("test %d: value mismatch: have %q, %v", i, value, err)
+		}
+		db.Close()
+	}
+	if _, err := ethdb.NewDatabase("rocksdb", filepath.Join(dir, "unknown"), 0, 0); err == nil {
+		t.Fatalf("unknown engine accepted")
+	}
+}
+
+func BenchmarkImportLevelDB(b *testing.B) { benchmarkImport(b, ethdb.EngineLevelDB) }
+func BenchmarkImportBolt(b *testing.B)    { benchmarkImport(b, ethdb.EngineBolt) }
+
+// benchmarkImport measures the import of a block into a database of the given
+// engine: a batch of chain data and trie nodes, followed by the single writes
+// updating the head markers.
+func benchmarkImport(b *testing.B, engine string) {
+	dir, err := ioutil.TempDir("", "ethdb_import_bench_")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ethdb.NewDatabase(engine, filepath.Join(dir, engine), 0, 0)
+	if err != nil {
+		b.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		rng   = rand.New(rand.NewSource(1))
+		key   = make([]byte, 32)
+		value = make([]byte, 256)
+		head  = make([]byte, 8)
+	)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch := db.NewBatch()
+		for j := 0; j < 64; j++ {
+			rng.Read(key)
+			rng.Read(value)
+			batch.Put(key, value)
+		}
+		if err := batch.Write(); err != nil {
+			b.Fatalf("failed to write batch: %v", err)
+		}
+		binary.BigEndian.PutUint64(head, uint64(i))
+		for _, marker := range []string{"LastHeader", "LastBlock", "LastFast"} {
+			if err := db.Put([]byte(marker), head); err != nil {
+				b.Fatalf("failed to write head marker: %v", err)
+			}
+		}
+	}
+}
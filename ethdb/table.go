@@ -16,6 +16,8 @@
 
 package ethdb
 
+import "bytes"
+
 type table struct {
 	db     Database
 	prefix string
@@ -49,3 +51,95 @@ func (dt *table) Delete(key []byte) error {
 func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
+
+func (dt *table) NewIterator() Iterator {
+	return dt.NewIteratorWithPrefix(nil)
+}
+
+func (dt *table) NewIteratorWithStart(start []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithStart(append([]byte(dt.prefix), start...)),
+		prefix: []byte(dt.prefix),
+	}
+}
+
+func (dt *table) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithPrefix(append([]byte(dt.prefix), prefix...)),
+		prefix: []byte(dt.prefix),
+	}
+}
+
+func (dt *table) Stat(property string) (string, error) {
+	return dt.db.Stat(property)
+}
+
+// Compact flattens the given key range of the table, nil bounds meaning the
+// start and the end of the table.
+func (dt *table) Compact(start []byte, limit []byte) error {
+	start = append([]byte(dt.prefix), start...)
+	if limit == nil {
+		limit = prefixLimit([]byte(dt.prefix))
+	} else {
+		limit = append([]byte(dt.prefix), limit...)
+	}
+	return dt.db.Compact(start, limit)
+}
+
+// prefixLimit returns the smallest key greater than all the keys with the given
+// prefix, nil if there is none.
+func prefixLimit(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			limit := append([]byte{}, prefix[:i+1]...)
+			limit[i]++
+			return limit
+		}
+	}
+	return nil
+}
+
+// tableIterator wraps an iterator of the underlying database, stripping the
+// table prefix from the keys and stopping at the end of the table.
+type tableIterator struct {
+	it     Iterator
+	prefix []byte
+	done   bool
+}
+
+func (it *tableIterator) Next() bool {
+	if it.done || !it.it.Next() {
+		return false
+	}
+	if !bytes.HasPrefix(it.it.Key(), it.prefix) {
+		it.done = true
+		return false
+	}
+	return true
+}
+
+func (it *tableIterator) Error() error {
+	return it.it.Error()
+}
+
+func (it *tableIterator) Key() []byte {
+	if it.done {
+		return nil
+	}
+	key := it.it.Key()
+	if key == nil {
+		return nil
+	}
+	return key[len(it.prefix):]
+}
+
+func (it *tableIterator) Value() []byte {
+	if it.done {
+		return nil
+	}
+	return it.it.Value()
+}
+
+func (it *tableIterator) Release() {
+	it.it.Release()
+}
@@ -0,0 +1,1258 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package graphql provides a GraphQL interface to Ethereum node data.
+package graphql
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ether-ark/etherark"
+	"github.com/ether-ark/etherark/accounts/abi/bind"
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/contracts/masternode/contract"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/eth/filters"
+	"github.com/ether-ark/etherark/internal/ethapi"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+// maxBlockRange is the maximum number of blocks a block range query may return.
+const maxBlockRange = 1000
+
+var (
+	errBlockUnknown     = errors.New("unknown block")
+	errBlockInvalid     = errors.New("only one of number or hash may be specified")
+	errFilterless       = errors.New("log filtering is not supported by this node")
+	errMasternodeIDSize = errors.New("masternode ID must be 8 bytes in hexadecimal")
+)
+
+// Account represents an Ethereum account at a particular block.
+type Account struct {
+	backend     ethapi.Backend
+	address     common.Address
+	blockNumber rpc.BlockNumber
+}
+
+// getState fetches the StateDB and header of the block the account is read at.
+func (a *Account) getState(ctx context.Context) (*state.StateDB, *types.Header, error) {
+	state, header, err := a.backend.StateAndHeaderByNumber(ctx, a.blockNumber)
+	if err == nil && state == nil {
+		err = errBlockUnknown
+	}
+	return state, header, err
+}
+
+func (a *Account) Address(ctx context.Context) (common.Address, error) {
+	return a.address, nil
+}
+
+func (a *Account) Balance(ctx context.Context) (hexutil.Big, error) {
+	state, _, err := a.getState(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*state.GetBalance(a.address)), nil
+}
+
+func (a *Account) TransactionCount(ctx context.Context) (hexutil.Uint64, error) {
+	state, _, err := a.getState(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(state.GetNonce(a.address)), nil
+}
+
+func (a *Account) Code(ctx context.Context) (hexutil.Bytes, error) {
+	state, _, err := a.getState(ctx)
+	if err != nil {
+		return hexutil.Bytes{}, err
+	}
+	return hexutil.Bytes(state.GetCode(a.address)), nil
+}
+
+func (a *Account) Storage(ctx context.Context, args struct{ Slot common.Hash }) (common.Hash, error) {
+	state, _, err := a.getState(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return state.GetState(a.address, args.Slot), nil
+}
+
+// Power returns the power of the account settled at the block it is read at.
+func (a *Account) Power(ctx context.Context) (hexutil.Big, error) {
+	state, header, err := a.getState(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*state.GetPower(a.address, header.Number)), nil
+}
+
+// Log represents an individual log message. All arguments are mandatory.
+type Log struct {
+	backend     ethapi.Backend
+	transaction *Transaction
+	log         *types.Log
+}
+
+func (l *Log) Transaction(ctx context.Context) *Transaction {
+	return l.transaction
+}
+
+func (l *Log) Account(ctx context.Context, args BlockNumberArgs) *Account {
+	return &Account{
+		backend:     l.backend,
+		address:     l.log.Address,
+		blockNumber: args.Number(),
+	}
+}
+
+func (l *Log) Index(ctx context.Context) int32 {
+	return int32(l.log.Index)
+}
+
+func (l *Log) Topics(ctx context.Context) []common.Hash {
+	return l.log.Topics
+}
+
+func (l *Log) Data(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(l.log.Data)
+}
+
+// PowerUsage represents the power a transaction sender paid for gas.
+type PowerUsage struct {
+	usage *types.PowerUsage
+}
+
+func (p *PowerUsage) Before(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*p.usage.Before)
+}
+
+func (p *PowerUsage) Charged(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*p.usage.Charged)
+}
+
+func (p *PowerUsage) Refunded(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*p.usage.Refunded)
+}
+
+func (p *PowerUsage) After(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*p.usage.After)
+}
+
+// Transaction represents an Ethereum transaction.
+// backend and hash are mandatory; all others will be fetched when required.
+type Transaction struct {
+	backend ethapi.Backend
+	hash    common.Hash
+	tx      *types.Transaction
+	block   *Block
+	index   uint64
+}
+
+// resolve returns the internal transaction object, fetching it if needed.
+func (t *Transaction) resolve(ctx context.Context) (*types.Transaction, error) {
+	if t.tx == nil {
+		tx, blockHash, _, index := rawdb.ReadTransaction(t.backend.ChainDb(), t.hash)
+		if tx != nil {
+			t.tx = tx
+			t.block = &Block{
+				backend: t.backend,
+				hash:    blockHash,
+			}
+			t.index = index
+		} else {
+			t.tx = t.backend.GetPoolTransaction(t.hash)
+		}
+	}
+	return t.tx, nil
+}
+
+// getReceipt returns the receipt of the transaction, or nil if it has not
+// been mined yet.
+func (t *Transaction) getReceipt(ctx context.Context) (*types.Receipt, error) {
+	if _, err := t.resolve(ctx); err != nil {
+		return nil, err
+	}
+	if t.block == nil {
+		return nil, nil
+	}
+	receipts, err := t.block.resolveReceipts(ctx)
+	if err != nil || int(t.index) >= len(receipts) {
+		return nil, err
+	}
+	return receipts[t.index], nil
+}
+
+func (t *Transaction) Hash(ctx context.Context) common.Hash {
+	return t.hash
+}
+
+func (t *Transaction) InputData(ctx context.Context) (hexutil.Bytes, error) {
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return hexutil.Bytes{}, err
+	}
+	return hexutil.Bytes(tx.Data()), nil
+}
+
+func (t *Transaction) Gas(ctx context.Context) (hexutil.Uint64, error) {
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return 0, err
+	}
+	return hexutil.Uint64(tx.Gas()), nil
+}
+
+func (t *Transaction) GasPrice(ctx context.Context) (hexutil.Big, error) {
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*tx.GasPrice()), nil
+}
+
+func (t *Transaction) Value(ctx context.Context) (hexutil.Big, error) {
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*tx.Value()), nil
+}
+
+func (t *Transaction) Nonce(ctx context.Context) (hexutil.Uint64, error) {
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return 0, err
+	}
+	return hexutil.Uint64(tx.Nonce()), nil
+}
+
+func (t *Transaction) Type(ctx context.Context) (int32, error) {
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return 0, err
+	}
+	return int32(tx.Type()), nil
+}
+
+func (t *Transaction) To(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil || tx.To() == nil {
+		return nil, err
+	}
+	return &Account{
+		backend:     t.backend,
+		address:     *tx.To(),
+		blockNumber: args.Number(),
+	}, nil
+}
+
+func (t *Transaction) From(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	return &Account{
+		backend:     t.backend,
+		address:     ethapi.TransactionSender(tx),
+		blockNumber: args.Number(),
+	}, nil
+}
+
+func (t *Transaction) Block(ctx context.Context) (*Block, error) {
+	if _, err := t.resolve(ctx); err != nil {
+		return nil, err
+	}
+	return t.block, nil
+}
+
+func (t *Transaction) Index(ctx context.Context) (*int32, error) {
+	if _, err := t.resolve(ctx); err != nil {
+		return nil, err
+	}
+	if t.block == nil {
+		return nil, nil
+	}
+	index := int32(t.index)
+	return &index, nil
+}
+
+func (t *Transaction) Status(ctx context.Context) (*hexutil.Uint64, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	ret := hexutil.Uint64(receipt.Status)
+	return &ret, nil
+}
+
+func (t *Transaction) GasUsed(ctx context.Context) (*hexutil.Uint64, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	ret := hexutil.Uint64(receipt.GasUsed)
+	return &ret, nil
+}
+
+func (t *Transaction) CumulativeGasUsed(ctx context.Context) (*hexutil.Uint64, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	ret := hexutil.Uint64(receipt.CumulativeGasUsed)
+	return &ret, nil
+}
+
+func (t *Transaction) CreatedContract(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil || receipt.ContractAddress == (common.Address{}) {
+		return nil, err
+	}
+	return &Account{
+		backend:     t.backend,
+		address:     receipt.ContractAddress,
+		blockNumber: args.Number(),
+	}, nil
+}
+
+func (t *Transaction) Logs(ctx context.Context) (*[]*Log, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	ret := make([]*Log, 0, len(receipt.Logs))
+	for _, log := range receipt.Logs {
+		ret = append(ret, &Log{
+			backend:     t.backend,
+			transaction: t,
+			log:         log,
+		})
+	}
+	return &ret, nil
+}
+
+func (t *Transaction) Power(ctx context.Context) (*PowerUsage, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil || receipt.Power == nil {
+		return nil, err
+	}
+	return &PowerUsage{receipt.Power}, nil
+}
+
+// Block represents an Ethereum block.
+// backend, and either num or hash are mandatory. All other fields are lazily
+// fetched when required.
+type Block struct {
+	backend  ethapi.Backend
+	num      *rpc.BlockNumber
+	hash     common.Hash
+	header   *types.Header
+	block    *types.Block
+	receipts []*types.Receipt
+}
+
+// resolve returns the internal Block object representing this block, fetching
+// it if necessary.
+func (b *Block) resolve(ctx context.Context) (*types.Block, error) {
+	if b.block != nil {
+		return b.block, nil
+	}
+	var err error
+	if b.num != nil {
+		b.block, err = b.backend.BlockByNumber(ctx, *b.num)
+	} else {
+		b.block, err = b.backend.GetBlock(ctx, b.hash)
+	}
+	if b.block != nil && b.header == nil {
+		b.header = b.block.Header()
+		b.pin(b.header)
+	}
+	return b.block, err
+}
+
+// resolveHeader returns the internal Header object for this block, fetching
+// it if necessary. Ommer blocks are created with their header only.
+func (b *Block) resolveHeader(ctx context.Context) (*types.Header, error) {
+	if b.header != nil {
+		return b.header, nil
+	}
+	if b.num != nil {
+		header, err := b.backend.HeaderByNumber(ctx, *b.num)
+		if header == nil {
+			if err == nil {
+				err = errBlockUnknown
+			}
+			return nil, err
+		}
+		b.header = header
+		b.pin(header)
+		return header, nil
+	}
+	if _, err := b.resolve(ctx); err != nil {
+		return nil, err
+	}
+	if b.header == nil {
+		return nil, errBlockUnknown
+	}
+	return b.header, nil
+}
+
+// pin fixes the number and hash of the block to the ones of the given header,
+// so that a block requested as "latest" stays the same block throughout the
+// query.
+func (b *Block) pin(header *types.Header) {
+	num := rpc.BlockNumber(header.Number.Uint64())
+	b.num = &num
+	b.hash = header.Hash()
+}
+
+// resolveReceipts returns the list of receipts for this block, fetching them
+// if necessary.
+func (b *Block) resolveReceipts(ctx context.Context) ([]*types.Receipt, error) {
+	if b.receipts == nil {
+		if _, err := b.resolveHeader(ctx); err != nil {
+			return nil, err
+		}
+		receipts, err := b.backend.GetReceipts(ctx, b.hash)
+		if err != nil {
+			return nil, err
+		}
+		b.receipts = []*types.Receipt(receipts)
+	}
+	return b.receipts, nil
+}
+
+// resolveNumber returns the number of the block, used to read its state.
+func (b *Block) resolveNumber(ctx context.Context) (rpc.BlockNumber, error) {
+	if _, err := b.resolveHeader(ctx); err != nil {
+		return 0, err
+	}
+	return *b.num, nil
+}
+
+func (b *Block) Number(ctx context.Context) (hexutil.Uint64, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(header.Number.Uint64()), nil
+}
+
+func (b *Block) Hash(ctx context.Context) (common.Hash, error) {
+	if _, err := b.resolveHeader(ctx); err != nil {
+		return common.Hash{}, err
+	}
+	return b.hash, nil
+}
+
+func (b *Block) GasLimit(ctx context.Context) (hexutil.Uint64, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(header.GasLimit), nil
+}
+
+func (b *Block) GasUsed(ctx context.Context) (hexutil.Uint64, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(header.GasUsed), nil
+}
+
+func (b *Block) Parent(ctx context.Context) (*Block, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header.Number.Sign() == 0 {
+		return nil, err
+	}
+	num := rpc.BlockNumber(header.Number.Uint64() - 1)
+	return &Block{
+		backend: b.backend,
+		num:     &num,
+		hash:    header.ParentHash,
+	}, nil
+}
+
+func (b *Block) Difficulty(ctx context.Context) (hexutil.Big, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*header.Difficulty), nil
+}
+
+func (b *Block) Timestamp(ctx context.Context) (hexutil.Uint64, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(header.Time), nil
+}
+
+func (b *Block) Nonce(ctx context.Context) (hexutil.Bytes, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return hexutil.Bytes{}, err
+	}
+	return hexutil.Bytes(header.Nonce[:]), nil
+}
+
+func (b *Block) MixHash(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.MixDigest, nil
+}
+
+func (b *Block) TransactionsRoot(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.TxHash, nil
+}
+
+func (b *Block) StateRoot(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.Root, nil
+}
+
+func (b *Block) ReceiptsRoot(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.ReceiptHash, nil
+}
+
+func (b *Block) OmmerHash(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.UncleHash, nil
+}
+
+func (b *Block) OmmerCount(ctx context.Context) (*int32, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	count := int32(len(block.Uncles()))
+	return &count, err
+}
+
+func (b *Block) Ommers(ctx context.Context) (*[]*Block, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	ret := make([]*Block, 0, len(block.Uncles()))
+	for _, uncle := range block.Uncles() {
+		ret = append(ret, b.ommer(uncle))
+	}
+	return &ret, nil
+}
+
+func (b *Block) OmmerAt(ctx context.Context, args struct{ Index int32 }) (*Block, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	uncles := block.Uncles()
+	if args.Index < 0 || int(args.Index) >= len(uncles) {
+		return nil, nil
+	}
+	return b.ommer(uncles[args.Index]), nil
+}
+
+// ommer creates a header only block for an ommer of this block.
+func (b *Block) ommer(header *types.Header) *Block {
+	return &Block{
+		backend: b.backend,
+		hash:    header.Hash(),
+		header:  header,
+	}
+}
+
+func (b *Block) ExtraData(ctx context.Context) (hexutil.Bytes, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return hexutil.Bytes{}, err
+	}
+	return hexutil.Bytes(header.Extra), nil
+}
+
+func (b *Block) LogsBloom(ctx context.Context) (hexutil.Bytes, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return hexutil.Bytes{}, err
+	}
+	return hexutil.Bytes(header.Bloom.Bytes()), nil
+}
+
+func (b *Block) TotalDifficulty(ctx context.Context) (hexutil.Big, error) {
+	if _, err := b.resolveHeader(ctx); err != nil {
+		return hexutil.Big{}, err
+	}
+	td := b.backend.GetTd(b.hash)
+	if td == nil {
+		return hexutil.Big{}, errBlockUnknown
+	}
+	return hexutil.Big(*td), nil
+}
+
+func (b *Block) Miner(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{
+		backend:     b.backend,
+		address:     header.Coinbase,
+		blockNumber: args.Number(),
+	}, nil
+}
+
+func (b *Block) Witness(ctx context.Context) (string, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return "", err
+	}
+	return header.Witness, nil
+}
+
+func (b *Block) Signature(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.Signature, nil
+}
+
+func (b *Block) Referrers(ctx context.Context) ([]common.Address, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if header.Referrers == nil {
+		return []common.Address{}, nil
+	}
+	return header.Referrers, nil
+}
+
+func (b *Block) TransactionCount(ctx context.Context) (*int32, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	count := int32(len(block.Transactions()))
+	return &count, err
+}
+
+func (b *Block) Transactions(ctx context.Context) (*[]*Transaction, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	ret := make([]*Transaction, 0, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		ret = append(ret, b.transaction(tx, uint64(i)))
+	}
+	return &ret, nil
+}
+
+func (b *Block) TransactionAt(ctx context.Context, args struct{ Index int32 }) (*Transaction, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	if args.Index < 0 || int(args.Index) >= len(txs) {
+		return nil, nil
+	}
+	return b.transaction(txs[args.Index], uint64(args.Index)), nil
+}
+
+// transaction creates the resolver of a transaction included in this block.
+func (b *Block) transaction(tx *types.Transaction, index uint64) *Transaction {
+	return &Transaction{
+		backend: b.backend,
+		hash:    tx.Hash(),
+		tx:      tx,
+		block:   b,
+		index:   index,
+	}
+}
+
+// BlockFilterCriteria encapsulates criteria passed to a `logs` accessor inside
+// a block.
+type BlockFilterCriteria struct {
+	Addresses *[]common.Address // restricts matches to events created by specific contracts
+
+	// The Topic list restricts matches to particular event topics. Each event has a list
+	// of topics. Topics matches a prefix of that list. An empty element slice matches any
+	// topic. Non-empty elements represent an alternative that matches any of the
+	// contained topics.
+	//
+	// Examples:
+	// {} or nil          matches any topic list
+	// {{A}}              matches topic A in first position
+	// {{}, {B}}          matches any topic in first position, B in second position
+	// {{A}, {B}}         matches topic A in first position, B in second position
+	// {{A, B}}, {C, D}}  matches topic (A OR B) in first position, (C OR D) in second position
+	Topics *[][]common.Hash
+}
+
+// unpack returns the addresses and topics of the criteria, nil if unset.
+func (c BlockFilterCriteria) unpack() ([]common.Address, [][]common.Hash) {
+	var (
+		addresses []common.Address
+		topics    [][]common.Hash
+	)
+	if c.Addresses != nil {
+		addresses = *c.Addresses
+	}
+	if c.Topics != nil {
+		topics = *c.Topics
+	}
+	return addresses, topics
+}
+
+func (b *Block) Logs(ctx context.Context, args struct{ Filter BlockFilterCriteria }) ([]*Log, error) {
+	if _, err := b.resolveHeader(ctx); err != nil {
+		return nil, err
+	}
+	backend, ok := b.backend.(filters.Backend)
+	if !ok {
+		return nil, errFilterless
+	}
+	addresses, topics := args.Filter.unpack()
+	return runFilter(ctx, b.backend, filters.NewBlockFilter(backend, b.hash, addresses, topics))
+}
+
+func (b *Block) Account(ctx context.Context, args struct{ Address common.Address }) (*Account, error) {
+	num, err := b.resolveNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{
+		backend:     b.backend,
+		address:     args.Address,
+		blockNumber: num,
+	}, nil
+}
+
+// CallData encapsulates arguments to `call` or `estimateGas`.
+// All arguments are optional.
+type CallData struct {
+	From     *common.Address // The Ethereum address the call is from.
+	To       *common.Address // The Ethereum address the call is to.
+	Gas      *hexutil.Uint64 // The amount of gas provided for the call.
+	GasPrice *hexutil.Big    // The price of each unit of gas, in wei.
+	Value    *hexutil.Big    // The value sent along with the call.
+	Data     *hexutil.Bytes  // Any data sent with the call.
+}
+
+// toCallArgs converts the call data into the arguments of a JSON-RPC call.
+func (data CallData) toCallArgs() ethapi.CallArgs {
+	args := ethapi.CallArgs{To: data.To}
+	if data.From != nil {
+		args.From = *data.From
+	}
+	if data.Gas != nil {
+		args.Gas = *data.Gas
+	}
+	if data.GasPrice != nil {
+		args.GasPrice = *data.GasPrice
+	}
+	if data.Value != nil {
+		args.Value = *data.Value
+	}
+	if data.Data != nil {
+		args.Data = *data.Data
+	}
+	return args
+}
+
+// CallResult encapsulates the result of an invocation of the `call` accessor.
+type CallResult struct {
+	data    hexutil.Bytes  // The return data from the call
+	gasUsed hexutil.Uint64 // The amount of gas used
+	status  hexutil.Uint64 // The return status of the call - 0 for failure or 1 for success.
+}
+
+func (c *CallResult) Data() hexutil.Bytes {
+	return c.data
+}
+
+func (c *CallResult) GasUsed() hexutil.Uint64 {
+	return c.gasUsed
+}
+
+func (c *CallResult) Status() hexutil.Uint64 {
+	return c.status
+}
+
+func (b *Block) Call(ctx context.Context, args struct{ Data CallData }) (*CallResult, error) {
+	num, err := b.resolveNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, gas, failed, err := ethapi.DoCall(ctx, b.backend, args.Data.toCallArgs(), num, nil, vm.Config{}, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	status := hexutil.Uint64(1)
+	if failed {
+		status = 0
+	}
+	return &CallResult{
+		data:    hexutil.Bytes(result),
+		gasUsed: hexutil.Uint64(gas),
+		status:  status,
+	}, nil
+}
+
+func (b *Block) EstimateGas(ctx context.Context, args struct{ Data CallData }) (hexutil.Uint64, error) {
+	num, err := b.resolveNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return ethapi.DoEstimateGas(ctx, b.backend, args.Data.toCallArgs(), num, nil)
+}
+
+func (b *Block) Masternodes(ctx context.Context, args struct{ Online *bool }) ([]*Masternode, error) {
+	num, err := b.resolveNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return listMasternodes(ctx, b.backend, num, args.Online != nil && *args.Online)
+}
+
+// Masternode represents a node registered in the masternode contract.
+type Masternode struct {
+	backend ethapi.Backend
+	id      [8]byte
+	node    masternodeData
+}
+
+// masternodeData is the registry entry of a masternode, as returned by the
+// contract binding.
+type masternodeData struct {
+	Id1            [32]byte
+	Id2            [32]byte
+	PreId          [8]byte
+	NextId         [8]byte
+	PreOnlineId    [8]byte
+	NextOnlineId   [8]byte
+	Coinbase       common.Address
+	BlockRegister  *big.Int
+	BlockLastPing  *big.Int
+	BlockOnline    *big.Int
+	BlockOnlineAcc *big.Int
+}
+
+func (m *Masternode) ID(ctx context.Context) string {
+	return fmt.Sprintf("%x", m.id[:])
+}
+
+func (m *Masternode) NodeID(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(append(m.node.Id1[:], m.node.Id2[:]...))
+}
+
+func (m *Masternode) Account(ctx context.Context, args BlockNumberArgs) *Account {
+	return &Account{
+		backend:     m.backend,
+		address:     m.node.Coinbase,
+		blockNumber: args.Number(),
+	}
+}
+
+func (m *Masternode) BlockRegister(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(m.node.BlockRegister.Uint64())
+}
+
+func (m *Masternode) BlockLastPing(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(m.node.BlockLastPing.Uint64())
+}
+
+func (m *Masternode) BlockOnline(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(m.node.BlockOnline.Uint64())
+}
+
+func (m *Masternode) BlockOnlineAcc(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(m.node.BlockOnlineAcc.Uint64())
+}
+
+// stateCaller serves the read-only contract calls of a binding from the state
+// of a single block, so that the masternode registry can be read on any node
+// implementing the API backend.
+type stateCaller struct {
+	backend ethapi.Backend
+	state   *state.StateDB
+	header  *types.Header
+}
+
+func (c *stateCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return c.state.GetCode(contract), c.state.Error()
+}
+
+// CallContract executes the call paying no gas, which bypasses the power
+// check of the sender.
+func (c *stateCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	msg := types.NewMessage(call.From, call.To, 0, new(big.Int), math.MaxUint64/2, new(big.Int), call.Data, call.AccessList, false)
+
+	evm, vmError, err := c.backend.GetEVM(ctx, msg, c.state, c.header)
+	if err != nil {
+		return nil, err
+	}
+	res, _, _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(math.MaxUint64))
+	if err := vmError(); err != nil {
+		return nil, err
+	}
+	return res, err
+}
+
+// registryAt binds the masternode contract to the state of the given block.
+func registryAt(ctx context.Context, backend ethapi.Backend, blockNumber rpc.BlockNumber) (*contract.ContractCaller, error) {
+	state, header, err := backend.StateAndHeaderByNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, errBlockUnknown
+	}
+	return contract.NewContractCaller(params.MasterndeContractAddress, &stateCaller{
+		backend: backend,
+		state:   state.Copy(),
+		header:  header,
+	})
+}
+
+// listMasternodes walks the registry of the masternode contract at the given
+// block from its last entry, following the list of online nodes if online is
+// set or the list of all registered nodes otherwise.
+func listMasternodes(ctx context.Context, backend ethapi.Backend, blockNumber rpc.BlockNumber, online bool) ([]*Masternode, error) {
+	registry, err := registryAt(ctx, backend, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	opts := &bind.CallOpts{Context: ctx}
+
+	var id [8]byte
+	if online {
+		id, err = registry.LastOnlineId(opts)
+	} else {
+		id, err = registry.LastId(opts)
+	}
+	if err == bind.ErrNoCode {
+		return []*Masternode{}, nil // No registry deployed at this block
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret := []*Masternode{}
+	for id != ([8]byte{}) {
+		node, err := registry.Nodes(opts, id)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &Masternode{
+			backend: backend,
+			id:      id,
+			node:    node,
+		})
+		if online {
+			id = node.PreOnlineId
+		} else {
+			id = node.PreId
+		}
+	}
+	return ret, nil
+}
+
+// BlockNumberArgs encapsulates arguments to accessors that specify a block number.
+type BlockNumberArgs struct {
+	Block *hexutil.Uint64
+}
+
+// Number returns the provided block number, or rpc.LatestBlockNumber if none
+// was provided.
+func (a BlockNumberArgs) Number() rpc.BlockNumber {
+	if a.Block != nil {
+		return rpc.BlockNumber(*a.Block)
+	}
+	return rpc.LatestBlockNumber
+}
+
+// SyncState represents the synchronisation status returned from the `syncing` accessor.
+type SyncState struct {
+	progress ethereum.SyncProgress
+}
+
+func (s *SyncState) StartingBlock() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.StartingBlock)
+}
+
+func (s *SyncState) CurrentBlock() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.CurrentBlock)
+}
+
+func (s *SyncState) HighestBlock() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.HighestBlock)
+}
+
+func (s *SyncState) PulledStates() *hexutil.Uint64 {
+	ret := hexutil.Uint64(s.progress.PulledStates)
+	return &ret
+}
+
+func (s *SyncState) KnownStates() *hexutil.Uint64 {
+	ret := hexutil.Uint64(s.progress.KnownStates)
+	return &ret
+}
+
+// Resolver is the top-level object in the GraphQL hierarchy.
+type Resolver struct {
+	backend    ethapi.Backend
+	logsLimits filters.Config // Limits of the log queries over block ranges
+}
+
+func (r *Resolver) Block(ctx context.Context, args struct {
+	Number *hexutil.Uint64
+	Hash   *common.Hash
+}) (*Block, error) {
+	var block *Block
+	switch {
+	case args.Number != nil && args.Hash != nil:
+		return nil, errBlockInvalid
+	case args.Number != nil:
+		num := rpc.BlockNumber(uint64(*args.Number))
+		block = &Block{
+			backend: r.backend,
+			num:     &num,
+		}
+	case args.Hash != nil:
+		block = &Block{
+			backend: r.backend,
+			hash:    *args.Hash,
+		}
+	default:
+		num := rpc.LatestBlockNumber
+		block = &Block{
+			backend: r.backend,
+			num:     &num,
+		}
+	}
+	// Resolve the header, return nil if it doesn't exist.
+	if _, err := block.resolveHeader(ctx); err == errBlockUnknown {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (r *Resolver) Blocks(ctx context.Context, args struct {
+	From hexutil.Uint64
+	To   *hexutil.Uint64
+}) ([]*Block, error) {
+	from := uint64(args.From)
+
+	to := r.backend.CurrentBlock().NumberU64()
+	if args.To != nil && uint64(*args.To) < to {
+		to = uint64(*args.To)
+	}
+	if from <= to && to-from >= maxBlockRange {
+		return nil, fmt.Errorf("block range exceeds limit of %d blocks", maxBlockRange)
+	}
+	ret := []*Block{}
+	for i := from; i <= to; i++ {
+		num := rpc.BlockNumber(i)
+		ret = append(ret, &Block{
+			backend: r.backend,
+			num:     &num,
+		})
+	}
+	return ret, nil
+}
+
+func (r *Resolver) Transaction(ctx context.Context, args struct{ Hash common.Hash }) (*Transaction, error) {
+	tx := &Transaction{
+		backend: r.backend,
+		hash:    args.Hash,
+	}
+	// Resolve the transaction; if it doesn't exist, return nil.
+	t, err := tx.resolve(ctx)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (r *Resolver) SendRawTransaction(ctx context.Context, args struct{ Data hexutil.Bytes }) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(args.Data); err != nil {
+		return common.Hash{}, err
+	}
+	return ethapi.SubmitTransaction(ctx, r.backend, tx)
+}
+
+// FilterCriteria encapsulates the arguments to `logs` on the root resolver object.
+type FilterCriteria struct {
+	FromBlock *hexutil.Uint64   // beginning of the queried range, nil means latest block
+	ToBlock   *hexutil.Uint64   // end of the range, nil means latest block
+	Addresses *[]common.Address // restricts matches to events created by specific contracts
+
+	// The Topic list restricts matches to particular event topics. Each event has a list
+	// of topics. Topics matches a prefix of that list. An empty element slice matches any
+	// topic. Non-empty elements represent an alternative that matches any of the
+	// contained topics.
+	//
+	// Examples:
+	// {} or nil          matches any topic list
+	// {{A}}              matches topic A in first position
+	// {{}, {B}}          matches any topic in first position, B in second position
+	// {{A}, {B}}         matches topic A in first position, B in second position
+	// {{A, B}}, {C, D}}  matches topic (A OR B) in first position, (C OR D) in second position
+	Topics *[][]common.Hash
+}
+
+// runFilter runs a filter and resolves its logs.
+func runFilter(ctx context.Context, be ethapi.Backend, filter *filters.Filter) ([]*Log, error) {
+	logs, err := filter.Logs(ctx)
+	if err != nil || logs == nil {
+		return nil, err
+	}
+	ret := make([]*Log, 0, len(logs))
+	for _, log := range logs {
+		ret = append(ret, &Log{
+			backend:     be,
+			transaction: &Transaction{backend: be, hash: log.TxHash},
+			log:         log,
+		})
+	}
+	return ret, nil
+}
+
+func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) ([]*Log, error) {
+	backend, ok := r.backend.(filters.Backend)
+	if !ok {
+		return nil, errFilterless
+	}
+	// Convert the RPC block numbers into internal representations
+	begin := rpc.LatestBlockNumber.Int64()
+	if args.Filter.FromBlock != nil {
+		begin = int64(*args.Filter.FromBlock)
+	}
+	end := rpc.LatestBlockNumber.Int64()
+	if args.Filter.ToBlock != nil {
+		end = int64(*args.Filter.ToBlock)
+	}
+	addresses, topics := BlockFilterCriteria{args.Filter.Addresses, args.Filter.Topics}.unpack()
+
+	// Construct the range filter, restricted to the limits of the node
+	filter := filters.NewRangeFilter(backend, begin, end, addresses, topics)
+	filter.SetLimits(r.logsLimits.BlockLimit, r.logsLimits.ResultLimit)
+
+	return runFilter(ctx, r.backend, filter)
+}
+
+func (r *Resolver) GasPrice(ctx context.Context) (hexutil.Big, error) {
+	price, err := r.backend.SuggestPrice(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*price), nil
+}
+
+func (r *Resolver) ProtocolVersion(ctx context.Context) (int32, error) {
+	return int32(r.backend.ProtocolVersion()), nil
+}
+
+// Syncing returns false in case the node is currently not syncing with the network. It can be up to date or has not
+// yet received the latest block headers from its pears. In case it is synchronizing:
+// - startingBlock: block number this node started to synchronise from
+// - currentBlock:  block number this node is currently importing
+// - highestBlock:  block number of the highest block header this node has received from peers
+// - pulledStates:  number of state entries processed until now
+// - knownStates:   number of known state entries that still need to be pulled
+func (r *Resolver) Syncing() (*SyncState, error) {
+	progress := r.backend.Downloader().Progress()
+
+	// Return not syncing if the synchronisation already completed
+	if progress.CurrentBlock >= progress.HighestBlock {
+		return nil, nil
+	}
+	// Otherwise gather the block sync stats
+	return &SyncState{progress}, nil
+}
+
+func (r *Resolver) Masternodes(ctx context.Context, args struct {
+	Block  *hexutil.Uint64
+	Online *bool
+}) ([]*Masternode, error) {
+	return listMasternodes(ctx, r.backend, BlockNumberArgs{args.Block}.Number(), args.Online != nil && *args.Online)
+}
+
+func (r *Resolver) Masternode(ctx context.Context, args struct {
+	ID    string
+	Block *hexutil.Uint64
+}) (*Masternode, error) {
+	raw, err := hex.DecodeString(args.ID)
+	if err != nil || len(raw) != 8 {
+		return nil, errMasternodeIDSize
+	}
+	var id [8]byte
+	copy(id[:], raw)
+
+	registry, err := registryAt(ctx, r.backend, BlockNumberArgs{args.Block}.Number())
+	if err != nil {
+		return nil, err
+	}
+	node, err := registry.Nodes(&bind.CallOpts{Context: ctx}, id)
+	if err == bind.ErrNoCode || (err == nil && node.Id1 == ([32]byte{})) {
+		return nil, nil // Unknown masternode, or no registry deployed at this block
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Masternode{
+		backend: r.backend,
+		id:      id,
+		node:    node,
+	}, nil
+}
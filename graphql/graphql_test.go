@@ -0,0 +1,287 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/consensus/ethash"
+	"github.com/ether-ark/etherark/core"
+	"github.com/ether-ark/etherark/core/bloombits"
+	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/core/vm"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/eth/filters"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/event"
+	"github.com/ether-ark/etherark/internal/ethapi"
+	"github.com/ether-ark/etherark/params"
+	"github.com/ether-ark/etherark/rpc"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+)
+
+// testBackend serves the GraphQL resolvers from a local chain. Methods the
+// resolvers exercised by the tests don't need are left unimplemented.
+type testBackend struct {
+	ethapi.Backend
+	db    ethdb.Database
+	chain *core.BlockChain
+}
+
+func (b *testBackend) ChainDb() ethdb.Database          { return b.db }
+func (b *testBackend) ChainConfig() *params.ChainConfig { return b.chain.Config() }
+func (b *testBackend) CurrentBlock() *types.Block       { return b.chain.CurrentBlock() }
+func (b *testBackend) GetTd(hash common.Hash) *big.Int  { return b.chain.GetTdByHash(hash) }
+
+func (b *testBackend) GetPoolTransaction(hash common.Hash) *types.Transaction { return nil }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.chain.CurrentBlock().Header(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, _ := b.HeaderByNumber(ctx, number)
+	if header == nil {
+		return nil, nil, nil
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(hash), nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.chain.GetReceiptsByHash(hash), nil
+}
+
+func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.chain.GetHeaderByHash(hash), nil
+}
+
+func (b *testBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	receipts := b.chain.GetReceiptsByHash(hash)
+	logs := make([][]*types.Log, len(receipts))
+	for i, receipt := range receipts {
+		logs[i] = receipt.Logs
+	}
+	return logs, nil
+}
+
+func (b *testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
+	return nil
+}
+
+func (b *testBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription { return nil }
+
+func (b *testBackend) BloomStatus() (uint64, uint64) { return params.BloomBitsBlocks, 0 }
+
+func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+	context := core.NewEVMContext(msg, header, b.chain, nil)
+	return vm.NewEVM(context, state, b.chain.Config(), vm.Config{}), func() error { return nil }, nil
+}
+
+// newTestBackend creates a chain of the given length, with a transfer from the
+// test account to the recipient in every block.
+func newTestBackend(t *testing.T, blocks int, recipient common.Address) *testBackend {
+	var (
+		db     = ethdb.NewMemDatabase()
+		engine = ethash.NewFaker()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testAddress: {Balance: big.NewInt(1000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, blocks, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testAddress), recipient, big.NewInt(1000), params.TxGas, new(big.Int), nil), signer, testKey)
+		block.AddTx(tx)
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil)
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return &testBackend{db: db, chain: blockchain}
+}
+
+// query posts the given GraphQL query to the handler, returning the decoded
+// response.
+func query(t *testing.T, handler http.Handler, q string) (data map[string]interface{}, errs []interface{}) {
+	body, _ := json.Marshal(map[string]string{"query": q})
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query %q: status mismatch: have %d, want %d", q, rec.Code, http.StatusOK)
+	}
+	var res struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []interface{}          `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("query %q: failed to decode response: %v", q, err)
+	}
+	return res.Data, res.Errors
+}
+
+// Tests that the schema parses and is fully backed by resolvers.
+func TestBuildSchema(t *testing.T) {
+	if _, err := newHandler(nil, filters.Config{}); err != nil {
+		t.Fatalf("could not construct GraphQL handler: %v", err)
+	}
+}
+
+// Tests that blocks, transactions, receipts and accounts are served from the
+// backend.
+func TestQueries(t *testing.T) {
+	recipient := common.Address{0x01}
+
+	backend := newTestBackend(t, 4, recipient)
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend, filters.Config{})
+	if err != nil {
+		t.Fatalf("could not construct GraphQL handler: %v", err)
+	}
+	block := backend.chain.GetBlockByNumber(2)
+	tx := block.Transactions()[0]
+
+	data, errs := query(t, handler, `{ block(number: 2) { number hash parent { number } transactionCount
+		transactions { hash from { address } to { address } value status gasUsed } } }`)
+	if len(errs) != 0 {
+		t.Fatalf("block query failed: %v", errs)
+	}
+	have, _ := json.Marshal(data)
+	want := fmt.Sprintf(`{"block":{"hash":"%s","number":"0x2","parent":{"number":"0x1"},"transactionCount":1,"transactions":[{"from":{"address":"%s"},"gasUsed":"0x5208","hash":"%s","status":"0x1","to":{"address":"%s"},"value":"0x3e8"}]}}`,
+		block.Hash().Hex(), strings.ToLower(testAddress.Hex()), tx.Hash().Hex(), strings.ToLower(recipient.Hex()))
+	if string(have) != want {
+		t.Errorf("block query mismatch:\nhave %s\nwant %s", have, want)
+	}
+	// Transactions are looked up by hash, and accounts are read at the block
+	// requested
+	data, errs = query(t, handler, fmt.Sprintf(`{ transaction(hash: "%s") { index block { number }
+		to(block: 2) { balance transactionCount } from { transactionCount } } }`, tx.Hash().Hex()))
+	if len(errs) != 0 {
+		t.Fatalf("transaction query failed: %v", errs)
+	}
+	have, _ = json.Marshal(data)
+	want = `{"transaction":{"block":{"number":"0x2"},"from":{"transactionCount":"0x4"},"index":0,"to":{"balance":"0x7d0","transactionCount":"0x0"}}}`
+	if string(have) != want {
+		t.Errorf("transaction query mismatch:\nhave %s\nwant %s", have, want)
+	}
+	// Unknown blocks and transactions resolve to null
+	data, errs = query(t, handler, `{ block(number: 100) { number } transaction(hash: "0x0000000000000000000000000000000000000000000000000000000000000001") { index } }`)
+	if len(errs) != 0 {
+		t.Fatalf("missing item query failed: %v", errs)
+	}
+	if data["block"] != nil || data["transaction"] != nil {
+		t.Errorf("missing items resolved: %v", data)
+	}
+	// Block ranges are capped at the head of the chain
+	data, errs = query(t, handler, `{ blocks(from: 3, to: 10) { number } }`)
+	if len(errs) != 0 {
+		t.Fatalf("block range query failed: %v", errs)
+	}
+	have, _ = json.Marshal(data)
+	if want = `{"blocks":[{"number":"0x3"},{"number":"0x4"}]}`; string(have) != want {
+		t.Errorf("block range mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
+
+// Tests that log queries are restricted to the limits of the node, and that
+// block range queries are capped.
+func TestQueryLimits(t *testing.T) {
+	backend := newTestBackend(t, maxBlockRange, common.Address{0x01})
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend, filters.Config{BlockLimit: 2})
+	if err != nil {
+		t.Fatalf("could not construct GraphQL handler: %v", err)
+	}
+	data, errs := query(t, handler, `{ logs(filter: { fromBlock: 1, toBlock: 2 }) { index } }`)
+	if len(errs) != 0 {
+		t.Fatalf("log query within limits failed: %v", errs)
+	}
+	if have, _ := json.Marshal(data); string(have) != `{"logs":[]}` {
+		t.Errorf("log query mismatch: have %s", have)
+	}
+	if _, errs := query(t, handler, `{ logs(filter: { fromBlock: 1, toBlock: 3 }) { index } }`); len(errs) == 0 {
+		t.Errorf("log query past block limit accepted")
+	}
+	data, errs = query(t, handler, `{ blocks(from: 1) { number } }`)
+	if len(errs) != 0 {
+		t.Fatalf("block range query within limit failed: %v", errs)
+	}
+	if blocks, _ := data["blocks"].([]interface{}); len(blocks) != maxBlockRange {
+		t.Errorf("block range length mismatch: have %d, want %d", len(blocks), maxBlockRange)
+	}
+	if _, errs := query(t, handler, `{ blocks(from: 0) { number } }`); len(errs) == 0 {
+		t.Errorf("block range query past limit accepted")
+	}
+}
+
+// Tests that the masternode registry reads as empty from chains which have not
+// deployed it, and that malformed masternode IDs are rejected.
+func TestMasternodeQueries(t *testing.T) {
+	backend := newTestBackend(t, 1, common.Address{0x01})
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend, filters.Config{})
+	if err != nil {
+		t.Fatalf("could not construct GraphQL handler: %v", err)
+	}
+	data, errs := query(t, handler, `{ masternodes { id } block { masternodes(online: true) { id } }
+		masternode(id: "0102030405060708") { id } }`)
+	if len(errs) != 0 {
+		t.Fatalf("masternode query failed: %v", errs)
+	}
+	have, _ := json.Marshal(data)
+	if want := `{"block":{"masternodes":[]},"masternode":null,"masternodes":[]}`; string(have) != want {
+		t.Errorf("masternode query mismatch:\nhave %s\nwant %s", have, want)
+	}
+	if _, errs := query(t, handler, `{ masternode(id: "0102") { id } }`); len(errs) == 0 {
+		t.Errorf("malformed masternode ID accepted")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/ether-ark/etherark/eth"
+	"github.com/ether-ark/etherark/eth/filters"
+	"github.com/ether-ark/etherark/internal/ethapi"
+	"github.com/ether-ark/etherark/les"
+	"github.com/ether-ark/etherark/log"
+	"github.com/ether-ark/etherark/node"
+	"github.com/ether-ark/etherark/p2p"
+	"github.com/ether-ark/etherark/rpc"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// maxRequestContentLength is the maximum size of a query the server accepts.
+const maxRequestContentLength = 1024 * 512
+
+// Service encapsulates a GraphQL service.
+type Service struct {
+	endpoint string           // The host:port endpoint for this service.
+	cors     []string         // Allowed CORS domains
+	vhosts   []string         // Recognised vhosts
+	timeouts rpc.HTTPTimeouts // Timeout settings for HTTP requests.
+	policy   rpc.Policy       // Access control and rate limit of the clients.
+	backend  ethapi.Backend   // The backend that queries will operate on.
+	handler  http.Handler     // The `http.Handler` used to answer queries.
+	listener net.Listener     // The listening socket.
+}
+
+// New constructs a new GraphQL service instance, restricting log queries to the
+// given limits and authenticating and rate limiting clients per the policy.
+func New(backend ethapi.Backend, endpoint string, cors, vhosts []string, timeouts rpc.HTTPTimeouts, logsLimits filters.Config, policy rpc.Policy) (*Service, error) {
+	handler, err := newHandler(backend, logsLimits)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		endpoint: endpoint,
+		cors:     cors,
+		vhosts:   vhosts,
+		timeouts: timeouts,
+		policy:   policy,
+		backend:  backend,
+		handler:  handler,
+	}, nil
+}
+
+// Protocols returns the list of protocols exported by this service.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs returns the list of APIs exported by this service.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start is called after all services have been constructed and the networking
+// layer was also initialized to spawn any goroutines required by the service.
+func (s *Service) Start(server *p2p.Server) error {
+	var err error
+	if s.listener, err = net.Listen("tcp", s.endpoint); err != nil {
+		return err
+	}
+	go rpc.NewHTTPServer(s.cors, s.vhosts, s.timeouts, rpc.NewPolicyHandler(s.policy, "graphql", s.handler)).Serve(s.listener)
+	log.Info("GraphQL endpoint opened", "url", fmt.Sprintf("http://%s", s.endpoint))
+	return nil
+}
+
+// Stop terminates all goroutines belonging to the service, blocking until they
+// are all terminated.
+func (s *Service) Stop() error {
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+		log.Info("GraphQL endpoint closed", "url", fmt.Sprintf("http://%s", s.endpoint))
+	}
+	return nil
+}
+
+// handler answers GraphQL queries posted as JSON documents.
+type handler struct {
+	schema *graphql.Schema
+}
+
+// newHandler returns a new `http.Handler` that will answer GraphQL queries
+// issued against the specified backend.
+func newHandler(backend ethapi.Backend, logsLimits filters.Config) (http.Handler, error) {
+	parsed, err := graphql.ParseSchema(schema, &Resolver{backend: backend, logsLimits: logsLimits})
+	if err != nil {
+		return nil, err
+	}
+	return &handler{schema: parsed}, nil
+}
+
+// ServeHTTP executes the query carried by the body of the request and writes
+// back its result.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var params struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestContentLength)).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response := h.schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables)
+	blob, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(blob)
+}
+
+// RegisterGraphQLService is a utility function to construct a new service and
+// register it against a node. The service is backed by the full node if one is
+// running, or by the light client otherwise, and enforces the RPC policy of the
+// node; credentials must permit the "graphql" namespace.
+func RegisterGraphQLService(stack *node.Node, endpoint string, cors, vhosts []string, timeouts rpc.HTTPTimeouts, logsLimits filters.Config) error {
+	return stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		policy, err := ctx.RPCPolicy()
+		if err != nil {
+			return nil, err
+		}
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err == nil {
+			return New(ethServ.APIBackend, endpoint, cors, vhosts, timeouts, logsLimits, policy)
+		}
+		var lesServ *les.LightEthereum
+		if err := ctx.Service(&lesServ); err != nil {
+			return nil, err
+		}
+		return New(lesServ.ApiBackend, endpoint, cors, vhosts, timeouts, logsLimits, policy)
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/rlp"
+	"github.com/golang/snappy"
+)
+
+// Builder writes an era file, one block at a time.
+type Builder struct {
+	w       io.Writer
+	written int64
+
+	start   uint64
+	offsets []int64
+	hashes  []common.Hash
+	tds     []*big.Int
+}
+
+// NewBuilder creates a builder writing an era file into w.
+func NewBuilder(w io.Writer) *Builder {
+	return &Builder{w: w}
+}
+
+// Add appends a block, along with its receipts and total difficulty. Blocks must
+// be added in order, starting at any block number.
+func (b *Builder) Add(block *types.Block, receipts types.Receipts, td *big.Int) error {
+	if len(b.offsets) == MaxEraSize {
+		return fmt.Errorf("era full, %d blocks", MaxEraSize)
+	}
+	if len(b.offsets) == 0 {
+		if err := b.writeRecord(typeVersion, nil); err != nil {
+			return err
+		}
+		b.start = block.NumberU64()
+	} else if want := b.start + uint64(len(b.offsets)); block.NumberU64() != want {
+		return fmt.Errorf("block number mismatch: have %d, want %d", block.NumberU64(), want)
+	}
+	if td.Sign() < 0 || td.BitLen() > 256 {
+		return fmt.Errorf("invalid total difficulty %v", td)
+	}
+	b.offsets = append(b.offsets, b.written)
+	b.hashes = append(b.hashes, block.Hash())
+	b.tds = append(b.tds, new(big.Int).Set(td))
+
+	storageReceipts := make([]*types.ReceiptForStorage, len(receipts))
+	for i, receipt := range receipts {
+		storageReceipts[i] = (*types.ReceiptForStorage)(receipt)
+	}
+	for _, record := range []struct {
+		typ uint16
+		val interface{}
+	}{
+		{typeHeader, block.Header()},
+		{typeBody, block.Body()},
+		{typeReceipts, storageReceipts},
+	} {
+		blob, err := rlp.EncodeToBytes(record.val)
+		if err != nil {
+			return err
+		}
+		if err := b.writeRecord(record.typ, snappy.Encode(nil, blob)); err != nil {
+			return err
+		}
+	}
+	return b.writeRecord(typeTotalDifficulty, common.LeftPadBytes(td.Bytes(), 32))
+}
+
+// Finalize writes the accumulator and the block index, completing the file. The
+// accumulator is returned.
+func (b *Builder) Finalize() (common.Hash, error) {
+	if len(b.offsets) == 0 {
+		return common.Hash{}, fmt.Errorf("empty era")
+	}
+	accumulator, err := ComputeAccumulator(b.hashes, b.tds)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := b.writeRecord(typeAccumulator, accumulator[:]); err != nil {
+		return common.Hash{}, err
+	}
+	index := make([]byte, 16+8*len(b.offsets))
+	binary.LittleEndian.PutUint64(index, b.start)
+	for i, offset := range b.offsets {
+		binary.LittleEndian.PutUint64(index[8+8*i:], uint64(offset))
+	}
+	binary.LittleEndian.PutUint64(index[len(index)-8:], uint64(len(b.offsets)))
+
+	if err := b.writeRecord(typeBlockIndex, index); err != nil {
+		return common.Hash{}, err
+	}
+	return accumulator, nil
+}
+
+func (b *Builder) writeRecord(typ uint16, data []byte) error {
+	var header [recordHeaderSize]byte
+	binary.LittleEndian.PutUint16(header[0:2], typ)
+	binary.LittleEndian.PutUint32(header[2:6], uint32(len(data)))
+
+	if _, err := b.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := b.w.Write(data); err != nil {
+		return err
+	}
+	b.written += recordHeaderSize + int64(len(data))
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumsFile is the name of the file listing the era files of an archive.
+const ChecksumsFile = "checksums.txt"
+
+// Checksum is an entry of the checksums file.
+type Checksum struct {
+	Sum  string // hex encoded SHA256 of the file
+	Name string // file name inside the archive directory
+}
+
+// FileChecksum returns the hex encoded SHA256 checksum of a file.
+func FileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// ReadChecksums loads the checksums file of an archive, listing the era files
+// in epoch order.
+func ReadChecksums(dir string) ([]Checksum, error) {
+	f, err := os.Open(filepath.Join(dir, ChecksumsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		checksums []Checksum
+		scanner   = bufio.NewScanner(f)
+	)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || len(fields[0]) != 2*sha256.Size || strings.ContainsAny(fields[1], `/\`) {
+			return nil, fmt.Errorf("%s:%d: invalid checksum entry", ChecksumsFile, line)
+		}
+		checksums = append(checksums, Checksum{Sum: fields[0], Name: fields[1]})
+	}
+	return checksums, scanner.Err()
+}
+
+// WriteChecksums atomically replaces the checksums file of an archive.
+func WriteChecksums(dir string, checksums []Checksum) error {
+	var buf bytes.Buffer
+	for _, checksum := range checksums {
+		fmt.Fprintf(&buf, "%s  %s\n", checksum.Sum, checksum.Name)
+	}
+	tmp := filepath.Join(dir, ChecksumsFile+".tmp")
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, ChecksumsFile))
+}
@@ -0,0 +1,347 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements the segmented chain history archive format.
+//
+// An archive is a directory of era files, each holding one epoch of MaxEraSize
+// consecutive blocks (the last one may be partial), and a checksums.txt file
+// listing the SHA256 checksum of every era file in the format of sha256sum.
+//
+// An era file is a sequence of records, each made of a 2 byte type, a 4 byte
+// data length and 2 reserved bytes, all little endian, followed by the data:
+//
+//	Version | (Header | Body | Receipts | TotalDifficulty)* | Accumulator | BlockIndex
+//
+// Headers, bodies and storage receipts are snappy compressed RLP, difficulties
+// are 32 byte big endian integers. The accumulator commits to the hashes and
+// total difficulties of all the blocks in the file. The block index holds the
+// first block number, the file offset of each block and the block count, so
+// that blocks can be read in any order.
+package era
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/rlp"
+	"github.com/golang/snappy"
+)
+
+// MaxEraSize is the number of blocks in an epoch.
+const MaxEraSize = 8192
+
+// Record types of the era files.
+const (
+	typeVersion         uint16 = 0x3265
+	typeHeader          uint16 = 0x03
+	typeBody            uint16 = 0x04
+	typeReceipts        uint16 = 0x05
+	typeTotalDifficulty uint16 = 0x06
+	typeAccumulator     uint16 = 0x07
+	typeBlockIndex      uint16 = 0x3266
+
+	recordHeaderSize = 8
+)
+
+var errCorrupted = errors.New("corrupted era file")
+
+// Filename returns the name of the era file of the given epoch, its accumulator
+// disambiguates partial epochs and files of different chains.
+func Filename(network string, epoch int, accumulator common.Hash) string {
+	return fmt.Sprintf("%s-%05d-%x.era", network, epoch, accumulator[:4])
+}
+
+// ComputeAccumulator returns the accumulator of a list of block hashes and total
+// difficulties.
+func ComputeAccumulator(hashes []common.Hash, tds []*big.Int) (common.Hash, error) {
+	if len(hashes) != len(tds) {
+		return common.Hash{}, fmt.Errorf("hash and difficulty count mismatch: %d != %d", len(hashes), len(tds))
+	}
+	entries := make([]accumulatorEntry, len(hashes))
+	for i := range hashes {
+		entries[i] = accumulatorEntry{hashes[i], tds[i]}
+	}
+	blob, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(blob), nil
+}
+
+type accumulatorEntry struct {
+	Hash common.Hash
+	TD   *big.Int
+}
+
+// Era is a read only era file.
+type Era struct {
+	f       *os.File
+	start   uint64  // number of the first block
+	offsets []int64 // offsets of the block records
+	index   int64   // offset of the block index record
+}
+
+// Open opens the era file at the given path, loading its block index.
+func Open(path string) (*Era, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	e, err := newEra(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return e, nil
+}
+
+func newEra(f *os.File) (*Era, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// The block count closes the file, the index record is sized after it
+	size := stat.Size()
+	if size < recordHeaderSize+16 {
+		return nil, errCorrupted
+	}
+	if typ, _, err := readRecordAt(f, 0); err != nil || typ != typeVersion {
+		return nil, errors.New("not an era file")
+	}
+	var buf [8]byte
+	if _, err := f.ReadAt(buf[:], size-8); err != nil {
+		return nil, err
+	}
+	count := binary.LittleEndian.Uint64(buf[:])
+	if count == 0 || count > MaxEraSize {
+		return nil, errCorrupted
+	}
+	index := size - recordHeaderSize - 16 - 8*int64(count)
+	typ, data, err := readRecordAt(f, index)
+	if err != nil {
+		return nil, err
+	}
+	if typ != typeBlockIndex || len(data) != 16+8*int(count) {
+		return nil, errCorrupted
+	}
+	e := &Era{
+		f:       f,
+		start:   binary.LittleEndian.Uint64(data),
+		offsets: make([]int64, count),
+		index:   index,
+	}
+	for i := range e.offsets {
+		e.offsets[i] = int64(binary.LittleEndian.Uint64(data[8+8*i:]))
+		if e.offsets[i] <= 0 || e.offsets[i] >= index {
+			return nil, errCorrupted
+		}
+	}
+	return e, nil
+}
+
+// Start returns the number of the first block in the file.
+func (e *Era) Start() uint64 {
+	return e.start
+}
+
+// Count returns the number of blocks in the file.
+func (e *Era) Count() uint64 {
+	return uint64(len(e.offsets))
+}
+
+// Close closes the era file.
+func (e *Era) Close() error {
+	return e.f.Close()
+}
+
+// Accumulator returns the accumulator stored in the file.
+func (e *Era) Accumulator() (common.Hash, error) {
+	typ, data, err := readRecordAt(e.f, e.index-recordHeaderSize-common.HashLength)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if typ != typeAccumulator || len(data) != common.HashLength {
+		return common.Hash{}, errCorrupted
+	}
+	return common.BytesToHash(data), nil
+}
+
+// GetHeaderByNumber returns the header of the given block.
+func (e *Era) GetHeaderByNumber(number uint64) (*types.Header, error) {
+	if number < e.start || number-e.start >= e.Count() {
+		return nil, fmt.Errorf("block #%d out of range [%d, %d)", number, e.start, e.start+e.Count())
+	}
+	r := &recordReader{r: e.f, offset: e.offsets[number-e.start]}
+
+	header := new(types.Header)
+	if err := r.decode(typeHeader, header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// GetBlockByNumber returns the given block, its receipts and total difficulty.
+// The body and receipts are checked against the roots of the header.
+func (e *Era) GetBlockByNumber(number uint64) (*types.Block, types.Receipts, *big.Int, error) {
+	if number < e.start || number-e.start >= e.Count() {
+		return nil, nil, nil, fmt.Errorf("block #%d out of range [%d, %d)", number, e.start, e.start+e.Count())
+	}
+	r := &recordReader{r: e.f, offset: e.offsets[number-e.start]}
+	return r.readBlock()
+}
+
+// Iterator returns an iterator over all the blocks of the file, in order.
+func (e *Era) Iterator() *Iterator {
+	return &Iterator{
+		era:    e,
+		reader: &recordReader{r: e.f, offset: e.offsets[0]},
+		next:   e.start,
+	}
+}
+
+// Iterator reads the blocks of an era file sequentially, checking their bodies
+// and receipts against the roots of their headers.
+type Iterator struct {
+	era    *Era
+	reader *recordReader
+	next   uint64
+
+	Block    *types.Block
+	Receipts types.Receipts
+	TD       *big.Int
+	Err      error
+}
+
+// Next loads the next block, returning false at the end of the file or upon an
+// error, which is then set in Err.
+func (it *Iterator) Next() bool {
+	if it.Err != nil || it.next >= it.era.start+it.era.Count() {
+		return false
+	}
+	if it.reader.offset != it.era.offsets[it.next-it.era.start] {
+		it.Err = fmt.Errorf("block #%d: %v", it.next, errCorrupted)
+		return false
+	}
+	it.Block, it.Receipts, it.TD, it.Err = it.reader.readBlock()
+	if it.Err != nil {
+		return false
+	}
+	if it.Block.NumberU64() != it.next {
+		it.Err = fmt.Errorf("block number mismatch: have %d, want %d", it.Block.NumberU64(), it.next)
+		return false
+	}
+	it.next++
+	return true
+}
+
+// recordReader reads consecutive records starting at an offset.
+type recordReader struct {
+	r      io.ReaderAt
+	offset int64
+}
+
+func (r *recordReader) read(want uint16) ([]byte, error) {
+	typ, data, err := readRecordAt(r.r, r.offset)
+	if err != nil {
+		return nil, err
+	}
+	if typ != want {
+		return nil, fmt.Errorf("unexpected record type %#x at offset %d, want %#x", typ, r.offset, want)
+	}
+	r.offset += recordHeaderSize + int64(len(data))
+	return data, nil
+}
+
+func (r *recordReader) decode(want uint16, val interface{}) error {
+	data, err := r.read(want)
+	if err != nil {
+		return err
+	}
+	blob, err := snappy.Decode(nil, data)
+	if err != nil {
+		return err
+	}
+	return rlp.DecodeBytes(blob, val)
+}
+
+func (r *recordReader) readBlock() (*types.Block, types.Receipts, *big.Int, error) {
+	var (
+		header   = new(types.Header)
+		body     = new(types.Body)
+		receipts []*types.ReceiptForStorage
+	)
+	if err := r.decode(typeHeader, header); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := r.decode(typeBody, body); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := r.decode(typeReceipts, &receipts); err != nil {
+		return nil, nil, nil, err
+	}
+	data, err := r.read(typeTotalDifficulty)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(data) != 32 {
+		return nil, nil, nil, errCorrupted
+	}
+	block := types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
+
+	converted := make(types.Receipts, len(receipts))
+	for i, receipt := range receipts {
+		converted[i] = (*types.Receipt)(receipt)
+	}
+	// Make sure the body and the receipts belong to the header
+	if hash := types.DeriveSha(types.Transactions(body.Transactions)); hash != header.TxHash {
+		return nil, nil, nil, fmt.Errorf("block #%d: transaction root mismatch: have %x, want %x", header.Number, hash, header.TxHash)
+	}
+	if hash := types.CalcUncleHash(body.Uncles); hash != header.UncleHash {
+		return nil, nil, nil, fmt.Errorf("block #%d: uncle hash mismatch: have %x, want %x", header.Number, hash, header.UncleHash)
+	}
+	if hash := types.DeriveSha(converted); hash != header.ReceiptHash {
+		return nil, nil, nil, fmt.Errorf("block #%d: receipt root mismatch: have %x, want %x", header.Number, hash, header.ReceiptHash)
+	}
+	return block, converted, new(big.Int).SetBytes(data), nil
+}
+
+// readRecordAt reads the record at the given offset.
+func readRecordAt(r io.ReaderAt, offset int64) (uint16, []byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return 0, nil, err
+	}
+	var (
+		typ    = binary.LittleEndian.Uint16(header[0:2])
+		length = binary.LittleEndian.Uint32(header[2:6])
+	)
+	if !bytes.Equal(header[6:8], []byte{0, 0}) {
+		return 0, nil, errCorrupted
+	}
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset+recordHeaderSize); err != nil {
+		return 0, nil, err
+	}
+	return typ, data, nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/types"
+)
+
+// makeBlocks creates a linked chain of blocks starting at the given number, each
+// with a single receipt.
+func makeBlocks(start uint64, n int) ([]*types.Block, []types.Receipts, []*big.Int) {
+	var (
+		blocks   []*types.Block
+		receipts []types.Receipts
+		tds      []*big.Int
+		parent   common.Hash
+	)
+	for i := 0; i < n; i++ {
+		receipt := types.NewReceipt(nil, false, uint64(21000*(i+1)))
+		receipt.Logs = []*types.Log{}
+		receipt.TxHash = common.Hash{byte(i)}
+
+		header := &types.Header{
+			ParentHash:  parent,
+			Number:      new(big.Int).SetUint64(start + uint64(i)),
+			Difficulty:  big.NewInt(1),
+			Extra:       []byte{byte(i)},
+			ReceiptHash: types.DeriveSha(types.Receipts{receipt}),
+		}
+		block := types.NewBlock(header, nil, nil, []*types.Receipt{receipt})
+		parent = block.Hash()
+
+		blocks = append(blocks, block)
+		receipts = append(receipts, types.Receipts{receipt})
+		tds = append(tds, new(big.Int).SetUint64(start+uint64(i)+1))
+	}
+	return blocks, receipts, tds
+}
+
+// writeEra builds an era file of the given blocks.
+func writeEra(t *testing.T, path string, blocks []*types.Block, receipts []types.Receipts, tds []*big.Int) common.Hash {
+	var buf bytes.Buffer
+	builder := NewBuilder(&buf)
+	for i := range blocks {
+		if err := builder.Add(blocks[i], receipts[i], tds[i]); err != nil {
+			t.Fatalf("failed to add block %d: %v", i, err)
+		}
+	}
+	accumulator, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("failed to finalize era: %v", err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write era: %v", err)
+	}
+	return accumulator
+}
+
+// Tests that an era file can be read back both sequentially and randomly.
+func TestEraRoundtrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "era-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocks, receipts, tds := makeBlocks(100, 16)
+	path := filepath.Join(dir, "test.era")
+	accumulator := writeEra(t, path, blocks, receipts, tds)
+
+	e, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open era: %v", err)
+	}
+	defer e.Close()
+
+	if e.Start() != 100 || e.Count() != 16 {
+		t.Fatalf("range mismatch: have [%d, +%d), want [100, +16)", e.Start(), e.Count())
+	}
+	if have, err := e.Accumulator(); err != nil || have != accumulator {
+		t.Fatalf("accumulator mismatch: have %x, %v, want %x", have, err, accumulator)
+	}
+	var hashes []common.Hash
+	for _, block := range blocks {
+		hashes = append(hashes, block.Hash())
+	}
+	if want, _ := ComputeAccumulator(hashes, tds); want != accumulator {
+		t.Fatalf("recomputed accumulator mismatch: have %x, want %x", want, accumulator)
+	}
+	it, i := e.Iterator(), 0
+	for ; it.Next(); i++ {
+		if it.Block.Hash() != blocks[i].Hash() {
+			t.Fatalf("block %d: hash mismatch: have %x, want %x", i, it.Block.Hash(), blocks[i].Hash())
+		}
+		if it.TD.Cmp(tds[i]) != 0 {
+			t.Fatalf("block %d: td mismatch: have %v, want %v", i, it.TD, tds[i])
+		}
+		if len(it.Receipts) != 1 || it.Receipts[0].TxHash != receipts[i][0].TxHash || it.Receipts[0].CumulativeGasUsed != receipts[i][0].CumulativeGasUsed {
+			t.Fatalf("block %d: receipts mismatch", i)
+		}
+	}
+	if it.Err != nil || i != len(blocks) {
+		t.Fatalf("iteration stopped at %d: %v", i, it.Err)
+	}
+	block, _, td, err := e.GetBlockByNumber(107)
+	if err != nil || block.Hash() != blocks[7].Hash() || td.Cmp(tds[7]) != 0 {
+		t.Fatalf("random access mismatch: %v", err)
+	}
+	if _, _, _, err := e.GetBlockByNumber(116); err == nil {
+		t.Fatalf("out of range block returned")
+	}
+}
+
+// Tests that receipts not matching their header are rejected.
+func TestEraReceiptMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "era-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocks, receipts, tds := makeBlocks(0, 4)
+	receipts[2], receipts[3] = receipts[3], receipts[2]
+
+	path := filepath.Join(dir, "test.era")
+	writeEra(t, path, blocks, receipts, tds)
+
+	e, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open era: %v", err)
+	}
+	defer e.Close()
+
+	it := e.Iterator()
+	for it.Next() {
+	}
+	if it.Err == nil || it.next != 2 {
+		t.Fatalf("mismatching receipts not detected, stopped at %d: %v", it.next, it.Err)
+	}
+}
+
+// Tests that the checksums file roundtrips and that garbage is rejected.
+func TestChecksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "era-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "a.era"), []byte("hello"), 0644)
+	sum, err := FileChecksum(filepath.Join(dir, "a.era"))
+	if err != nil {
+		t.Fatalf("failed to checksum file: %v", err)
+	}
+	if sum != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatalf("checksum mismatch: %s", sum)
+	}
+	want := []Checksum{{sum, "a.era"}, {sum, "b.era"}}
+	if err := WriteChecksums(dir, want); err != nil {
+		t.Fatalf("failed to write checksums: %v", err)
+	}
+	have, err := ReadChecksums(dir)
+	if err != nil || !reflect.DeepEqual(have, want) {
+		t.Fatalf("checksums mismatch: have %v, %v, want %v", have, err, want)
+	}
+	ioutil.WriteFile(filepath.Join(dir, ChecksumsFile), []byte(sum+"  ../a.era\n"), 0644)
+	if _, err := ReadChecksums(dir); err == nil {
+		t.Fatalf("path outside archive accepted")
+	}
+}
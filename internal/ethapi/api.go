@@ -34,6 +34,7 @@ import (
 	"github.com/ether-ark/etherark/consensus/ethash"
 	"github.com/ether-ark/etherark/core"
 	"github.com/ether-ark/etherark/core/rawdb"
+	"github.com/ether-ark/etherark/core/state"
 	"github.com/ether-ark/etherark/core/types"
 	"github.com/ether-ark/etherark/core/vm"
 	"github.com/ether-ark/etherark/crypto"
@@ -42,8 +43,6 @@ import (
 	"github.com/ether-ark/etherark/params"
 	"github.com/ether-ark/etherark/rlp"
 	"github.com/ether-ark/etherark/rpc"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 const (
@@ -153,7 +152,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -161,7 +160,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -444,7 +443,7 @@ func (s *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs
 		log.Warn("Failed transaction send attempt", "from", args.From, "to", args.To, "value", args.Value.ToInt(), "err", err)
 		return common.Hash{}, err
 	}
-	return submitTransaction(ctx, s.b, signed)
+	return SubmitTransaction(ctx, s.b, signed)
 }
 
 // SignTransaction will create a transaction from the given arguments and
@@ -468,7 +467,7 @@ func (s *PrivateAccountAPI) SignTransaction(ctx context.Context, args SendTxArgs
 		log.Warn("Failed transaction sign attempt", "from", args.From, "to", args.To, "value", args.Value.ToInt(), "err", err)
 		return nil, err
 	}
-	data, err := rlp.EncodeToBytes(signed)
+	data, err := signed.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -556,6 +555,77 @@ func (s *PublicBlockChainAPI) GetPower(ctx context.Context, address common.Addre
 	return b, state.Error()
 }
 
+// maxPowerHistoryRange is the maximum number of blocks a single
+// eth_getPowerHistory request is allowed to scan.
+const maxPowerHistoryRange = 10000
+
+// PowerHistoryEntry is the power accounting of a single transaction as returned
+// by eth_getPowerHistory.
+type PowerHistoryEntry struct {
+	BlockNumber      hexutil.Uint64    `json:"blockNumber"`
+	BlockHash        common.Hash       `json:"blockHash"`
+	TransactionHash  common.Hash       `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64    `json:"transactionIndex"`
+	Power            *types.PowerUsage `json:"power"`
+}
+
+// GetPowerHistory returns the power charged and refunded for every transaction
+// sent by address in the canonical blocks between fromBlock and toBlock (both
+// inclusive). It fails for ranges reaching below the blocks fast synced.
+func (s *PublicBlockChainAPI) GetPowerHistory(ctx context.Context, address common.Address, fromBlock, toBlock rpc.BlockNumber) ([]*PowerHistoryEntry, error) {
+	from, err := s.resolveBlockNumber(ctx, fromBlock)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.resolveBlockNumber(ctx, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	if to-from >= maxPowerHistoryRange {
+		return nil, fmt.Errorf("block range %d-%d exceeds the limit of %d blocks", from, to, maxPowerHistoryRange)
+	}
+	// Fast synced blocks have no power accounting to return
+	if tail := rawdb.ReadPowerHistoryTail(s.b.ChainDb()); from < tail {
+		return nil, fmt.Errorf("power history unavailable below block %d", tail)
+	}
+	history := []*PowerHistoryEntry{}
+	for number := from; number <= to; number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hash := rawdb.ReadCanonicalHash(s.b.ChainDb(), number)
+		if hash == (common.Hash{}) {
+			break
+		}
+		for _, entry := range rawdb.ReadPowerHistory(s.b.ChainDb(), address, hash, number) {
+			history = append(history, &PowerHistoryEntry{
+				BlockNumber:      hexutil.Uint64(number),
+				BlockHash:        hash,
+				TransactionHash:  entry.TxHash,
+				TransactionIndex: hexutil.Uint64(entry.TxIndex),
+				Power:            entry.Usage,
+			})
+		}
+	}
+	return history, nil
+}
+
+// resolveBlockNumber maps the special latest and pending block numbers onto the
+// number of the block they currently refer to.
+func (s *PublicBlockChainAPI) resolveBlockNumber(ctx context.Context, number rpc.BlockNumber) (uint64, error) {
+	if number >= 0 {
+		return uint64(number), nil
+	}
+	header, err := s.b.HeaderByNumber(ctx, number)
+	if header == nil || err != nil {
+		return 0, fmt.Errorf("block %d not found", number)
+	}
+	return header.Number.Uint64(), nil
+}
+
 // PublicBlockChainAPI provides an API to access the Ethereum blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
@@ -757,35 +827,66 @@ type CallArgs struct {
 	GasPrice hexutil.Big     `json:"gasPrice"`
 	Value    hexutil.Big     `json:"value"`
 	Data     hexutil.Bytes   `json:"data"`
+
+	AccessList *types.AccessList `json:"accessList"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
-	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+// OverrideAccount indicates the overriding fields of an account during the
+// execution of a message call. Unset fields keep their state value.
+type OverrideAccount struct {
+	Nonce     *hexutil.Uint64             `json:"nonce"`
+	Code      *hexutil.Bytes              `json:"code"`
+	Balance   *hexutil.Big                `json:"balance"`
+	Power     *hexutil.Big                `json:"power"`
+	StateDiff map[common.Hash]common.Hash `json:"stateDiff"`
+}
 
-	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
-	if state == nil || err != nil {
-		return nil, 0, false, err
+// StateOverride is the collection of overridden accounts.
+type StateOverride map[common.Address]OverrideAccount
+
+// Apply overrides the fields of the specified accounts in the given state. The
+// power is set as settled at blockNumber, the block the call is executed in.
+func (diff *StateOverride) Apply(statedb *state.StateDB, blockNumber *big.Int) error {
+	if diff == nil {
+		return nil
 	}
-	// Set sender address or use a default if none specified
-	addr := args.From
-	if addr == (common.Address{}) {
-		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
-			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
-				addr = accounts[0].Address
-			}
+	for addr, account := range *diff {
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, uint64(*account.Nonce))
+		}
+		if account.Code != nil {
+			statedb.SetCode(addr, *account.Code)
+		}
+		if account.Balance != nil {
+			statedb.SetBalance(addr, (*big.Int)(account.Balance), blockNumber)
+		}
+		// Power goes after the balance, setting the balance settles the power.
+		if account.Power != nil {
+			statedb.SetPowerAndBlock(addr, (*big.Int)(account.Power), blockNumber)
+		}
+		for key, value := range account.StateDiff {
+			statedb.SetState(addr, key, value)
 		}
 	}
-	// Set default gas & gas price if none were set
-	gas, gasPrice := uint64(args.Gas), args.GasPrice.ToInt()
-	if gas == 0 {
-		gas = math.MaxUint64 / 2
+	return statedb.Error()
+}
+
+// DoCall executes the given call on the state of the given block number, with
+// the given accounts overridden.
+func DoCall(ctx context.Context, b Backend, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
+	state, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, 0, false, err
 	}
-	if gasPrice.Sign() == 0 {
-		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
+	state = state.Copy()
+	if err := overrides.Apply(state, header.Number); err != nil {
+		return nil, 0, false, err
 	}
+	return doCall(ctx, b, args.ToMessage(b), state, header, vmCfg, timeout)
+}
 
-	// Create new call message
-	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
+func doCall(ctx context.Context, b Backend, msg types.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
@@ -800,7 +901,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	defer cancel()
 
 	// Get a new instance of the EVM.
-	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header)
+	evm, vmError, err := b.GetEVM(ctx, msg, state, header)
 	if err != nil {
 		return nil, 0, false, err
 	}
@@ -821,31 +922,89 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	return res, gas, failed, err
 }
 
+// ToMessage converts the call arguments into a message, defaulting the sender
+// to the first local account, and the gas and gas price if none were set.
+func (args *CallArgs) ToMessage(b Backend) types.Message {
+	// Set sender address or use a default if none specified
+	addr := callSender(b, *args)
+
+	// Set default gas & gas price if none were set
+	gas, gasPrice := uint64(args.Gas), args.GasPrice.ToInt()
+	if gas == 0 {
+		gas = math.MaxUint64 / 2
+	}
+	if gasPrice.Sign() == 0 {
+		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
+	}
+
+	var accessList types.AccessList
+	if args.AccessList != nil {
+		accessList = *args.AccessList
+	}
+	return types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, accessList, false)
+}
+
+// TopUpPower raises the power of the message's sender at the given block to the
+// cost of the message's gas, if lower. Gas on Circum chains is paid from the
+// sender's power, so this lets any message be executed at its own gas price
+// regardless of whether its sender could afford it. Only accounts holding at
+// least one coin have power, so poorer senders are lifted to that balance too.
+// The state must be a throwaway copy.
+func TopUpPower(statedb *state.StateDB, msg types.Message, number *big.Int) {
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(msg.Gas()), msg.GasPrice())
+	if statedb.GetPower(msg.From(), number).Cmp(cost) >= 0 {
+		return
+	}
+	if minimum := big.NewInt(params.Ether); statedb.GetBalance(msg.From()).Cmp(minimum) < 0 {
+		statedb.SetBalance(msg.From(), minimum, number)
+	}
+	statedb.SetPowerAndBlock(msg.From(), cost, number)
+}
+
+// callSender returns the sender of a call, defaulting to the first local
+// account if none was specified.
+func callSender(b Backend, args CallArgs) common.Address {
+	addr := args.From
+	if addr == (common.Address{}) {
+		if wallets := b.AccountManager().Wallets(); len(wallets) > 0 {
+			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
+				addr = accounts[0].Address
+			}
+		}
+	}
+	return addr
+}
+
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
+// Accounts listed in overrides are modified on a copy of the state beforehand.
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (hexutil.Bytes, error) {
+	result, _, _, err := DoCall(ctx, s.b, args, blockNr, overrides, vm.Config{}, 5*time.Second)
 	return (hexutil.Bytes)(result), err
 }
 
-// EstimateGas returns an estimate of the amount of gas needed to execute the
-// given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (hexutil.Uint64, error) {
+// DoEstimateGas binary searches the gas needed to execute the given call on the
+// state of the given block number, with the given accounts overridden.
+//
+// Gas on Circum chains is paid from the sender's power. The search tops the
+// sender's power up on a copy of the state, so the estimate reflects the needs
+// of the execution only; whether the sender can afford it is up to the caller.
+func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
 		lo  uint64 = params.TxGas - 1
 		hi  uint64
 		cap uint64
 	)
+	state, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return 0, err
+	}
 	if uint64(args.Gas) >= params.TxGas {
 		hi = uint64(args.Gas)
 	} else {
-		// Retrieve the current pending block to act as the gas ceiling
-		block, err := s.b.BlockByNumber(ctx, rpc.PendingBlockNumber)
-		if err != nil {
-			return 0, err
-		}
-		hi = block.GasLimit()
+		// Use the gas limit of the requested block as the gas ceiling
+		hi = header.GasLimit
 	}
 	cap = hi
 
@@ -853,7 +1012,13 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	executable := func(gas uint64) bool {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0)
+		statedb := state.Copy()
+		if err := overrides.Apply(statedb, header.Number); err != nil {
+			return false
+		}
+		msg := args.ToMessage(b)
+		TopUpPower(statedb, msg, header.Number)
+		_, _, failed, err := doCall(ctx, b, msg, statedb, header, vm.Config{}, 0)
 		if err != nil || failed {
 			return false
 		}
@@ -877,6 +1042,77 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	return hexutil.Uint64(hi), nil
 }
 
+// EstimateGas returns an estimate of the amount of gas needed to execute the
+// given transaction against the given block, the pending one by default, with
+// the given accounts overridden.
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, blockNr *rpc.BlockNumber, overrides *StateOverride) (hexutil.Uint64, error) {
+	number := rpc.PendingBlockNumber
+	if blockNr != nil {
+		number = *blockNr
+	}
+	return DoEstimateGas(ctx, s.b, args, number, overrides)
+}
+
+// FeeEstimate is the gas and power recommendation for a transaction. The gas
+// of a transaction is paid from the sender's power, which regenerates over
+// time up to MaxPower depending on the sender's balance.
+type FeeEstimate struct {
+	Gas                 hexutil.Uint64 `json:"gas"`
+	GasPrice            *hexutil.Big   `json:"gasPrice"`            // price the power cost is computed with
+	PowerCost           *hexutil.Big   `json:"powerCost"`           // power drawn when buying the gas
+	Power               *hexutil.Big   `json:"power"`               // power of the sender at the estimated block
+	MaxPower            *hexutil.Big   `json:"maxPower"`            // most power the sender can accumulate
+	Affordable          bool           `json:"affordable"`          // whether the sender can pay powerCost right now
+	RecommendedGasPrice *hexutil.Big   `json:"recommendedGasPrice"` // suggested price, capped to what MaxPower can cover
+}
+
+// EstimateFee estimates the gas of the given transaction against the given
+// block, the pending one by default, and combines it with the sender's power
+// budget. The power cost is computed with the price of the transaction if set
+// and with the recommended price otherwise. Overridden accounts are taken into
+// account for both the gas and the power budget.
+func (s *PublicBlockChainAPI) EstimateFee(ctx context.Context, args CallArgs, blockNr *rpc.BlockNumber, overrides *StateOverride) (*FeeEstimate, error) {
+	number := rpc.PendingBlockNumber
+	if blockNr != nil {
+		number = *blockNr
+	}
+	gas, err := DoEstimateGas(ctx, s.b, args, number, overrides)
+	if err != nil {
+		return nil, err
+	}
+	statedb, header, err := s.b.StateAndHeaderByNumber(ctx, number)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	statedb = statedb.Copy()
+	if err := overrides.Apply(statedb, header.Number); err != nil {
+		return nil, err
+	}
+	from := callSender(s.b, args)
+	power := statedb.GetPower(from, header.Number)
+	maxPower := state.MaxPower(statedb.GetBalance(from))
+
+	recommended, err := s.b.SuggestPowerPrice(ctx, uint64(gas), maxPower)
+	if err != nil {
+		return nil, err
+	}
+	price := args.GasPrice.ToInt()
+	if price.Sign() == 0 {
+		price = recommended
+	}
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(uint64(gas)), price)
+
+	return &FeeEstimate{
+		Gas:                 gas,
+		GasPrice:            (*hexutil.Big)(price),
+		PowerCost:           (*hexutil.Big)(cost),
+		Power:               (*hexutil.Big)(power),
+		MaxPower:            (*hexutil.Big)(maxPower),
+		Affordable:          power.Cmp(cost) >= 0,
+		RecommendedGasPrice: (*hexutil.Big)(recommended),
+	}, statedb.Error()
+}
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
 // execution status, the amount of gas used and the return value
@@ -1024,20 +1260,19 @@ type RPCTransaction struct {
 	V                *hexutil.Big    `json:"v"`
 	R                *hexutil.Big    `json:"r"`
 	S                *hexutil.Big    `json:"s"`
+
+	Type       hexutil.Uint64    `json:"type"`
+	ChainID    *hexutil.Big      `json:"chainId,omitempty"`
+	AccessList *types.AccessList `json:"accessList,omitempty"`
 }
 
-// newRPCTransaction returns a transaction that will serialize to the RPC
+// NewRPCTransaction returns a transaction that will serialize to the RPC
 // representation, with the given location metadata set (if available).
-func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64) *RPCTransaction {
-	var signer types.Signer = types.FrontierSigner{}
-	if tx.Protected() {
-		signer = types.NewEIP155Signer(tx.ChainId())
-	}
-	from, _ := types.Sender(signer, tx)
+func NewRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64) *RPCTransaction {
 	v, r, s := tx.RawSignatureValues()
 
 	result := &RPCTransaction{
-		From:     from,
+		From:     TransactionSender(tx),
 		Gas:      hexutil.Uint64(tx.Gas()),
 		GasPrice: (*hexutil.Big)(tx.GasPrice()),
 		Hash:     tx.Hash(),
@@ -1048,6 +1283,12 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		V:        (*hexutil.Big)(v),
 		R:        (*hexutil.Big)(r),
 		S:        (*hexutil.Big)(s),
+		Type:     hexutil.Uint64(tx.Type()),
+	}
+	if tx.Type() != types.LegacyTxType {
+		al := tx.AccessList()
+		result.ChainID = (*hexutil.Big)(tx.ChainId())
+		result.AccessList = &al
 	}
 	if blockHash != (common.Hash{}) {
 		result.BlockHash = blockHash
@@ -1057,9 +1298,20 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 	return result
 }
 
-// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
-	return newRPCTransaction(tx, common.Hash{}, 0, 0)
+// TransactionSender returns the address a transaction was signed by, deriving the
+// signer from the transaction itself.
+func TransactionSender(tx *types.Transaction) common.Address {
+	var signer types.Signer = types.FrontierSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP2930Signer(tx.ChainId())
+	}
+	from, _ := types.Sender(signer, tx)
+	return from
+}
+
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
+	return NewRPCTransaction(tx, common.Hash{}, 0, 0)
 }
 
 // newRPCTransactionFromBlockIndex returns a transaction that will serialize to the RPC representation.
@@ -1068,7 +1320,7 @@ func newRPCTransactionFromBlockIndex(b *types.Block, index uint64) *RPCTransacti
 	if index >= uint64(len(txs)) {
 		return nil
 	}
-	return newRPCTransaction(txs[index], b.Hash(), b.NumberU64(), index)
+	return NewRPCTransaction(txs[index], b.Hash(), b.NumberU64(), index)
 }
 
 // newRPCRawTransactionFromBlockIndex returns the bytes of a transaction given a block and a transaction index.
@@ -1077,7 +1329,7 @@ func newRPCRawTransactionFromBlockIndex(b *types.Block, index uint64) hexutil.By
 	if index >= uint64(len(txs)) {
 		return nil
 	}
-	blob, _ := rlp.EncodeToBytes(txs[index])
+	blob, _ := txs[index].MarshalBinary()
 	return blob
 }
 
@@ -1166,11 +1418,11 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		return NewRPCTransaction(tx, blockHash, blockNumber, index)
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return NewRPCPendingTransaction(tx)
 	}
 	// Transaction unknown, return as such
 	return nil
@@ -1188,7 +1440,7 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 		}
 	}
 	// Serialize to RLP and return
-	return rlp.EncodeToBytes(tx)
+	return tx.MarshalBinary()
 }
 
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
@@ -1204,20 +1456,18 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if len(receipts) <= int(index) {
 		return nil, nil
 	}
-	receipt := receipts[index]
-
-	var signer types.Signer = types.FrontierSigner{}
-	if tx.Protected() {
-		signer = types.NewEIP155Signer(tx.ChainId())
-	}
-	from, _ := types.Sender(signer, tx)
+	return RPCMarshalReceipt(receipts[index], tx, blockHash, blockNumber, index), nil
+}
 
+// RPCMarshalReceipt converts the receipt of a transaction to the RPC output,
+// along with the location of the transaction in the chain.
+func RPCMarshalReceipt(receipt *types.Receipt, tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64) map[string]interface{} {
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
+		"transactionHash":   tx.Hash(),
 		"transactionIndex":  hexutil.Uint64(index),
-		"from":              from,
+		"from":              TransactionSender(tx),
 		"to":                tx.To(),
 		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
 		"cumulativeGasUsed": hexutil.Uint64(receipt.CumulativeGasUsed),
@@ -1225,6 +1475,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 		"logs":              receipt.Logs,
 		"logsBloom":         receipt.Bloom,
 		"intxs":             receipt.Intxs,
+		"type":              hexutil.Uint(tx.Type()),
 	}
 
 	// Assign receipt status or post state.
@@ -1239,11 +1490,14 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.Intxs == nil {
 		fields["intxs"] = [][]*types.Intx{}
 	}
+	if receipt.Power != nil {
+		fields["power"] = receipt.Power
+	}
 	// If the ContractAddress is 20 0x0 bytes, assume it is not a contract creation
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
@@ -1275,6 +1529,11 @@ type SendTxArgs struct {
 	// newer name and should be preferred by clients.
 	Data  *hexutil.Bytes `json:"data"`
 	Input *hexutil.Bytes `json:"input"`
+
+	// Setting an access list turns the transaction into a typed access list
+	// transaction, bound to the given chain (the node's chain by default).
+	AccessList *types.AccessList `json:"accessList"`
+	ChainID    *hexutil.Big      `json:"chainId"`
 }
 
 // setDefaults is a helper function that fills in default values for unspecified tx fields.
@@ -1300,6 +1559,14 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 		}
 		args.Nonce = (*hexutil.Uint64)(&nonce)
 	}
+	if args.AccessList != nil {
+		want := b.ChainConfig().ChainID
+		if args.ChainID == nil {
+			args.ChainID = (*hexutil.Big)(want)
+		} else if args.ChainID.ToInt().Cmp(want) != 0 {
+			return fmt.Errorf("chainId does not match node's (have=%v, want=%v)", args.ChainID.ToInt(), want)
+		}
+	}
 	if args.Data != nil && args.Input != nil && !bytes.Equal(*args.Data, *args.Input) {
 		return errors.New(`Both "data" and "input" are set and not equal. Please use "input" to pass transaction call data.`)
 	}
@@ -1325,14 +1592,17 @@ func (args *SendTxArgs) toTransaction() *types.Transaction {
 	} else if args.Input != nil {
 		input = *args.Input
 	}
+	if args.AccessList != nil {
+		return types.NewAccessListTransaction((*big.Int)(args.ChainID), uint64(*args.Nonce), args.To, (*big.Int)(args.Value), uint64(*args.Gas), (*big.Int)(args.GasPrice), input, *args.AccessList)
+	}
 	if args.To == nil {
 		return types.NewContractCreation(uint64(*args.Nonce), (*big.Int)(args.Value), uint64(*args.Gas), (*big.Int)(args.GasPrice), input)
 	}
 	return types.NewTransaction(uint64(*args.Nonce), *args.To, (*big.Int)(args.Value), uint64(*args.Gas), (*big.Int)(args.GasPrice), input)
 }
 
-// submitTransaction is a helper function that submits tx to txPool and logs a message.
-func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (common.Hash, error) {
+// SubmitTransaction is a helper function that submits tx to txPool and logs a message.
+func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (common.Hash, error) {
 	if err := b.SendTx(ctx, tx); err != nil {
 		return common.Hash{}, err
 	}
@@ -1384,17 +1654,17 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	if err != nil {
 		return common.Hash{}, err
 	}
-	return submitTransaction(ctx, s.b, signed)
+	return SubmitTransaction(ctx, s.b, signed)
 }
 
 // SendRawTransaction will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
 	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+	if err := tx.UnmarshalBinary(encodedTx); err != nil {
 		return common.Hash{}, err
 	}
-	return submitTransaction(ctx, s.b, tx)
+	return SubmitTransaction(ctx, s.b, tx)
 }
 
 // Sign calculates an ECDSA signature for:
@@ -1448,7 +1718,7 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 	if err != nil {
 		return nil, err
 	}
-	data, err := rlp.EncodeToBytes(tx)
+	data, err := tx.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -1472,11 +1742,11 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, err
 	for _, tx := range pending {
 		var signer types.Signer = types.HomesteadSigner{}
 		if tx.Protected() {
-			signer = types.NewEIP155Signer(tx.ChainId())
+			signer = types.NewEIP2930Signer(tx.ChainId())
 		}
 		from, _ := types.Sender(signer, tx)
 		if _, exists := accounts[from]; exists {
-			transactions = append(transactions, newRPCPendingTransaction(tx))
+			transactions = append(transactions, NewRPCPendingTransaction(tx))
 		}
 	}
 	return transactions, nil
@@ -1500,7 +1770,7 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 	for _, p := range pending {
 		var signer types.Signer = types.HomesteadSigner{}
 		if p.Protected() {
-			signer = types.NewEIP155Signer(p.ChainId())
+			signer = types.NewEIP2930Signer(p.ChainId())
 		}
 		wantSigHash := signer.Hash(matchTx)
 
@@ -1583,31 +1853,18 @@ func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
 
 // ChaindbProperty returns leveldb properties of the chain database.
 func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return "", fmt.Errorf("chaindbProperty does not work for memory databases")
-	}
 	if property == "" {
 		property = "leveldb.stats"
 	} else if !strings.HasPrefix(property, "leveldb.") {
 		property = "leveldb." + property
 	}
-	return ldb.LDB().GetProperty(property)
+	return api.b.ChainDb().Stat(property)
 }
 
 func (api *PrivateDebugAPI) ChaindbCompact() error {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return fmt.Errorf("chaindbCompact does not work for memory databases")
-	}
 	for b := byte(0); b < 255; b++ {
 		log.Info("Compacting chain database", "range", fmt.Sprintf("0x%0.2X-0x%0.2X", b, b+1))
-		err := ldb.LDB().CompactRange(util.Range{Start: []byte{b}, Limit: []byte{b + 1}})
-		if err != nil {
+		if err := api.b.ChainDb().Compact([]byte{b}, []byte{b + 1}); err != nil {
 			log.Error("Database compaction failed", "err", err)
 			return err
 		}
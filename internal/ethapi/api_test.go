@@ -0,0 +1,112 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/common/hexutil"
+	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/ethdb"
+)
+
+func TestStateOverrideApply(t *testing.T) {
+	var (
+		addr   = common.HexToAddress("0x0102")
+		other  = common.HexToAddress("0x0304")
+		number = big.NewInt(10)
+		slot   = common.HexToHash("0x01")
+		kept   = common.HexToHash("0x02")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.SetBalance(other, big.NewInt(5), number)
+	statedb.SetState(addr, kept, common.HexToHash("0xff"))
+
+	var (
+		nonce   = hexutil.Uint64(3)
+		code    = hexutil.Bytes{0x60, 0x00}
+		balance = (*hexutil.Big)(big.NewInt(1e18))
+		power   = (*hexutil.Big)(big.NewInt(42))
+	)
+	overrides := &StateOverride{
+		addr: {
+			Nonce:     &nonce,
+			Code:      &code,
+			Balance:   balance,
+			Power:     power,
+			StateDiff: map[common.Hash]common.Hash{slot: common.HexToHash("0xaa")},
+		},
+	}
+	if err := overrides.Apply(statedb, number); err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+	if have := statedb.GetNonce(addr); have != 3 {
+		t.Errorf("nonce mismatch: have %d, want 3", have)
+	}
+	if have := statedb.GetCode(addr); !bytes.Equal(have, code) {
+		t.Errorf("code mismatch: have %x, want %x", have, code)
+	}
+	if have := statedb.GetBalance(addr); have.Cmp(balance.ToInt()) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", have, balance)
+	}
+	if have := statedb.GetPower(addr, number); have.Cmp(power.ToInt()) != 0 {
+		t.Errorf("power mismatch: have %v, want %v", have, power)
+	}
+	if have := statedb.GetState(addr, slot); have != common.HexToHash("0xaa") {
+		t.Errorf("overridden slot mismatch: have %x", have)
+	}
+	if have := statedb.GetState(addr, kept); have != common.HexToHash("0xff") {
+		t.Errorf("untouched slot mismatch: have %x", have)
+	}
+	if have := statedb.GetBalance(other); have.Cmp(big.NewInt(5)) != 0 {
+		t.Errorf("untouched account balance mismatch: have %v, want 5", have)
+	}
+	// A nil override set must leave the state alone.
+	var none *StateOverride
+	if err := none.Apply(statedb, number); err != nil {
+		t.Fatalf("failed to apply nil overrides: %v", err)
+	}
+}
+
+func TestTopUpPower(t *testing.T) {
+	var (
+		poor   = common.HexToAddress("0x0102")
+		rich   = common.HexToAddress("0x0304")
+		number = big.NewInt(10)
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.SetBalance(rich, big.NewInt(1e18), number)
+	statedb.SetPowerAndBlock(rich, big.NewInt(1e9), number)
+
+	// A sender lacking power gets just enough to pay for the gas
+	TopUpPower(statedb, types.NewMessage(poor, nil, 0, new(big.Int), 1000, big.NewInt(3), nil, nil, false), number)
+	if power := statedb.GetPower(poor, number); power.Cmp(big.NewInt(3000)) != 0 {
+		t.Errorf("topped up power mismatch: have %v, want 3000", power)
+	}
+	if balance := statedb.GetBalance(poor); balance.Cmp(big.NewInt(1e18)) != 0 {
+		t.Errorf("topped up balance mismatch: have %v, want %v", balance, big.NewInt(1e18))
+	}
+	// A sender able to pay keeps its power
+	TopUpPower(statedb, types.NewMessage(rich, nil, 0, new(big.Int), 1000, big.NewInt(3), nil, nil, false), number)
+	if power := statedb.GetPower(rich, number); power.Cmp(big.NewInt(1e9)) != 0 {
+		t.Errorf("sufficient power changed: have %v, want %v", power, big.NewInt(1e9))
+	}
+}
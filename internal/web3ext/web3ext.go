@@ -174,6 +174,12 @@ web3._extend({
          call: 'admin_importChain',
          params: 1
       }),
+      new web3._extend.Method({
+         name: 'rebuildTokenIndex',
+         call: 'admin_rebuildTokenIndex',
+         params: 1,
+         inputFormatter: [web3._extend.utils.fromDecimal]
+      }),
       new web3._extend.Method({
          name: 'sleepBlocks',
          call: 'admin_sleepBlocks',
@@ -403,6 +409,12 @@ web3._extend({
          params: 2,
          inputFormatter: [null, null]
       }),
+      new web3._extend.Method({
+         name: 'traceCall',
+         call: 'debug_traceCall',
+         params: 3,
+         inputFormatter: [web3._extend.formatters.inputCallFormatter, null, null]
+      }),
       new web3._extend.Method({
          name: 'preimage',
          call: 'debug_preimage',
@@ -488,6 +500,64 @@ web3._extend({
          params: 3,
          inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
       }),
+      new web3._extend.Method({
+         name: 'estimateFee',
+         call: 'eth_estimateFee',
+         params: 2,
+         inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+      }),
+      new web3._extend.Method({
+         name: 'getPowerHistory',
+         call: 'eth_getPowerHistory',
+         params: 3,
+         inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+      }),
+      new web3._extend.Method({
+         name: 'getTransactionsByAddress',
+         call: 'eth_getTransactionsByAddress',
+         params: 2,
+         inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+      }),
+      new web3._extend.Method({
+         name: 'getInternalTransfers',
+         call: 'eth_getInternalTransfers',
+         params: 1
+      }),
+      new web3._extend.Method({
+         name: 'getInternalTransfersByBlock',
+         call: 'eth_getInternalTransfersByBlock',
+         params: 1,
+         inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+      }),
+      new web3._extend.Method({
+         name: 'getTokenTransfers',
+         call: 'eth_getTokenTransfers',
+         params: 1
+      }),
+      new web3._extend.Method({
+         name: 'getTokenBalanceHistory',
+         call: 'eth_getTokenBalanceHistory',
+         params: 3,
+         inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputAddressFormatter, null]
+      }),
+      new web3._extend.Method({
+         name: 'getSupply',
+         call: 'eth_getSupply',
+         params: 1,
+         inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+      }),
+      new web3._extend.Method({
+         name: 'getIssuance',
+         call: 'eth_getIssuance',
+         params: 2,
+         inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+      }),
+      new web3._extend.Method({
+         name: 'getLogsPage',
+         call: 'eth_getLogsPage',
+         params: 2,
+         inputFormatter: [null, null]
+      }),
    ],
    properties: [
       new web3._extend.Property({
@@ -60,6 +60,18 @@ func (b *LesApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNum
 	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
 		return b.eth.blockchain.CurrentHeader(), nil
 	}
+	if blockNr == rpc.FinalizedBlockNumber {
+		// Light clients don't track finality, derive it from the current head
+		config := b.eth.chainConfig.Circum
+		if config == nil || config.FinalityDepth == 0 {
+			return nil, nil
+		}
+		var number uint64
+		if head := b.eth.blockchain.CurrentHeader().Number.Uint64(); head > config.FinalityDepth {
+			number = head - config.FinalityDepth
+		}
+		return b.eth.blockchain.GetHeaderByNumberOdr(ctx, number)
+	}
 	return b.eth.blockchain.GetHeaderByNumberOdr(ctx, uint64(blockNr))
 }
 
@@ -175,6 +187,10 @@ func (b *LesApiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *LesApiBackend) SuggestPowerPrice(ctx context.Context, gas uint64, maxPower *big.Int) (*big.Int, error) {
+	return b.gpo.SuggestPowerPrice(ctx, gas, maxPower)
+}
+
 func (b *LesApiBackend) ChainDb() ethdb.Database {
 	return b.eth.chainDb
 }
@@ -34,6 +34,7 @@ import (
 	"github.com/ether-ark/etherark/eth/downloader"
 	"github.com/ether-ark/etherark/eth/filters"
 	"github.com/ether-ark/etherark/eth/gasprice"
+	"github.com/ether-ark/etherark/ethdb"
 	"github.com/ether-ark/etherark/event"
 	"github.com/ether-ark/etherark/internal/ethapi"
 	"github.com/ether-ark/etherark/light"
@@ -78,10 +79,14 @@ type LightEthereum struct {
 }
 
 func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
-	chainDb, err := eth.CreateDB(ctx, config, "lightchaindata")
+	// Light clients only keep headers, there is nothing to freeze
+	chainDb, err := ctx.OpenDatabase("lightchaindata", config.DatabaseCache, config.DatabaseHandles)
 	if err != nil {
 		return nil, err
 	}
+	if db, ok := chainDb.(*ethdb.LDBDatabase); ok {
+		db.Meter("eth/db/chaindata/")
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
 	if _, isCompat := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !isCompat {
 		return nil, genesisErr
@@ -186,6 +191,10 @@ func (s *LightDummyAPI) Mining() bool {
 // APIs returns the collection of RPC services the ethereum package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *LightEthereum) APIs() []rpc.API {
+	logsLimits := filters.Config{
+		BlockLimit:  s.config.RPCLogsBlockLimit,
+		ResultLimit: s.config.RPCLogsResultLimit,
+	}
 	return append(ethapi.GetAPIs(s.ApiBackend), []rpc.API{
 		{
 			Namespace: "eth",
@@ -200,7 +209,7 @@ func (s *LightEthereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true),
+			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true, logsLimits),
 			Public:    true,
 		}, {
 			Namespace: "net",
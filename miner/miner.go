@@ -157,6 +157,12 @@ func (self *Miner) HashRate() (tot int64) {
 	return
 }
 
+// SetOrdering sets the policy deciding the order in which pending transactions
+// are included in newly built blocks.
+func (self *Miner) SetOrdering(policy OrderingPolicy) {
+	self.worker.setOrdering(policy)
+}
+
 func (self *Miner) SetExtra(extra []byte) error {
 	if uint64(len(extra)) > params.MaximumExtraDataSize {
 		return fmt.Errorf("Extra exceeds max length. %d > %v", len(extra), params.MaximumExtraDataSize)
@@ -0,0 +1,316 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/params"
+)
+
+// Names of the supported transaction ordering policies.
+const (
+	PriceOrdering      = "price"      // highest gas price first (default)
+	GasShareOrdering   = "gasshare"   // highest gas price first, capped per account
+	MasternodeOrdering = "masternode" // masternode pings first, then by gas price
+	FairOrdering       = "fair"       // round-robin between accounts
+)
+
+// TransactionSet is an iterator over pending transactions that honours the
+// nonce order of every account. It is implemented by
+// types.TransactionsByPriceAndNonce and by every ordering policy below.
+type TransactionSet interface {
+	// Peek returns the next transaction to include, or nil if none are left.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one from the same account.
+	Shift()
+
+	// Pop removes the current transaction and all further ones from the same account.
+	Pop()
+}
+
+// OrderingPolicy decides the order in which pending transactions are offered
+// to the block being built.
+type OrderingPolicy interface {
+	// Order assembles an iterator over the per-account, nonce sorted transactions
+	// for a block with the given gas limit, built on top of statedb. The txs map
+	// is reowned by the policy.
+	Order(signer types.Signer, statedb *state.StateDB, txs map[common.Address]types.Transactions, gasLimit uint64) TransactionSet
+}
+
+// NewOrderingPolicy creates the ordering policy registered under name. The
+// gasShare is the percentage of the block gas limit a single account may use
+// and is only consulted by the gas share policy.
+func NewOrderingPolicy(name string, gasShare uint64) (OrderingPolicy, error) {
+	switch name {
+	case "", PriceOrdering:
+		return priceOrdering{}, nil
+	case GasShareOrdering:
+		if gasShare == 0 || gasShare > 100 {
+			return nil, fmt.Errorf("invalid per-account gas share %d%%, must be within 1-100", gasShare)
+		}
+		return gasShareOrdering{share: gasShare}, nil
+	case MasternodeOrdering:
+		return masternodeOrdering{}, nil
+	case FairOrdering:
+		return fairOrdering{}, nil
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering policy %q", name)
+	}
+}
+
+// priceOrdering orders transactions purely by gas price.
+type priceOrdering struct{}
+
+func (priceOrdering) Order(signer types.Signer, statedb *state.StateDB, txs map[common.Address]types.Transactions, gasLimit uint64) TransactionSet {
+	return types.NewTransactionsByPriceAndNonce(signer, txs)
+}
+
+// gasShareOrdering orders transactions by gas price, but stops including the
+// transactions of an account once they would claim more than share percent
+// of the block gas limit. Since gas is paid from regenerating power, price
+// alone would let well funded accounts fill entire blocks.
+//
+// The share is measured by the gas limits of the included transactions, not by
+// the gas they end up using: the limit is known before execution and is what a
+// transaction reserves from the block gas pool.
+type gasShareOrdering struct {
+	share uint64
+}
+
+func (p gasShareOrdering) Order(signer types.Signer, statedb *state.StateDB, txs map[common.Address]types.Transactions, gasLimit uint64) TransactionSet {
+	return &gasShareLimiter{
+		txs:    types.NewTransactionsByPriceAndNonce(signer, txs),
+		signer: signer,
+		limit:  gasLimit / 100 * p.share,
+		used:   make(map[common.Address]uint64),
+	}
+}
+
+// gasShareLimiter wraps a transaction set, dropping the remaining transactions
+// of any account whose included gas limits would exceed the per-account limit.
+type gasShareLimiter struct {
+	txs    TransactionSet
+	signer types.Signer
+	limit  uint64
+	used   map[common.Address]uint64
+}
+
+// Peek returns the next transaction whose sender is still within its share.
+func (l *gasShareLimiter) Peek() *types.Transaction {
+	for {
+		tx := l.txs.Peek()
+		if tx == nil {
+			return nil
+		}
+		from, _ := types.Sender(l.signer, tx)
+		if l.used[from]+tx.Gas() <= l.limit {
+			return tx
+		}
+		l.txs.Pop()
+	}
+}
+
+// Shift accounts the gas limit of the current transaction to its sender and
+// moves on to the sender's next transaction.
+func (l *gasShareLimiter) Shift() {
+	if tx := l.txs.Peek(); tx != nil {
+		from, _ := types.Sender(l.signer, tx)
+		l.used[from] += tx.Gas()
+	}
+	l.txs.Shift()
+}
+
+// Pop drops the current account.
+func (l *gasShareLimiter) Pop() {
+	l.txs.Pop()
+}
+
+// masternodeOrdering includes the pings of registered masternodes before any
+// other transaction, so masternodes stay online even when blocks are full.
+// Both groups are ordered by gas price.
+type masternodeOrdering struct{}
+
+func (masternodeOrdering) Order(signer types.Signer, statedb *state.StateDB, txs map[common.Address]types.Transactions, gasLimit uint64) TransactionSet {
+	var (
+		heads       = make(txsByPingAndPrice, 0, len(txs))
+		masternodes = make(map[common.Address]bool)
+	)
+	for from, accTxs := range txs {
+		acc, _ := types.Sender(signer, accTxs[0])
+		masternodes[acc] = isMasternode(statedb, acc)
+		heads = append(heads, pingTx{tx: accTxs[0], ping: masternodes[acc] && isPingTx(accTxs[0])})
+		txs[acc] = accTxs[1:]
+		if from != acc {
+			delete(txs, from)
+		}
+	}
+	heap.Init(&heads)
+
+	return &transactionsByPingAndPrice{
+		txs:         txs,
+		heads:       heads,
+		masternodes: masternodes,
+		signer:      signer,
+	}
+}
+
+// masternodeIdsSlot is the storage slot of the masternode contract's mapping
+// from node accounts to node ids.
+var masternodeIdsSlot = common.BigToHash(big.NewInt(4))
+
+// isMasternode reports whether addr is the account of a masternode registered
+// in the masternode contract.
+func isMasternode(statedb *state.StateDB, addr common.Address) bool {
+	key := crypto.Keccak256Hash(common.LeftPadBytes(addr[:], 32), masternodeIdsSlot[:])
+	return statedb.GetState(params.MasterndeContractAddress, key) != (common.Hash{})
+}
+
+// isPingTx reports whether tx is a ping, a plain call of the masternode
+// contract's fallback function.
+func isPingTx(tx *types.Transaction) bool {
+	return tx.To() != nil && *tx.To() == params.MasterndeContractAddress && len(tx.Data()) == 0 && tx.Value().Sign() == 0
+}
+
+// pingTx is a transaction along with whether it is the ping of a masternode.
+type pingTx struct {
+	tx   *types.Transaction
+	ping bool
+}
+
+// txsByPingAndPrice is a heap of transactions preferring masternode pings, then
+// higher gas prices.
+type txsByPingAndPrice []pingTx
+
+func (s txsByPingAndPrice) Len() int { return len(s) }
+func (s txsByPingAndPrice) Less(i, j int) bool {
+	if s[i].ping != s[j].ping {
+		return s[i].ping
+	}
+	return s[i].tx.GasPrice().Cmp(s[j].tx.GasPrice()) > 0
+}
+func (s txsByPingAndPrice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txsByPingAndPrice) Push(x interface{}) {
+	*s = append(*s, x.(pingTx))
+}
+
+func (s *txsByPingAndPrice) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+// transactionsByPingAndPrice is the masternode-first counterpart of
+// types.TransactionsByPriceAndNonce.
+type transactionsByPingAndPrice struct {
+	txs         map[common.Address]types.Transactions
+	heads       txsByPingAndPrice
+	masternodes map[common.Address]bool
+	signer      types.Signer
+}
+
+// Peek returns the next transaction by priority.
+func (t *transactionsByPingAndPrice) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0].tx
+}
+
+// Shift replaces the current best head with the next one from the same account.
+func (t *transactionsByPingAndPrice) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads[0].tx)
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads[0] = pingTx{tx: txs[0], ping: t.masternodes[acc] && isPingTx(txs[0])}
+		t.txs[acc] = txs[1:]
+		heap.Fix(&t.heads, 0)
+	} else {
+		heap.Pop(&t.heads)
+	}
+}
+
+// Pop removes the best transaction without replacing it from the same account.
+func (t *transactionsByPingAndPrice) Pop() {
+	heap.Pop(&t.heads)
+}
+
+// fairOrdering takes one transaction from every account in turn, so a single
+// account cannot crowd out the others regardless of the price it pays. The
+// initial turn order is decided by the gas price of the accounts' first
+// transaction, ties broken by address.
+type fairOrdering struct{}
+
+func (fairOrdering) Order(signer types.Signer, statedb *state.StateDB, txs map[common.Address]types.Transactions, gasLimit uint64) TransactionSet {
+	accounts := make([]common.Address, 0, len(txs))
+	for from, accTxs := range txs {
+		if len(accTxs) > 0 {
+			accounts = append(accounts, from)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if cmp := txs[accounts[i]][0].GasPrice().Cmp(txs[accounts[j]][0].GasPrice()); cmp != 0 {
+			return cmp > 0
+		}
+		return bytes.Compare(accounts[i][:], accounts[j][:]) < 0
+	})
+	queue := make([]types.Transactions, len(accounts))
+	for i, from := range accounts {
+		queue[i] = txs[from]
+	}
+	return &transactionsRoundRobin{queue: queue}
+}
+
+// transactionsRoundRobin is a queue of accounts, each holding its remaining
+// nonce sorted transactions. The account at the front is served once and then
+// moved to the back.
+type transactionsRoundRobin struct {
+	queue []types.Transactions
+}
+
+// Peek returns the next transaction of the account at the front of the queue.
+func (t *transactionsRoundRobin) Peek() *types.Transaction {
+	if len(t.queue) == 0 {
+		return nil
+	}
+	return t.queue[0][0]
+}
+
+// Shift moves the front account, minus its current transaction, to the back.
+func (t *transactionsRoundRobin) Shift() {
+	if rest := t.queue[0][1:]; len(rest) > 0 {
+		t.queue = append(t.queue[1:], rest)
+	} else {
+		t.queue = t.queue[1:]
+	}
+}
+
+// Pop removes the front account from the queue.
+func (t *transactionsRoundRobin) Pop() {
+	t.queue = t.queue[1:]
+}
@@ -0,0 +1,243 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ether-ark/etherark/common"
+	"github.com/ether-ark/etherark/core/state"
+	"github.com/ether-ark/etherark/core/types"
+	"github.com/ether-ark/etherark/crypto"
+	"github.com/ether-ark/etherark/ethdb"
+	"github.com/ether-ark/etherark/params"
+)
+
+// orderingAccount is a test account with a list of signed, nonce sorted transactions.
+type orderingAccount struct {
+	key  *ecdsa.PrivateKey
+	addr common.Address
+}
+
+func newOrderingAccounts(t *testing.T, n int) []orderingAccount {
+	accounts := make([]orderingAccount, n)
+	for i := range accounts {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		accounts[i] = orderingAccount{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+	}
+	return accounts
+}
+
+// signOrderingTx creates a signed transaction with the given parameters.
+func signOrderingTx(t *testing.T, signer types.Signer, key *ecdsa.PrivateKey, nonce uint64, to common.Address, gas uint64, price int64) *types.Transaction {
+	return signOrderingCall(t, signer, key, nonce, to, gas, price, nil)
+}
+
+// signOrderingCall creates a signed transaction calling to with the given input.
+func signOrderingCall(t *testing.T, signer types.Signer, key *ecdsa.PrivateKey, nonce uint64, to common.Address, gas uint64, price int64, data []byte) *types.Transaction {
+	tx, err := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(0), gas, big.NewInt(price), data), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+// newOrderingState creates an empty state with the given accounts registered
+// as masternodes in the masternode contract.
+func newOrderingState(t *testing.T, masternodes ...common.Address) *state.StateDB {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	for i, addr := range masternodes {
+		key := crypto.Keccak256Hash(common.LeftPadBytes(addr[:], 32), masternodeIdsSlot[:])
+		statedb.SetState(params.MasterndeContractAddress, key, common.BigToHash(big.NewInt(int64(i+1))))
+	}
+	return statedb
+}
+
+// drain iterates over a transaction set, shifting after every transaction.
+func drain(set TransactionSet) []*types.Transaction {
+	var txs []*types.Transaction
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		txs = append(txs, tx)
+		set.Shift()
+	}
+	return txs
+}
+
+func TestNewOrderingPolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		share uint64
+		fail  bool
+	}{
+		{"", 0, false},
+		{PriceOrdering, 0, false},
+		{GasShareOrdering, 10, false},
+		{GasShareOrdering, 0, true},
+		{GasShareOrdering, 101, true},
+		{MasternodeOrdering, 0, false},
+		{FairOrdering, 0, false},
+		{"random", 0, true},
+	}
+	for i, tt := range tests {
+		_, err := NewOrderingPolicy(tt.name, tt.share)
+		if (err != nil) != tt.fail {
+			t.Errorf("test %d (%q, %d): error mismatch: have %v, want failure %v", i, tt.name, tt.share, err, tt.fail)
+		}
+	}
+}
+
+// Tests that the gas share policy drops the transactions of an account once it
+// would exceed its share of the block gas limit.
+func TestGasShareOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	accounts := newOrderingAccounts(t, 2)
+	whale, other := accounts[0], accounts[1]
+
+	txs := make(map[common.Address]types.Transactions)
+	for i := 0; i < 5; i++ {
+		txs[whale.addr] = append(txs[whale.addr], signOrderingTx(t, signer, whale.key, uint64(i), other.addr, 21000, 100))
+	}
+	txs[other.addr] = types.Transactions{signOrderingTx(t, signer, other.key, 0, whale.addr, 21000, 1)}
+
+	policy, _ := NewOrderingPolicy(GasShareOrdering, 50)
+	included := drain(policy.Order(signer, newOrderingState(t), txs, 100000))
+
+	var whaleTxs, otherTxs int
+	for _, tx := range included {
+		if from, _ := types.Sender(signer, tx); from == whale.addr {
+			whaleTxs++
+		} else {
+			otherTxs++
+		}
+	}
+	// 50% of 100000 gas admits two 21000 gas transactions per account
+	if whaleTxs != 2 || otherTxs != 1 {
+		t.Fatalf("included transactions mismatch: have %d/%d, want 2/1", whaleTxs, otherTxs)
+	}
+	if from, _ := types.Sender(signer, included[len(included)-1]); from != other.addr {
+		t.Fatalf("cheap transaction not included after capped account")
+	}
+}
+
+// Tests that the gas share policy charges accounts by the gas limits of their
+// transactions rather than the gas the transactions would use.
+func TestGasShareOrderingByGasLimit(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	accounts := newOrderingAccounts(t, 2)
+	sender, recipient := accounts[0], accounts[1]
+
+	// Plain transfers using 21000 gas each, but reserving 30000
+	txs := make(map[common.Address]types.Transactions)
+	for i := 0; i < 3; i++ {
+		txs[sender.addr] = append(txs[sender.addr], signOrderingTx(t, signer, sender.key, uint64(i), recipient.addr, 30000, 1))
+	}
+	policy, _ := NewOrderingPolicy(GasShareOrdering, 50)
+	included := drain(policy.Order(signer, newOrderingState(t), txs, 100000))
+
+	// 50% of 100000 gas would admit two transactions using 21000 gas, but only
+	// one reserving 30000
+	if len(included) != 1 {
+		t.Fatalf("included transaction count mismatch: have %d, want 1", len(included))
+	}
+}
+
+// Tests that the masternode policy includes the pings of registered masternodes
+// first, regardless of their price, while keeping nonce order. Other calls of
+// the masternode contract and pings of unregistered accounts get no priority.
+func TestMasternodeOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	accounts := newOrderingAccounts(t, 5)
+	statedb := newOrderingState(t, accounts[1].addr, accounts[2].addr, accounts[4].addr)
+
+	txs := make(map[common.Address]types.Transactions)
+	txs[accounts[0].addr] = types.Transactions{signOrderingTx(t, signer, accounts[0].key, 0, accounts[1].addr, 21000, 100)}
+	txs[accounts[1].addr] = types.Transactions{
+		signOrderingTx(t, signer, accounts[1].key, 0, params.MasterndeContractAddress, 50000, 1),
+		signOrderingTx(t, signer, accounts[1].key, 1, accounts[0].addr, 21000, 1),
+	}
+	txs[accounts[2].addr] = types.Transactions{signOrderingTx(t, signer, accounts[2].key, 0, params.MasterndeContractAddress, 50000, 2)}
+	txs[accounts[3].addr] = types.Transactions{signOrderingTx(t, signer, accounts[3].key, 0, params.MasterndeContractAddress, 50000, 50)}
+	txs[accounts[4].addr] = types.Transactions{signOrderingCall(t, signer, accounts[4].key, 0, params.MasterndeContractAddress, 50000, 20, []byte{0x01})}
+
+	included := drain(masternodeOrdering{}.Order(signer, statedb, txs, 8000000))
+	if len(included) != 6 {
+		t.Fatalf("included transaction count mismatch: have %d, want 6", len(included))
+	}
+	for i, want := range []struct {
+		from common.Address
+		ping bool
+	}{
+		{accounts[2].addr, true},
+		{accounts[1].addr, true},
+		{accounts[0].addr, false},
+		{accounts[3].addr, false},
+		{accounts[4].addr, false},
+		{accounts[1].addr, false},
+	} {
+		from, _ := types.Sender(signer, included[i])
+		ping := isMasternode(statedb, from) && isPingTx(included[i])
+		if from != want.from || ping != want.ping {
+			t.Errorf("transaction %d: have sender %x (ping %v), want %x (ping %v)", i, from, ping, want.from, want.ping)
+		}
+	}
+}
+
+// Tests that the fair policy serves accounts in turn rather than draining the
+// best paying account first.
+func TestFairOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	accounts := newOrderingAccounts(t, 2)
+	rich, poor := accounts[0], accounts[1]
+
+	txs := make(map[common.Address]types.Transactions)
+	for i := 0; i < 3; i++ {
+		txs[rich.addr] = append(txs[rich.addr], signOrderingTx(t, signer, rich.key, uint64(i), poor.addr, 21000, 100))
+		txs[poor.addr] = append(txs[poor.addr], signOrderingTx(t, signer, poor.key, uint64(i), rich.addr, 21000, 1))
+	}
+	included := drain(fairOrdering{}.Order(signer, newOrderingState(t), txs, 8000000))
+	if len(included) != 6 {
+		t.Fatalf("included transaction count mismatch: have %d, want 6", len(included))
+	}
+	for i, tx := range included {
+		from, _ := types.Sender(signer, tx)
+		want := rich
+		if i%2 == 1 {
+			want = poor
+		}
+		if from != want.addr || tx.Nonce() != uint64(i/2) {
+			t.Errorf("transaction %d: have %x/%d, want %x/%d", i, from, tx.Nonce(), want.addr, i/2)
+		}
+	}
+	// Popping an account must skip all of its remaining transactions
+	txs = map[common.Address]types.Transactions{
+		rich.addr: {signOrderingTx(t, signer, rich.key, 0, poor.addr, 21000, 100), signOrderingTx(t, signer, rich.key, 1, poor.addr, 21000, 100)},
+		poor.addr: {signOrderingTx(t, signer, poor.key, 0, rich.addr, 21000, 1)},
+	}
+	set := fairOrdering{}.Order(signer, newOrderingState(t), txs, 8000000)
+	set.Pop()
+	if rest := drain(set); len(rest) != 1 {
+		t.Fatalf("remaining transaction count mismatch after pop: have %d, want 1", len(rest))
+	}
+}
@@ -115,6 +115,7 @@ type worker struct {
 	coinbase common.Address
 	coinbases map[string]common.Address
 	extra    []byte
+	ordering OrderingPolicy
 
 	currentMu sync.Mutex
 	current   *Work
@@ -150,6 +151,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		proc:           eth.BlockChain().Validator(),
 		possibleUncles: make(map[common.Hash]*types.Block),
 		coinbase:       coinbase,
+		ordering:       priceOrdering{},
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
 		quitCh:         make(chan struct{}, 1),
@@ -180,6 +182,12 @@ func (self *worker) setExtra(extra []byte) {
 	self.extra = extra
 }
 
+func (self *worker) setOrdering(policy OrderingPolicy) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.ordering = policy
+}
+
 func (self *worker) pending() (*types.Block, *state.StateDB) {
 	if atomic.LoadInt32(&self.mining) == 0 {
 		// return a snapshot to avoid contention on currentMu mutex
@@ -346,13 +354,17 @@ func (self *worker) update() {
 			// already included in the current mining block. These transactions will
 			// be automatically eliminated.
 			if atomic.LoadInt32(&self.mining) == 0 {
+				self.mu.Lock()
+				ordering := self.ordering
+				self.mu.Unlock()
+
 				self.currentMu.Lock()
 				txs := make(map[common.Address]types.Transactions)
 				for _, tx := range ev.Txs {
 					acc, _ := types.Sender(self.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(self.current.signer, txs)
+				txset := ordering.Order(self.current.signer, self.current.state, txs, self.current.header.GasLimit)
 				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase)
 				self.updateSnapshot()
 				self.currentMu.Unlock()
@@ -437,7 +449,7 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 	}
 	work := &Work{
 		config:    self.config,
-		signer:    types.NewEIP155Signer(self.config.ChainID),
+		signer:    types.MakeSigner(self.config, header.Number),
 		state:     state,
 		ancestors: set.New(),
 		family:    set.New(),
@@ -504,7 +516,7 @@ func (self *worker) commitNewWork(witnessTime int64) (*Work, error) {
 		return nil, fmt.Errorf("got error when fetch pending transactions, err: %s", err)
 	}
 
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
+	txs := self.ordering.Order(self.current.signer, work.state, pending, header.GasLimit)
 	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
 
 	// compute uncles for the new block.
@@ -555,7 +567,7 @@ func (self *worker) updateSnapshot() {
 	self.snapshotState = self.current.state.Copy()
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
+func (env *Work) commitTransactions(mux *event.TypeMux, txs TransactionSet, bc *core.BlockChain, coinbase common.Address) {
 	if env.gasPool == nil {
 		env.gasPool = new(core.GasPool).AddGas(env.header.GasLimit)
 	}
@@ -68,6 +68,11 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DBEngine is the key-value store backing the databases, leveldb or bolt. If
+	// empty, existing databases keep their engine and new ones use LevelDB. A
+	// database is never opened with an engine different from the one creating it.
+	DBEngine string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -151,6 +156,53 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// RPCAuthTokens maps the static bearer tokens accepted by the HTTP and websocket
+	// RPC servers to the namespaces and methods they may call. If any token or a
+	// JWT secret is configured, requests to these servers must carry a valid token
+	// in their Authorization header. The GraphQL endpoint requires them too, and
+	// the "graphql" namespace to be permitted.
+	RPCAuthTokens map[string]rpc.Permissions `toml:",omitempty"`
+
+	// RPCAuthJWTSecret is the path of the file holding the hex encoded secret of the
+	// HS256 signed JWTs accepted as bearer tokens. The "namespaces" and "methods"
+	// claims of the tokens list what they may call, their "exp" claim is required.
+	RPCAuthJWTSecret string `toml:",omitempty"`
+
+	// RPCRateLimit is the number of requests per second each client of the HTTP,
+	// websocket and GraphQL servers may issue, clients being told apart by their
+	// credential or IP address. Zero disables rate limiting.
+	RPCRateLimit float64 `toml:",omitempty"`
+
+	// RPCRateBurst is the number of requests a client may issue at once, regardless
+	// of its rate. It's raised to the rate limit if lower.
+	RPCRateBurst int `toml:",omitempty"`
+
+	// RPCBatchLimit is the maximum number of requests in a batch served by the HTTP
+	// and websocket RPC servers. Zero means unlimited.
+	RPCBatchLimit int `toml:",omitempty"`
+
+	// RPCResponseLimit is the maximum size in bytes of the responses to a request
+	// or batch served by the HTTP and websocket RPC servers. Zero means unlimited.
+	RPCResponseLimit int `toml:",omitempty"`
+
+	// GraphQLHost is the host interface on which to start the GraphQL server. If this
+	// field is empty, no GraphQL API endpoint will be started.
+	GraphQLHost string `toml:",omitempty"`
+
+	// GraphQLPort is the TCP port number on which to start the GraphQL server. The
+	// default zero value is/ valid and will pick a port number randomly (useful
+	// for ephemeral nodes).
+	GraphQLPort int `toml:",omitempty"`
+
+	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
+	// clients. Please be aware that CORS is a browser enforced security, it's fully
+	// useless for custom HTTP clients.
+	GraphQLCors []string `toml:",omitempty"`
+
+	// GraphQLVirtualHosts is the list of virtual hostnames which are allowed on incoming requests.
+	// This is by default {'localhost'}.
+	GraphQLVirtualHosts []string `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -234,6 +286,50 @@ func DefaultWSEndpoint() string {
 	return config.WSEndpoint()
 }
 
+// rpcPolicy assembles the access control and resource limits enforced on the
+// clients of the HTTP and websocket RPC servers, and of the endpoints of the
+// services, such as GraphQL.
+func (c *Config) rpcPolicy() (rpc.Policy, error) {
+	policy := rpc.Policy{
+		RateLimit:     c.RPCRateLimit,
+		RateBurst:     c.RPCRateBurst,
+		BatchLimit:    c.RPCBatchLimit,
+		ResponseLimit: c.RPCResponseLimit,
+	}
+	var auths []rpc.Authenticator
+	if len(c.RPCAuthTokens) > 0 {
+		auths = append(auths, rpc.NewTokenAuthenticator(c.RPCAuthTokens))
+	}
+	if c.RPCAuthJWTSecret != "" {
+		blob, err := ioutil.ReadFile(c.RPCAuthJWTSecret)
+		if err != nil {
+			return rpc.Policy{}, fmt.Errorf("failed to read JWT secret: %v", err)
+		}
+		secret := common.FromHex(strings.TrimSpace(string(blob)))
+		if len(secret) < 32 {
+			return rpc.Policy{}, fmt.Errorf("invalid JWT secret: want at least 32 hex encoded bytes, have %d", len(secret))
+		}
+		auths = append(auths, rpc.NewJWTAuthenticator(secret))
+	}
+	switch len(auths) {
+	case 0:
+	case 1:
+		policy.Auth = auths[0]
+	default:
+		policy.Auth = rpc.NewMultiAuthenticator(auths...)
+	}
+	return policy, nil
+}
+
+// GraphQLEndpoint resolves a GraphQL endpoint based on the configured host interface
+// and port parameters.
+func (c *Config) GraphQLEndpoint() string {
+	if c.GraphQLHost == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.GraphQLHost, c.GraphQLPort)
+}
+
 // NodeName returns the devp2p node identifier.
 func (c *Config) NodeName() string {
 	name := c.name()
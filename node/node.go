@@ -27,6 +27,7 @@ import (
 	"sync"
 
 	"github.com/ether-ark/etherark/accounts"
+	"github.com/ether-ark/etherark/core/rawdb"
 	"github.com/ether-ark/etherark/ethdb"
 	"github.com/ether-ark/etherark/event"
 	"github.com/ether-ark/etherark/internal/debug"
@@ -52,6 +53,7 @@ type Node struct {
 	services     map[reflect.Type]Service // Currently running services
 
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
+	rpcPolicy     rpc.Policy  // Access control and limits of the HTTP and websocket endpoints
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
 	ipcEndpoint string       // IPC endpoint to listen at (empty = IPC disabled)
@@ -250,6 +252,13 @@ func (n *Node) openDataDir() error {
 // startup. It's not meant to be called at any time afterwards as it makes certain
 // assumptions about the state of the node.
 func (n *Node) startRPC(services map[reflect.Type]Service) error {
+	// Assemble the policy enforced on the remote clients
+	policy, err := n.config.rpcPolicy()
+	if err != nil {
+		return err
+	}
+	n.rpcPolicy = policy
+
 	// Gather all the possible APIs to surface
 	apis := n.apis()
 	for _, service := range services {
@@ -336,7 +345,7 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts)
+	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts, n.rpcPolicy)
 	if err != nil {
 		return err
 	}
@@ -369,7 +378,7 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll)
+	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll, n.rpcPolicy)
 	if err != nil {
 		return err
 	}
@@ -582,7 +591,47 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (ethdb.Database, er
 	if n.config.DataDir == "" {
 		return ethdb.NewMemDatabase(), nil
 	}
-	return ethdb.NewLDBDatabase(n.config.ResolvePath(name), cache, handles)
+	return ethdb.NewDatabase(n.config.DBEngine, n.config.ResolvePath(name), cache, handles)
+}
+
+// OpenDatabaseWithFreezer opens an existing database with the given name (or
+// creates one if no previous can be found) from within the node's data directory,
+// also attaching a chain freezer to it that moves ancient chain data from the
+// database to immutable append-only files. If the node is an ephemeral one, a
+// memory database is returned.
+func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer, namespace string) (ethdb.Database, error) {
+	return openDatabaseWithFreezer(n.config, name, cache, handles, freezer, namespace)
+}
+
+// openDatabaseWithFreezer opens a key-value database with a chain freezer at the
+// given path attached. An empty freezer path places the ancient chain data in
+// an "ancient" folder inside the database, a relative one is resolved against
+// the instance directory.
+func openDatabaseWithFreezer(config *Config, name string, cache, handles int, freezer, namespace string) (ethdb.Database, error) {
+	if config.DataDir == "" {
+		return ethdb.NewMemDatabase(), nil
+	}
+	root := config.ResolvePath(name)
+
+	switch {
+	case freezer == "":
+		freezer = filepath.Join(root, "ancient")
+	case !filepath.IsAbs(freezer):
+		freezer = config.ResolvePath(freezer)
+	}
+	kvdb, err := ethdb.NewDatabase(config.DBEngine, root, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	if ldb, ok := kvdb.(*ethdb.LDBDatabase); ok && namespace != "" {
+		ldb.Meter(namespace)
+	}
+	db, err := rawdb.NewDatabaseWithFreezer(kvdb, freezer, namespace)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
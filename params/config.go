@@ -163,16 +163,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0),nil, nil, nil, new(EthashConfig), nil, nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0),nil, big.NewInt(0), nil, nil, big.NewInt(0), big.NewInt(0), new(EthashConfig), nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0),nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, &CircumConfig{Period: 3}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0),nil, big.NewInt(0), nil, nil, big.NewInt(0), big.NewInt(0), nil, &CliqueConfig{Period: 0, Epoch: 30000}, &CircumConfig{Period: 3}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig),nil,nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(0), big.NewInt(0), new(EthashConfig),nil,nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -211,8 +211,11 @@ type ChainConfig struct {
 	ByzantiumBlock      *big.Int `json:"byzantiumBlock,omitempty"`      // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 	ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` // Constantinople switch block (nil = no fork, 0 = already activated)
 	PetersburgBlock     *big.Int `json:"petersburgBlock,omitempty"`     // Petersburg switch block (nil = same as Constantinople)
+	IstanbulBlock       *big.Int `json:"istanbulBlock,omitempty"`       // Istanbul switch block (nil = no fork, 0 = already on istanbul)
 	EWASMBlock          *big.Int `json:"ewasmBlock,omitempty"`          // EWASM switch block (nil = no fork, 0 = already activated)
 	CircumBlock    *big.Int `json:"circumBlock,omitempty"`    // Circum switch block (nil = no fork, 0 = already on byzantium)
+	BLSBlock       *big.Int `json:"blsBlock,omitempty"`       // BLS precompiles switch block (nil = no fork, 0 = already activated)
+	TypedTxBlock   *big.Int `json:"typedTxBlock,omitempty"`   // Typed transactions and access lists switch block (nil = no fork, 0 = already activated)
 
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
@@ -241,8 +244,9 @@ func (c *CliqueConfig) String() string {
 
 // MasternodeConfig is the consensus engine configs for circum + delegated proof-of-stake based sealing.
 type CircumConfig struct {
-	Period    uint64   `json:"period"`    // Number of seconds between blocks to enforce
-	Witnesses []string `json:"witnesses"` // Genesis witness list
+	Period        uint64   `json:"period"`                  // Number of seconds between blocks to enforce
+	Witnesses     []string `json:"witnesses"`               // Genesis witness list
+	FinalityDepth uint64   `json:"finalityDepth,omitempty"` // Number of blocks after which a block can't be reorged (0 = unlimited reorgs)
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -317,6 +321,21 @@ func (c *ChainConfig) IsPetersburg(num *big.Int) bool {
 	return isForked(c.PetersburgBlock, num) || c.PetersburgBlock == nil && isForked(c.ConstantinopleBlock, num)
 }
 
+// IsIstanbul returns whether num is either equal to the Istanbul fork block or greater.
+func (c *ChainConfig) IsIstanbul(num *big.Int) bool {
+	return isForked(c.IstanbulBlock, num)
+}
+
+// IsBLS returns whether num is either equal to the BLS precompiles fork block or greater.
+func (c *ChainConfig) IsBLS(num *big.Int) bool {
+	return isForked(c.BLSBlock, num)
+}
+
+// IsTypedTx returns whether num is either equal to the typed transaction fork block or greater.
+func (c *ChainConfig) IsTypedTx(num *big.Int) bool {
+	return isForked(c.TypedTxBlock, num)
+}
+
 // IsEWASM returns whether num represents a block number after the EWASM fork
 func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
@@ -330,6 +349,8 @@ func (c *ChainConfig) GasTable(num *big.Int) GasTable {
 		return GasTableHomestead
 	}
 	switch {
+	case c.IsIstanbul(num):
+		return GasTableIstanbul
 	case c.IsConstantinople(num):
 		return GasTableConstantinople
 	case c.IsEIP158(num):
@@ -359,6 +380,39 @@ func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *Confi
 	return lasterr
 }
 
+// CheckConfigForkOrder checks that the forks which build on each other are not
+// scheduled out of order, since the rules of a later fork are only meaningful
+// on top of the ones of the earlier. The BLS precompiles are only available on
+// top of the Istanbul set.
+func (c *ChainConfig) CheckConfigForkOrder() error {
+	type fork struct {
+		name  string
+		block *big.Int
+	}
+	petersburg := c.PetersburgBlock
+	if petersburg == nil {
+		petersburg = c.ConstantinopleBlock // Petersburg is implied by Constantinople if unset
+	}
+	var last fork
+	for _, cur := range []fork{
+		{"constantinopleBlock", c.ConstantinopleBlock},
+		{"petersburgBlock", petersburg},
+		{"istanbulBlock", c.IstanbulBlock},
+		{"blsBlock", c.BLSBlock},
+	} {
+		if last.name != "" {
+			if last.block == nil && cur.block != nil {
+				return fmt.Errorf("unsupported fork ordering: %v not enabled, but %v enabled at %v", last.name, cur.name, cur.block)
+			}
+			if last.block != nil && cur.block != nil && last.block.Cmp(cur.block) > 0 {
+				return fmt.Errorf("unsupported fork ordering: %v enabled at %v, but %v enabled at %v", last.name, last.block, cur.name, cur.block)
+			}
+		}
+		last = cur
+	}
+	return nil
+}
+
 func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
 	if isForkIncompatible(c.HomesteadBlock, newcfg.HomesteadBlock, head) {
 		return newCompatError("Homestead fork block", c.HomesteadBlock, newcfg.HomesteadBlock)
@@ -393,6 +447,15 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.PetersburgBlock, newcfg.PetersburgBlock, head) {
 		return newCompatError("ConstantinopleFix fork block", c.PetersburgBlock, newcfg.PetersburgBlock)
 	}
+	if isForkIncompatible(c.IstanbulBlock, newcfg.IstanbulBlock, head) {
+		return newCompatError("Istanbul fork block", c.IstanbulBlock, newcfg.IstanbulBlock)
+	}
+	if isForkIncompatible(c.BLSBlock, newcfg.BLSBlock, head) {
+		return newCompatError("BLS fork block", c.BLSBlock, newcfg.BLSBlock)
+	}
+	if isForkIncompatible(c.TypedTxBlock, newcfg.TypedTxBlock, head) {
+		return newCompatError("typed transaction fork block", c.TypedTxBlock, newcfg.TypedTxBlock)
+	}
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
@@ -463,6 +526,7 @@ type Rules struct {
 	ChainID                                     *big.Int
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158 bool
 	IsByzantium, IsCircum, IsConstantinople,IsPetersburg   bool
+	IsIstanbul, IsBLS, IsTypedTx                bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -481,5 +545,8 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsCircum:         c.IsCircum(num),
 		IsConstantinople: c.IsConstantinople(num),
 		IsPetersburg:     c.IsPetersburg(num),
+		IsIstanbul:       c.IsIstanbul(num),
+		IsBLS:            c.IsBLS(num),
+		IsTypedTx:        c.IsTypedTx(num),
 	}
 }
@@ -79,3 +79,28 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckConfigForkOrder(t *testing.T) {
+	tests := []struct {
+		config  *ChainConfig
+		wantErr bool
+	}{
+		{config: AllEthashProtocolChanges},
+		{config: AllCliqueProtocolChanges},
+		{config: CircumChainConfig},
+		{config: &ChainConfig{ConstantinopleBlock: big.NewInt(10), IstanbulBlock: big.NewInt(10)}},
+		{config: &ChainConfig{ConstantinopleBlock: big.NewInt(10), PetersburgBlock: big.NewInt(20), IstanbulBlock: big.NewInt(30)}},
+		{config: &ChainConfig{ConstantinopleBlock: big.NewInt(10), PetersburgBlock: big.NewInt(5)}, wantErr: true},
+		{config: &ChainConfig{ConstantinopleBlock: big.NewInt(10), IstanbulBlock: big.NewInt(5)}, wantErr: true},
+		{config: &ChainConfig{ConstantinopleBlock: big.NewInt(10), PetersburgBlock: big.NewInt(20), IstanbulBlock: big.NewInt(15)}, wantErr: true},
+		{config: &ChainConfig{IstanbulBlock: big.NewInt(10)}, wantErr: true},
+		{config: &ChainConfig{ConstantinopleBlock: big.NewInt(0), IstanbulBlock: big.NewInt(10), BLSBlock: big.NewInt(20)}},
+		{config: &ChainConfig{ConstantinopleBlock: big.NewInt(0), IstanbulBlock: big.NewInt(10), BLSBlock: big.NewInt(5)}, wantErr: true},
+		{config: &ChainConfig{ConstantinopleBlock: big.NewInt(0), BLSBlock: big.NewInt(5)}, wantErr: true},
+	}
+	for i, test := range tests {
+		if err := test.config.CheckConfigForkOrder(); (err != nil) != test.wantErr {
+			t.Errorf("test %d: error mismatch: have %v, want error %v", i, err, test.wantErr)
+		}
+	}
+}
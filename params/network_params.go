@@ -33,6 +33,31 @@ const (
 	//BloomConfirms = 256
 	BloomConfirms = 256
 
+	// AddressIndexBlocks is the number of blocks a single address transaction
+	// index section covers. Blocks past the last complete section are scanned on
+	// demand.
+	AddressIndexBlocks uint64 = 64
+
+	// AddressIndexConfirms is the number of confirmation blocks before an address
+	// transaction index section is considered probably final and indexed.
+	AddressIndexConfirms = 16
+
+	// TokenIndexBlocks is the number of blocks a single token transfer index
+	// section covers. Blocks past the last complete section are scanned on demand.
+	TokenIndexBlocks uint64 = 64
+
+	// TokenIndexConfirms is the number of confirmation blocks before a token
+	// transfer index section is considered probably final and indexed.
+	TokenIndexConfirms = 16
+
+	// SupplyIndexBlocks is the number of blocks a single coin supply index section
+	// covers. The supply past the last complete section is derived on demand.
+	SupplyIndexBlocks uint64 = 64
+
+	// SupplyIndexConfirms is the number of confirmation blocks before a coin
+	// supply index section is considered probably final and indexed.
+	SupplyIndexConfirms = 16
+
 	// CHTFrequencyClient is the block frequency for creating CHTs on the client side.
 	CHTFrequencyClient = 32768
 
@@ -52,4 +77,9 @@ const (
 	// HelperTrieProcessConfirmations is the number of confirmations before a HelperTrie
 	// is generated
 	HelperTrieProcessConfirmations = 256
+
+	// ImmutabilityThreshold is the number of blocks after which a chain segment is
+	// considered immutable (i.e. soft finality). It is used by the chain freezer
+	// to decide which blocks may be moved out of the key-value store.
+	ImmutabilityThreshold = 90000
 )
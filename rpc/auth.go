@@ -0,0 +1,240 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	errMissingToken  = errors.New("missing bearer token")
+	errUnknownToken  = errors.New("unknown bearer token")
+	errMissingExpiry = errors.New("invalid JWT: missing exp claim")
+)
+
+// Permissions lists the namespaces and methods a credential may call. The
+// namespace "*" permits every method.
+type Permissions struct {
+	Namespaces []string `json:"namespaces" toml:",omitempty"` // namespaces whose methods may all be called
+	Methods    []string `json:"methods" toml:",omitempty"`    // methods which may be called, such as "eth_call"
+}
+
+// Permits returns whether the permissions allow calling the given method of
+// the given namespace.
+func (p *Permissions) Permits(namespace, method string) bool {
+	if p.PermitsNamespace(namespace) {
+		return true
+	}
+	name := namespace + serviceMethodSeparator + method
+	for _, allowed := range p.Methods {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PermitsNamespace returns whether the permissions allow calling every method
+// of the given namespace.
+func (p *Permissions) PermitsNamespace(namespace string) bool {
+	for _, allowed := range p.Namespaces {
+		if allowed == "*" || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Credential is the identity a request was authenticated as.
+type Credential struct {
+	ID string // identifies the credential to rate limit its requests, never the secret itself
+	Permissions
+}
+
+// Authenticator verifies the bearer tokens of requests.
+type Authenticator interface {
+	// Authenticate returns the credential identified by the token, or an
+	// error if the token isn't valid.
+	Authenticate(token string) (*Credential, error)
+}
+
+// tokenAuthenticator accepts a static set of bearer tokens.
+type tokenAuthenticator struct {
+	tokens map[string]Permissions
+}
+
+// NewTokenAuthenticator creates an authenticator accepting the given static
+// bearer tokens, each permitted to call the methods it maps to.
+func NewTokenAuthenticator(tokens map[string]Permissions) Authenticator {
+	return &tokenAuthenticator{tokens: tokens}
+}
+
+// Authenticate implements Authenticator, looking the token up in constant time
+// so the tokens can't be guessed by timing the rejections.
+func (a *tokenAuthenticator) Authenticate(token string) (*Credential, error) {
+	var (
+		found bool
+		perms Permissions
+	)
+	for known, p := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			found, perms = true, p
+		}
+	}
+	if !found {
+		return nil, errUnknownToken
+	}
+	return &Credential{ID: fmt.Sprintf("token-%x", sha256.Sum256([]byte(token)))[:14], Permissions: perms}, nil
+}
+
+// jwtAuthenticator accepts JWTs signed with a shared HMAC secret.
+type jwtAuthenticator struct {
+	secret []byte
+	parser *jwt.Parser
+}
+
+// NewJWTAuthenticator creates an authenticator accepting JWTs signed with
+// HS256 using the given secret. The "namespaces" and "methods" claims of the
+// tokens list what they may call, the "sub" claim identifies them for rate
+// limiting. Tokens must expire through their "exp" claim, the "iat" and "nbf"
+// claims are enforced when present.
+func NewJWTAuthenticator(secret []byte) Authenticator {
+	return &jwtAuthenticator{
+		secret: secret,
+		parser: &jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}},
+	}
+}
+
+// Authenticate implements Authenticator, verifying the signature and claims of
+// the token.
+func (a *jwtAuthenticator) Authenticate(token string) (*Credential, error) {
+	parsed, err := a.parser.Parse(token, func(*jwt.Token) (interface{}, error) { return a.secret, nil })
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT: %v", err)
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+	if _, ok := claims["exp"]; !ok {
+		return nil, errMissingExpiry
+	}
+
+	cred := &Credential{ID: fmt.Sprintf("jwt-%x", sha256.Sum256([]byte(token)))[:12]}
+	if sub, ok := claims["sub"].(string); ok && sub != "" {
+		cred.ID = "jwt-" + sub
+	}
+	if cred.Namespaces, err = stringsClaim(claims, "namespaces"); err != nil {
+		return nil, err
+	}
+	if cred.Methods, err = stringsClaim(claims, "methods"); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// stringsClaim retrieves a claim holding a list of strings, nil if unset.
+func stringsClaim(claims jwt.MapClaims, name string) ([]string, error) {
+	raw, ok := claims[name]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid JWT: %s claim is not a list", name)
+	}
+	strs := make([]string, len(list))
+	for i, item := range list {
+		if strs[i], ok = item.(string); !ok {
+			return nil, fmt.Errorf("invalid JWT: %s claim holds a non-string", name)
+		}
+	}
+	return strs, nil
+}
+
+// multiAuthenticator accepts the tokens accepted by any of its authenticators.
+type multiAuthenticator []Authenticator
+
+// NewMultiAuthenticator creates an authenticator accepting the tokens accepted
+// by any of the given authenticators, tried in order.
+func NewMultiAuthenticator(auths ...Authenticator) Authenticator {
+	return multiAuthenticator(auths)
+}
+
+// Authenticate implements Authenticator, returning the credential of the first
+// authenticator accepting the token, or the error of the last one otherwise.
+func (m multiAuthenticator) Authenticate(token string) (*Credential, error) {
+	err := errUnknownToken
+	for _, auth := range m {
+		var cred *Credential
+		if cred, err = auth.Authenticate(token); err == nil {
+			return cred, nil
+		}
+	}
+	return nil, err
+}
+
+// credentialKey is the context key of the credential of a request.
+type credentialKey struct{}
+
+// CredentialFromContext returns the credential the request was authenticated
+// with, if the server authenticates requests.
+func CredentialFromContext(ctx context.Context) (*Credential, bool) {
+	cred, ok := ctx.Value(credentialKey{}).(*Credential)
+	return cred, ok
+}
+
+// authenticate verifies the bearer token in the Authorization header of the
+// request against the authenticator of the policy, returning the context of
+// the request carrying the credential it was authenticated with. Requests are
+// passed through untouched if the policy doesn't authenticate them.
+func (p *Policy) authenticate(ctx context.Context, r *http.Request) (context.Context, Error) {
+	if p.Auth == nil {
+		return ctx, nil
+	}
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		rpcUnauthorizedMeter.Mark(1)
+		return ctx, &unauthorizedError{errMissingToken.Error()}
+	}
+	cred, err := p.Auth.Authenticate(strings.TrimSpace(header[7:]))
+	if err != nil {
+		rpcUnauthorizedMeter.Mark(1)
+		return ctx, &unauthorizedError{err.Error()}
+	}
+	return context.WithValue(ctx, credentialKey{}, cred), nil
+}
+
+// clientID identifies the client a request was issued by for rate limiting,
+// by its credential if it was authenticated or its IP address otherwise. An
+// empty ID is returned for the requests of local transports.
+func clientID(ctx context.Context) string {
+	if cred, ok := CredentialFromContext(ctx); ok {
+		return cred.ID
+	}
+	remote, _ := ctx.Value("remote").(string)
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
@@ -0,0 +1,321 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// testResponse is a decoded JSON-RPC response.
+type testResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int                    `json:"code"`
+		Data map[string]interface{} `json:"data"`
+	} `json:"error"`
+}
+
+// postRequest posts the given JSON-RPC request to the server, authenticated
+// with the token if not empty, returning the status and raw response.
+func postRequest(t *testing.T, url, token, body string) (int, []byte) {
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("content-type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	var blob json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&blob); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return res.StatusCode, blob
+}
+
+// checkError checks that the response failed with the given code and reason,
+// or succeeded if the code is zero.
+func checkError(t *testing.T, name string, blob []byte, code int, reason string) {
+	var res testResponse
+	if err := json.Unmarshal(blob, &res); err != nil {
+		t.Fatalf("%s: failed to decode response %s: %v", name, blob, err)
+	}
+	switch {
+	case code == 0 && res.Error != nil:
+		t.Errorf("%s: unexpected error %d: %s", name, res.Error.Code, blob)
+	case code == 0:
+	case res.Error == nil:
+		t.Errorf("%s: expected error %d, got result %s", name, code, res.Result)
+	case res.Error.Code != code || res.Error.Data["reason"] != reason:
+		t.Errorf("%s: error mismatch: have %d/%v, want %d/%s", name, res.Error.Code, res.Error.Data["reason"], code, reason)
+	}
+}
+
+func newPolicyServer(policy Policy) *httptest.Server {
+	server := newTestServer("test", new(Service))
+	server.SetPolicy(policy)
+	return httptest.NewServer(server)
+}
+
+const (
+	echoRequest = `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["hello",1,{"S":"world"}]}`
+	retsRequest = `{"jsonrpc":"2.0","id":1,"method":"test_rets","params":[]}`
+)
+
+// Tests that static bearer tokens are required, and restrict the methods the
+// clients may call.
+func TestTokenAuthentication(t *testing.T) {
+	hs := newPolicyServer(Policy{Auth: NewTokenAuthenticator(map[string]Permissions{
+		"all-token":  {Namespaces: []string{"*"}},
+		"echo-token": {Methods: []string{"test_echo"}},
+	})})
+	defer hs.Close()
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+		code   int
+		reason string
+	}{
+		{"missing token", "", echoRequest, http.StatusUnauthorized, -32001, "unauthorized"},
+		{"unknown token", "bad-token", echoRequest, http.StatusUnauthorized, -32001, "unauthorized"},
+		{"permitted namespace", "all-token", retsRequest, http.StatusOK, 0, ""},
+		{"permitted method", "echo-token", echoRequest, http.StatusOK, 0, ""},
+		{"forbidden method", "echo-token", retsRequest, http.StatusOK, -32004, "forbidden"},
+	}
+	for _, tt := range tests {
+		status, blob := postRequest(t, hs.URL, tt.token, tt.body)
+		if status != tt.status {
+			t.Errorf("%s: status mismatch: have %d, want %d", tt.name, status, tt.status)
+		}
+		checkError(t, tt.name, blob, tt.code, tt.reason)
+	}
+}
+
+// Tests that JWTs are accepted only if signed with the secret and within their
+// validity, which they must declare, and restrict the methods to the ones listed in their claims.
+func TestJWTAuthentication(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	hs := newPolicyServer(Policy{Auth: NewJWTAuthenticator(secret)})
+	defer hs.Close()
+
+	sign := func(secret []byte, claims jwt.MapClaims) string {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		return token
+	}
+	var (
+		now     = time.Now().Unix()
+		valid   = sign(secret, jwt.MapClaims{"sub": "alice", "methods": []string{"test_echo"}, "exp": now + 60})
+		expired = sign(secret, jwt.MapClaims{"sub": "alice", "methods": []string{"test_echo"}, "exp": now - 60})
+		eternal = sign(secret, jwt.MapClaims{"sub": "alice", "methods": []string{"test_echo"}, "iat": now})
+		forged  = sign([]byte("fedcba9876543210fedcba9876543210"), jwt.MapClaims{"namespaces": []string{"*"}, "exp": now + 60})
+	)
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+		code   int
+		reason string
+	}{
+		{"valid token", valid, echoRequest, http.StatusOK, 0, ""},
+		{"forbidden method", valid, retsRequest, http.StatusOK, -32004, "forbidden"},
+		{"expired token", expired, echoRequest, http.StatusUnauthorized, -32001, "unauthorized"},
+		{"non-expiring token", eternal, echoRequest, http.StatusUnauthorized, -32001, "unauthorized"},
+		{"forged token", forged, echoRequest, http.StatusUnauthorized, -32001, "unauthorized"},
+	}
+	for _, tt := range tests {
+		status, blob := postRequest(t, hs.URL, tt.token, tt.body)
+		if status != tt.status {
+			t.Errorf("%s: status mismatch: have %d, want %d", tt.name, status, tt.status)
+		}
+		checkError(t, tt.name, blob, tt.code, tt.reason)
+	}
+}
+
+// Tests that clients are rejected once they exhaust their burst of requests.
+func TestRateLimit(t *testing.T) {
+	hs := newPolicyServer(Policy{RateLimit: 0.001, RateBurst: 2})
+	defer hs.Close()
+
+	for i := 0; i < 2; i++ {
+		_, blob := postRequest(t, hs.URL, "", echoRequest)
+		checkError(t, "within burst", blob, 0, "")
+	}
+	_, blob := postRequest(t, hs.URL, "", echoRequest)
+	checkError(t, "past burst", blob, -32005, "rateLimit")
+}
+
+// Tests that handlers serving a namespace outside of the RPC server are
+// authenticated and rate limited per the policy too.
+func TestPolicyHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	hs := httptest.NewServer(NewPolicyHandler(Policy{
+		Auth: NewTokenAuthenticator(map[string]Permissions{
+			"graphql-token": {Namespaces: []string{"graphql"}},
+			"echo-token":    {Methods: []string{"test_echo"}},
+		}),
+		RateLimit: 0.001,
+		RateBurst: 2,
+	}, "graphql", next))
+	defer hs.Close()
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"unknown token", "bad-token", http.StatusUnauthorized},
+		{"forbidden namespace", "echo-token", http.StatusForbidden},
+		{"permitted namespace", "graphql-token", http.StatusOK},
+		{"within burst", "graphql-token", http.StatusOK},
+		{"past burst", "graphql-token", http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, hs.URL, strings.NewReader("{}"))
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.name, err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: status mismatch: have %d, want %d", tt.name, res.StatusCode, tt.status)
+		}
+		if tt.status == http.StatusTooManyRequests && res.Header.Get("Retry-After") == "" {
+			t.Errorf("%s: missing Retry-After header", tt.name)
+		}
+	}
+}
+
+// Tests that batches larger than the limit are rejected as a whole.
+func TestBatchLimit(t *testing.T) {
+	hs := newPolicyServer(Policy{BatchLimit: 2})
+	defer hs.Close()
+
+	_, blob := postRequest(t, hs.URL, "", "["+echoRequest+","+echoRequest+"]")
+	var resps []json.RawMessage
+	if err := json.Unmarshal(blob, &resps); err != nil {
+		t.Fatalf("failed to decode batch response: %v", err)
+	}
+	for _, resp := range resps {
+		checkError(t, "within limit", resp, 0, "")
+	}
+	_, blob = postRequest(t, hs.URL, "", "["+echoRequest+","+echoRequest+","+echoRequest+"]")
+	if err := json.Unmarshal(blob, &resps); err != nil {
+		t.Fatalf("failed to decode batch response: %v", err)
+	}
+	if len(resps) != 3 {
+		t.Fatalf("response count mismatch: have %d, want 3", len(resps))
+	}
+	for _, resp := range resps {
+		checkError(t, "past limit", resp, -32005, "batchLimit")
+	}
+}
+
+// Tests that responses are replaced with errors once they take the responses
+// of a request or batch past the limit.
+func TestResponseLimit(t *testing.T) {
+	hs := newPolicyServer(Policy{ResponseLimit: 150})
+	defer hs.Close()
+
+	_, blob := postRequest(t, hs.URL, "", echoRequest)
+	checkError(t, "within limit", blob, 0, "")
+
+	_, blob = postRequest(t, hs.URL, "", "["+echoRequest+","+echoRequest+"]")
+	var resps []json.RawMessage
+	if err := json.Unmarshal(blob, &resps); err != nil {
+		t.Fatalf("failed to decode batch response: %v", err)
+	}
+	if len(resps) != 2 {
+		t.Fatalf("response count mismatch: have %d, want 2", len(resps))
+	}
+	checkError(t, "first in batch", resps[0], 0, "")
+	checkError(t, "second in batch", resps[1], -32005, "responseLimit")
+}
+
+// Tests that the token buckets refill at the rate limit, and that the time to
+// wait for them to do so is reported.
+func TestRateLimiter(t *testing.T) {
+	var (
+		limiter = newRateLimiter(2, 4)
+		now     = time.Now()
+	)
+	if _, ok := limiter.take("a", 4, now); !ok {
+		t.Fatalf("full burst rejected")
+	}
+	if wait, ok := limiter.take("a", 1, now); ok || wait != 500*time.Millisecond {
+		t.Fatalf("drained bucket mismatch: have %v/%v, want %v/false", wait, ok, 500*time.Millisecond)
+	}
+	if _, ok := limiter.take("b", 1, now); !ok {
+		t.Fatalf("independent client rejected")
+	}
+	if _, ok := limiter.take("a", 1, now.Add(500*time.Millisecond)); !ok {
+		t.Fatalf("refilled bucket rejected")
+	}
+	// Batches larger than the burst are admitted once the bucket is full
+	if _, ok := limiter.take("a", 10, now.Add(3*time.Second)); !ok {
+		t.Fatalf("oversized batch rejected from full bucket")
+	}
+}
+
+// Tests that the number of tracked clients stays bounded even if none of them
+// is idle, the least recently seen ones being dropped first.
+func TestRateLimiterEviction(t *testing.T) {
+	var (
+		limiter = newRateLimiter(1, 1)
+		now     = time.Now()
+	)
+	for i := 0; i < maxRateBuckets; i++ {
+		limiter.take(fmt.Sprintf("client-%d", i), 1, now)
+	}
+	// Touch the oldest client so the second oldest is evicted in its place
+	limiter.take("client-0", 1, now)
+	limiter.take("newcomer", 1, now)
+
+	if len(limiter.buckets) != maxRateBuckets || limiter.recency.Len() != maxRateBuckets {
+		t.Fatalf("bucket count mismatch: have %d/%d, want %d", len(limiter.buckets), limiter.recency.Len(), maxRateBuckets)
+	}
+	if _, ok := limiter.buckets["client-1"]; ok {
+		t.Errorf("least recently seen client not evicted")
+	}
+	for _, client := range []string{"client-0", "client-2", "newcomer"} {
+		if _, ok := limiter.buckets[client]; !ok {
+			t.Errorf("%s evicted", client)
+		}
+	}
+}
@@ -16,7 +16,10 @@
 
 package rpc
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // request is for an unknown service
 type methodNotFoundError struct {
@@ -64,3 +67,65 @@ type shutdownError struct{}
 func (e *shutdownError) ErrorCode() int { return -32000 }
 
 func (e *shutdownError) Error() string { return "server is shutting down" }
+
+// request carries no valid credential
+type unauthorizedError struct{ message string }
+
+func (e *unauthorizedError) ErrorCode() int { return -32001 }
+
+func (e *unauthorizedError) Error() string { return "unauthorized: " + e.message }
+
+func (e *unauthorizedError) ErrorData() interface{} {
+	return map[string]interface{}{"reason": "unauthorized"}
+}
+
+// credential of the request doesn't permit calling the method
+type forbiddenError struct {
+	service string
+	method  string
+}
+
+func (e *forbiddenError) ErrorCode() int { return -32004 }
+
+func (e *forbiddenError) Error() string {
+	return fmt.Sprintf("The method %s%s%s is not permitted", e.service, serviceMethodSeparator, e.method)
+}
+
+func (e *forbiddenError) ErrorData() interface{} {
+	return map[string]interface{}{"reason": "forbidden", "method": e.service + serviceMethodSeparator + e.method}
+}
+
+// client issued requests faster than permitted
+type rateLimitError struct{ retryAfter time.Duration }
+
+func (e *rateLimitError) ErrorCode() int { return -32005 }
+
+func (e *rateLimitError) Error() string { return "rate limit exceeded" }
+
+func (e *rateLimitError) ErrorData() interface{} {
+	return map[string]interface{}{"reason": "rateLimit", "retryAfter": e.retryAfter.Seconds()}
+}
+
+// batch holds more requests than permitted
+type batchLimitError struct{ size, limit int }
+
+func (e *batchLimitError) ErrorCode() int { return -32005 }
+
+func (e *batchLimitError) Error() string {
+	return fmt.Sprintf("batch too large (%d>%d)", e.size, e.limit)
+}
+
+func (e *batchLimitError) ErrorData() interface{} {
+	return map[string]interface{}{"reason": "batchLimit", "size": e.size, "limit": e.limit}
+}
+
+// responses to a request or batch are larger than permitted
+type responseLimitError struct{ limit int }
+
+func (e *responseLimitError) ErrorCode() int { return -32005 }
+
+func (e *responseLimitError) Error() string { return "response too large" }
+
+func (e *responseLimitError) ErrorData() interface{} {
+	return map[string]interface{}{"reason": "responseLimit", "limit": e.limit}
+}
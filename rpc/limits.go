@@ -0,0 +1,221 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxRateBuckets is the number of clients tracked by the rate limiter past
+// which the buckets of the idle ones, or failing that of the least recently
+// seen ones, are dropped.
+const maxRateBuckets = 4096
+
+// Policy configures the access control and resource limits a server enforces
+// on the requests of its remote clients.
+type Policy struct {
+	Auth Authenticator // authenticates requests, nil to serve anyone
+
+	RateLimit     float64 // requests per second each client may issue, 0 for unlimited
+	RateBurst     int     // requests a client may issue at once, at least the rate limit
+	BatchLimit    int     // maximum number of requests in a batch, 0 for unlimited
+	ResponseLimit int     // maximum size in bytes of the responses to a request or batch, 0 for unlimited
+}
+
+// SetPolicy configures the server to enforce the given policy. It must be set
+// before the server starts serving requests.
+func (s *Server) SetPolicy(policy Policy) {
+	s.policy = policy
+	s.limiter = nil
+	if policy.RateLimit > 0 {
+		s.limiter = newRateLimiter(policy.RateLimit, policy.RateBurst)
+	}
+}
+
+// policyHandler enforces the authentication and rate limit of a policy on the
+// requests of an HTTP handler serving a namespace outside of the RPC server.
+type policyHandler struct {
+	policy    Policy
+	namespace string
+	limiter   *rateLimiter
+	next      http.Handler
+}
+
+// NewPolicyHandler wraps an HTTP handler serving the given namespace outside of
+// the RPC server, such as GraphQL, with the bearer token authentication and the
+// rate limit of the policy. Authenticated requests must be permitted to call
+// the whole namespace. The batch and response limits are left to the handler.
+func NewPolicyHandler(policy Policy, namespace string, next http.Handler) http.Handler {
+	h := &policyHandler{policy: policy, namespace: namespace, next: next}
+	if policy.RateLimit > 0 {
+		h.limiter = newRateLimiter(policy.RateLimit, policy.RateBurst)
+	}
+	return h
+}
+
+// ServeHTTP authenticates and rate limits the request, passing it on to the
+// wrapped handler with the credential in its context if admitted.
+func (h *policyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), "remote", r.RemoteAddr)
+	ctx, err := h.policy.authenticate(ctx, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if cred, ok := CredentialFromContext(ctx); ok && !cred.PermitsNamespace(h.namespace) {
+		rpcForbiddenMeter.Mark(1)
+		http.Error(w, fmt.Sprintf("the %s namespace is not permitted", h.namespace), http.StatusForbidden)
+		return
+	}
+	if h.limiter != nil {
+		if client := clientID(ctx); client != "" {
+			if wait, ok := h.limiter.take(client, 1, time.Now()); !ok {
+				rpcRateLimitMeter.Mark(1)
+				w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+		}
+	}
+	h.next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// admit checks the requests read from a client against the rate and batch
+// limits of the server, returning the error to reject them with if they
+// exceed either.
+func (s *Server) admit(ctx context.Context, reqs []*serverRequest, batch bool) Error {
+	if batch && s.policy.BatchLimit > 0 && len(reqs) > s.policy.BatchLimit {
+		rpcBatchLimitMeter.Mark(1)
+		return &batchLimitError{size: len(reqs), limit: s.policy.BatchLimit}
+	}
+	if s.limiter != nil {
+		if client := clientID(ctx); client != "" {
+			if wait, ok := s.limiter.take(client, len(reqs), time.Now()); !ok {
+				rpcRateLimitMeter.Mark(1)
+				return &rateLimitError{retryAfter: wait}
+			}
+		}
+	}
+	return nil
+}
+
+// limitResponse accounts the response written back for a request against the
+// response size limit of the server, given the size of the responses already
+// written for the same request or batch. The response is replaced with an
+// error if it takes them past the limit, in which case false is returned.
+func (s *Server) limitResponse(codec ServerCodec, id interface{}, response interface{}, used *int) (interface{}, bool) {
+	if s.policy.ResponseLimit <= 0 {
+		return response, true
+	}
+	blob, err := json.Marshal(response)
+	if err != nil {
+		return response, true // Leave it to the codec to report the failure
+	}
+	if *used+len(blob) > s.policy.ResponseLimit {
+		rpcResponseLimitMeter.Mark(1)
+		return createErrorResponse(codec, id, &responseLimitError{limit: s.policy.ResponseLimit}), false
+	}
+	*used += len(blob)
+	return json.RawMessage(blob), true
+}
+
+// rateBucket is the token bucket of a single client.
+type rateBucket struct {
+	client string        // client the bucket belongs to
+	tokens float64       // requests the client may issue
+	last   time.Time     // time the tokens were last refilled
+	elem   *list.Element // position of the bucket in the recency list
+}
+
+// rateLimiter limits the rate of requests of each client with a token bucket,
+// refilled at the rate limit up to the burst size.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	buckets map[string]*rateBucket
+	recency *list.List // buckets ordered from the most to the least recently used
+	lock    sync.Mutex
+}
+
+// newRateLimiter creates a rate limiter allowing each client the given number
+// of requests per second, and bursts of up to the given number of requests.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   math.Max(float64(burst), math.Max(rate, 1)),
+		buckets: make(map[string]*rateBucket),
+		recency: list.New(),
+	}
+}
+
+// take withdraws the given number of requests from the bucket of the client.
+// If there aren't enough of them, nothing is withdrawn and the time until
+// there will be is returned.
+func (l *rateLimiter) take(client string, n int, now time.Time) (time.Duration, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	bucket := l.buckets[client]
+	if bucket == nil {
+		if len(l.buckets) >= maxRateBuckets {
+			l.prune(now)
+		}
+		for len(l.buckets) >= maxRateBuckets {
+			l.drop(l.recency.Back().Value.(*rateBucket))
+		}
+		bucket = &rateBucket{client: client, tokens: l.burst, last: now}
+		bucket.elem = l.recency.PushFront(bucket)
+		l.buckets[client] = bucket
+	} else {
+		l.recency.MoveToFront(bucket.elem)
+	}
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed.Seconds()*l.rate)
+		bucket.last = now
+	}
+	// Batches larger than the burst size drain the bucket, the batch limit caps them
+	need := math.Min(float64(n), l.burst)
+	if bucket.tokens < need {
+		return time.Duration((need - bucket.tokens) / l.rate * float64(time.Second)), false
+	}
+	bucket.tokens -= need
+	return 0, true
+}
+
+// prune drops the buckets of the clients idle long enough for their buckets to
+// have filled up, as they are no different from new ones.
+func (l *rateLimiter) prune(now time.Time) {
+	for _, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			l.drop(bucket)
+		}
+	}
+}
+
+// drop forgets the bucket of a client.
+func (l *rateLimiter) drop(bucket *rateBucket) {
+	delete(l.buckets, bucket.client)
+	l.recency.Remove(bucket.elem)
+}
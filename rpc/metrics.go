@@ -0,0 +1,27 @@
+// Copyright 2019 The go-auc Authors
+// This file is part of the go-auc library.
+//
+// The go-auc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-auc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-auc library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import "github.com/ether-ark/etherark/metrics"
+
+var (
+	rpcUnauthorizedMeter  = metrics.NewRegisteredMeter("rpc/rejected/unauthorized", nil)  // Meter counting the requests lacking a valid credential
+	rpcForbiddenMeter     = metrics.NewRegisteredMeter("rpc/rejected/forbidden", nil)     // Meter counting the calls not permitted to their credential
+	rpcRateLimitMeter     = metrics.NewRegisteredMeter("rpc/rejected/ratelimit", nil)     // Meter counting the requests over the rate limit of their client
+	rpcBatchLimitMeter    = metrics.NewRegisteredMeter("rpc/rejected/batchlimit", nil)    // Meter counting the batches over the batch limit
+	rpcResponseLimitMeter = metrics.NewRegisteredMeter("rpc/rejected/responselimit", nil) // Meter counting the responses over the response limit
+)
@@ -156,8 +156,9 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 	s.codecsMu.Unlock()
 
 	// test if the server is ordered to stop
+	cred, _ := CredentialFromContext(ctx)
 	for atomic.LoadInt32(&s.run) == 1 {
-		reqs, batch, err := s.readRequest(codec)
+		reqs, batch, err := s.readRequest(codec, cred)
 		if err != nil {
 			// If a parsing error occurred, send an error
 			if err.Error() != "EOF" {
@@ -172,18 +173,17 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 		// check if server is ordered to shutdown and return an error
 		// telling the client that his request failed.
 		if atomic.LoadInt32(&s.run) != 1 {
-			err = &shutdownError{}
-			if batch {
-				resps := make([]interface{}, len(reqs))
-				for i, r := range reqs {
-					resps[i] = codec.CreateErrorResponse(&r.id, err)
-				}
-				codec.Write(resps)
-			} else {
-				codec.Write(codec.CreateErrorResponse(&reqs[0].id, err))
-			}
+			s.reject(codec, reqs, batch, &shutdownError{})
 			return nil
 		}
+		// reject the requests outright if they exceed the limits of the server
+		if err := s.admit(ctx, reqs, batch); err != nil {
+			s.reject(codec, reqs, batch, err)
+			if singleShot {
+				return nil
+			}
+			continue
+		}
 		// If a single shot request is executing, run and return immediately
 		if singleShot {
 			if batch {
@@ -208,6 +208,19 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 	return nil
 }
 
+// reject writes back the given error as the response to all the requests.
+func (s *Server) reject(codec ServerCodec, reqs []*serverRequest, batch bool, err Error) {
+	if batch {
+		resps := make([]interface{}, len(reqs))
+		for i, r := range reqs {
+			resps[i] = createErrorResponse(codec, &r.id, err)
+		}
+		codec.Write(resps)
+	} else {
+		codec.Write(createErrorResponse(codec, &reqs[0].id, err))
+	}
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes the
 // response back using the given codec. It will block until the codec is closed or the server is
 // stopped. In either case the codec is closed.
@@ -313,22 +326,39 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+			return createErrorResponse(codec, &req.id, e), nil
 		}
 	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
 
+// createErrorResponse creates the response of a callback failing with the given
+// error, keeping the code and data of errors carrying them.
+func createErrorResponse(codec ServerCodec, id interface{}, err error) interface{} {
+	rpcErr, ok := err.(Error)
+	if !ok {
+		rpcErr = &callbackError{err.Error()}
+	}
+	if de, ok := err.(DataError); ok {
+		return codec.CreateErrorResponseWithInfo(id, rpcErr, de.ErrorData())
+	}
+	return codec.CreateErrorResponse(id, rpcErr)
+}
+
 // exec executes the given request and writes the result back using the codec.
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
 	var response interface{}
 	var callback func()
 	if req.err != nil {
-		response = codec.CreateErrorResponse(&req.id, req.err)
+		response = createErrorResponse(codec, &req.id, req.err)
 	} else {
 		response, callback = s.handle(ctx, codec, req)
 	}
+	var used int
+	response, ok := s.limitResponse(codec, &req.id, response, &used)
+	if !ok {
+		callback = nil // the subscription is void if its ID wasn't delivered
+	}
 
 	if err := codec.Write(response); err != nil {
 		log.Error(fmt.Sprintf("%v\n", err))
@@ -345,15 +375,20 @@ func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest
 // It will only write the response back when the last request is processed.
 func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
 	responses := make([]interface{}, len(requests))
-	var callbacks []func()
+	var (
+		callbacks []func()
+		used      int
+	)
 	for i, req := range requests {
+		var callback func()
 		if req.err != nil {
-			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
+			responses[i] = createErrorResponse(codec, &req.id, req.err)
 		} else {
-			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
-				callbacks = append(callbacks, callback)
-			}
+			responses[i], callback = s.handle(ctx, codec, req)
+		}
+		var ok bool
+		if responses[i], ok = s.limitResponse(codec, &req.id, responses[i], &used); ok && callback != nil {
+			callbacks = append(callbacks, callback)
 		}
 	}
 
@@ -370,8 +405,9 @@ func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*s
 
 // readRequest requests the next (batch) request from the codec. It will return the collection
 // of requests, an indication if the request was a batch, the invalid request identifier and an
-// error when the request could not be read/parsed.
-func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error) {
+// error when the request could not be read/parsed. Requests calling methods not permitted to the
+// credential of the client, if authenticated, are flagged as erroneous.
+func (s *Server) readRequest(codec ServerCodec, cred *Credential) ([]*serverRequest, bool, Error) {
 	reqs, batch, err := codec.ReadRequestHeaders()
 	if err != nil {
 		return nil, batch, err
@@ -405,6 +441,17 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			continue
 		}
 
+		if method := r.method; cred != nil { // rpc method must be permitted to the client
+			if r.isPubSub {
+				method = strings.TrimPrefix(subscribeMethodSuffix, serviceMethodSeparator)
+			}
+			if !cred.Permits(r.service, method) {
+				rpcForbiddenMeter.Mark(1)
+				requests[i] = &serverRequest{id: r.id, err: &forbiddenError{r.service, method}}
+				continue
+			}
+		}
+
 		if r.isPubSub { // eth_subscribe, r.method contains the subscription method name
 			if callb, ok := svc.subscriptions[r.method]; ok {
 				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
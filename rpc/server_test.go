@@ -19,6 +19,7 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"reflect"
 	"testing"
@@ -160,3 +161,48 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+type limitError struct{ Limit int }
+
+func (e *limitError) Error() string          { return "limit exceeded" }
+func (e *limitError) ErrorCode() int         { return -32005 }
+func (e *limitError) ErrorData() interface{} { return e }
+
+type ErrorService struct{}
+
+func (s *ErrorService) Limited() (string, error) {
+	return "", &limitError{Limit: 10}
+}
+
+func (s *ErrorService) Failed() (string, error) {
+	return "", errors.New("failed")
+}
+
+func TestServerErrorResponse(t *testing.T) {
+	server := newTestServer("test", new(ErrorService))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	// Errors carrying a code and data should be passed on as they are
+	var result string
+	err := client.Call(&result, "test_limited")
+	jerr, ok := err.(*jsonError)
+	if !ok {
+		t.Fatalf("error type mismatch: have %T, want *jsonError", err)
+	}
+	if jerr.Code != -32005 || jerr.Message != "limit exceeded" {
+		t.Errorf("error mismatch: have %d %q, want -32005 %q", jerr.Code, jerr.Message, "limit exceeded")
+	}
+	if data, ok := jerr.ErrorData().(map[string]interface{}); !ok || data["Limit"] != float64(10) {
+		t.Errorf("error data mismatch: have %v", jerr.ErrorData())
+	}
+	// Plain errors should fall back to the generic callback error
+	err = client.Call(&result, "test_failed")
+	if jerr, ok = err.(*jsonError); !ok {
+		t.Fatalf("error type mismatch: have %T, want *jsonError", err)
+	}
+	if jerr.Code != -32000 || jerr.Data != nil {
+		t.Errorf("error mismatch: have %d %v, want -32000 without data", jerr.Code, jerr.Data)
+	}
+}
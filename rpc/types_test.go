@@ -18,8 +18,10 @@ package rpc
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
+	"github.com/ether-ark/etherark/common"
 	"github.com/ether-ark/etherark/common/math"
 )
 
@@ -46,6 +48,7 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		14: {`someString`, true, BlockNumber(0)},
 		15: {`""`, true, BlockNumber(0)},
 		16: {``, true, BlockNumber(0)},
+		17: {`"finalized"`, false, FinalizedBlockNumber},
 	}
 
 	for i, test := range tests {
@@ -64,3 +67,46 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockNumberOrHashJSONUnmarshal(t *testing.T) {
+	hash := common.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
+
+	tests := []struct {
+		input    string
+		mustFail bool
+		expected BlockNumberOrHash
+	}{
+		0: {`"0x12"`, false, BlockNumberOrHashWithNumber(18)},
+		1: {`"latest"`, false, BlockNumberOrHashWithNumber(LatestBlockNumber)},
+		2: {`"finalized"`, false, BlockNumberOrHashWithNumber(FinalizedBlockNumber)},
+		3: {`"` + hash.Hex() + `"`, false, BlockNumberOrHashWithHash(hash, false)},
+		4: {`{"blockNumber":"0x12"}`, false, BlockNumberOrHashWithNumber(18)},
+		5: {`{"blockHash":"` + hash.Hex() + `","requireCanonical":true}`, false, BlockNumberOrHashWithHash(hash, true)},
+		6: {`{"blockNumber":"0x12","blockHash":"` + hash.Hex() + `"}`, true, BlockNumberOrHash{}},
+		7: {`{}`, true, BlockNumberOrHash{}},
+		8: {`"0x` + strings.Repeat("z", 64) + `"`, true, BlockNumberOrHash{}},
+		9: {`someString`, true, BlockNumberOrHash{}},
+	}
+	for i, test := range tests {
+		var bnh BlockNumberOrHash
+		err := json.Unmarshal([]byte(test.input), &bnh)
+		if test.mustFail && err == nil {
+			t.Errorf("Test %d should fail", i)
+			continue
+		}
+		if !test.mustFail && err != nil {
+			t.Errorf("Test %d should pass but got err: %v", i, err)
+			continue
+		}
+		if test.mustFail {
+			continue
+		}
+		haveNum, haveNumOk := bnh.Number()
+		wantNum, wantNumOk := test.expected.Number()
+		haveHash, haveHashOk := bnh.Hash()
+		wantHash, wantHashOk := test.expected.Hash()
+		if haveNum != wantNum || haveNumOk != wantNumOk || haveHash != wantHash || haveHashOk != wantHashOk || bnh.RequireCanonical != test.expected.RequireCanonical {
+			t.Errorf("Test %d got unexpected value, want %+v, got %+v", i, test.expected, bnh)
+		}
+	}
+}
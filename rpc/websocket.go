@@ -69,7 +69,17 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			decoder := func(v interface{}) error {
 				return websocketJSONCodec.Receive(conn, v)
 			}
-			srv.ServeCodec(NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
+			codec := NewCodec(conn, encoder, decoder)
+			defer codec.Close()
+
+			// Authenticate the connection by the headers of its upgrade request
+			ctx := context.WithValue(context.Background(), "remote", conn.Request().RemoteAddr)
+			ctx, err := srv.policy.authenticate(ctx, conn.Request())
+			if err != nil {
+				codec.Write(createErrorResponse(codec, nil, err))
+				return
+			}
+			srv.serveRequest(ctx, codec, false, OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
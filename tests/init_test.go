@@ -41,6 +41,9 @@ var (
 	vmTestDir          = filepath.Join(baseDir, "VMTests")
 	rlpTestDir         = filepath.Join(baseDir, "RLPTests")
 	difficultyTestDir  = filepath.Join(baseDir, "BasicTests")
+
+	circumBaseDir      = filepath.Join(".", "circum-testdata")
+	circumStateTestDir = filepath.Join(circumBaseDir, "StateTests")
 )
 
 func readJSON(reader io.Reader, value interface{}) error {